@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+
+	"github.com/vadicheck/shorturl/internal/app"
+)
+
+// watchCompaction is a no-op on platforms without SIGUSR1.
+// The storage is still compacted automatically when its thresholds are reached.
+func watchCompaction(ctx context.Context, httpApp *app.App) {}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vadicheck/shorturl/internal/app"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// watchCompaction compacts the storage every time the process receives SIGUSR1,
+// until ctx is done. It lets an operator trigger a compaction with `kill -USR1 <pid>`.
+func watchCompaction(ctx context.Context, httpApp *app.App) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				slog.Info("storage compaction requested")
+
+				if err := httpApp.Compact(ctx); err != nil {
+					slog.Error("storage compaction failed", sl.Err(err))
+				} else {
+					slog.Info("storage compacted")
+				}
+			}
+		}
+	}()
+}
@@ -1,6 +1,6 @@
 // Main package of the application.
 //
-// The `main` package starts the HTTP server for the URL shortening service.
+// The `main` package starts the HTTP and gRPC servers for the URL shortening service.
 // It manages the application's lifecycle, handles system signals,
 // and ensures the server shuts down gracefully.
 package main
@@ -14,6 +14,8 @@ import (
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
 	"github.com/vadicheck/shorturl/internal/app"
 )
 
@@ -35,9 +37,11 @@ var (
 // Workflow:
 //  1. Create a context signal.NotifyContext.
 //  2. Initialize the HTTP application using `app.New(ctx)`.
-//  3. Start the server with `httpApp.Run()` and handle potential errors.
-//  4. Wait for system signals (`os.Interrupt`, `syscall.SIGTERM`).
-//  5. Shut down the server when a signal is received or the context is canceled.
+//  3. Start the HTTP and gRPC servers with `httpApp.Run()` and handle potential errors.
+//  4. Wait for system signals (`os.Interrupt`, `syscall.SIGTERM`); on unix, SIGUSR1
+//     compacts the file storage on demand.
+//  5. Shut down both servers when a signal is received or the context is canceled,
+//     then drain the deletion queue and close the storage so pending writes are flushed.
 //
 // If the application starts successfully, it logs `"app is ready"`.
 // When the server shuts down, it logs `"Server Exited Properly"`.
@@ -49,11 +53,13 @@ func main() {
 
 	httpApp := app.New(ctx)
 
-	httpServer, err := httpApp.Run()
+	httpServer, grpcServer, err := httpApp.Run()
 	if err != nil {
-		log.Panic(fmt.Errorf("http server can't start: %w", err))
+		log.Panic(fmt.Errorf("server can't start: %w", err))
 	}
 
+	watchCompaction(ctx, httpApp)
+
 	slog.Info("app is ready")
 
 	<-ctx.Done()
@@ -67,6 +73,31 @@ func main() {
 	} else {
 		slog.Info("server exited properly")
 	}
+
+	stopGRPC(shutdownCtx, grpcServer)
+
+	if err := httpApp.Shutdown(shutdownCtx); err != nil {
+		slog.Error("app shutdown error", "error", err)
+	}
+}
+
+// stopGRPC gracefully stops the gRPC server, waiting for in-flight RPCs
+// until ctx is done, after which the server is stopped forcibly.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		slog.Info("grpc server exited properly")
+	case <-ctx.Done():
+		server.Stop()
+		slog.Error("grpc server shutdown timed out")
+	}
 }
 
 func printBuildInfo() {
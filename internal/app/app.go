@@ -4,29 +4,68 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/vadicheck/shorturl/internal/config"
+	"github.com/vadicheck/shorturl/internal/grpc/interceptor"
+	grpcserver "github.com/vadicheck/shorturl/internal/grpc/server"
+	"github.com/vadicheck/shorturl/internal/handlers/account/login"
+	"github.com/vadicheck/shorturl/internal/handlers/account/register"
+	adminaudit "github.com/vadicheck/shorturl/internal/handlers/admin/audit"
+	disableurl "github.com/vadicheck/shorturl/internal/handlers/admin/disable"
+	purgeuser "github.com/vadicheck/shorturl/internal/handlers/admin/purge"
+	reassignuser "github.com/vadicheck/shorturl/internal/handlers/admin/reassign"
+	setrole "github.com/vadicheck/shorturl/internal/handlers/admin/role"
+	adminurls "github.com/vadicheck/shorturl/internal/handlers/admin/urls"
+	createkey "github.com/vadicheck/shorturl/internal/handlers/apikey/create"
+	listkeys "github.com/vadicheck/shorturl/internal/handlers/apikey/list"
+	revokekey "github.com/vadicheck/shorturl/internal/handlers/apikey/revoke"
 	"github.com/vadicheck/shorturl/internal/handlers/url/batch"
 	deleteurl "github.com/vadicheck/shorturl/internal/handlers/url/delete"
 	geturl "github.com/vadicheck/shorturl/internal/handlers/url/get"
+	"github.com/vadicheck/shorturl/internal/handlers/url/history"
 	"github.com/vadicheck/shorturl/internal/handlers/url/ping"
+	restoreurl "github.com/vadicheck/shorturl/internal/handlers/url/restore"
 	saveurl "github.com/vadicheck/shorturl/internal/handlers/url/save"
+	"github.com/vadicheck/shorturl/internal/handlers/url/servicestats"
 	"github.com/vadicheck/shorturl/internal/handlers/url/shorten"
+	"github.com/vadicheck/shorturl/internal/handlers/url/stats"
 	"github.com/vadicheck/shorturl/internal/handlers/url/urls"
+	createwebhook "github.com/vadicheck/shorturl/internal/handlers/webhook/create"
+	webhookdeadletters "github.com/vadicheck/shorturl/internal/handlers/webhook/deadletters"
+	deletewebhook "github.com/vadicheck/shorturl/internal/handlers/webhook/delete"
+	listwebhooks "github.com/vadicheck/shorturl/internal/handlers/webhook/list"
 	mwcookie "github.com/vadicheck/shorturl/internal/middleware/cookie"
 	"github.com/vadicheck/shorturl/internal/middleware/gzip"
 	middlewarelogger "github.com/vadicheck/shorturl/internal/middleware/logger"
+	"github.com/vadicheck/shorturl/internal/middleware/timeout"
+	"github.com/vadicheck/shorturl/internal/middleware/trusted"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+	"github.com/vadicheck/shorturl/internal/services/reaper"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 	"github.com/vadicheck/shorturl/internal/services/storage/postgres"
+	"github.com/vadicheck/shorturl/internal/services/token"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
 	"github.com/vadicheck/shorturl/internal/validator"
+	pb "github.com/vadicheck/shorturl/pkg/api/shortener"
 	"github.com/vadicheck/shorturl/pkg/logger/sl"
 )
 
@@ -34,17 +73,75 @@ const (
 	readTimeout  = 10
 	writeTimeout = 10
 	idleTimeout  = 15
+
+	deleteRetryBackoff    = 100 * time.Millisecond
+	deleteRequeueInterval = time.Minute
+	auditWebhookTimeout   = 5 * time.Second
 )
 
 // App represents the main entity for starting the application.
 type App struct {
-	router        *chi.Mux // Router is the HTTP request router used by the application.
-	serverAddress string   // The address of the server.
+	router            *chi.Mux              // Router is the HTTP request router used by the application.
+	serverAddress     string                // The address of the server.
+	grpcServer        *grpcserver.Server    // The gRPC implementation of the shortener API.
+	grpcServerAddress string                // The address of the gRPC server.
+	cookies           *keyring.Cookies      // The codec of the secure cookies identifying users.
+	tokens            *token.Manager        // The issuer of the bearer tokens identifying users.
+	storage           urlservice.URLStorage // The storage backend used by the services.
+	deleteQueue       *deletequeue.Queue    // The queue processing the deletion requests.
+	clicks            *analytics.Pipeline   // The pipeline writing the click events.
+	reaper            *reaper.Reaper        // The purger of expired URLs, nil if disabled.
+	auditLog          *audit.FileSink       // The file the link lifecycle events are written to.
+	dispatcher        *webhook.Dispatcher   // The deliverer of the link events to the user webhooks.
+}
+
+// compactor is implemented by storages that can compact their persistent state.
+type compactor interface {
+	Compact(ctx context.Context) error
+}
+
+// Compact compacts the storage on demand if the storage supports it.
+// It returns an error if the storage does not support compaction or the compaction fails.
+func (a *App) Compact(ctx context.Context) error {
+	c, ok := a.storage.(compactor)
+	if !ok {
+		return errors.New("storage does not support compaction")
+	}
+
+	return c.Compact(ctx)
 }
 
-// Run starts the HTTP server and listens for incoming requests.
-// It returns the server instance and any error encountered.
-func (a *App) Run() (*http.Server, error) {
+// Shutdown stops the purging of expired URLs, drains the deletion queue, saving what is
+// left when ctx is done, writes the buffered clicks, delivers the pending webhook events,
+// and then releases the storage if it holds resources, flushing any pending writes. The
+// webhook dispatcher and the audit log are closed after the queue and the clicks, as the
+// deletions drained from the queue and the written clicks still emit events to them.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errReaper error
+	if a.reaper != nil {
+		errReaper = a.reaper.Shutdown(ctx)
+	}
+
+	errQueue := a.deleteQueue.Shutdown(ctx)
+	errClicks := a.clicks.Shutdown(ctx)
+	errWebhooks := a.dispatcher.Shutdown(ctx)
+
+	var errStorage error
+	if c, ok := a.storage.(io.Closer); ok {
+		errStorage = c.Close()
+	}
+
+	return errors.Join(errReaper, errQueue, errClicks, errWebhooks, errStorage, a.auditLog.Close())
+}
+
+// Run starts the HTTP and gRPC servers and listens for incoming requests.
+// It returns both server instances and any error encountered.
+func (a *App) Run() (*http.Server, *grpc.Server, error) {
+	grpcServer, err := a.runGRPC()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	server := &http.Server{
 		Addr:         config.Config.ServerAddress,
 		Handler:      a.router,
@@ -74,6 +171,40 @@ func (a *App) Run() (*http.Server, error) {
 		}
 	}()
 
+	return server, grpcServer, nil
+}
+
+// runGRPC starts the gRPC server in the background.
+// It returns the server instance or an error if the listener cannot be created.
+func (a *App) runGRPC() (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", a.grpcServerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen grpc address: %w", err)
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptor.RequestID(), interceptor.Auth(a.cookies, a.tokens)),
+	}
+
+	if config.Config.EnableHTTPS {
+		creds, errCreds := credentials.NewServerTLSFromFile(config.Config.TLSCertPath, config.Config.TLSKeyPath)
+		if errCreds != nil {
+			return nil, fmt.Errorf("can't load grpc TLS credentials: %w", errCreds)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterShortenerServer(server, a.grpcServer)
+
+	slog.Info(fmt.Sprintf("gRPC server starting: %s", a.grpcServerAddress))
+
+	go func() {
+		if errServe := server.Serve(listener); errServe != nil && !errors.Is(errServe, grpc.ErrServerStopped) {
+			slog.Error("error starting grpc server", sl.Err(errServe))
+		}
+	}()
+
 	return server, nil
 }
 
@@ -86,42 +217,248 @@ func New(ctx context.Context) *App {
 	var storage urlservice.URLStorage
 
 	if config.Config.DatabaseDsn != "" {
-		storage, err = postgres.New(config.Config.DatabaseDsn)
+		storage, err = postgres.NewPool(ctx, config.Config.DatabaseDsn, postgres.PoolConfig{
+			MaxConns:               config.Config.DatabaseMaxConns,
+			MinConns:               config.Config.DatabaseMinConns,
+			MaxConnLifetime:        time.Duration(config.Config.DatabaseMaxConnLifetime) * time.Second,
+			StatementCacheCapacity: config.Config.DatabaseStatementCache,
+		})
 		if err != nil {
 			log.Panic(err)
 		}
 		slog.Info("Storage: postgres")
 	} else {
-		storage, err = memory.New(config.Config.FileStoragePath)
+		syncMode, errSync := memory.ParseSyncMode(config.Config.FileStorageSync)
+		if errSync != nil {
+			log.Panic(errSync)
+		}
+
+		storage, err = memory.New(
+			config.Config.FileStoragePath,
+			memory.WithCompaction(config.Config.FileStorageCompactRecords, config.Config.FileStorageCompactSize),
+			memory.WithSync(syncMode, time.Duration(config.Config.FileStorageSyncInterval)*time.Millisecond),
+		)
 		if err != nil {
 			log.Panic(err)
 		}
 		slog.Info("Storage: memory")
 	}
 
-	urlService := urlservice.New(storage)
+	auditLog, err := audit.NewFileSink(config.Config.AuditLogPath)
+	if err != nil {
+		log.Panic(err)
+	}
+	webhookStorage, ok := storage.(webhook.Storage)
+	if !ok {
+		log.Panic("the storage does not support webhooks")
+	}
+	webhooks := webhook.New(webhookStorage)
+	dispatcher := webhook.NewDispatcher(webhookStorage, webhook.Config{
+		Size:         config.Config.WebhookQueueSize,
+		Workers:      config.Config.WebhookWorkers,
+		MaxAttempts:  config.Config.WebhookMaxAttempts,
+		RetryBackoff: time.Duration(config.Config.WebhookRetryBackoff) * time.Millisecond,
+		MaxBackoff:   time.Duration(config.Config.WebhookMaxBackoff) * time.Millisecond,
+		Timeout:      time.Duration(config.Config.WebhookTimeout) * time.Millisecond,
+	})
+	dispatcher.Start()
+
+	sinks := audit.Multi{auditLog, dispatcher}
+	if config.Config.AuditWebhookURL != "" {
+		sinks = append(sinks, audit.NewWebhookSink(config.Config.AuditWebhookURL, auditWebhookTimeout))
+	}
+
+	urlService := urlservice.New(storage, urlservice.WithAudit(sinks))
 	shortenValidator := validator.New()
+	unlocker := unlock.New(config.Config.UnlockMaxFailures, time.Duration(config.Config.UnlockLockout)*time.Second)
+
+	trustedSubnet, err := trusted.ParseSubnet(config.Config.TrustedSubnet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ring, err := keyring.New(loadKeys())
+	if err != nil {
+		log.Panic(err)
+	}
+	cookies := keyring.NewCookies(ring)
+
+	tokens, err := token.New(token.Config{
+		Algorithm: config.Config.JwtAlgorithm,
+		TTL:       config.Config.JwtTokenExpire,
+	}, ring)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	apiKeyStorage, ok := storage.(apikey.Storage)
+	if !ok {
+		log.Panic("the storage does not support API keys")
+	}
+	apiKeys := apikey.New(apiKeyStorage)
+
+	accountStorage, ok := storage.(account.Storage)
+	if !ok {
+		log.Panic("the storage does not support accounts")
+	}
+	accounts := account.New(accountStorage,
+		account.WithAdmins(config.Config.AdminLogins),
+		account.WithEvents(urlService),
+	)
+	sessions := mwcookie.NewSessions(cookies, tokens)
+
+	adminStorage, ok := storage.(admin.Storage)
+	if !ok {
+		log.Panic("the storage does not support the admin API")
+	}
+	admins := admin.New(adminStorage, admin.WithEvents(urlService))
 
 	r := chi.NewRouter()
 
+	r.Use(middleware.RequestID)
 	r.Use(gzip.New())
-	r.Use(mwcookie.New())
+	r.Use(mwcookie.New(cookies, tokens, apiKeys))
 	r.Use(middlewarelogger.New())
 
-	r.Get("/{id}", geturl.New(ctx, storage))
-	r.Get("/ping", ping.New(ctx, storage))
-	r.Get("/api/user/urls", urls.New(ctx, storage))
-	r.Post("/", saveurl.New(ctx, urlService))
-	r.Post("/api/shorten", shorten.New(ctx, urlService))
-	r.Post("/api/shorten/batch", batch.New(ctx, urlService, shortenValidator))
-	r.Delete("/api/user/urls", deleteurl.New(ctx, urlService, shortenValidator))
+	queue := deletequeue.New(urlService, deletequeue.Config{
+		Size:            config.Config.DeleteQueueSize,
+		Workers:         config.Config.DeleteWorkers,
+		BatchSize:       config.Config.DeleteBatchSize,
+		FlushInterval:   time.Duration(config.Config.DeleteFlushInterval) * time.Millisecond,
+		MaxRetries:      config.Config.DeleteMaxRetries,
+		RetryBackoff:    deleteRetryBackoff,
+		RequeueInterval: deleteRequeueInterval,
+		PendingPath:     config.Config.DeleteQueuePath,
+	})
+	if err = queue.Start(); err != nil {
+		log.Panic(err)
+	}
+
+	clickStorage, ok := storage.(analytics.Storage)
+	if !ok {
+		log.Panic("the storage does not support click statistics")
+	}
+	clicks := analytics.New(clickStorage, analytics.Config{
+		Size:          config.Config.ClickQueueSize,
+		BatchSize:     config.Config.ClickBatchSize,
+		FlushInterval: time.Duration(config.Config.ClickFlushInterval) * time.Millisecond,
+		HashKey:       config.Config.ClickHashKey,
+	}, analytics.WithEvents(dispatcher))
+	clicks.Start()
+
+	statsStorage, ok := storage.(servicestats.URLStorage)
+	if !ok {
+		log.Panic("the storage does not support service statistics")
+	}
+
+	var expiredReaper *reaper.Reaper
+	if config.Config.ExpiredPurgeInterval > 0 {
+		expiredReaper = reaper.New(urlService, time.Duration(config.Config.ExpiredPurgeInterval)*time.Second)
+		expiredReaper.Start()
+	}
+
+	// route registers a handler behind the request timeout configured for it.
+	route := func(method, pattern string, handler http.HandlerFunc) {
+		r.With(timeout.New(routeTimeout(method, pattern))).Method(method, pattern, handler)
+	}
+
+	route(http.MethodGet, "/{id}", geturl.New(storage, unlocker, clicks, urlService))
+	route(http.MethodPost, "/{id}", geturl.New(storage, unlocker, clicks, urlService))
+	route(http.MethodGet, "/ping", ping.New(storage))
+	route(http.MethodGet, "/api/user/urls", urls.New(storage))
+	route(http.MethodGet, "/api/user/urls/history", history.New(urlService))
+	route(http.MethodGet, "/api/user/urls/{code}/stats", stats.New(storage, clickStorage))
+	route(http.MethodGet, "/api/internal/stats", trusted.New(trustedSubnet)(servicestats.New(statsStorage)).ServeHTTP)
+	route(http.MethodPost, "/", saveurl.New(urlService))
+	route(http.MethodPost, "/api/shorten", shorten.New(urlService))
+	route(http.MethodPost, "/api/shorten/batch", batch.New(urlService, shortenValidator))
+	route(http.MethodDelete, "/api/user/urls", deleteurl.New(queue, shortenValidator))
+	route(http.MethodPost, "/api/user/urls/restore", restoreurl.New(urlService, queue, shortenValidator))
+	route(http.MethodPost, "/api/user/register", register.New(accounts, sessions))
+	route(http.MethodPost, "/api/user/login", login.New(accounts, sessions))
+	route(http.MethodGet, "/api/user/keys", listkeys.New(apiKeys))
+	route(http.MethodPost, "/api/user/keys", createkey.New(apiKeys))
+	route(http.MethodDelete, "/api/user/keys/{id}", revokekey.New(apiKeys))
+	route(http.MethodGet, "/api/user/webhooks", listwebhooks.New(webhooks))
+	route(http.MethodPost, "/api/user/webhooks", createwebhook.New(webhooks))
+	route(http.MethodDelete, "/api/user/webhooks/{id}", deletewebhook.New(webhooks))
+	route(http.MethodGet, "/api/user/webhooks/dead-letters", webhookdeadletters.New(webhooks))
+
+	r.Group(func(r chi.Router) {
+		r.Use(mwcookie.RequireAdmin(admins))
+
+		// adminRoute registers an admin handler behind the request timeout configured for it.
+		adminRoute := func(method, pattern string, handler http.HandlerFunc) {
+			r.With(timeout.New(routeTimeout(method, pattern))).Method(method, pattern, handler)
+		}
+
+		adminRoute(http.MethodGet, "/api/admin/urls", adminurls.New(admins))
+		adminRoute(http.MethodPost, "/api/admin/urls/{code}/disable", disableurl.New(admins, true))
+		adminRoute(http.MethodPost, "/api/admin/urls/{code}/enable", disableurl.New(admins, false))
+		adminRoute(http.MethodPost, "/api/admin/users/{id}/reassign", reassignuser.New(admins))
+		adminRoute(http.MethodDelete, "/api/admin/users/{id}/urls", purgeuser.New(admins))
+		adminRoute(http.MethodPut, "/api/admin/users/{id}/role", setrole.New(admins))
+		adminRoute(http.MethodGet, "/api/admin/audit", adminaudit.New(admins))
+	})
 
 	if config.Config.AppEnv == "dev" {
 		r.Mount("/debug", middleware.Profiler())
 	}
 
 	return &App{
-		router:        r,
-		serverAddress: config.Config.ServerAddress,
+		router:            r,
+		serverAddress:     config.Config.ServerAddress,
+		cookies:           cookies,
+		tokens:            tokens,
+		grpcServer:        grpcserver.New(queue, urlService, storage, clickStorage, shortenValidator, unlocker, clicks),
+		grpcServerAddress: config.Config.GRPCServerAddress,
+		storage:           storage,
+		deleteQueue:       queue,
+		clicks:            clicks,
+		reaper:            expiredReaper,
+		auditLog:          auditLog,
+		dispatcher:        dispatcher,
+	}
+}
+
+// loadKeys returns the keys signing the user sessions: the Keyring JSON if set, else the keys
+// in the KeyringPath file, else a single key made of the JWT and secure cookie settings, so a
+// deployment without a keyring keeps its sessions.
+func loadKeys() []keyring.Key {
+	var (
+		keys []keyring.Key
+		err  error
+	)
+
+	switch {
+	case config.Config.Keyring != "":
+		keys, err = keyring.Parse([]byte(config.Config.Keyring))
+	case config.Config.KeyringPath != "":
+		keys, err = keyring.Load(config.Config.KeyringPath)
+	default:
+		keys = []keyring.Key{{
+			ID:         "default",
+			HashKey:    config.Config.SecureCookieHashKey,
+			BlockKey:   config.Config.SecureCookieBlockKey,
+			JwtSecret:  config.Config.JwtSecret,
+			JwtKeyPath: config.Config.JwtKeyPath,
+		}}
+	}
+	if err != nil {
+		log.Panic(err)
 	}
+
+	return keys
+}
+
+// routeTimeout returns the request timeout configured for the route,
+// falling back to the default request timeout.
+func routeTimeout(method, pattern string) time.Duration {
+	for _, key := range []string{method + " " + pattern, pattern} {
+		if ms, ok := config.Config.RouteTimeouts[key]; ok {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return time.Duration(config.Config.RequestTimeout) * time.Millisecond
 }
@@ -6,14 +6,53 @@
 // The following configuration values can be set through flags or environment variables:
 // - AppEnv: The environment in which the application is running (e.g., "prod", "dev").
 // - ServerAddress: The address on which the HTTP server will listen (e.g., "localhost:8080").
+// - GRPCServerAddress: The address on which the gRPC server will listen (e.g., "localhost:3200").
 // - BaseURL: The base address of the resulting shortened URL (e.g., "http://localhost:8080").
 // - DatabaseDsn: The Data Source Name (DSN) for connecting to the database.
+// - DatabaseMaxConns: The maximum number of connections in the database pool.
+// - DatabaseMinConns: The number of connections the database pool keeps open when idle.
+// - DatabaseMaxConnLifetime: The lifetime of a database connection in seconds.
+// - DatabaseStatementCache: The number of prepared statements cached per connection; negative disables the cache.
 // - FileStoragePath: The path to the file used for storing data in file storage.
-// - JwtSecret: The secret key used to sign JWT tokens.
+// - FileStorageCompactRecords: The number of log records that triggers a file storage compaction.
+// - FileStorageCompactSize: The log size in bytes that triggers a file storage compaction.
+// - FileStorageSync: When the file storage log is fsynced: "always", "interval" or "none".
+// - FileStorageSyncInterval: The group commit interval in milliseconds for the "interval" sync mode.
+// - RequestTimeout: The default HTTP request timeout in milliseconds; a negative value disables it.
+// - RouteTimeouts: Per-route request timeouts in milliseconds, keyed by "METHOD /pattern" or "/pattern".
+// - DeleteQueuePath: The journal of the deletions not processed yet, replayed on start; empty disables it.
+// - DeleteQueueSize: The number of deletion requests the queue holds before rejecting new ones.
+// - DeleteWorkers: The number of workers processing the deletion queue.
+// - DeleteBatchSize: The number of codes that triggers a deletion batch.
+// - DeleteFlushInterval: The longest time in milliseconds a deletion waits in a batch.
+// - DeleteMaxRetries: The number of times a failed deletion is retried.
+// - ExpiredPurgeInterval: How often in seconds expired URLs are purged; a negative value disables purging.
+// - UnlockMaxFailures: The number of wrong passwords after which a protected URL is locked.
+// - UnlockLockout: How long in seconds a protected URL stays locked after too many wrong passwords.
+// - ClickQueueSize: The number of click events buffered before new ones are dropped.
+// - ClickBatchSize: The number of click events that triggers a write to the storage.
+// - ClickFlushInterval: The longest time in milliseconds a click event waits in the buffer.
+// - ClickHashKey: The key client IPs are hashed with before click events are stored.
+// - TrustedSubnet: The CIDR the X-Real-IP of internal requests must fall into; empty denies them all.
+// - JwtAlgorithm: The algorithm JWT tokens are signed with: "HS256", "RS256" or "EdDSA".
+// - JwtSecret: The secret key used to sign JWT tokens with HS256.
+// - JwtKeyPath: The path to the PEM private key used to sign JWT tokens with RS256 or EdDSA.
 // - JwtTokenExpire: The duration for which JWT tokens are valid.
 // - SecureCookieHashKey: The key used for securing cookies in hashing.
 // - SecureCookieBlockKey: The key used for securing cookies in encryption.
 // - SecureCookieExpire: The duration for which cookies are valid.
+// - Keyring: A JSON array of the keys signing the sessions; overrides KeyringPath.
+// - KeyringPath: The path to a JSON file holding the keys signing the sessions. Without either,
+// the sessions are signed with a single key made of the JWT and secure cookie settings above.
+// - AdminLogins: The logins of the accounts given the admin role, comma-separated in the environment.
+// - AuditLogPath: The JSON lines file the link lifecycle events are written to and read back from.
+// - AuditWebhookURL: The URL the link lifecycle events are posted to as well; empty disables it.
+// - WebhookQueueSize: The number of events waiting for delivery to webhooks before new ones are dropped.
+// - WebhookWorkers: The number of webhook deliveries made concurrently.
+// - WebhookMaxAttempts: The number of times a webhook delivery is attempted before it is dead-lettered.
+// - WebhookRetryBackoff: The wait in milliseconds before the first retry of a webhook delivery; it doubles with every retry.
+// - WebhookMaxBackoff: The longest wait in milliseconds between the retries of a webhook delivery.
+// - WebhookTimeout: The longest time in milliseconds a webhook delivery attempt may take.
 // - EnableHTTPS: Enable HTTPS on server.
 // - TLSCertPath: Cert path.
 // - TLSKeyPath: Key path.
@@ -26,31 +65,99 @@ package config
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const defaultJwtHours = 24
+const (
+	defaultJwtHours                  = 24
+	defaultFileStorageCompactRecords = 100_000
+	defaultFileStorageCompactSize    = 64 << 20
+	defaultFileStorageSync           = "interval"
+	defaultFileStorageSyncInterval   = 100
+	defaultRequestTimeout            = 5000
+	defaultDeleteQueuePath           = "./storage/delete_queue.json"
+	defaultDeleteQueueSize           = 1024
+	defaultDeleteWorkers             = 2
+	defaultDeleteBatchSize           = 100
+	defaultDeleteFlushInterval       = 500
+	defaultDeleteMaxRetries          = 3
+	defaultExpiredPurgeInterval      = 60
+	defaultUnlockMaxFailures         = 5
+	defaultUnlockLockout             = 300
+	defaultClickQueueSize            = 4096
+	defaultClickBatchSize            = 100
+	defaultClickFlushInterval        = 1000
+	defaultClickHashKey              = "click-secret"
+	defaultJwtAlgorithm              = "HS256"
+	defaultAuditLogPath              = "./storage/audit.jsonl"
+	defaultWebhookQueueSize          = 1024
+	defaultWebhookWorkers            = 4
+	defaultWebhookMaxAttempts        = 5
+	defaultWebhookRetryBackoff       = 1000
+	defaultWebhookMaxBackoff         = 60000
+	defaultWebhookTimeout            = 5000
+)
 
 // CfgStruct holds the configuration values for the application.
 type CfgStruct struct {
-	AppEnv               string `json:"app_env"`
-	ServerAddress        string `json:"server_address"`
-	BaseURL              string `json:"base_url"`
-	DatabaseDsn          string `json:"database_dsn"`
-	FileStoragePath      string `json:"file_storage_path"`
-	JwtSecret            string `json:"jwt_secret"`
-	JwtTokenExpire       time.Duration
-	SecureCookieHashKey  string `json:"secure_cookie_hash_key"`
-	SecureCookieBlockKey string `json:"secure_cookie_block_key"`
-	SecureCookieExpire   time.Duration
-	EnableHTTPS          bool   `json:"enable_https"`
-	TLSCertPath          string `json:"tls_cert_path"`
-	TLSKeyPath           string `json:"tls_key_path"`
-	JSONConfig           string
+	AppEnv                    string         `json:"app_env"`
+	ServerAddress             string         `json:"server_address"`
+	GRPCServerAddress         string         `json:"grpc_server_address"`
+	BaseURL                   string         `json:"base_url"`
+	DatabaseDsn               string         `json:"database_dsn"`
+	DatabaseMaxConns          int32          `json:"database_max_conns"`
+	DatabaseMinConns          int32          `json:"database_min_conns"`
+	DatabaseMaxConnLifetime   int            `json:"database_max_conn_lifetime"`
+	DatabaseStatementCache    int            `json:"database_statement_cache"`
+	FileStoragePath           string         `json:"file_storage_path"`
+	FileStorageCompactRecords int            `json:"file_storage_compact_records"`
+	FileStorageCompactSize    int64          `json:"file_storage_compact_size"`
+	FileStorageSync           string         `json:"file_storage_sync"`
+	FileStorageSyncInterval   int            `json:"file_storage_sync_interval"`
+	RequestTimeout            int            `json:"request_timeout"`
+	RouteTimeouts             map[string]int `json:"route_timeouts"`
+	DeleteQueuePath           string         `json:"delete_queue_path"`
+	DeleteQueueSize           int            `json:"delete_queue_size"`
+	DeleteWorkers             int            `json:"delete_workers"`
+	DeleteBatchSize           int            `json:"delete_batch_size"`
+	DeleteFlushInterval       int            `json:"delete_flush_interval"`
+	DeleteMaxRetries          int            `json:"delete_max_retries"`
+	ExpiredPurgeInterval      int            `json:"expired_purge_interval"`
+	UnlockMaxFailures         int            `json:"unlock_max_failures"`
+	UnlockLockout             int            `json:"unlock_lockout"`
+	ClickQueueSize            int            `json:"click_queue_size"`
+	ClickBatchSize            int            `json:"click_batch_size"`
+	ClickFlushInterval        int            `json:"click_flush_interval"`
+	ClickHashKey              string         `json:"click_hash_key"`
+	TrustedSubnet             string         `json:"trusted_subnet"`
+	JwtAlgorithm              string         `json:"jwt_algorithm"`
+	JwtSecret                 string         `json:"jwt_secret"`
+	JwtKeyPath                string         `json:"jwt_key_path"`
+	JwtTokenExpire            time.Duration
+	SecureCookieHashKey       string `json:"secure_cookie_hash_key"`
+	SecureCookieBlockKey      string `json:"secure_cookie_block_key"`
+	SecureCookieExpire        time.Duration
+	Keyring                   string
+	KeyringPath               string   `json:"keyring_path"`
+	AdminLogins               []string `json:"admin_logins"`
+	AuditLogPath              string   `json:"audit_log_path"`
+	AuditWebhookURL           string   `json:"audit_webhook_url"`
+	WebhookQueueSize          int      `json:"webhook_queue_size"`
+	WebhookWorkers            int      `json:"webhook_workers"`
+	WebhookMaxAttempts        int      `json:"webhook_max_attempts"`
+	WebhookRetryBackoff       int      `json:"webhook_retry_backoff"`
+	WebhookMaxBackoff         int      `json:"webhook_max_backoff"`
+	WebhookTimeout            int      `json:"webhook_timeout"`
+	EnableHTTPS               bool     `json:"enable_https"`
+	TLSCertPath               string   `json:"tls_cert_path"`
+	TLSKeyPath                string   `json:"tls_key_path"`
+	JSONConfig                string
 }
 
 // Config is the global instance of CfgStruct used by the application.
@@ -60,11 +167,16 @@ type CfgStruct struct {
 // arguments, and JSON configuration files.
 var Config CfgStruct
 
+// fromJSON holds the addresses of the Config fields set by the JSON config,
+// so that a zero given there is told apart from a missing value.
+var fromJSON map[any]struct{}
+
 // ParseFlags parses command-line flags and environment variables to populate the configuration values.
 // Default values are set in the code, but they can be overridden by flags or environment variables.
 func ParseFlags() {
 	flag.StringVar(&Config.AppEnv, "e", "prod", "environment")
 	flag.StringVar(&Config.ServerAddress, "a", "localhost:8080", "HTTP server startup address")
+	flag.StringVar(&Config.GRPCServerAddress, "g", "localhost:3200", "gRPC server startup address")
 	flag.StringVar(&Config.BaseURL, "b", "http://localhost:8080", "the base address of the resulting shortened URL")
 	flag.StringVar(&Config.DatabaseDsn, "d", "", "database DSN")
 	flag.StringVar(&Config.FileStoragePath, "f", "./storage/filestorage.txt", "path to file storage")
@@ -72,9 +184,12 @@ func ParseFlags() {
 	flag.StringVar(&Config.TLSCertPath, "t", "certs/localhost.pem", "path to TLS cert")
 	flag.StringVar(&Config.TLSKeyPath, "k", "certs/localhost-key.pem", "path to TLS key")
 	flag.StringVar(&Config.JSONConfig, "c", "", "path to json config")
+	flag.StringVar(&Config.TrustedSubnet, "n", "", "trusted subnet in CIDR notation")
 
 	flag.Parse()
 
+	fromJSON = make(map[any]struct{})
+
 	if envJSONConfig := os.Getenv("CONFIG"); envJSONConfig != "" {
 		Config.JSONConfig = envJSONConfig
 	}
@@ -94,6 +209,10 @@ func ParseFlags() {
 		Config.ServerAddress = serverAddress
 	}
 
+	if grpcServerAddress := os.Getenv("GRPC_SERVER_ADDRESS"); grpcServerAddress != "" {
+		Config.GRPCServerAddress = grpcServerAddress
+	}
+
 	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
 		Config.BaseURL = baseURL
 	}
@@ -102,10 +221,124 @@ func ParseFlags() {
 		Config.DatabaseDsn = databaseDsn
 	}
 
+	if maxConns := os.Getenv("DATABASE_MAX_CONNS"); maxConns != "" {
+		parsed, err := strconv.ParseInt(maxConns, 10, 32)
+		if err != nil {
+			log.Fatalf("invalid DATABASE_MAX_CONNS value: %v", err)
+		}
+		Config.DatabaseMaxConns = int32(parsed)
+	}
+
+	if minConns := os.Getenv("DATABASE_MIN_CONNS"); minConns != "" {
+		parsed, err := strconv.ParseInt(minConns, 10, 32)
+		if err != nil {
+			log.Fatalf("invalid DATABASE_MIN_CONNS value: %v", err)
+		}
+		Config.DatabaseMinConns = int32(parsed)
+	}
+
+	if connLifetime := os.Getenv("DATABASE_MAX_CONN_LIFETIME"); connLifetime != "" {
+		parsed, err := strconv.Atoi(connLifetime)
+		if err != nil {
+			log.Fatalf("invalid DATABASE_MAX_CONN_LIFETIME value: %v", err)
+		}
+		Config.DatabaseMaxConnLifetime = parsed
+	}
+
+	if statementCache := os.Getenv("DATABASE_STATEMENT_CACHE"); statementCache != "" {
+		parsed, err := strconv.Atoi(statementCache)
+		if err != nil {
+			log.Fatalf("invalid DATABASE_STATEMENT_CACHE value: %v", err)
+		}
+		Config.DatabaseStatementCache = parsed
+	}
+
 	if fileStoragePath := os.Getenv("FILE_STORAGE_PATH"); fileStoragePath != "" {
 		Config.FileStoragePath = fileStoragePath
 	}
 
+	if compactRecords := os.Getenv("FILE_STORAGE_COMPACT_RECORDS"); compactRecords != "" {
+		parsed, err := strconv.Atoi(compactRecords)
+		if err != nil {
+			log.Fatalf("invalid FILE_STORAGE_COMPACT_RECORDS value: %v", err)
+		}
+		Config.FileStorageCompactRecords = parsed
+	} else if !isSetByJSON(&Config.FileStorageCompactRecords) {
+		Config.FileStorageCompactRecords = defaultFileStorageCompactRecords
+	}
+
+	if compactSize := os.Getenv("FILE_STORAGE_COMPACT_SIZE"); compactSize != "" {
+		parsed, err := strconv.ParseInt(compactSize, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid FILE_STORAGE_COMPACT_SIZE value: %v", err)
+		}
+		Config.FileStorageCompactSize = parsed
+	} else if !isSetByJSON(&Config.FileStorageCompactSize) {
+		Config.FileStorageCompactSize = defaultFileStorageCompactSize
+	}
+
+	if storageSync := os.Getenv("FILE_STORAGE_SYNC"); storageSync != "" {
+		Config.FileStorageSync = storageSync
+	} else if Config.FileStorageSync == "" {
+		Config.FileStorageSync = defaultFileStorageSync
+	}
+
+	if syncInterval := os.Getenv("FILE_STORAGE_SYNC_INTERVAL"); syncInterval != "" {
+		parsed, err := strconv.Atoi(syncInterval)
+		if err != nil {
+			log.Fatalf("invalid FILE_STORAGE_SYNC_INTERVAL value: %v", err)
+		}
+		Config.FileStorageSyncInterval = parsed
+	} else if !isSetByJSON(&Config.FileStorageSyncInterval) {
+		Config.FileStorageSyncInterval = defaultFileStorageSyncInterval
+	}
+
+	if requestTimeout := os.Getenv("REQUEST_TIMEOUT"); requestTimeout != "" {
+		parsed, err := strconv.Atoi(requestTimeout)
+		if err != nil {
+			log.Fatalf("invalid REQUEST_TIMEOUT value: %v", err)
+		}
+		Config.RequestTimeout = parsed
+	} else if !isSetByJSON(&Config.RequestTimeout) {
+		Config.RequestTimeout = defaultRequestTimeout
+	}
+
+	if routeTimeouts := os.Getenv("ROUTE_TIMEOUTS"); routeTimeouts != "" {
+		parsed, err := parseRouteTimeouts(routeTimeouts)
+		if err != nil {
+			log.Fatalf("invalid ROUTE_TIMEOUTS value: %v", err)
+		}
+		Config.RouteTimeouts = parsed
+	}
+
+	if deleteQueuePath, ok := os.LookupEnv("DELETE_QUEUE_PATH"); ok {
+		Config.DeleteQueuePath = deleteQueuePath
+	} else if Config.DeleteQueuePath == "" && !isSetByJSON(&Config.DeleteQueuePath) {
+		Config.DeleteQueuePath = defaultDeleteQueuePath
+	}
+
+	parseIntEnv("DELETE_QUEUE_SIZE", &Config.DeleteQueueSize, defaultDeleteQueueSize)
+	parseIntEnv("DELETE_WORKERS", &Config.DeleteWorkers, defaultDeleteWorkers)
+	parseIntEnv("DELETE_BATCH_SIZE", &Config.DeleteBatchSize, defaultDeleteBatchSize)
+	parseIntEnv("DELETE_FLUSH_INTERVAL", &Config.DeleteFlushInterval, defaultDeleteFlushInterval)
+	parseIntEnv("DELETE_MAX_RETRIES", &Config.DeleteMaxRetries, defaultDeleteMaxRetries)
+	parseIntEnv("EXPIRED_PURGE_INTERVAL", &Config.ExpiredPurgeInterval, defaultExpiredPurgeInterval)
+	parseIntEnv("UNLOCK_MAX_FAILURES", &Config.UnlockMaxFailures, defaultUnlockMaxFailures)
+	parseIntEnv("UNLOCK_LOCKOUT", &Config.UnlockLockout, defaultUnlockLockout)
+	parseIntEnv("CLICK_QUEUE_SIZE", &Config.ClickQueueSize, defaultClickQueueSize)
+	parseIntEnv("CLICK_BATCH_SIZE", &Config.ClickBatchSize, defaultClickBatchSize)
+	parseIntEnv("CLICK_FLUSH_INTERVAL", &Config.ClickFlushInterval, defaultClickFlushInterval)
+
+	if clickHashKey := os.Getenv("CLICK_HASH_KEY"); clickHashKey != "" {
+		Config.ClickHashKey = clickHashKey
+	} else if Config.ClickHashKey == "" {
+		Config.ClickHashKey = defaultClickHashKey
+	}
+
+	if trustedSubnet := os.Getenv("TRUSTED_SUBNET"); trustedSubnet != "" {
+		Config.TrustedSubnet = trustedSubnet
+	}
+
 	if enableHTTPS := os.Getenv("ENABLE_HTTPS"); enableHTTPS != "" {
 		parsed, err := strconv.ParseBool(enableHTTPS)
 		if err != nil {
@@ -122,9 +355,19 @@ func ParseFlags() {
 		Config.TLSKeyPath = TLSKeyPath
 	}
 
+	if jwtAlgorithm := os.Getenv("JWT_ALGORITHM"); jwtAlgorithm != "" {
+		Config.JwtAlgorithm = jwtAlgorithm
+	} else if Config.JwtAlgorithm == "" {
+		Config.JwtAlgorithm = defaultJwtAlgorithm
+	}
+
+	if jwtKeyPath := os.Getenv("JWT_KEY_PATH"); jwtKeyPath != "" {
+		Config.JwtKeyPath = jwtKeyPath
+	}
+
 	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
 		Config.JwtSecret = jwtSecret
-	} else {
+	} else if Config.JwtSecret == "" {
 		Config.JwtSecret = "secretkey"
 	}
 
@@ -139,6 +382,35 @@ func ParseFlags() {
 	} else {
 		Config.SecureCookieBlockKey = "alotsecretalotsecretalotsecretgr"
 	}
+
+	if keyring := os.Getenv("KEYRING"); keyring != "" {
+		Config.Keyring = keyring
+	}
+
+	if keyringPath := os.Getenv("KEYRING_PATH"); keyringPath != "" {
+		Config.KeyringPath = keyringPath
+	}
+
+	if adminLogins := os.Getenv("ADMIN_LOGINS"); adminLogins != "" {
+		Config.AdminLogins = strings.Split(adminLogins, ",")
+	}
+
+	if auditLogPath := os.Getenv("AUDIT_LOG_PATH"); auditLogPath != "" {
+		Config.AuditLogPath = auditLogPath
+	} else if Config.AuditLogPath == "" {
+		Config.AuditLogPath = defaultAuditLogPath
+	}
+
+	if auditWebhookURL := os.Getenv("AUDIT_WEBHOOK_URL"); auditWebhookURL != "" {
+		Config.AuditWebhookURL = auditWebhookURL
+	}
+
+	parseIntEnv("WEBHOOK_QUEUE_SIZE", &Config.WebhookQueueSize, defaultWebhookQueueSize)
+	parseIntEnv("WEBHOOK_WORKERS", &Config.WebhookWorkers, defaultWebhookWorkers)
+	parseIntEnv("WEBHOOK_MAX_ATTEMPTS", &Config.WebhookMaxAttempts, defaultWebhookMaxAttempts)
+	parseIntEnv("WEBHOOK_RETRY_BACKOFF", &Config.WebhookRetryBackoff, defaultWebhookRetryBackoff)
+	parseIntEnv("WEBHOOK_MAX_BACKOFF", &Config.WebhookMaxBackoff, defaultWebhookMaxBackoff)
+	parseIntEnv("WEBHOOK_TIMEOUT", &Config.WebhookTimeout, defaultWebhookTimeout)
 }
 
 // parseJSONConfig reads a JSON configuration file from the path specified
@@ -158,15 +430,70 @@ func parseJSONConfig() {
 		log.Panic(err)
 	}
 
-	copyMissingFields(&Config, *cfgJSON)
+	var keys map[string]json.RawMessage
+	err = json.Unmarshal(file, &keys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	present := make(map[string]struct{}, len(keys))
+	for key := range keys {
+		present[key] = struct{}{}
+	}
+
+	copyMissingFields(&Config, *cfgJSON, present)
+}
+
+// isSetByJSON reports whether the Config field at target was set by the JSON config.
+func isSetByJSON(target any) bool {
+	_, ok := fromJSON[target]
+	return ok
+}
+
+// parseIntEnv sets target from the named environment variable, or to fallback
+// if the variable is not set and target has not been set by the JSON config.
+// A zero given by either of them is kept.
+func parseIntEnv(name string, target *int, fallback int) {
+	if value := os.Getenv(name); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			log.Fatalf("invalid %s value: %v", name, err)
+		}
+		*target = parsed
+	} else if !isSetByJSON(target) {
+		*target = fallback
+	}
+}
+
+// parseRouteTimeouts parses a comma-separated list of route=milliseconds pairs,
+// for example "POST /api/shorten/batch=10000,/ping=500".
+func parseRouteTimeouts(value string) (map[string]int, error) {
+	timeouts := make(map[string]int)
+
+	for _, pair := range strings.Split(value, ",") {
+		route, ms, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("expected route=milliseconds, got %q", pair)
+		}
+
+		parsed, err := strconv.Atoi(strings.TrimSpace(ms))
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+
+		timeouts[strings.TrimSpace(route)] = parsed
+	}
+
+	return timeouts, nil
 }
 
 // copyMissingFields copies all non-zero fields from the 'from' CfgStruct
 // into the 'to' CfgStruct pointer, but only for fields that are currently
-// zero-valued in 'to'.
+// zero-valued in 'to'. A zero field is copied too when its JSON key is in
+// present, and every copied field is recorded in fromJSON.
 //
 // Fields are compared and copied using reflection.
-func copyMissingFields(to *CfgStruct, from CfgStruct) {
+func copyMissingFields(to *CfgStruct, from CfgStruct, present map[string]struct{}) {
 	toVal := reflect.ValueOf(to).Elem()
 	fromVal := reflect.ValueOf(from)
 
@@ -174,8 +501,12 @@ func copyMissingFields(to *CfgStruct, from CfgStruct) {
 		toField := toVal.Field(i)
 		fromField := fromVal.Field(i)
 
-		if isZero(toField) && !isZero(fromField) {
+		key, _, _ := strings.Cut(toVal.Type().Field(i).Tag.Get("json"), ",")
+		_, inJSON := present[key]
+
+		if isZero(toField) && (!isZero(fromField) || inJSON) {
 			toField.Set(fromField)
+			fromJSON[toField.Addr().Interface()] = struct{}{}
 		}
 	}
 }
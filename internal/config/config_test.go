@@ -33,6 +33,57 @@ func TestParseFlags_DefaultValues(t *testing.T) {
 	if cfg.SecureCookieBlockKey != "alotsecretalotsecretalotsecretgr" {
 		t.Errorf("expected default SecureCookieBlockKey, got '%s'", cfg.SecureCookieBlockKey)
 	}
+	if cfg.FileStorageSync != "interval" {
+		t.Errorf("expected FileStorageSync to be 'interval', got '%s'", cfg.FileStorageSync)
+	}
+	if cfg.FileStorageSyncInterval != 100 {
+		t.Errorf("expected FileStorageSyncInterval to be 100, got %d", cfg.FileStorageSyncInterval)
+	}
+	if cfg.ExpiredPurgeInterval != 60 {
+		t.Errorf("expected ExpiredPurgeInterval to be 60, got %d", cfg.ExpiredPurgeInterval)
+	}
+	if cfg.UnlockMaxFailures != 5 {
+		t.Errorf("expected UnlockMaxFailures to be 5, got %d", cfg.UnlockMaxFailures)
+	}
+	if cfg.UnlockLockout != 300 {
+		t.Errorf("expected UnlockLockout to be 300, got %d", cfg.UnlockLockout)
+	}
+	if cfg.ClickFlushInterval != 1000 {
+		t.Errorf("expected ClickFlushInterval to be 1000, got %d", cfg.ClickFlushInterval)
+	}
+	if cfg.ClickHashKey != "click-secret" {
+		t.Errorf("expected default ClickHashKey, got '%s'", cfg.ClickHashKey)
+	}
+	if cfg.TrustedSubnet != "" {
+		t.Errorf("expected TrustedSubnet to be empty, got '%s'", cfg.TrustedSubnet)
+	}
+	if cfg.JwtAlgorithm != "HS256" {
+		t.Errorf("expected JwtAlgorithm to be 'HS256', got '%s'", cfg.JwtAlgorithm)
+	}
+	if cfg.KeyringPath != "" {
+		t.Errorf("expected KeyringPath to be empty, got '%s'", cfg.KeyringPath)
+	}
+	if len(cfg.AdminLogins) != 0 {
+		t.Errorf("expected AdminLogins to be empty, got %v", cfg.AdminLogins)
+	}
+	if cfg.AuditLogPath != "./storage/audit.jsonl" {
+		t.Errorf("expected AuditLogPath to be './storage/audit.jsonl', got '%s'", cfg.AuditLogPath)
+	}
+	if cfg.AuditWebhookURL != "" {
+		t.Errorf("expected AuditWebhookURL to be empty, got '%s'", cfg.AuditWebhookURL)
+	}
+	if cfg.WebhookWorkers != 4 {
+		t.Errorf("expected WebhookWorkers to be 4, got %d", cfg.WebhookWorkers)
+	}
+	if cfg.WebhookMaxAttempts != 5 {
+		t.Errorf("expected WebhookMaxAttempts to be 5, got %d", cfg.WebhookMaxAttempts)
+	}
+	if cfg.WebhookRetryBackoff != 1000 {
+		t.Errorf("expected WebhookRetryBackoff to be 1000, got %d", cfg.WebhookRetryBackoff)
+	}
+	if cfg.WebhookMaxBackoff != 60000 {
+		t.Errorf("expected WebhookMaxBackoff to be 60000, got %d", cfg.WebhookMaxBackoff)
+	}
 }
 
 func TestParseFlags_WithEnvOverrides(t *testing.T) {
@@ -42,6 +93,12 @@ func TestParseFlags_WithEnvOverrides(t *testing.T) {
 	os.Setenv("JWT_SECRET", "env-secret")
 	os.Setenv("SECURE_COOKIE_HASH_KEY", "env-hash")
 	os.Setenv("SECURE_COOKIE_BLOCK_KEY", "env-block")
+	os.Setenv("TRUSTED_SUBNET", "10.0.0.0/8")
+	os.Setenv("KEYRING_PATH", "/etc/shorturl/keyring.json")
+	os.Setenv("ADMIN_LOGINS", "alice,bob")
+	os.Setenv("AUDIT_LOG_PATH", "/var/log/shorturl/audit.jsonl")
+	os.Setenv("AUDIT_WEBHOOK_URL", "https://audit.example.com/events")
+	os.Setenv("WEBHOOK_MAX_ATTEMPTS", "8")
 
 	resetArgs()
 
@@ -68,6 +125,24 @@ func TestParseFlags_WithEnvOverrides(t *testing.T) {
 	if cfg.SecureCookieBlockKey != "env-block" {
 		t.Errorf("expected SecureCookieBlockKey to be 'env-block', got '%s'", cfg.SecureCookieBlockKey)
 	}
+	if cfg.TrustedSubnet != "10.0.0.0/8" {
+		t.Errorf("expected TrustedSubnet to be '10.0.0.0/8', got '%s'", cfg.TrustedSubnet)
+	}
+	if cfg.KeyringPath != "/etc/shorturl/keyring.json" {
+		t.Errorf("expected KeyringPath to be '/etc/shorturl/keyring.json', got '%s'", cfg.KeyringPath)
+	}
+	if len(cfg.AdminLogins) != 2 || cfg.AdminLogins[0] != "alice" || cfg.AdminLogins[1] != "bob" {
+		t.Errorf("expected AdminLogins to be [alice bob], got %v", cfg.AdminLogins)
+	}
+	if cfg.AuditLogPath != "/var/log/shorturl/audit.jsonl" {
+		t.Errorf("expected AuditLogPath to be '/var/log/shorturl/audit.jsonl', got '%s'", cfg.AuditLogPath)
+	}
+	if cfg.AuditWebhookURL != "https://audit.example.com/events" {
+		t.Errorf("expected AuditWebhookURL to be 'https://audit.example.com/events', got '%s'", cfg.AuditWebhookURL)
+	}
+	if cfg.WebhookMaxAttempts != 8 {
+		t.Errorf("expected WebhookMaxAttempts to be 8, got %d", cfg.WebhookMaxAttempts)
+	}
 }
 
 func TestParseFlags_JSONConfig(t *testing.T) {
@@ -100,6 +175,72 @@ func TestParseFlags_JSONConfig(t *testing.T) {
 	}
 }
 
+func TestParseFlags_JSONConfigZeroValues(t *testing.T) {
+	os.Clearenv()
+	Config = CfgStruct{}
+
+	tmpFile := createTempJSONConfig(t, `{
+		"delete_max_retries": 0,
+		"webhook_max_attempts": 0,
+		"file_storage_compact_records": 0,
+		"delete_queue_path": ""
+	}`)
+
+	os.Setenv("CONFIG", tmpFile)
+	defer os.Unsetenv("CONFIG")
+	resetArgs()
+
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	ParseFlags()
+
+	cfg := Config
+
+	if cfg.DeleteMaxRetries != 0 {
+		t.Errorf("expected DeleteMaxRetries to be 0, got %d", cfg.DeleteMaxRetries)
+	}
+	if cfg.WebhookMaxAttempts != 0 {
+		t.Errorf("expected WebhookMaxAttempts to be 0, got %d", cfg.WebhookMaxAttempts)
+	}
+	if cfg.FileStorageCompactRecords != 0 {
+		t.Errorf("expected FileStorageCompactRecords to be 0, got %d", cfg.FileStorageCompactRecords)
+	}
+	if cfg.DeleteQueuePath != "" {
+		t.Errorf("expected DeleteQueuePath to be empty, got '%s'", cfg.DeleteQueuePath)
+	}
+	if cfg.DeleteWorkers != defaultDeleteWorkers {
+		t.Errorf("expected DeleteWorkers to be %d, got %d", defaultDeleteWorkers, cfg.DeleteWorkers)
+	}
+}
+
+func TestParseFlags_EnvZeroValues(t *testing.T) {
+	os.Clearenv()
+	Config = CfgStruct{}
+
+	os.Setenv("DELETE_MAX_RETRIES", "0")
+	os.Setenv("WEBHOOK_MAX_ATTEMPTS", "0")
+	os.Setenv("DELETE_QUEUE_PATH", "")
+	defer os.Clearenv()
+	resetArgs()
+
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	ParseFlags()
+
+	cfg := Config
+
+	if cfg.DeleteMaxRetries != 0 {
+		t.Errorf("expected DeleteMaxRetries to be 0, got %d", cfg.DeleteMaxRetries)
+	}
+	if cfg.WebhookMaxAttempts != 0 {
+		t.Errorf("expected WebhookMaxAttempts to be 0, got %d", cfg.WebhookMaxAttempts)
+	}
+	if cfg.DeleteQueuePath != "" {
+		t.Errorf("expected DeleteQueuePath to be empty, got '%s'", cfg.DeleteQueuePath)
+	}
+	if cfg.DeleteBatchSize != defaultDeleteBatchSize {
+		t.Errorf("expected DeleteBatchSize to be %d, got %d", defaultDeleteBatchSize, cfg.DeleteBatchSize)
+	}
+}
+
 // resetArgs resets os.Args to default
 func resetArgs() {
 	os.Args = []string{"cmd"}
@@ -155,3 +296,21 @@ func Test_isZero(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRouteTimeouts(t *testing.T) {
+	got, err := parseRouteTimeouts("POST /api/shorten/batch=10000, /ping=500")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]int{"POST /api/shorten/batch": 10000, "/ping": 500}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, invalid := range []string{"/ping", "/ping=fast", "=100"} {
+		if _, err := parseRouteTimeouts(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
@@ -10,3 +10,12 @@ type headerKey string
 //
 // This constant is used to access or set the "X-User-ID" header in HTTP requests.
 const XUserID headerKey = "X-User-ID"
+
+// XLinkPassword is the key used in HTTP headers to carry the password of a protected short URL.
+const XLinkPassword headerKey = "X-Link-Password"
+
+// XRealIP is the key used in HTTP headers to carry the IP of the client behind a proxy.
+const XRealIP headerKey = "X-Real-IP"
+
+// XAPIKey is the key used in HTTP headers to carry the API key of a server-to-server client.
+const XAPIKey headerKey = "X-API-Key"
@@ -0,0 +1,181 @@
+// Package interceptor provides gRPC server interceptors used by the shortener gRPC API.
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// UserMetadataKey is the metadata key that carries the encoded user token,
+// the gRPC counterpart of the "user" cookie.
+const UserMetadataKey = "user"
+
+// AuthorizationMetadataKey is the metadata key that carries a bearer JWT identifying the user.
+const AuthorizationMetadataKey = "authorization"
+
+// bearerPrefix is the scheme prefix of a token in the authorization metadata.
+const bearerPrefix = "Bearer "
+
+// UserIDMetadataKey is the incoming metadata key holding the authenticated UserID.
+var UserIDMetadataKey = strings.ToLower(string(constants.XUserID))
+
+// Cookies encodes and decodes the user tokens carried in the "user" metadata.
+type Cookies interface {
+	Encode(name string, value any) (string, error)
+	Decode(name, value string, dst any) (stale bool, err error)
+}
+
+// Tokens issues and verifies the bearer tokens identifying users.
+type Tokens interface {
+	Issue(userID string) (string, error)
+	Parse(token string) (userID string, stale bool, err error)
+}
+
+// user represents the structure of the user information stored in the token.
+type user struct {
+	UserID string `json:"user_id"`
+}
+
+// Auth returns a unary server interceptor that identifies the caller.
+//
+// A caller sending a bearer JWT in the "authorization" metadata is identified by the
+// token alone. Otherwise the interceptor reads the "user" metadata value and decodes it
+// with the same keyring as the HTTP cookie middleware. If the value is missing, a new user
+// is created and both its encoded token and a JWT are sent back, in the "user" and
+// "authorization" response headers. A value signed with a previous key is sent back
+// re-signed with the current one in the same header it came in. As with the HTTP cookie,
+// a "user" value signed with a key that has expired or been removed is treated as a missing one.
+//
+// The resolved UserID is stored in the incoming metadata under the lower-cased
+// `X-User-ID` key, overwriting anything the client has sent, so downstream handlers
+// can read it the same way HTTP handlers read the `X-User-ID` header.
+func Auth(cookies Cookies, tokens Tokens) grpc.UnaryServerInterceptor {
+	slog.Info("grpc auth interceptor enabled")
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			md = metadata.MD{}
+		} else {
+			md = md.Copy()
+		}
+
+		u := &user{}
+
+		if values := md.Get(AuthorizationMetadataKey); len(values) > 0 && values[0] != "" {
+			raw, ok := strings.CutPrefix(values[0], bearerPrefix)
+			if !ok {
+				return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+			}
+
+			userID, stale, err := tokens.Parse(raw)
+			if err != nil {
+				slog.Info("invalid bearer token", sl.Err(err))
+				return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+			}
+
+			u.UserID = userID
+
+			if stale {
+				if token, errToken := tokens.Issue(userID); errToken != nil {
+					slog.Error("can't re-issue user token", sl.Err(errToken))
+				} else {
+					reissue(ctx, AuthorizationMetadataKey, bearerPrefix+token)
+				}
+			}
+		} else if values := md.Get(UserMetadataKey); len(values) > 0 && values[0] != "" {
+			stale, err := cookies.Decode(UserMetadataKey, values[0], u)
+			if errors.Is(err, keyring.ErrUnknownKey) {
+				// The token was signed with a key that has expired or been removed.
+				slog.Info("user token of an unknown key, issuing a new one", sl.Err(err))
+				if err = issue(ctx, cookies, tokens, u); err != nil {
+					return nil, err
+				}
+			} else if err != nil {
+				slog.Error("can't decode user token", sl.Err(err))
+				return nil, status.Error(codes.Unauthenticated, "Auth error")
+			} else if u.UserID == "" {
+				slog.Error("user_id is absent in token")
+				return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+			} else if stale {
+				if encoded, errEncode := cookies.Encode(UserMetadataKey, u); errEncode != nil {
+					slog.Error("can't re-issue user token", sl.Err(errEncode))
+				} else {
+					reissue(ctx, UserMetadataKey, encoded)
+				}
+			}
+		} else if err := issue(ctx, cookies, tokens, u); err != nil {
+			return nil, err
+		}
+
+		md.Set(UserIDMetadataKey, u.UserID)
+
+		return handler(metadata.NewIncomingContext(ctx, md), req)
+	}
+}
+
+// issue assigns a new UserID to u and sends its encoded token and a JWT back in the
+// "user" and "authorization" response headers.
+func issue(ctx context.Context, cookies Cookies, tokens Tokens, u *user) error {
+	u.UserID = uuid.New().String()
+
+	encoded, err := cookies.Encode(UserMetadataKey, u)
+	if err != nil {
+		slog.Error("can't build user token", sl.Err(err))
+		return status.Error(codes.Internal, "Auth error")
+	}
+
+	token, err := tokens.Issue(u.UserID)
+	if err != nil {
+		slog.Error("can't issue user token", sl.Err(err))
+		return status.Error(codes.Internal, "Auth error")
+	}
+
+	header := metadata.Pairs(UserMetadataKey, encoded, AuthorizationMetadataKey, bearerPrefix+token)
+	if err = grpc.SetHeader(ctx, header); err != nil {
+		slog.Error("can't send user token", sl.Err(err))
+		return status.Error(codes.Internal, "Auth error")
+	}
+
+	return nil
+}
+
+// reissue sends a credential re-signed with the current key back in the response header key.
+// A failure is only logged, as the caller is authenticated with the old credential anyway.
+func reissue(ctx context.Context, key, value string) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs(key, value)); err != nil {
+		slog.Error("can't send re-issued user token", sl.Err(err))
+	}
+}
+
+// UserID returns the UserID set by the Auth interceptor, or an empty string.
+func UserID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(UserIDMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,208 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+	"github.com/vadicheck/shorturl/internal/services/token"
+)
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/shortener.Shortener/Ping"}
+
+var oldKey = keyring.Key{
+	ID:        "old",
+	HashKey:   "very-secret",
+	BlockKey:  "alotsecretalotsecretalotsecretgr",
+	JwtSecret: "secret",
+}
+
+var cookies, tokens = newAuth(oldKey)
+
+// newAuth returns the cookie codec and the token manager over a keyring of keys.
+func newAuth(keys ...keyring.Key) (*keyring.Cookies, *token.Manager) {
+	ring, err := keyring.New(keys)
+	if err != nil {
+		panic(err)
+	}
+
+	tokens, err := token.New(token.Config{TTL: time.Hour}, ring)
+	if err != nil {
+		panic(err)
+	}
+
+	return keyring.NewCookies(ring), tokens
+}
+
+// headerCapture is a grpc.ServerTransportStream recording the headers set by the interceptor.
+type headerCapture struct {
+	header metadata.MD
+}
+
+func (h *headerCapture) Method() string { return info.FullMethod }
+
+func (h *headerCapture) SetHeader(md metadata.MD) error {
+	h.header = metadata.Join(h.header, md)
+	return nil
+}
+
+func (h *headerCapture) SendHeader(md metadata.MD) error { return h.SetHeader(md) }
+
+func (h *headerCapture) SetTrailer(metadata.MD) error { return nil }
+
+func TestAuth_ExistingUserToken(t *testing.T) {
+	userID := uuid.New().String()
+	encoded, err := cookies.Encode(UserMetadataKey, user{UserID: userID})
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		UserMetadataKey, encoded,
+		UserIDMetadataKey, "spoofed",
+	))
+
+	_, err = Auth(cookies, tokens)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		assert.Equal(t, userID, UserID(ctx))
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAuth_InvalidUserToken(t *testing.T) {
+	expired := oldKey
+	expired.NotBefore = time.Now().Add(-2 * time.Hour)
+	expired.NotAfter = time.Now().Add(-time.Hour)
+	newKey := keyring.Key{ID: "new", HashKey: "another-secret", JwtSecret: "another secret"}
+	rotatedCookies, rotatedTokens := newAuth(expired, newKey)
+
+	userID := uuid.New().String()
+	encoded, err := cookies.Encode(UserMetadataKey, user{UserID: userID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "expired key", value: encoded},
+		{name: "forged", value: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &headerCapture{}
+			ctx := grpc.NewContextWithServerTransportStream(
+				metadata.NewIncomingContext(context.Background(), metadata.Pairs(UserMetadataKey, tt.value)),
+				stream,
+			)
+
+			var got string
+			_, err := Auth(rotatedCookies, rotatedTokens)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+				got = UserID(ctx)
+				return nil, nil
+			})
+			require.NoError(t, err)
+			assert.NotEmpty(t, got)
+			assert.NotEqual(t, userID, got, "a new identity is issued")
+
+			values := stream.header.Get(UserMetadataKey)
+			require.Len(t, values, 1)
+
+			var issued user
+			_, err = rotatedCookies.Decode(UserMetadataKey, values[0], &issued)
+			require.NoError(t, err)
+			assert.Equal(t, got, issued.UserID)
+			assert.Len(t, stream.header.Get(AuthorizationMetadataKey), 1)
+		})
+	}
+}
+
+func TestAuth_BearerToken(t *testing.T) {
+	userID := uuid.New().String()
+	signed, err := tokens.Issue(userID)
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		AuthorizationMetadataKey, "Bearer "+signed,
+		UserIDMetadataKey, "spoofed",
+	))
+
+	_, err = Auth(cookies, tokens)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		assert.Equal(t, userID, UserID(ctx))
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAuth_InvalidBearerToken(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationMetadataKey, "Bearer invalid"))
+
+	_, err := Auth(cookies, tokens)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		t.Fatal("handler must not be called")
+		return nil, nil
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestUserID_NoMetadata(t *testing.T) {
+	assert.Empty(t, UserID(context.Background()))
+}
+
+func TestAuth_RotatedKey(t *testing.T) {
+	newKey := keyring.Key{ID: "new", HashKey: "another-secret", JwtSecret: "another secret", NotBefore: time.Now()}
+	rotatedCookies, rotatedTokens := newAuth(oldKey, newKey)
+
+	userID := uuid.New().String()
+
+	encoded, err := cookies.Encode(UserMetadataKey, user{UserID: userID})
+	require.NoError(t, err)
+
+	signed, err := tokens.Issue(userID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		key  string
+	}{
+		{name: "user token", md: metadata.Pairs(UserMetadataKey, encoded), key: UserMetadataKey},
+		{name: "bearer token", md: metadata.Pairs(AuthorizationMetadataKey, "Bearer "+signed), key: AuthorizationMetadataKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &headerCapture{}
+			ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), tt.md), stream)
+
+			_, err = Auth(rotatedCookies, rotatedTokens)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+				assert.Equal(t, userID, UserID(ctx), "a token of the previous key is accepted")
+				return nil, nil
+			})
+			require.NoError(t, err)
+
+			values := stream.header.Get(tt.key)
+			require.Len(t, values, 1, "the token is re-issued")
+
+			var reissued user
+			if tt.key == UserMetadataKey {
+				stale, errDecode := rotatedCookies.Decode(UserMetadataKey, values[0], &reissued)
+				require.NoError(t, errDecode)
+				assert.False(t, stale, "the re-issued token is signed with the current key")
+			} else {
+				var stale bool
+				reissued.UserID, stale, err = rotatedTokens.Parse(strings.TrimPrefix(values[0], bearerPrefix))
+				require.NoError(t, err)
+				assert.False(t, stale, "the re-issued token is signed with the current key")
+			}
+			assert.Equal(t, userID, reissued.UserID)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package interceptor
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/vadicheck/shorturl/internal/services/audit"
+)
+
+// RequestIDMetadataKey is the metadata key that carries the request ID,
+// the gRPC counterpart of the X-Request-Id header.
+const RequestIDMetadataKey = "x-request-id"
+
+// RequestID returns a unary server interceptor that stores the request ID in the context,
+// so the events a call emits can be traced back to it. The ID is taken from the
+// "x-request-id" metadata, or generated if the client has not sent one.
+func RequestID() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+
+		return handler(audit.WithRequestID(ctx, requestID), req)
+	}
+}
@@ -0,0 +1,30 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/vadicheck/shorturl/internal/services/audit"
+)
+
+func TestRequestID(t *testing.T) {
+	var requestID string
+	handler := func(ctx context.Context, _ any) (any, error) {
+		requestID = audit.RequestID(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "req-1"))
+	_, err := RequestID()(ctx, nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "req-1", requestID, "the client's request ID is kept")
+
+	_, err = RequestID()(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	assert.NoError(t, uuid.Validate(requestID), "a request ID is generated when absent")
+}
@@ -0,0 +1,392 @@
+// Package server provides the gRPC implementation of the URL shortening service.
+//
+// Every RPC mirrors one of the HTTP handlers and reuses the same urlservice.Service
+// and urlservice.URLStorage implementations.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vadicheck/shorturl/internal/config"
+	"github.com/vadicheck/shorturl/internal/grpc/interceptor"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	reqValidator "github.com/vadicheck/shorturl/internal/validator"
+	pb "github.com/vadicheck/shorturl/pkg/api/shortener"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+	"github.com/vadicheck/shorturl/pkg/validators/url"
+)
+
+// Validator combines the validators required by the gRPC server.
+type Validator interface {
+	reqValidator.CreateBatchURLValidator
+	reqValidator.DeleteURLsValidator
+}
+
+// ClickStorage retrieves the click statistics of the URLs.
+type ClickStorage interface {
+	GetClickStats(ctx context.Context, urlID int64, interval models.StatsInterval) (models.ClickStats, error)
+}
+
+// Server implements pb.ShortenerServer.
+type Server struct {
+	pb.UnimplementedShortenerServer
+
+	queue     *deletequeue.Queue
+	service   *urlservice.Service
+	storage   urlservice.URLStorage
+	stats     ClickStorage
+	validator Validator
+	unlocker  *unlock.Unlocker
+	clicks    *analytics.Pipeline
+}
+
+// New creates a new gRPC shortener server.
+//
+// Parameters:
+// - queue: The queue that deletes the URLs asynchronously.
+// - service: The URL shortening service.
+// - storage: The URL storage used for lookups and health checks.
+// - stats: The click storage used for the statistics of the URLs.
+// - validator: The validator used for batch and delete requests.
+// - unlocker: The checker of the passwords of protected URLs, shared with the HTTP API.
+// - clicks: The pipeline recording the resolves, shared with the HTTP API.
+func New(
+	queue *deletequeue.Queue,
+	service *urlservice.Service,
+	storage urlservice.URLStorage,
+	stats ClickStorage,
+	validator Validator,
+	unlocker *unlock.Unlocker,
+	clicks *analytics.Pipeline,
+) *Server {
+	return &Server{
+		queue:     queue,
+		service:   service,
+		storage:   storage,
+		stats:     stats,
+		validator: validator,
+		unlocker:  unlocker,
+		clicks:    clicks,
+	}
+}
+
+// Shorten creates a short URL for the given original URL.
+// If the URL has already been shortened, the existing short URL is returned
+// with AlreadyExists set. A taken alias is reported with codes.AlreadyExists.
+func (s *Server) Shorten(ctx context.Context, in *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	if _, err := url.IsValid(in.GetUrl()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "URL is invalid")
+	}
+
+	code, err := s.service.Create(
+		ctx,
+		in.GetUrl(),
+		interceptor.UserID(ctx),
+		urlservice.WithAlias(in.GetAlias()),
+		urlservice.WithExpiresAt(timestampTime(in.GetExpiresAt())),
+		urlservice.WithTTL(time.Duration(in.GetTtl())*time.Second),
+		urlservice.WithMaxClicks(in.GetMaxClicks()),
+		urlservice.WithPassword(in.GetPassword()),
+	)
+	if err != nil {
+		var storageErr *storage.ExistsURLError
+
+		if errors.As(err, &storageErr) {
+			return &pb.ShortenResponse{
+				Result:        config.Config.BaseURL + "/" + storageErr.ShortCode,
+				AlreadyExists: true,
+			}, nil
+		}
+
+		if errors.Is(err, urlservice.ErrInvalidAlias) ||
+			errors.Is(err, urlservice.ErrInvalidExpiration) ||
+			errors.Is(err, urlservice.ErrInvalidMaxClicks) ||
+			errors.Is(err, urlservice.ErrInvalidPassword) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, urlservice.ErrAliasTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Alias is already taken")
+		}
+
+		slog.Error("failed to create short url", sl.Err(err))
+		return nil, status.Error(codes.Internal, "Failed to create")
+	}
+
+	return &pb.ShortenResponse{Result: config.Config.BaseURL + "/" + code}, nil
+}
+
+// ShortenBatch creates short URLs for several original URLs.
+func (s *Server) ShortenBatch(
+	ctx context.Context,
+	in *pb.ShortenBatchRequest,
+) (*pb.ShortenBatchResponse, error) {
+	request := make([]shorten.CreateBatchURLRequest, 0, len(in.GetItems()))
+	for _, item := range in.GetItems() {
+		request = append(request, shorten.CreateBatchURLRequest{
+			CorrelationID: item.GetCorrelationId(),
+			OriginalURL:   item.GetOriginalUrl(),
+			Alias:         item.GetAlias(),
+			ExpiresAt:     timestampTime(item.GetExpiresAt()),
+			TTL:           item.GetTtl(),
+			MaxClicks:     item.GetMaxClicks(),
+			Password:      item.GetPassword(),
+		})
+	}
+
+	errs := s.validator.CreateBatchShortURL(&request)
+	if len(errs.Errors) != 0 {
+		return nil, status.Error(codes.InvalidArgument, errs.Error())
+	}
+
+	batchURL, err := s.service.CreateBatch(ctx, request, interceptor.UserID(ctx))
+	if err != nil {
+		if errors.Is(err, urlservice.ErrAliasTaken) {
+			return nil, status.Error(codes.AlreadyExists, "Alias is already taken")
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response := &pb.ShortenBatchResponse{}
+	for _, u := range *batchURL {
+		response.Items = append(response.Items, &pb.ShortenBatchResponse_Item{
+			CorrelationId: u.CorrelationID,
+			ShortUrl:      config.Config.BaseURL + "/" + u.ShortCode,
+		})
+	}
+
+	return response, nil
+}
+
+// Resolve returns the original URL for a short code.
+// Like a redirect, resolving a click-limited URL uses up one of its clicks;
+// IsExhausted is set once none are left. A protected URL requires its password: a missing
+// or wrong one is reported with codes.PermissionDenied, and too many wrong ones with
+// codes.ResourceExhausted. A URL disabled by an admin is reported with IsDisabled alone,
+// and a deleted or expired one without checking the password.
+// The original URL is left empty unless the URL resolves.
+func (s *Server) Resolve(ctx context.Context, in *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	if in.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is empty")
+	}
+
+	mURL, err := s.storage.GetURLByID(ctx, in.GetCode())
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get url by id. id: %s, err: %s", in.GetCode(), err))
+		return nil, status.Error(codes.Internal, "Failed to get url")
+	}
+
+	if mURL.ID == 0 {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+
+	if mURL.IsDisabled {
+		return &pb.ResolveResponse{IsDisabled: true}, nil
+	}
+
+	response := &pb.ResolveResponse{
+		IsDeleted: mURL.IsDeleted,
+		IsExpired: mURL.IsExpired(s.service.Now()),
+	}
+
+	// As with the redirect, a gone URL neither uses up unlock attempts nor accepts a password.
+	if response.IsDeleted || response.IsExpired {
+		return response, nil
+	}
+
+	if err = s.unlocker.Check(mURL, in.GetPassword()); err != nil {
+		if errors.Is(err, unlock.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if mURL.IsClickLimited() {
+		granted, err := s.storage.ConsumeClick(ctx, in.GetCode())
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to consume click. id: %s, err: %s", in.GetCode(), err))
+			return nil, status.Error(codes.Internal, "Failed to get url")
+		}
+		response.IsExhausted = !granted
+	}
+
+	// Like the redirect, the original URL is only revealed when the URL resolves.
+	if !response.IsExhausted {
+		response.OriginalUrl = mURL.URL
+		s.clicks.Record(mURL, visit(ctx))
+	}
+
+	return response, nil
+}
+
+// visit describes the caller of ctx for click recording.
+func visit(ctx context.Context) analytics.Visit {
+	var v analytics.Visit
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			v.UserAgent = ua[0]
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		v.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(v.IP); err == nil {
+			v.IP = host
+		}
+	}
+
+	return v
+}
+
+// GetUserURLs returns all URLs shortened by the caller.
+func (s *Server) GetUserURLs(
+	ctx context.Context,
+	_ *pb.GetUserURLsRequest,
+) (*pb.GetUserURLsResponse, error) {
+	userID := interceptor.UserID(ctx)
+	if userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "userID is empty")
+	}
+
+	mURLs, err := s.storage.GetUserURLs(ctx, userID)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get user urls. userID: %s, err: %s", userID, err))
+		return nil, status.Error(codes.Internal, "Failed to get urls")
+	}
+
+	response := &pb.GetUserURLsResponse{}
+	for _, u := range mURLs {
+		item := &pb.GetUserURLsResponse_Item{
+			ShortUrl:    config.Config.BaseURL + "/" + u.Code,
+			OriginalUrl: u.URL,
+			IsProtected: u.IsProtected(),
+		}
+		if !u.ExpiresAt.IsZero() {
+			item.ExpiresAt = timestamppb.New(u.ExpiresAt)
+		}
+		if u.IsClickLimited() {
+			item.RemainingClicks = proto.Int64(u.RemainingClicks())
+		}
+		response.Items = append(response.Items, item)
+	}
+
+	return response, nil
+}
+
+// GetURLStats returns the click statistics of one of the caller's short URLs.
+func (s *Server) GetURLStats(
+	ctx context.Context,
+	in *pb.GetURLStatsRequest,
+) (*pb.GetURLStatsResponse, error) {
+	userID := interceptor.UserID(ctx)
+	if userID == "" {
+		return nil, status.Error(codes.Unauthenticated, "userID is empty")
+	}
+
+	interval, err := models.ParseStatsInterval(in.GetInterval())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	mURL, err := s.storage.GetURLByID(ctx, in.GetCode())
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get url by id. id: %s, err: %s", in.GetCode(), err))
+		return nil, status.Error(codes.Internal, "Failed to get url")
+	}
+
+	if mURL.ID == 0 {
+		return nil, status.Error(codes.NotFound, "URL not found")
+	}
+
+	if mURL.UserID != userID {
+		return nil, status.Error(codes.PermissionDenied, "Forbidden")
+	}
+
+	stats, err := s.stats.GetClickStats(ctx, mURL.ID, interval)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get click stats. id: %s, err: %s", in.GetCode(), err))
+		return nil, status.Error(codes.Internal, "Failed to get stats")
+	}
+
+	response := &pb.GetURLStatsResponse{
+		ShortUrl:       config.Config.BaseURL + "/" + mURL.Code,
+		TotalClicks:    stats.Total,
+		UniqueVisitors: stats.UniqueVisitors,
+		Interval:       string(interval),
+	}
+	for _, bucket := range stats.Buckets {
+		response.Buckets = append(response.Buckets, &pb.GetURLStatsResponse_Bucket{
+			Start:  timestamppb.New(bucket.Start),
+			Clicks: bucket.Clicks,
+		})
+	}
+
+	return response, nil
+}
+
+// DeleteUserURLs accepts the caller's short codes for deletion.
+// The deletion itself is queued and runs in the background, like its HTTP counterpart.
+func (s *Server) DeleteUserURLs(
+	ctx context.Context,
+	in *pb.DeleteUserURLsRequest,
+) (*pb.DeleteUserURLsResponse, error) {
+	request := in.GetCodes()
+
+	errs := s.validator.DeleteShortURLs(&request)
+	if len(errs.Errors) != 0 {
+		return nil, status.Error(codes.InvalidArgument, errs.Error())
+	}
+
+	userID := interceptor.UserID(ctx)
+
+	if err := s.queue.Enqueue(ctx, userID, request); err != nil {
+		slog.Error("failed to enqueue URLs for deletion", sl.Err(err))
+
+		if errors.Is(err, deletequeue.ErrQueueFull) {
+			return nil, status.Error(codes.ResourceExhausted, "too many pending deletions")
+		}
+
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+
+	return &pb.DeleteUserURLsResponse{}, nil
+}
+
+// Ping checks the availability of the storage.
+func (s *Server) Ping(ctx context.Context, _ *pb.PingRequest) (*pb.PingResponse, error) {
+	pingCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if err := s.storage.PingContext(pingCtx); err != nil {
+		return nil, status.Error(codes.Unavailable, "storage is unavailable")
+	}
+
+	return &pb.PingResponse{}, nil
+}
+
+// timestampTime converts an optional protobuf timestamp, mapping an unset one to the zero time.
+func timestampTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+
+	return ts.AsTime()
+}
@@ -0,0 +1,476 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vadicheck/shorturl/internal/config"
+	"github.com/vadicheck/shorturl/internal/grpc/interceptor"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/token"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/internal/validator"
+	pb "github.com/vadicheck/shorturl/pkg/api/shortener"
+)
+
+const bufSize = 1024 * 1024
+
+func init() {
+	config.Config.BaseURL = "http://localhost:8080"
+	config.Config.SecureCookieHashKey = "very-secret"
+	config.Config.SecureCookieBlockKey = "alotsecretalotsecretalotsecretgr"
+}
+
+// newClient starts the shortener gRPC server on an in-process bufconn listener
+// and returns a client connected to it together with the underlying storage.
+// The URL service is created with opts.
+func newClient(t *testing.T, opts ...urlservice.Option) (pb.ShortenerClient, *memory.Storage) {
+	t.Helper()
+
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	})
+
+	storage, err := memory.New(tempFile.Name())
+	require.NoError(t, err)
+
+	service := urlservice.New(storage, opts...)
+
+	queue := deletequeue.New(service, deletequeue.Config{FlushInterval: time.Millisecond})
+	require.NoError(t, queue.Start())
+	t.Cleanup(func() {
+		if errShutdown := queue.Shutdown(context.Background()); errShutdown != nil {
+			log.Printf("failed to stop delete queue: %v", errShutdown)
+		}
+	})
+
+	clicks := analytics.New(storage, analytics.Config{FlushInterval: time.Millisecond})
+	clicks.Start()
+	t.Cleanup(func() {
+		if errShutdown := clicks.Shutdown(context.Background()); errShutdown != nil {
+			log.Printf("failed to stop click pipeline: %v", errShutdown)
+		}
+	})
+
+	listener := bufconn.Listen(bufSize)
+
+	ring, err := keyring.New([]keyring.Key{{ID: "test", HashKey: "very-secret", JwtSecret: "secret"}})
+	require.NoError(t, err)
+
+	tokens, err := token.New(token.Config{TTL: time.Hour}, ring)
+	require.NoError(t, err)
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor.Auth(keyring.NewCookies(ring), tokens)))
+	pb.RegisterShortenerServer(server, New(queue, service, storage, storage, validator.New(), unlock.New(3, time.Minute), clicks))
+
+	go func() {
+		if errServe := server.Serve(listener); errServe != nil {
+			log.Printf("grpc server exited: %v", errServe)
+		}
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if errClose := conn.Close(); errClose != nil {
+			log.Printf("failed to close connection: %v", errClose)
+		}
+	})
+
+	return pb.NewShortenerClient(conn), storage
+}
+
+// authorize performs a call without credentials and returns a context carrying
+// the user token issued by the server.
+func authorize(t *testing.T, client pb.ShortenerClient) context.Context {
+	t.Helper()
+
+	var header metadata.MD
+	_, err := client.Ping(context.Background(), &pb.PingRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	token := header.Get(interceptor.UserMetadataKey)
+	require.Len(t, token, 1)
+
+	return metadata.AppendToOutgoingContext(context.Background(), interceptor.UserMetadataKey, token[0])
+}
+
+func TestServer_Shorten(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	res, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://practicum.yandex.ru/"})
+	require.NoError(t, err)
+	assert.False(t, res.GetAlreadyExists())
+	assert.Contains(t, res.GetResult(), config.Config.BaseURL+"/")
+
+	again, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://practicum.yandex.ru/"})
+	require.NoError(t, err)
+	assert.True(t, again.GetAlreadyExists())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "not a url"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_ShortenAlias(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	res, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/1", Alias: "vanity"})
+	require.NoError(t, err)
+	assert.Equal(t, config.Config.BaseURL+"/vanity", res.GetResult())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/2", Alias: "vanity"})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/2", Alias: "x"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.ShortenBatch(ctx, &pb.ShortenBatchRequest{
+		Items: []*pb.ShortenBatchRequest_Item{
+			{CorrelationId: "1", OriginalUrl: "https://example.com/3", Alias: "vanity"},
+		},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestServer_ShortenExpiration(t *testing.T) {
+	client, storage := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/1", Alias: "ttl", Ttl: 3600})
+	require.NoError(t, err)
+
+	stored, err := storage.GetURLByID(context.Background(), "ttl")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+
+	res, err := client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetItems(), 1)
+	assert.True(t, stored.ExpiresAt.Equal(res.GetItems()[0].GetExpiresAt().AsTime()))
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{
+		Url:       "https://example.com/2",
+		ExpiresAt: timestamppb.New(time.Now().Add(-time.Hour)),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = storage.SaveURL(context.Background(), "expired", "https://example.com/3", "user",
+		repository.URLSettings{ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "expired"})
+	require.NoError(t, err)
+	assert.True(t, resolved.GetIsExpired())
+	assert.Empty(t, resolved.GetOriginalUrl())
+}
+
+func TestServer_ResolveExpiredByClock(t *testing.T) {
+	now := time.Now().Add(2 * time.Hour)
+	client, storage := newClient(t, urlservice.WithClock(func() time.Time { return now }))
+	ctx := authorize(t, client)
+
+	_, err := storage.SaveURL(context.Background(), "ttl", "https://example.com/1", "user",
+		repository.URLSettings{ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "ttl"})
+	require.NoError(t, err)
+	assert.True(t, resolved.GetIsExpired(), "the expiration is checked against the service clock")
+}
+
+func TestServer_ShortenMaxClicks(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/1", Alias: "twice", MaxClicks: 2})
+	require.NoError(t, err)
+
+	for _, exhausted := range []bool{false, false, true} {
+		resolved, errResolve := client.Resolve(ctx, &pb.ResolveRequest{Code: "twice"})
+		require.NoError(t, errResolve)
+		assert.Equal(t, exhausted, resolved.GetIsExhausted())
+		if exhausted {
+			assert.Empty(t, resolved.GetOriginalUrl(), "an exhausted link does not reveal the original URL")
+		} else {
+			assert.Equal(t, "https://example.com/1", resolved.GetOriginalUrl())
+		}
+	}
+
+	res, err := client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetItems(), 1)
+	require.NotNil(t, res.GetItems()[0].RemainingClicks)
+	assert.Equal(t, int64(0), res.GetItems()[0].GetRemainingClicks())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/2", MaxClicks: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_ResolveProtected(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com/1", Alias: "secret", Password: "hunter2"})
+	require.NoError(t, err)
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Code: "secret"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "secret", Password: "hunter2"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", resolved.GetOriginalUrl())
+
+	for range 3 {
+		_, err = client.Resolve(ctx, &pb.ResolveRequest{Code: "secret", Password: "wrong"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Code: "secret", Password: "hunter2"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	res, err := client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetItems(), 1)
+	assert.True(t, res.GetItems()[0].GetIsProtected())
+}
+
+func TestServer_ResolveProtectedGone(t *testing.T) {
+	client, storage := newClient(t)
+	ctx := authorize(t, client)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(context.Background(), "expired", "https://example.com/1", "user",
+		repository.URLSettings{PasswordHash: string(hash), ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	// A gone link does not use up unlock attempts, so it is never locked.
+	for range 4 {
+		resolved, errResolve := client.Resolve(ctx, &pb.ResolveRequest{Code: "expired", Password: "wrong"})
+		require.NoError(t, errResolve)
+		assert.True(t, resolved.GetIsExpired())
+	}
+
+	// The right password does not reveal the original URL of a gone link either.
+	resolved, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "expired", Password: "hunter2"})
+	require.NoError(t, err)
+	assert.True(t, resolved.GetIsExpired())
+	assert.Empty(t, resolved.GetOriginalUrl())
+}
+
+func TestServer_ShortenBatch(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	res, err := client.ShortenBatch(ctx, &pb.ShortenBatchRequest{
+		Items: []*pb.ShortenBatchRequest_Item{
+			{CorrelationId: "1", OriginalUrl: "https://example.com"},
+			{CorrelationId: "2", OriginalUrl: "https://google.com"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.GetItems(), 2)
+	assert.Equal(t, "1", res.GetItems()[0].GetCorrelationId())
+	assert.Equal(t, "2", res.GetItems()[1].GetCorrelationId())
+
+	_, err = client.ShortenBatch(ctx, &pb.ShortenBatchRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Resolve(t *testing.T) {
+	client, storage := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := storage.SaveURL(context.Background(), "code", "https://practicum.yandex.ru/", "user", repository.URLSettings{})
+	require.NoError(t, err)
+
+	res, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "code"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.yandex.ru/", res.GetOriginalUrl())
+	assert.False(t, res.GetIsDeleted())
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{Code: "nonexistent"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Resolve(ctx, &pb.ResolveRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_ResolveDisabled(t *testing.T) {
+	client, storage := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := storage.SaveURL(context.Background(), "abuse", "https://example.com/", "user", repository.URLSettings{})
+	require.NoError(t, err)
+
+	_, err = storage.SetURLDisabled(context.Background(), "abuse", true)
+	require.NoError(t, err)
+
+	res, err := client.Resolve(ctx, &pb.ResolveRequest{Code: "abuse"})
+	require.NoError(t, err)
+	assert.True(t, res.GetIsDisabled())
+	assert.Empty(t, res.GetOriginalUrl(), "the original URL of a disabled link is not revealed")
+}
+
+func TestServer_GetUserURLs(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	res, err := client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, res.GetItems())
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"})
+	require.NoError(t, err)
+
+	res, err = client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetItems(), 1)
+	assert.Equal(t, "https://example.com", res.GetItems()[0].GetOriginalUrl())
+
+	other := authorize(t, client)
+
+	res, err = client.GetUserURLs(other, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, res.GetItems())
+}
+
+func TestServer_GetURLStats(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := authorize(t, client)
+
+	_, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com", Alias: "stats"})
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err = client.Resolve(ctx, &pb.ResolveRequest{Code: "stats"})
+		require.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		res, errStats := client.GetURLStats(ctx, &pb.GetURLStatsRequest{Code: "stats"})
+		return errStats == nil && res.GetTotalClicks() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	res, err := client.GetURLStats(ctx, &pb.GetURLStatsRequest{Code: "stats", Interval: "hour"})
+	require.NoError(t, err)
+	assert.Equal(t, "hour", res.GetInterval())
+	assert.Equal(t, int64(1), res.GetUniqueVisitors())
+	require.NotEmpty(t, res.GetBuckets())
+
+	_, err = client.GetURLStats(ctx, &pb.GetURLStatsRequest{Code: "stats", Interval: "week"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetURLStats(ctx, &pb.GetURLStatsRequest{Code: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetURLStats(authorize(t, client), &pb.GetURLStatsRequest{Code: "stats"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestServer_DeleteUserURLs(t *testing.T) {
+	client, storage := newClient(t)
+	ctx := authorize(t, client)
+
+	shortened, err := client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"})
+	require.NoError(t, err)
+
+	code := shortened.GetResult()[len(config.Config.BaseURL)+1:]
+
+	_, err = client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{Codes: []string{code}})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		res, errResolve := client.Resolve(ctx, &pb.ResolveRequest{Code: code})
+		return errResolve == nil && res.GetIsDeleted()
+	}, time.Second, 10*time.Millisecond)
+
+	mURL, err := storage.GetURLByID(context.Background(), code)
+	require.NoError(t, err)
+	assert.True(t, mURL.IsDeleted)
+
+	_, err = client.DeleteUserURLs(ctx, &pb.DeleteUserURLsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Ping(t *testing.T) {
+	client, _ := newClient(t)
+
+	_, err := client.Ping(context.Background(), &pb.PingRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_Unauthenticated(t *testing.T) {
+	client, _ := newClient(t)
+
+	// A forged "user" value gets a new identity like a missing one, but a forged bearer token is rejected.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), interceptor.AuthorizationMetadataKey, "Bearer invalid")
+
+	_, err := client.Ping(ctx, &pb.PingRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var header metadata.MD
+	ctx = metadata.AppendToOutgoingContext(context.Background(), interceptor.UserMetadataKey, "invalid")
+	_, err = client.Ping(ctx, &pb.PingRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Len(t, header.Get(interceptor.UserMetadataKey), 1)
+}
+
+func TestServer_BearerToken(t *testing.T) {
+	client, _ := newClient(t)
+
+	var header metadata.MD
+	_, err := client.Ping(context.Background(), &pb.PingRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	authorization := header.Get(interceptor.AuthorizationMetadataKey)
+	require.Len(t, authorization, 1)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), interceptor.AuthorizationMetadataKey, authorization[0])
+
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://example.com"})
+	require.NoError(t, err)
+
+	res, err := client.GetUserURLs(ctx, &pb.GetUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, res.GetItems(), 1, "the token keeps the identity across calls")
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), interceptor.AuthorizationMetadataKey, "Bearer invalid")
+
+	_, err = client.Ping(ctx, &pb.PingRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
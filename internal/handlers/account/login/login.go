@@ -0,0 +1,75 @@
+// Package login provides a handler for logging in to a named account.
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// Authenticator logs in to accounts.
+type Authenticator interface {
+	Login(ctx context.Context, login, password, currentUserID string) (models.User, error)
+}
+
+// Sessions issues the cookie and token identifying a user.
+type Sessions interface {
+	Issue(w http.ResponseWriter, userID string) error
+}
+
+// New creates a new handler function that logs in to an account.
+//
+// It reads a JSON body with the login and password of the account. If the request comes with
+// an anonymous identity, its URLs are moved over to the account. The response sets the "user"
+// cookie and the `Authorization` header of the account, so the client acts as the account
+// from then on. Unknown logins and wrong passwords are rejected with 401 Unauthorized alike.
+//
+// Parameters:
+// - accounts: The account service used to verify the credentials.
+// - sessions: The issuer of the cookie and token of the account.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the account.
+func New(accounts Authenticator, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request shorten.CredentialsRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		user, err := accounts.Login(r.Context(), request.Login, request.Password, r.Header.Get(string(constants.XUserID)))
+		if errors.Is(err, account.ErrInvalidCredentials) {
+			httpError.RespondWithError(w, http.StatusUnauthorized, "Invalid login or password")
+			return
+		}
+		if err != nil {
+			slog.Error("failed to log in", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to log in")
+			return
+		}
+
+		if err = sessions.Issue(w, user.ID); err != nil {
+			slog.Error("failed to issue account session", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(shorten.AccountResponse{UserID: user.ID, Login: user.Login}); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const anonymous = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+
+// mockSessions records the users sessions are issued for.
+type mockSessions struct {
+	issued []string
+}
+
+func (m *mockSessions) Issue(w http.ResponseWriter, userID string) error {
+	m.issued = append(m.issued, userID)
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	accounts := account.New(storage)
+	alice, err := accounts.Register(ctx, "alice", "correct horse", "")
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "practicum", "https://practicum.yandex.ru/", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+
+	sessions := &mockSessions{}
+	handler := New(accounts, sessions)
+
+	login := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+		req.Header.Set(string(constants.XUserID), anonymous)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, login(`{"login":"alice","password":"wrong password"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, login(`{"login":"bob","password":"correct horse"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, login(`{`).Code)
+	assert.Empty(t, sessions.issued)
+
+	urls, err := storage.GetUserURLs(ctx, anonymous)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "a failed login claims nothing")
+
+	rec := login(`{"login":"Alice","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response shorten.AccountResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, shorten.AccountResponse{UserID: alice.ID, Login: "alice"}, response)
+	assert.Equal(t, []string{alice.ID}, sessions.issued)
+
+	urls, err = storage.GetUserURLs(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "the anonymous identity is claimed")
+}
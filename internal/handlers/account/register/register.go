@@ -0,0 +1,79 @@
+// Package register provides a handler for registering a named account.
+package register
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// Registrar registers accounts.
+type Registrar interface {
+	Register(ctx context.Context, login, password, currentUserID string) (models.User, error)
+}
+
+// Sessions issues the cookie and token identifying a user.
+type Sessions interface {
+	Issue(w http.ResponseWriter, userID string) error
+}
+
+// New creates a new handler function that registers an account.
+//
+// It reads a JSON body with the login and password of the account. The URLs of the anonymous
+// identity the request comes with are moved over to the new account, and the client is logged
+// in: the response sets the "user" cookie and the `Authorization` header of the account, and
+// responds with 201 Created. A malformed login or password is rejected with a bad request status,
+// and a login that is taken with 409 Conflict.
+//
+// Parameters:
+// - accounts: The account service used to register the account.
+// - sessions: The issuer of the cookie and token of the account.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the account.
+func New(accounts Registrar, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request shorten.CredentialsRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		user, err := accounts.Register(r.Context(), request.Login, request.Password, r.Header.Get(string(constants.XUserID)))
+		switch {
+		case errors.Is(err, account.ErrInvalidParams):
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, account.ErrLoginTaken):
+			httpError.RespondWithError(w, http.StatusConflict, "Login is taken")
+			return
+		case err != nil:
+			slog.Error("failed to register account", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to register")
+			return
+		}
+
+		if err = sessions.Issue(w, user.ID); err != nil {
+			slog.Error("failed to issue account session", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		if err = json.NewEncoder(w).Encode(shorten.AccountResponse{UserID: user.ID, Login: user.Login}); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
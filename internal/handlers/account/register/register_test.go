@@ -0,0 +1,73 @@
+package register
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const anonymous = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+
+// mockSessions records the users sessions are issued for.
+type mockSessions struct {
+	issued []string
+}
+
+func (m *mockSessions) Issue(w http.ResponseWriter, userID string) error {
+	m.issued = append(m.issued, userID)
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "practicum", "https://practicum.yandex.ru/", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+
+	sessions := &mockSessions{}
+	handler := New(account.New(storage), sessions)
+
+	register := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(body))
+		req.Header.Set(string(constants.XUserID), anonymous)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := register(`{"login":"alice","password":"correct horse"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var response shorten.AccountResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, "alice", response.Login)
+	assert.Equal(t, []string{response.UserID}, sessions.issued, "the client is logged in")
+
+	urls, err := storage.GetUserURLs(ctx, response.UserID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "the anonymous identity is claimed")
+
+	assert.Equal(t, http.StatusConflict, register(`{"login":"alice","password":"another password"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, register(`{"login":"bob","password":"short"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, register(`{`).Code)
+	assert.Len(t, sessions.issued, 1)
+}
@@ -0,0 +1,66 @@
+// Package audit provides a handler for reading the audit trail of the admin actions.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// AuditReader reads the audit trail.
+type AuditReader interface {
+	Audit(ctx context.Context, limit int) ([]models.AuditEntry, error)
+}
+
+// New creates a new handler function that returns the latest entries of the audit trail,
+// newest first, as a JSON array. The number of entries is set with the limit query parameter.
+//
+// Parameters:
+// - trail: The admin service used to read the audit trail.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the entries.
+func New(trail AuditReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var limit int
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				httpError.RespondWithError(w, http.StatusBadRequest, "limit is invalid")
+				return
+			}
+			limit = parsed
+		}
+
+		entries, err := trail.Audit(r.Context(), limit)
+		if errors.Is(err, admin.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to read audit trail", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get audit trail")
+			return
+		}
+
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(entries); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	handler := New(admin.New(storage))
+
+	call := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit"+query, nil)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := call("")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+
+	for _, target := range []string{"code1", "code2"} {
+		require.NoError(t, storage.SaveAuditEntry(ctx, models.AuditEntry{
+			ActorID: "admin",
+			Action:  models.AuditDisableURL,
+			Target:  target,
+		}))
+	}
+
+	rec = call("?limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var entries []models.AuditEntry
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "code2", entries[0].Target)
+
+	assert.Equal(t, http.StatusBadRequest, call("?limit=many").Code)
+	assert.Equal(t, http.StatusBadRequest, call("?limit=-1").Code)
+}
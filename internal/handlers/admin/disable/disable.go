@@ -0,0 +1,49 @@
+// Package disable provides a handler for disabling and re-enabling a URL of any user.
+package disable
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// URLDisabler disables and re-enables URLs.
+type URLDisabler interface {
+	SetURLDisabled(ctx context.Context, actorID, code string, disabled bool) error
+}
+
+// New creates a new handler function that disables the URL with the code in the path,
+// or re-enables it if disabled is false.
+//
+// A disabled URL responds with 410 Gone without revealing its original URL, but stays
+// owned by its user. It responds with 204 No Content once done, and with 404 Not Found
+// for an unknown code. The action is recorded in the audit trail.
+//
+// Parameters:
+// - urls: The admin service used to disable the URL.
+// - disabled: Whether the handler disables or re-enables the URL.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(urls URLDisabler, disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := urls.SetURLDisabled(r.Context(), r.Header.Get(string(constants.XUserID)), r.PathValue("code"), disabled)
+		if errors.Is(err, admin.ErrNotFound) {
+			httpError.RespondWithError(w, http.StatusNotFound, "URL not found")
+			return
+		}
+		if err != nil {
+			slog.Error("failed to disable url", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to update url")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
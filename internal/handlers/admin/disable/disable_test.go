@@ -0,0 +1,63 @@
+package disable
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const adminID = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "code", "http://example.com", "user", repository.URLSettings{})
+	require.NoError(t, err)
+
+	service := admin.New(storage)
+
+	call := func(handler http.HandlerFunc, code string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/urls/"+code+"/disable", nil)
+		req.SetPathValue("code", code)
+		req.Header.Set(string(constants.XUserID), adminID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, call(New(service, true), "missing"))
+
+	assert.Equal(t, http.StatusNoContent, call(New(service, true), "code"))
+	url, err := storage.GetURLByID(ctx, "code")
+	require.NoError(t, err)
+	assert.True(t, url.IsDisabled)
+
+	assert.Equal(t, http.StatusNoContent, call(New(service, false), "code"))
+	url, err = storage.GetURLByID(ctx, "code")
+	require.NoError(t, err)
+	assert.False(t, url.IsDisabled)
+
+	entries, err := storage.GetAuditEntries(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.AuditEnableURL, entries[0].Action)
+	assert.Equal(t, models.AuditDisableURL, entries[1].Action)
+	assert.Equal(t, adminID, entries[1].ActorID)
+}
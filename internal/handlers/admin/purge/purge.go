@@ -0,0 +1,55 @@
+// Package purge provides a handler for removing all URLs of a user.
+package purge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// UserPurger removes the URLs of a user.
+type UserPurger interface {
+	PurgeUser(ctx context.Context, actorID, userID string) (int64, error)
+}
+
+// New creates a new handler function that removes all URLs of the user with the ID in the path.
+//
+// Unlike a soft delete, the URLs are gone for good and their codes and original URLs become
+// free again. It responds with the number of URLs removed. The action is recorded in the
+// audit trail.
+//
+// Parameters:
+// - users: The admin service used to remove the URLs.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(users UserPurger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		purged, err := users.PurgeUser(r.Context(), r.Header.Get(string(constants.XUserID)), r.PathValue("id"))
+		if errors.Is(err, admin.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to purge user", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to purge urls")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(shorten.AffectedURLsResponse{URLs: purged}); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package purge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	adminID = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userID  = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "code1", "http://example.com/1", userID, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "code2", "http://example.com/2", userID, repository.URLSettings{})
+	require.NoError(t, err)
+
+	handler := New(admin.New(storage))
+
+	call := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/"+id+"/urls", nil)
+		req.SetPathValue("id", id)
+		req.Header.Set(string(constants.XUserID), adminID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call("unknown").Code)
+
+	rec := call(userID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response shorten.AffectedURLsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, int64(2), response.URLs)
+
+	urls, err := storage.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
@@ -0,0 +1,63 @@
+// Package reassign provides a handler for moving the URLs of a user to another user.
+package reassign
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// UserReassigner moves the URLs of a user to another user.
+type UserReassigner interface {
+	ReassignUser(ctx context.Context, actorID, fromUserID, toUserID string) (int64, error)
+}
+
+// New creates a new handler function that moves the URLs and API keys of the user with
+// the ID in the path to the user in the JSON body.
+//
+// It responds with the number of URLs moved, including soft-deleted ones. The action is
+// recorded in the audit trail.
+//
+// Parameters:
+// - users: The admin service used to move the URLs.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(users UserReassigner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request shorten.ReassignUserRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		moved, err := users.ReassignUser(
+			r.Context(), r.Header.Get(string(constants.XUserID)), r.PathValue("id"), request.ToUserID,
+		)
+		if errors.Is(err, admin.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to reassign user", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to reassign urls")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(shorten.AffectedURLsResponse{URLs: moved}); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
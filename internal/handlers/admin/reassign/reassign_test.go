@@ -0,0 +1,65 @@
+package reassign
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	adminID = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userOne = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+	userTwo = "da2da41c-8f69-4ed3-abea-d58f57c41410"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "code", "http://example.com", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+
+	handler := New(admin.New(storage))
+
+	call := func(from, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+from+"/reassign", strings.NewReader(body))
+		req.SetPathValue("id", from)
+		req.Header.Set(string(constants.XUserID), adminID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call(userOne, "{").Code)
+	assert.Equal(t, http.StatusBadRequest, call(userOne, `{"to_user_id":"`+userOne+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, call("unknown", `{"to_user_id":"`+userTwo+`"}`).Code)
+
+	rec := call(userOne, `{"to_user_id":"`+userTwo+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response shorten.AffectedURLsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	assert.Equal(t, int64(1), response.URLs)
+
+	url, err := storage.GetURLByID(ctx, "code")
+	require.NoError(t, err)
+	assert.Equal(t, userTwo, url.UserID)
+}
@@ -0,0 +1,59 @@
+// Package role provides a handler for changing the role of an account.
+package role
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// RoleSetter changes the roles of accounts.
+type RoleSetter interface {
+	SetRole(ctx context.Context, actorID, userID, role string) error
+}
+
+// New creates a new handler function that changes the role of the account with the ID
+// in the path to the role in the JSON body.
+//
+// It responds with 204 No Content once done, and with 404 Not Found if there is no such
+// account. An admin cannot demote themself. The action is recorded in the audit trail.
+//
+// Parameters:
+// - roles: The admin service used to change the role.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(roles RoleSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request shorten.SetRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		err := roles.SetRole(r.Context(), r.Header.Get(string(constants.XUserID)), r.PathValue("id"), request.Role)
+		if errors.Is(err, admin.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, admin.ErrNotFound) {
+			httpError.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			slog.Error("failed to set role", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to set role")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
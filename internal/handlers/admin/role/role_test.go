@@ -0,0 +1,57 @@
+package role
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	adminID = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userID  = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+	unknown = "da2da41c-8f69-4ed3-abea-d58f57c41410"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.CreateUser(ctx, models.User{ID: userID, Login: "alice", Role: models.RoleUser}))
+
+	handler := New(admin.New(storage))
+
+	call := func(id, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/"+id+"/role", strings.NewReader(body))
+		req.SetPathValue("id", id)
+		req.Header.Set(string(constants.XUserID), adminID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, call(userID, "{"))
+	assert.Equal(t, http.StatusBadRequest, call(userID, `{"role":"root"}`))
+	assert.Equal(t, http.StatusBadRequest, call(adminID, `{"role":"user"}`), "an admin cannot demote themself")
+	assert.Equal(t, http.StatusNotFound, call(unknown, `{"role":"admin"}`))
+	assert.Equal(t, http.StatusNoContent, call(userID, `{"role":"admin"}`))
+
+	user, err := storage.GetUserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, user.IsAdmin())
+}
@@ -0,0 +1,101 @@
+// Package urls provides a handler for looking up the URLs of all users.
+package urls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/vadicheck/shorturl/internal/config"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// URLFinder looks up the URLs of all users.
+type URLFinder interface {
+	FindURLs(ctx context.Context, filter models.URLFilter) ([]models.URL, error)
+}
+
+// New creates a new handler function that looks up the URLs of all users.
+//
+// The URLs are filtered by the query parameters code, url (the original URL), user_id,
+// deleted and disabled ("true" or "false"), and paged with limit and offset in the order
+// they were created. It responds with a JSON array, which is empty if nothing matches.
+//
+// Parameters:
+// - finder: The admin service used to look up the URLs.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the URLs.
+func New(finder URLFinder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseFilter(r.URL.Query())
+		if err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		found, err := finder.FindURLs(r.Context(), filter)
+		if errors.Is(err, admin.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to find urls", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get urls")
+			return
+		}
+
+		response := make([]shorten.AdminURLResponse, 0, len(found))
+		for _, u := range found {
+			response = append(response, shorten.NewAdminURLResponse(u, config.Config.BaseURL))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
+
+// parseFilter reads a URL filter from the query parameters.
+func parseFilter(query url.Values) (models.URLFilter, error) {
+	filter := models.URLFilter{
+		Code:   query.Get("code"),
+		URL:    query.Get("url"),
+		UserID: query.Get("user_id"),
+	}
+
+	for name, dst := range map[string]**bool{"deleted": &filter.Deleted, "disabled": &filter.Disabled} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return models.URLFilter{}, fmt.Errorf("%s is invalid", name)
+			}
+			*dst = &parsed
+		}
+	}
+
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return models.URLFilter{}, fmt.Errorf("%s is invalid", name)
+			}
+			*dst = parsed
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,83 @@
+package urls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "code1", "http://example.com/1", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "code2", "http://example.com/2", userTwo, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "code3", "http://example.com/3", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SetURLDisabled(ctx, "code3", true)
+	require.NoError(t, err)
+
+	handler := New(admin.New(storage))
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		codes  []string
+	}{
+		{name: "all", query: "", status: http.StatusOK, codes: []string{"code1", "code2", "code3"}},
+		{name: "by code", query: "?code=code2", status: http.StatusOK, codes: []string{"code2"}},
+		{name: "by url", query: "?url=http://example.com/1", status: http.StatusOK, codes: []string{"code1"}},
+		{name: "by user", query: "?user_id=" + userOne, status: http.StatusOK, codes: []string{"code1", "code3"}},
+		{name: "disabled", query: "?disabled=true", status: http.StatusOK, codes: []string{"code3"}},
+		{name: "page", query: "?limit=1&offset=1", status: http.StatusOK, codes: []string{"code2"}},
+		{name: "no match", query: "?code=missing", status: http.StatusOK, codes: []string{}},
+		{name: "bad flag", query: "?deleted=maybe", status: http.StatusBadRequest},
+		{name: "bad limit", query: "?limit=many", status: http.StatusBadRequest},
+		{name: "limit too large", query: "?limit=100000", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/urls"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var response []shorten.AdminURLResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+
+			codes := make([]string, 0, len(response))
+			for _, u := range response {
+				codes = append(codes, u.Code)
+			}
+			assert.Equal(t, tt.codes, codes)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package create provides a handler for creating an API key of the user.
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// KeyCreator issues API keys.
+type KeyCreator interface {
+	Create(ctx context.Context, userID string, name string, scopes []models.APIKeyScope) (models.APIKey, string, error)
+}
+
+// New creates a new handler function that creates an API key for the user.
+//
+// It reads a JSON body with an optional name and the scopes of the key, "read", "write"
+// and/or "delete", and responds with 201 Created and the key. The key itself is part of
+// this response only: it is stored hashed and cannot be shown again. Missing or unknown
+// scopes and a name that is too long are rejected with a bad request status.
+//
+// Parameters:
+// - keys: The API key service used to issue the key.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the new key.
+func New(keys KeyCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		var request shorten.CreateAPIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		scopes := make([]models.APIKeyScope, 0, len(request.Scopes))
+		for _, scope := range request.Scopes {
+			scopes = append(scopes, models.APIKeyScope(scope))
+		}
+
+		key, secret, err := keys.Create(r.Context(), userID, request.Name, scopes)
+		if errors.Is(err, apikey.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to create api key", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to create key")
+			return
+		}
+
+		response := shorten.NewAPIKeyResponse(key)
+		response.Key = secret
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+
+func TestNew(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	keys := apikey.New(storage)
+	handler := New(keys)
+
+	tests := []struct {
+		name   string
+		userID string
+		body   string
+		status int
+	}{
+		{name: "created", userID: userOne, body: `{"name":"backend","scopes":["read","write"]}`, status: http.StatusCreated},
+		{name: "no scopes", userID: userOne, body: `{"name":"backend"}`, status: http.StatusBadRequest},
+		{name: "unknown scope", userID: userOne, body: `{"scopes":["admin"]}`, status: http.StatusBadRequest},
+		{name: "invalid json", userID: userOne, body: `{`, status: http.StatusBadRequest},
+		{name: "no user", body: `{"scopes":["read"]}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/keys", strings.NewReader(tt.body))
+			req.Header.Set(string(constants.XUserID), tt.userID)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			var response shorten.APIKeyResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			assert.Equal(t, "backend", response.Name)
+			assert.Equal(t, []string{"read", "write"}, response.Scopes)
+			assert.True(t, strings.HasPrefix(response.Key, response.Prefix))
+
+			key, err := keys.Authenticate(context.Background(), response.Key)
+			require.NoError(t, err, "the returned key authenticates")
+			assert.Equal(t, userOne, key.UserID)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Package list provides a handler for listing the API keys of the user.
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// KeyLister lists the API keys of a user.
+type KeyLister interface {
+	List(ctx context.Context, userID string) ([]models.APIKey, error)
+}
+
+// New creates a new handler function that lists the active API keys of the user, oldest first.
+//
+// The keys themselves are never returned, only their prefixes. If the user has no active
+// keys, it responds with 204 No Content.
+//
+// Parameters:
+// - keys: The API key service used to list the keys.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the keys.
+func New(keys KeyLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		userKeys, err := keys.List(r.Context(), userID)
+		if err != nil {
+			slog.Error("failed to list api keys", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get keys")
+			return
+		}
+
+		if len(userKeys) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := make([]shorten.APIKeyResponse, 0, len(userKeys))
+		for _, key := range userKeys {
+			response = append(response, shorten.NewAPIKeyResponse(key))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
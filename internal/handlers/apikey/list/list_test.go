@@ -0,0 +1,60 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	keys := apikey.New(storage)
+	created, secret, err := keys.Create(context.Background(), userOne, "backend", []models.APIKeyScope{models.ScopeRead})
+	require.NoError(t, err)
+
+	handler := New(keys)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/keys", nil)
+	req.Header.Set(string(constants.XUserID), userOne)
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), secret, "the key itself is never listed")
+
+	var response []shorten.APIKeyResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	require.Len(t, response, 1)
+	assert.Equal(t, created.ID, response[0].ID)
+	assert.Equal(t, created.Prefix, response[0].Prefix)
+	assert.Equal(t, []string{"read"}, response[0].Scopes)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/keys", nil)
+	req.Header.Set(string(constants.XUserID), userTwo)
+	rec = httptest.NewRecorder()
+
+	handler(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
@@ -0,0 +1,55 @@
+// Package revoke provides a handler for revoking an API key of the user.
+package revoke
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// KeyRevoker revokes API keys.
+type KeyRevoker interface {
+	Revoke(ctx context.Context, userID string, id string) error
+}
+
+// New creates a new handler function that revokes the API key with the ID in the path.
+//
+// It responds with 204 No Content once the key is revoked; requests with the key are
+// rejected from then on. A key that is unknown, already revoked or owned by another
+// user responds with 404 Not Found.
+//
+// Parameters:
+// - keys: The API key service used to revoke the key.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(keys KeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		err := keys.Revoke(r.Context(), userID, r.PathValue("id"))
+		if errors.Is(err, apikey.ErrNotFound) {
+			httpError.RespondWithError(w, http.StatusNotFound, "Key not found")
+			return
+		}
+		if err != nil {
+			slog.Error("failed to revoke api key", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke key")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,55 @@
+package revoke
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	keys := apikey.New(storage)
+	created, secret, err := keys.Create(ctx, userOne, "", []models.APIKeyScope{models.ScopeRead})
+	require.NoError(t, err)
+
+	handler := New(keys)
+
+	revoke := func(userID, id string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+id, nil)
+		req.SetPathValue("id", id)
+		req.Header.Set(string(constants.XUserID), userID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, revoke(userTwo, created.ID), "a key of another user cannot be revoked")
+	assert.Equal(t, http.StatusNotFound, revoke(userOne, "unknown"))
+	assert.Equal(t, http.StatusNoContent, revoke(userOne, created.ID))
+	assert.Equal(t, http.StatusNotFound, revoke(userOne, created.ID), "a key is revoked only once")
+
+	_, err = keys.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+}
@@ -2,8 +2,8 @@
 package batch
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -20,16 +20,16 @@ import (
 //
 // It reads a JSON body containing a batch of URL shortening requests, validates the data,
 // creates shortened URLs for each request, and returns the results in JSON format.
+// Items may carry a custom alias; if any alias is already taken, the whole batch is
+// rejected with a conflict status.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - service: The URL shortening service used to generate the short URLs.
 // - validator: The validator used to validate the batch URL requests.
 //
 // Returns:
 // - A handler function that processes HTTP requests for batch URL shortening.
 func New(
-	ctx context.Context,
 	service *urlservice.Service,
 	validator reqValidator.CreateBatchURLValidator,
 ) http.HandlerFunc {
@@ -48,8 +48,13 @@ func New(
 			return
 		}
 
-		batchURL, err := service.CreateBatch(ctx, request, r.Header.Get(string(constants.XUserID)))
+		batchURL, err := service.CreateBatch(r.Context(), request, r.Header.Get(string(constants.XUserID)))
 		if err != nil {
+			if errors.Is(err, urlservice.ErrAliasTaken) {
+				httpError.RespondWithError(w, http.StatusConflict, "Alias is already taken")
+				return
+			}
+
 			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -1,7 +1,6 @@
 package batch
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -11,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -53,6 +53,50 @@ func TestNew(t *testing.T) {
 				{CorrelationID: "2", OriginalURL: "https://google.com"},
 			},
 		},
+		{
+			name: "Alias",
+			want: want{
+				statusCode:  http.StatusCreated,
+				contentType: "application/json",
+			},
+			err: nil,
+			request: []shorten.CreateBatchURLRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com/alias", Alias: "batch-alias"},
+			},
+		},
+		{
+			name: "Alias taken",
+			want: want{
+				statusCode:  http.StatusConflict,
+				contentType: "application/json",
+			},
+			err: nil,
+			request: []shorten.CreateBatchURLRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com/other", Alias: "batch-alias"},
+			},
+		},
+		{
+			name: "Invalid alias",
+			want: want{
+				statusCode:  http.StatusBadRequest,
+				contentType: "application/json",
+			},
+			err: nil,
+			request: []shorten.CreateBatchURLRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com/invalid", Alias: "ping"},
+			},
+		},
+		{
+			name: "Invalid expiration",
+			want: want{
+				statusCode:  http.StatusBadRequest,
+				contentType: "application/json",
+			},
+			err: nil,
+			request: []shorten.CreateBatchURLRequest{
+				{CorrelationID: "1", OriginalURL: "https://example.com/expired", ExpiresAt: time.Now().Add(-time.Hour)},
+			},
+		},
 		{
 			name: "Validation error",
 			want: want{
@@ -73,7 +117,6 @@ func TestNew(t *testing.T) {
 		},
 	}
 
-	ctx := context.Background()
 	tempFile, err := os.CreateTemp("", "tempfile-*.json")
 	require.NoError(t, err)
 	defer func() {
@@ -104,7 +147,7 @@ func TestNew(t *testing.T) {
 			req.Header.Set(string(constants.XUserID), uuid.New().String())
 
 			w := httptest.NewRecorder()
-			handler := New(ctx, service, &validator.Validator{})
+			handler := New(service, &validator.Validator{})
 			handler(w, req)
 
 			result := w.Result()
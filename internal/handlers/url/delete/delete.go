@@ -4,33 +4,38 @@ package delete
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/vadicheck/shorturl/internal/constants"
 	httpError "github.com/vadicheck/shorturl/internal/http/error"
-	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
 	delValidator "github.com/vadicheck/shorturl/internal/validator"
 	"github.com/vadicheck/shorturl/pkg/logger/sl"
 )
 
+// Queue accepts deletion requests that are processed asynchronously.
+type Queue interface {
+	Enqueue(ctx context.Context, userID string, codes []string) error
+}
+
 // New creates a new handler function for processing URL deletion requests.
 //
 // It reads a JSON body containing a list of URLs to be deleted, validates the data,
-// and then asynchronously deletes the URLs. The response is returned immediately
-// with a 202 Accepted status, and the deletion process continues in the background.
+// and then puts the request into the deletion queue. The response is returned immediately
+// with a 202 Accepted status, and the deletion is processed in the background.
+// If the queue is full or shutting down, the handler responds with 503 Service Unavailable.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
-// - service: The URL service used to delete the URLs.
+// - queue: The deletion queue.
 // - validator: The validator used to validate the delete request data.
 //
 // Returns:
 // - A handler function that processes HTTP requests for URL deletion.
 func New(
-	ctx context.Context,
-	service *urlservice.Service,
+	queue Queue,
 	validator delValidator.DeleteURLsValidator,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -50,15 +55,19 @@ func New(
 			return
 		}
 
-		closeCh := make(chan string)
+		userID := r.Header.Get(string(constants.XUserID))
 
-		go func() {
-			defer close(closeCh)
+		if err := queue.Enqueue(r.Context(), userID, request); err != nil {
+			slog.Error("failed to enqueue URLs for deletion", sl.Err(err))
 
-			if err := service.Delete(ctx, request, r.Header.Get(string(constants.XUserID))); err != nil {
-				slog.Error("failed to delete URLs", sl.Err(err))
+			if errors.Is(err, deletequeue.ErrQueueFull) {
+				httpError.RespondWithError(w, http.StatusServiceUnavailable, "Too many pending deletions")
+				return
 			}
-		}()
+
+			httpError.RespondWithError(w, http.StatusServiceUnavailable, "Server is shutting down")
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
@@ -68,11 +77,5 @@ func New(
 			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed encoding response")
 			return
 		}
-
-		select {
-		case <-closeCh:
-		case <-ctx.Done():
-			return
-		}
 	}
 }
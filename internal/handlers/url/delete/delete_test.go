@@ -19,6 +19,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
 )
@@ -127,14 +129,19 @@ func TestNew(t *testing.T) {
 			require.NoError(t, err)
 
 			for code, url := range tt.existing {
-				_, err = storage.SaveURL(ctx, code, url, uuid.New().String())
+				_, err = storage.SaveURL(ctx, code, url, uuid.New().String(), repository.URLSettings{})
 				require.NoError(t, err)
 			}
 
 			req.Header.Set(string(constants.XUserID), uuid.New().String())
 
+			queue := deletequeue.New(urlservice.New(storage), deletequeue.Config{})
+			require.NoError(t, queue.Start())
+
 			w := httptest.NewRecorder()
-			New(ctx, urlservice.New(storage), tt.validator)(w, req)
+			New(queue, tt.validator)(w, req)
+
+			require.NoError(t, queue.Shutdown(ctx))
 
 			result := w.Result()
 			defer func() {
@@ -153,3 +160,70 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestNew_OutlivesRequestContext(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	require.NoError(t, err)
+	defer func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	}()
+
+	storage, err := memory.New(tempFile.Name())
+	require.NoError(t, err)
+
+	userID := uuid.New().String()
+	_, err = storage.SaveURL(context.Background(), "code1", "https://example.com", userID, repository.URLSettings{})
+	require.NoError(t, err)
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequestWithContext(reqCtx, http.MethodDelete, "/", strings.NewReader(`["code1"]`))
+	req.Header.Set(string(constants.XUserID), userID)
+
+	queue := deletequeue.New(urlservice.New(storage), deletequeue.Config{})
+	require.NoError(t, queue.Start())
+
+	w := httptest.NewRecorder()
+	New(queue, &mockValidator{})(w, req)
+	cancel()
+
+	require.NoError(t, queue.Shutdown(context.Background()))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	deleted, err := storage.GetURLByID(context.Background(), "code1")
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted)
+}
+
+type mockQueue struct {
+	err error
+}
+
+func (m *mockQueue) Enqueue(_ context.Context, _ string, _ []string) error {
+	return m.err
+}
+
+func TestNew_QueueUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		response string
+	}{
+		{name: "full", err: deletequeue.ErrQueueFull, response: `{"error":"Too many pending deletions"}`},
+		{name: "closed", err: deletequeue.ErrClosed, response: `{"error":"Server is shutting down"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/", strings.NewReader(`["code1"]`))
+			req.Header.Set(string(constants.XUserID), uuid.New().String())
+
+			w := httptest.NewRecorder()
+			New(&mockQueue{err: tt.err}, &mockValidator{})(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.JSONEq(t, tt.response, w.Body.String())
+		})
+	}
+}
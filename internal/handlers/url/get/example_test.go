@@ -7,9 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"time"
 
 	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
 )
 
 // mockStorage имитирует хранилище с разными сценариями.
@@ -25,6 +29,29 @@ func (m *mockStorage) GetURLByID(ctx context.Context, code string) (models.URL,
 	return url, nil
 }
 
+func (m *mockStorage) ConsumeClick(ctx context.Context, code string) (bool, error) {
+	return false, nil
+}
+
+// nopRecorder не записывает клики.
+type nopRecorder struct{}
+
+func (nopRecorder) Record(url models.URL, visit analytics.Visit) {}
+
+// clockFunc возвращает время, по которому проверяется истечение срока URL.
+type clockFunc func() time.Time
+
+func (f clockFunc) Now() time.Time { return f() }
+
+// mockRecorder запоминает записанные визиты.
+type mockRecorder struct {
+	visits []analytics.Visit
+}
+
+func (m *mockRecorder) Record(url models.URL, visit analytics.Visit) {
+	m.visits = append(m.visits, visit)
+}
+
 // ExampleNew демонстрирует использование обработчика New.
 func ExampleNew() {
 	ctx := context.Background()
@@ -54,7 +81,7 @@ func ExampleNew() {
 		URL:    "https://example.com/",
 		UserID: userID,
 	}
-	_, err = storage.SaveURL(ctx, testURL.Code, testURL.URL, testURL.UserID)
+	_, err = storage.SaveURL(ctx, testURL.Code, testURL.URL, testURL.UserID, repository.URLSettings{})
 	if err != nil {
 		fmt.Println("ошибка сохранения URL:", err)
 		return
@@ -65,7 +92,7 @@ func ExampleNew() {
 	req.SetPathValue("id", "example")
 	w := httptest.NewRecorder()
 
-	handler := New(ctx, storage)
+	handler := New(storage, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(time.Now))
 	handler(w, req)
 
 	result := w.Result()
@@ -85,7 +112,7 @@ func ExampleNew() {
 	reqNotFound.SetPathValue("id", "notfound")
 	wNotFound := httptest.NewRecorder()
 
-	handlerNotFound := New(ctx, mock)
+	handlerNotFound := New(mock, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(time.Now))
 	handlerNotFound(wNotFound, reqNotFound)
 
 	resultNotFound := wNotFound.Result()
@@ -108,7 +135,7 @@ func ExampleNew() {
 	reqDeleted.SetPathValue("id", "deleted")
 	wDeleted := httptest.NewRecorder()
 
-	handlerDeleted := New(ctx, mockDeleted)
+	handlerDeleted := New(mockDeleted, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(time.Now))
 	handlerDeleted(wDeleted, reqDeleted)
 
 	resultDeleted := wDeleted.Result()
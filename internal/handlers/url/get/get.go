@@ -3,30 +3,82 @@ package get
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html/template"
 	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/vadicheck/shorturl/internal/constants"
 	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
 )
 
 // URLStorage defines the interface for accessing URL data in the storage system.
 type URLStorage interface {
 	GetURLByID(ctx context.Context, code string) (models.URL, error)
+	ConsumeClick(ctx context.Context, code string) (bool, error)
 }
 
+// Unlocker checks the password of a protected URL.
+type Unlocker interface {
+	Check(url models.URL, password string) error
+}
+
+// ClickRecorder records the clicks on short URLs without blocking.
+type ClickRecorder interface {
+	Record(url models.URL, visit analytics.Visit)
+}
+
+// Clock tells the current time the expiration of the URLs is checked against.
+type Clock interface {
+	Now() time.Time
+}
+
+// unlockForm is served to browsers for a protected URL. It posts the password back to the short URL.
+var unlockForm = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Protected link</title></head>
+<body>
+<form method="post">
+<p>This link is protected with a password.</p>
+{{if .}}<p>{{.}}</p>
+{{end}}<input type="password" name="password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
 // New creates a new handler function for retrieving a URL by its ID.
 //
 // It extracts the URL ID from the request path, retrieves the corresponding URL from
-// the storage, and returns a redirect response based on the URL's status.
+// the storage, and returns a redirect response based on the URL's status:
+// deleted, disabled and expired URLs respond with 410 Gone. Every redirect of a click-limited URL
+// uses up one of its clicks; once none are left, the URL responds with 410 Gone as well.
+//
+// A protected URL redirects only when the request carries its password in the X-Link-Password
+// header, as the basic auth password or as the password field of a posted form. Otherwise it
+// responds with 401 Unauthorized and an unlock form. Once too many wrong passwords have been
+// tried, the URL responds with 429 Too Many Requests until the lockout is over.
+//
+// Every redirect is recorded as a click with the referrer, the user agent and the client IP.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - storage: The URL storage service used to retrieve the URL by ID.
+// - unlocker: The checker of the passwords of protected URLs.
+// - recorder: The recorder of the clicks.
+// - clock: The clock the expiration of the URLs is checked against.
 //
 // Returns:
 // - An HTTP handler function that processes requests for retrieving a URL by its ID.
-func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
+func New(storage URLStorage, unlocker Unlocker, recorder ClickRecorder, clock Clock) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
 		id := req.PathValue("id")
 
@@ -38,7 +90,7 @@ func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
 
 		slog.Info(fmt.Sprintf("id requested: %s", id))
 
-		mURL, err := storage.GetURLByID(ctx, id)
+		mURL, err := storage.GetURLByID(req.Context(), id)
 		if err != nil {
 			slog.Error(
 				fmt.Sprintf("Failed to get url by id. id: %s, err: %s", id, err),
@@ -55,13 +107,106 @@ func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
 			return
 		}
 
+		gone := mURL.IsDeleted || mURL.IsDisabled || mURL.IsExpired(clock.Now())
+
+		if !gone && !unlocked(res, req, unlocker, mURL) {
+			return
+		}
+
+		if !gone && mURL.IsClickLimited() {
+			granted, err := storage.ConsumeClick(req.Context(), id)
+			if err != nil {
+				slog.Error(
+					fmt.Sprintf("Failed to consume click. id: %s, err: %s", id, err),
+				)
+				http.Error(res, "Failed to get url", http.StatusInternalServerError)
+				return
+			}
+			gone = !granted
+		}
+
 		res.Header().Set("Content-Type", "text/plain")
-		res.Header().Set("Location", mURL.URL)
 
-		if mURL.IsDeleted {
+		// The original URL is only revealed with the redirect, so that a gone link
+		// does not give away the target of a used up one-time or a disabled link.
+		if gone {
 			res.WriteHeader(http.StatusGone)
-		} else {
-			res.WriteHeader(http.StatusTemporaryRedirect)
+			return
 		}
+
+		res.Header().Set("Location", mURL.URL)
+
+		recorder.Record(mURL, analytics.Visit{
+			Referrer:  req.Referer(),
+			UserAgent: req.UserAgent(),
+			IP:        clientIP(req),
+		})
+
+		res.WriteHeader(http.StatusTemporaryRedirect)
+	}
+}
+
+// clientIP returns the IP of the peer of the request.
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// unlocked checks the password of a protected URL. If the URL stays locked, it writes
+// the response and returns false.
+func unlocked(res http.ResponseWriter, req *http.Request, unlocker Unlocker, mURL models.URL) bool {
+	err := unlocker.Check(mURL, password(req))
+	if err == nil {
+		return true
+	}
+
+	var lockedErr *unlock.LockedError
+
+	switch {
+	case errors.As(err, &lockedErr):
+		slog.Info(fmt.Sprintf("URL is locked. id: %s", mURL.Code))
+		res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+		http.Error(res, "Too many failed attempts", http.StatusTooManyRequests)
+	case errors.Is(err, unlock.ErrPasswordRequired):
+		writeUnlockForm(res, "")
+	case errors.Is(err, unlock.ErrWrongPassword):
+		slog.Info(fmt.Sprintf("Wrong password. id: %s", mURL.Code))
+		writeUnlockForm(res, "The password is incorrect.")
+	default:
+		slog.Error("failed to check password", sl.Err(err))
+		http.Error(res, "Failed to get url", http.StatusInternalServerError)
+	}
+
+	return false
+}
+
+// password returns the password carried by the request, if any.
+func password(req *http.Request) string {
+	if p := req.Header.Get(string(constants.XLinkPassword)); p != "" {
+		return p
+	}
+
+	if _, p, ok := req.BasicAuth(); ok {
+		return p
+	}
+
+	if req.Method == http.MethodPost {
+		return req.PostFormValue("password")
+	}
+
+	return ""
+}
+
+// writeUnlockForm responds with 401 Unauthorized and the unlock form showing message.
+func writeUnlockForm(res http.ResponseWriter, message string) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusUnauthorized)
+
+	if err := unlockForm.Execute(res, message); err != nil {
+		slog.Error("failed to write unlock form", sl.Err(err))
 	}
 }
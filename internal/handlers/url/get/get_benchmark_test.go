@@ -9,9 +9,13 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
 )
 
 const (
@@ -51,12 +55,12 @@ func BenchmarkNew(b *testing.B) {
 	ctx := context.Background()
 
 	for key, url := range urls {
-		if _, err := storage.SaveURL(ctx, strconv.Itoa(key), url, userID); err != nil {
+		if _, err := storage.SaveURL(ctx, strconv.Itoa(key), url, userID, repository.URLSettings{}); err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	handler := New(ctx, storage)
+	handler := New(storage, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(time.Now))
 
 	b.ResetTimer()
 
@@ -71,3 +75,73 @@ func BenchmarkNew(b *testing.B) {
 		handler(w, req)
 	}
 }
+
+// BenchmarkNew_Size measures lookups by code in storages of growing size.
+// The time per operation should stay flat as the number of stored URLs grows.
+func BenchmarkNew_Size(b *testing.B) {
+	for _, size := range []int{1_000, 1_000_000} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			storage := seedStorage(b, size)
+			handler := New(storage, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(time.Now))
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+				req.SetPathValue("id", "code"+strconv.Itoa(i%size))
+
+				w := httptest.NewRecorder()
+
+				handler(w, req)
+			}
+		})
+	}
+}
+
+// seedStorage creates a file storage preloaded with size URLs spread over 1000 users.
+func seedStorage(b *testing.B, size int) *memory.Storage {
+	b.Helper()
+
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	})
+
+	writer := bufio.NewWriter(tempFile)
+
+	producer, err := memory.NewProducer(writer)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := range size {
+		err = producer.WriteURL(&models.URL{
+			ID:     int64(i + 1),
+			Code:   "code" + strconv.Itoa(i),
+			URL:    "https://example.com/" + strconv.Itoa(i),
+			UserID: "user" + strconv.Itoa(i%1000),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err = tempFile.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	storage, err := memory.New(tempFile.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return storage
+}
@@ -5,15 +5,23 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
+	"github.com/vadicheck/shorturl/internal/constants"
 	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/unlock"
 )
 
 func TestNew(t *testing.T) {
@@ -96,6 +104,42 @@ func TestNew(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "url expired",
+			code: "expired",
+			want: want{
+				contentType: "text/plain",
+				statusCode:  http.StatusGone,
+				response:    "",
+			},
+			urls: map[string]models.URL{
+				"expired": {
+					ID:        1,
+					Code:      "expired",
+					URL:       "https://practicum.yandex.ru/",
+					UserID:    uuid.New().String(),
+					ExpiresAt: time.Now().Add(-time.Minute),
+				},
+			},
+		},
+		{
+			name: "url not expired yet",
+			code: "expiring",
+			want: want{
+				contentType: "text/plain",
+				statusCode:  http.StatusTemporaryRedirect,
+				response:    "https://practicum.yandex.ru/",
+			},
+			urls: map[string]models.URL{
+				"expiring": {
+					ID:        1,
+					Code:      "expiring",
+					URL:       "https://practicum.yandex.ru/",
+					UserID:    uuid.New().String(),
+					ExpiresAt: time.Now().Add(time.Hour),
+				},
+			},
+		},
 	}
 
 	ctx := context.Background()
@@ -119,7 +163,8 @@ func TestNew(t *testing.T) {
 			require.NoError(t, err)
 
 			for code, url := range tt.urls {
-				_, err = storage.SaveURL(ctx, code, url.URL, url.UserID)
+				settings := repository.URLSettings{ExpiresAt: url.ExpiresAt, MaxClicks: url.MaxClicks}
+				_, err = storage.SaveURL(ctx, code, url.URL, url.UserID, settings)
 				require.NoError(t, err)
 
 				if tt.code == "delete" {
@@ -130,7 +175,9 @@ func TestNew(t *testing.T) {
 
 			req.SetPathValue("id", tt.code)
 
-			New(ctx, storage)(w, req)
+			recorder := &mockRecorder{}
+
+			New(storage, unlock.New(3, time.Minute), recorder, clockFunc(time.Now))(w, req)
 
 			result := w.Result()
 			defer func() {
@@ -141,6 +188,191 @@ func TestNew(t *testing.T) {
 
 			assert.Equal(t, tt.want.statusCode, result.StatusCode)
 			assert.Equal(t, tt.want.contentType, result.Header.Get("Content-Type"))
+
+			if tt.want.statusCode == http.StatusTemporaryRedirect {
+				require.Len(t, recorder.visits, 1)
+				assert.Equal(t, "192.0.2.1", recorder.visits[0].IP)
+			} else {
+				assert.Empty(t, recorder.visits)
+			}
 		})
 	}
 }
+
+func TestNew_OneTimeURL(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(
+		ctx, "once", "https://practicum.yandex.ru/", uuid.New().String(), repository.URLSettings{MaxClicks: 1},
+	)
+	require.NoError(t, err)
+
+	recorder := &mockRecorder{}
+
+	for _, want := range []int{http.StatusTemporaryRedirect, http.StatusGone, http.StatusGone} {
+		req := httptest.NewRequest(http.MethodGet, "/once", nil)
+		req.SetPathValue("id", "once")
+		w := httptest.NewRecorder()
+
+		New(storage, unlock.New(3, time.Minute), recorder, clockFunc(time.Now))(w, req)
+
+		assert.Equal(t, want, w.Code)
+		if want == http.StatusGone {
+			assert.Empty(t, w.Header().Get("Location"), "an exhausted link does not reveal the original URL")
+		}
+	}
+
+	mURL, err := storage.GetURLByID(ctx, "once")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), mURL.Clicks)
+	assert.Len(t, recorder.visits, 1, "only the redirect is recorded")
+}
+
+func TestNew_ExpiredByClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "ttl", "https://example.com/", uuid.New().String(),
+		repository.URLSettings{ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		now  time.Time
+		want int
+	}{
+		{now: now, want: http.StatusTemporaryRedirect},
+		{now: now.Add(2 * time.Hour), want: http.StatusGone},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ttl", nil)
+		req.SetPathValue("id", "ttl")
+		w := httptest.NewRecorder()
+
+		New(storage, unlock.New(3, time.Minute), nopRecorder{}, clockFunc(func() time.Time { return tt.now }))(w, req)
+
+		assert.Equal(t, tt.want, w.Code, "the expiration is checked against the clock")
+	}
+}
+
+func TestNew_Disabled(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "abuse", "https://example.com/", uuid.New().String(), repository.URLSettings{})
+	require.NoError(t, err)
+
+	_, err = storage.SetURLDisabled(ctx, "abuse", true)
+	require.NoError(t, err)
+
+	recorder := &mockRecorder{}
+
+	req := httptest.NewRequest(http.MethodGet, "/abuse", nil)
+	req.SetPathValue("id", "abuse")
+	w := httptest.NewRecorder()
+
+	New(storage, unlock.New(3, time.Minute), recorder, clockFunc(time.Now))(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"), "the original URL of a disabled link is not revealed")
+	assert.Empty(t, recorder.visits)
+}
+
+func TestNew_Protected(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "secret", "https://practicum.yandex.ru/", uuid.New().String(),
+		repository.URLSettings{PasswordHash: string(hash), MaxClicks: 1})
+	require.NoError(t, err)
+
+	handler := New(storage, unlock.New(2, time.Minute), nopRecorder{}, clockFunc(time.Now))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		req.SetPathValue("id", "secret")
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// Without a password the unlock form is served and the original URL is not revealed.
+	w := serve(httptest.NewRequest(http.MethodGet, "/secret", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `<form method="post">`)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// A wrong password does not use up a click.
+	req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.Header.Set(string(constants.XLinkPassword), "wrong")
+	w = serve(req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "The password is incorrect.")
+
+	form := url.Values{"password": {"hunter2"}}
+	req = httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://practicum.yandex.ru/", w.Header().Get("Location"))
+
+	// The only click is used up now; the exhausted link still does not reveal the URL.
+	req = httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.SetBasicAuth("", "hunter2")
+	w = serve(req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestNew_ProtectedLockout(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "secret", "https://practicum.yandex.ru/", uuid.New().String(),
+		repository.URLSettings{PasswordHash: string(hash)})
+	require.NoError(t, err)
+
+	handler := New(storage, unlock.New(2, time.Minute), nopRecorder{}, clockFunc(time.Now))
+
+	for _, tt := range []struct {
+		password   string
+		statusCode int
+	}{
+		{password: "wrong", statusCode: http.StatusUnauthorized},
+		{password: "wrong", statusCode: http.StatusUnauthorized},
+		{password: "hunter2", statusCode: http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		req.SetPathValue("id", "secret")
+		req.Header.Set(string(constants.XLinkPassword), tt.password)
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		assert.Equal(t, tt.statusCode, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+	req.SetPathValue("id", "secret")
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
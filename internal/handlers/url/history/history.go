@@ -0,0 +1,80 @@
+// Package history provides a handler for retrieving the lifecycle events of a user's short URLs.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// HistoryReader reads the lifecycle events of a user's URLs.
+type HistoryReader interface {
+	History(ctx context.Context, userID, code string, limit int) ([]models.LinkEvent, error)
+}
+
+// New creates a new handler function that returns the lifecycle events of the URLs owned
+// by the user, newest first, as a JSON array. The optional code query parameter restricts
+// the events to one URL and the limit query parameter sets their number. If no audit sink
+// keeps a history, it responds with 503 Service Unavailable.
+//
+// Parameters:
+// - events: The URL service used to read the history.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the events.
+func New(events HistoryReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		var limit int
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				httpError.RespondWithError(w, http.StatusBadRequest, "limit is invalid")
+				return
+			}
+			limit = parsed
+		}
+
+		history, err := events.History(r.Context(), userID, r.URL.Query().Get("code"), limit)
+		switch {
+		case errors.Is(err, urlservice.ErrInvalidLimit):
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, urlservice.ErrHistoryUnavailable):
+			httpError.RespondWithError(w, http.StatusServiceUnavailable, "History is unavailable")
+			return
+		case err != nil:
+			slog.Error("failed to read url history", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get history")
+			return
+		}
+
+		if history == nil {
+			history = []models.LinkEvent{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(history); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
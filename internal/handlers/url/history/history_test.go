@@ -0,0 +1,96 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	sink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	service := urlservice.New(storage, urlservice.WithAudit(sink))
+	handler := New(service)
+
+	call := func(userID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/history"+query, nil)
+		if userID != "" {
+			req.Header.Set(string(constants.XUserID), userID)
+		}
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := call("user1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+
+	code1, err := service.Create(ctx, "https://example.com/1", "user1")
+	require.NoError(t, err)
+	code2, err := service.Create(ctx, "https://example.com/2", "user1")
+	require.NoError(t, err)
+	_, err = service.Create(ctx, "https://example.com/3", "user2")
+	require.NoError(t, err)
+
+	decode := func(rec *httptest.ResponseRecorder) []models.LinkEvent {
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var events []models.LinkEvent
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&events))
+
+		return events
+	}
+
+	events := decode(call("user1", ""))
+	require.Len(t, events, 2, "only the user's own URLs are listed")
+	assert.Equal(t, code2, events[0].Code)
+	assert.Equal(t, code1, events[1].Code)
+
+	events = decode(call("user1", "?code="+code1))
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventCreate, events[0].Action)
+	assert.Equal(t, "https://example.com/1", events[0].URL)
+
+	assert.Len(t, decode(call("user1", "?limit=1")), 1)
+
+	assert.Equal(t, http.StatusBadRequest, call("", "").Code)
+	assert.Equal(t, http.StatusBadRequest, call("user1", "?limit=many").Code)
+	assert.Equal(t, http.StatusBadRequest, call("user1", "?limit=-1").Code)
+}
+
+func TestNew_Unavailable(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls/history", nil)
+	req.Header.Set(string(constants.XUserID), "user1")
+	rec := httptest.NewRecorder()
+
+	New(urlservice.New(storage))(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
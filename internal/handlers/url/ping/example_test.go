@@ -1,7 +1,6 @@
 package ping
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,8 +12,6 @@ import (
 
 // ExampleNew демонстрирует использование обработчика New.
 func ExampleNew() {
-	ctx := context.Background()
-
 	// Создание временного файла для хранения данных.
 	tempFile, err := os.CreateTemp("", "tempfile-*.json")
 	if err != nil {
@@ -38,7 +35,7 @@ func ExampleNew() {
 	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
 	w := httptest.NewRecorder()
 
-	handler := New(ctx, storage)
+	handler := New(storage)
 	handler(w, req)
 
 	result := w.Result()
@@ -56,7 +53,7 @@ func ExampleNew() {
 	reqErr := httptest.NewRequest(http.MethodGet, "/ping", nil)
 	wErr := httptest.NewRecorder()
 
-	handlerErr := New(ctx, errorStorage)
+	handlerErr := New(errorStorage)
 	handlerErr(wErr, reqErr)
 
 	resultErr := wErr.Result()
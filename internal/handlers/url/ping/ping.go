@@ -18,14 +18,13 @@ type URLStorage interface {
 // A successful ping returns an HTTP 200 OK status, while a failure returns an HTTP 500 Internal Server Error.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - storage: The URL storage service to check for availability.
 //
 // Returns:
 // - An HTTP handler function that processes the ping request and returns the appropriate status code.
-func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
+func New(storage URLStorage) http.HandlerFunc {
 	return func(res http.ResponseWriter, req *http.Request) {
-		reqCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+		reqCtx, cancel := context.WithTimeout(req.Context(), 1*time.Second)
 		defer cancel()
 
 		res.Header().Set("Content-Type", "application/json")
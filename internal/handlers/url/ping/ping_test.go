@@ -37,8 +37,6 @@ func TestNew(t *testing.T) {
 	storage, err := memory.New(tempFile.Name())
 	require.NoError(t, err)
 
-	ctx := context.Background()
-
 	tests := []struct {
 		name       string
 		storage    URLStorage
@@ -62,7 +60,7 @@ func TestNew(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
-			New(ctx, tt.storage)(w, req)
+			New(tt.storage)(w, req)
 
 			result := w.Result()
 			defer func() {
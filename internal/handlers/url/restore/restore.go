@@ -0,0 +1,95 @@
+// Package restore provides a handler for undoing the deletion of a user's short URLs.
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
+	delValidator "github.com/vadicheck/shorturl/internal/validator"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// URLRestorer undoes the deletion of short URLs.
+type URLRestorer interface {
+	Restore(ctx context.Context, urls []string, userID string) ([]string, error)
+}
+
+// Queue is the deletion queue, whose pending deletions of the user are waited for.
+type Queue interface {
+	Wait(ctx context.Context, userID string) error
+}
+
+// New creates a new handler function for restoring deleted short URLs.
+//
+// It reads a JSON body containing a list of short codes, validates it, and restores the
+// URLs among them that the user owns and has deleted. Unlike a deletion, the restore is
+// made at once: it responds with 200 OK and a JSON array of the codes restored, which is
+// empty if none of the codes could be restored.
+//
+// The deletions the user has queued are waited for first, so they cannot undo the restore.
+// If some of them failed and wait to be queued again, the handler responds with 503 Service
+// Unavailable, and the restore can be retried later.
+//
+// Parameters:
+// - service: The URL service used to restore the URLs.
+// - queue: The deletion queue.
+// - validator: The validator used to validate the list of codes.
+//
+// Returns:
+// - A handler function that processes HTTP requests for URL restoring.
+func New(service URLRestorer, queue Queue, validator delValidator.DeleteURLsValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		var request []string
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		errs := validator.DeleteShortURLs(&request)
+		if len(errs.Errors) != 0 {
+			httpError.RespondWithError(w, http.StatusBadRequest, errs.Error())
+			return
+		}
+
+		if err := queue.Wait(r.Context(), userID); err != nil {
+			slog.Error("failed to wait for pending deletions", sl.Err(err))
+
+			if errors.Is(err, deletequeue.ErrPending) {
+				httpError.RespondWithError(w, http.StatusServiceUnavailable, "Deletions are pending")
+				return
+			}
+
+			httpError.RespondWithError(w, http.StatusServiceUnavailable, "Deletions are in progress")
+			return
+		}
+
+		restored, err := service.Restore(r.Context(), request, userID)
+		if err != nil {
+			slog.Error("failed to restore urls", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to restore URLs")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(restored); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
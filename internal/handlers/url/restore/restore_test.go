@@ -0,0 +1,105 @@
+package restore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/deletequeue"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/internal/validator"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "first", "https://example.com/1", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "second", "https://example.com/2", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, storage.DeleteShortURLs(ctx, []string{"first"}, userOne))
+
+	queue := deletequeue.New(urlservice.New(storage), deletequeue.Config{})
+	require.NoError(t, queue.Start())
+	defer queue.Shutdown(ctx)
+
+	handler := New(urlservice.New(storage), queue, validator.New())
+
+	call := func(userID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(body))
+		req.Header.Set(string(constants.XUserID), userID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := call(userTwo, `["first"]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String(), "another user cannot restore the URL")
+
+	rec = call(userOne, `["first","second","missing"]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `["first"]`, rec.Body.String(), "only the deleted URL is restored")
+
+	stored, err := storage.GetURLByID(ctx, "first")
+	require.NoError(t, err)
+	assert.False(t, stored.IsDeleted)
+
+	assert.Equal(t, http.StatusBadRequest, call(userOne, `[]`).Code)
+	assert.Equal(t, http.StatusBadRequest, call(userOne, `invalid`).Code)
+	assert.Equal(t, http.StatusBadRequest, call("", `["first"]`).Code)
+}
+
+func TestNew_AfterQueuedDelete(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	_, err = storage.SaveURL(ctx, "first", "https://example.com/1", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+
+	service := urlservice.New(storage)
+	queue := deletequeue.New(service, deletequeue.Config{BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	require.NoError(t, queue.Start())
+
+	require.NoError(t, queue.Enqueue(ctx, userOne, []string{"first"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(`["first"]`))
+	req.Header.Set(string(constants.XUserID), userOne)
+	rec := httptest.NewRecorder()
+
+	New(service, queue, validator.New())(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `["first"]`, rec.Body.String(), "the queued deletion is done before the restore")
+
+	require.NoError(t, queue.Shutdown(ctx))
+
+	stored, err := storage.GetURLByID(ctx, "first")
+	require.NoError(t, err)
+	assert.False(t, stored.IsDeleted, "the queued deletion does not undo the restore")
+}
@@ -1,7 +1,6 @@
 package save
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"log"
@@ -21,8 +20,6 @@ const userID = "da9da41c-8f65-4ed6-abea-d58f50c41590"
 
 // ExampleNew демонстрирует использование обработчика New.
 func ExampleNew() {
-	ctx := context.Background()
-
 	// Настройка конфигурации
 	config.Config.BaseURL = "http://localhost:8080"
 
@@ -58,7 +55,7 @@ func ExampleNew() {
 	w := httptest.NewRecorder()
 
 	// Вызов обработчика
-	handler := New(ctx, urlService)
+	handler := New(urlService)
 	handler(w, req)
 
 	// Получение результата
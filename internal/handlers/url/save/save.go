@@ -2,12 +2,14 @@
 package save
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
@@ -15,7 +17,7 @@ import (
 	"github.com/vadicheck/shorturl/internal/models/shorten"
 	"github.com/vadicheck/shorturl/internal/services/storage"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
-	"github.com/vadicheck/shorturl/pkg/validators/url"
+	urlValidator "github.com/vadicheck/shorturl/pkg/validators/url"
 )
 
 // New creates a new handler function for saving a URL and generating its shortened version.
@@ -23,15 +25,18 @@ import (
 // It processes the URL from the request body, validates it, and attempts to create a shortened URL.
 // If the URL is already shortened, it returns a conflict status with the existing shortened URL.
 // If the URL is invalid, it returns a bad request status.
+// The optional ttl (seconds) and expires_at (RFC 3339) query parameters make the link expire;
+// an invalid or past expiration is rejected with a bad request status. The optional
+// max_clicks query parameter limits the number of redirects; a negative limit is rejected too.
+// The optional X-Link-Password header protects the link with a password, which is kept out of the URL.
 // On successful creation, it returns the shortened URL with an HTTP status of 201 Created.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - service: The URL service used to create the shortened URL.
 //
 // Returns:
 // - An HTTP handler function that processes the URL creation request and returns the result.
-func New(ctx context.Context, service *urlservice.Service) http.HandlerFunc {
+func New(service *urlservice.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -49,25 +54,38 @@ func New(ctx context.Context, service *urlservice.Service) http.HandlerFunc {
 
 		reqURL := string(body)
 
-		_, err = url.IsValid(reqURL)
+		_, err = urlValidator.IsValid(reqURL)
 		if err != nil {
 			slog.Error(fmt.Sprintf("URL is invalid: %s", err))
 			http.Error(w, "URL is invalid", http.StatusBadRequest)
 			return
 		}
 
+		opts, err := createOptions(r.URL.Query())
+		if err != nil {
+			slog.Error(fmt.Sprintf("Query is invalid: %s", err))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, urlservice.WithPassword(r.Header.Get(string(constants.XLinkPassword))))
+
 		httpStatus := http.StatusCreated
 		response := shorten.CreateURLResponse{}
 
 		slog.Info(fmt.Sprintf("userID requested (save.go): %s", r.Header.Get(string(constants.XUserID))))
 
-		code, err := service.Create(ctx, reqURL, r.Header.Get(string(constants.XUserID)))
+		code, err := service.Create(r.Context(), reqURL, r.Header.Get(string(constants.XUserID)), opts...)
 		if err != nil {
 			var storageErr *storage.ExistsURLError
 
 			if errors.As(err, &storageErr) {
 				httpStatus = http.StatusConflict
 				response.Result = config.Config.BaseURL + "/" + storageErr.ShortCode
+			} else if errors.Is(err, urlservice.ErrInvalidExpiration) ||
+				errors.Is(err, urlservice.ErrInvalidMaxClicks) ||
+				errors.Is(err, urlservice.ErrInvalidPassword) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
 			} else {
 				httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to create")
 			}
@@ -88,3 +106,35 @@ func New(ctx context.Context, service *urlservice.Service) http.HandlerFunc {
 		}
 	}
 }
+
+// createOptions reads the optional ttl, in seconds, expires_at, in RFC 3339, and max_clicks
+// query parameters, since the request body holds nothing but the URL.
+func createOptions(query url.Values) ([]urlservice.CreateOption, error) {
+	var opts []urlservice.CreateOption
+
+	if ttl := query.Get("ttl"); ttl != "" {
+		seconds, err := strconv.ParseInt(ttl, 10, 64)
+		if err != nil {
+			return nil, errors.New("ttl is invalid")
+		}
+		opts = append(opts, urlservice.WithTTL(time.Duration(seconds)*time.Second))
+	}
+
+	if expiresAt := query.Get("expires_at"); expiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, errors.New("expires_at is invalid")
+		}
+		opts = append(opts, urlservice.WithExpiresAt(parsed))
+	}
+
+	if maxClicks := query.Get("max_clicks"); maxClicks != "" {
+		parsed, err := strconv.ParseInt(maxClicks, 10, 64)
+		if err != nil {
+			return nil, errors.New("max_clicks is invalid")
+		}
+		opts = append(opts, urlservice.WithMaxClicks(parsed))
+	}
+
+	return opts, nil
+}
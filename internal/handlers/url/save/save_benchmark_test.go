@@ -1,17 +1,19 @@
 package save
 
 import (
+	"bufio"
 	"bytes"
-	"context"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/google/uuid"
 
 	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
 )
@@ -32,7 +34,7 @@ func BenchmarkNew(b *testing.B) {
 		panic(err)
 	}
 
-	handler := New(context.Background(), urlservice.New(storage))
+	handler := New(urlservice.New(storage))
 
 	requestBody := []byte("https://example.com")
 
@@ -48,3 +50,77 @@ func BenchmarkNew(b *testing.B) {
 		handler(w, req)
 	}
 }
+
+// BenchmarkNew_Size measures URL creation in storages of growing size.
+// The time per operation should stay flat as the number of stored URLs grows.
+func BenchmarkNew_Size(b *testing.B) {
+	for _, size := range []int{1_000, 1_000_000} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			storage := seedStorage(b, size)
+			handler := New(urlservice.New(storage))
+			userID := uuid.New().String()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				requestBody := []byte("https://example.org/" + strconv.Itoa(i))
+
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+				req.Header.Set("Content-Type", "text/plain")
+				req.Header.Set(string(constants.XUserID), userID)
+
+				w := httptest.NewRecorder()
+
+				handler(w, req)
+			}
+		})
+	}
+}
+
+// seedStorage creates a file storage preloaded with size URLs spread over 1000 users.
+func seedStorage(b *testing.B, size int) *memory.Storage {
+	b.Helper()
+
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	})
+
+	writer := bufio.NewWriter(tempFile)
+
+	producer, err := memory.NewProducer(writer)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := range size {
+		err = producer.WriteURL(&models.URL{
+			ID:     int64(i + 1),
+			Code:   "code" + strconv.Itoa(i),
+			URL:    "https://example.com/" + strconv.Itoa(i),
+			UserID: "user" + strconv.Itoa(i%1000),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err = tempFile.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	storage, err := memory.New(tempFile.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return storage
+}
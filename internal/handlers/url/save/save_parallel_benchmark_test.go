@@ -0,0 +1,59 @@
+package save
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+)
+
+// BenchmarkNewParallel measures the save handler throughput when many
+// goroutines shorten distinct URLs against the same storage at once.
+func BenchmarkNewParallel(b *testing.B) {
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	}()
+
+	storage, err := memory.New(tempFile.Name())
+	if err != nil {
+		panic(err)
+	}
+
+	handler := New(urlservice.New(storage))
+
+	var counter atomic.Int64
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		userID := uuid.New().String()
+
+		for pb.Next() {
+			requestBody := []byte("https://example.com/" + strconv.FormatInt(counter.Add(1), 10))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(requestBody))
+			req.Header.Set("Content-Type", "text/plain")
+			req.Header.Set(string(constants.XUserID), userID)
+
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+		}
+	})
+}
@@ -9,8 +9,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,7 @@ import (
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
 	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
 )
@@ -122,13 +125,13 @@ func TestNew(t *testing.T) {
 			require.NoError(t, err)
 
 			for code, url := range tt.urls {
-				_, err = storage.SaveURL(ctx, code, url.URL, url.UserID)
+				_, err = storage.SaveURL(ctx, code, url.URL, url.UserID, repository.URLSettings{})
 				require.NoError(t, err)
 			}
 
 			req.Header.Set(string(constants.XUserID), uuid.New().String())
 
-			New(ctx, urlservice.New(storage))(w, req)
+			New(urlservice.New(storage))(w, req)
 
 			result := w.Result()
 			assert.Equal(t, tt.want.statusCode, result.StatusCode)
@@ -153,3 +156,113 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestNew_Expiration(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		expires    bool
+	}{
+		{name: "no expiration", query: "", statusCode: http.StatusCreated},
+		{name: "ttl", query: "?ttl=3600", statusCode: http.StatusCreated, expires: true},
+		{
+			name:       "expires_at",
+			query:      "?expires_at=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			statusCode: http.StatusCreated,
+			expires:    true,
+		},
+		{name: "invalid ttl", query: "?ttl=hour", statusCode: http.StatusBadRequest},
+		{name: "negative ttl", query: "?ttl=-1", statusCode: http.StatusBadRequest},
+		{name: "invalid expires_at", query: "?expires_at=tomorrow", statusCode: http.StatusBadRequest},
+		{name: "past expires_at", query: "?expires_at=2000-01-01T00:00:00Z", statusCode: http.StatusBadRequest},
+		{name: "ttl and expires_at", query: "?ttl=60&expires_at=2100-01-01T00:00:00Z", statusCode: http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+			require.NoError(t, err)
+
+			originalURL := fmt.Sprintf("https://example.com/%d", i)
+
+			req := httptest.NewRequest(http.MethodPost, "/"+tt.query, strings.NewReader(originalURL))
+			req.Header.Set(string(constants.XUserID), uuid.New().String())
+			w := httptest.NewRecorder()
+
+			New(urlservice.New(storage))(w, req)
+
+			result := w.Result()
+			defer func() {
+				require.NoError(t, result.Body.Close())
+			}()
+
+			require.Equal(t, tt.statusCode, result.StatusCode)
+			if tt.statusCode != http.StatusCreated {
+				return
+			}
+
+			mURL, err := storage.GetURLByURL(context.Background(), originalURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expires, !mURL.ExpiresAt.IsZero())
+		})
+	}
+}
+
+func TestNew_MaxClicks(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		maxClicks  int64
+	}{
+		{name: "unlimited", query: "", statusCode: http.StatusCreated},
+		{name: "one time", query: "?max_clicks=1", statusCode: http.StatusCreated, maxClicks: 1},
+		{name: "invalid", query: "?max_clicks=many", statusCode: http.StatusBadRequest},
+		{name: "negative", query: "?max_clicks=-1", statusCode: http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+			require.NoError(t, err)
+
+			originalURL := fmt.Sprintf("https://example.com/%d", i)
+
+			req := httptest.NewRequest(http.MethodPost, "/"+tt.query, strings.NewReader(originalURL))
+			req.Header.Set(string(constants.XUserID), uuid.New().String())
+			w := httptest.NewRecorder()
+
+			New(urlservice.New(storage))(w, req)
+
+			require.Equal(t, tt.statusCode, w.Code)
+			if tt.statusCode != http.StatusCreated {
+				return
+			}
+
+			mURL, err := storage.GetURLByURL(context.Background(), originalURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.maxClicks, mURL.MaxClicks)
+		})
+	}
+}
+
+func TestNew_Password(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	originalURL := "https://example.com/protected"
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(originalURL))
+	req.Header.Set(string(constants.XUserID), uuid.New().String())
+	req.Header.Set(string(constants.XLinkPassword), "hunter2")
+	w := httptest.NewRecorder()
+
+	New(urlservice.New(storage))(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	mURL, err := storage.GetURLByURL(context.Background(), originalURL)
+	require.NoError(t, err)
+	assert.True(t, mURL.IsProtected())
+}
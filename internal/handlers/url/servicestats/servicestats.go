@@ -0,0 +1,66 @@
+// Package servicestats provides a handler for retrieving the statistics of the whole service.
+package servicestats
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// URLStorage defines the method for counting the stored URLs and users.
+type URLStorage interface {
+	GetServiceStats(ctx context.Context) (models.ServiceStats, error)
+}
+
+// PoolStorage is implemented by the storages running on a connection pool.
+type PoolStorage interface {
+	Stats() models.PoolStats
+}
+
+// New creates a new handler function that returns the number of shortened URLs and users.
+//
+// If the storage implements PoolStorage, the statistics of its connection pool are
+// returned too. The handler does not check the caller itself; it is meant to be served
+// behind the trusted subnet middleware.
+//
+// Parameters:
+// - storage: The URL storage service used to count the URLs and users.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the statistics.
+func New(storage URLStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := storage.GetServiceStats(r.Context())
+		if err != nil {
+			slog.Error("failed to get service stats", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get stats")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		response := shorten.ServiceStatsResponse{URLs: stats.URLs, Users: stats.Users}
+		if pool, ok := storage.(PoolStorage); ok {
+			poolStats := pool.Stats()
+			response.Pool = &shorten.PoolStatsResponse{
+				MaxConns:       poolStats.MaxConns,
+				TotalConns:     poolStats.TotalConns,
+				AcquiredConns:  poolStats.AcquiredConns,
+				IdleConns:      poolStats.IdleConns,
+				WaitCount:      poolStats.WaitCount,
+				WaitDurationMs: poolStats.WaitDuration.Milliseconds(),
+			}
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
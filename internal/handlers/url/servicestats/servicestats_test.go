@@ -0,0 +1,92 @@
+package servicestats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+type failingStorage struct{}
+
+func (failingStorage) GetServiceStats(ctx context.Context) (models.ServiceStats, error) {
+	return models.ServiceStats{}, errors.New("storage is unavailable")
+}
+
+// poolStorage is a storage running on a connection pool.
+type poolStorage struct{}
+
+func (poolStorage) GetServiceStats(ctx context.Context) (models.ServiceStats, error) {
+	return models.ServiceStats{URLs: 1, Users: 1}, nil
+}
+
+func (poolStorage) Stats() models.PoolStats {
+	return models.PoolStats{
+		MaxConns:      10,
+		TotalConns:    4,
+		AcquiredConns: 1,
+		IdleConns:     3,
+		WaitCount:     2,
+		WaitDuration:  1500 * time.Millisecond,
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	for code, userID := range map[string]string{"a": "user1", "b": "user1", "c": "user2"} {
+		_, err = storage.SaveURL(ctx, code, "https://example.com/"+code, userID, repository.URLSettings{})
+		require.NoError(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	New(storage)(w, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var response shorten.ServiceStatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, shorten.ServiceStatsResponse{URLs: 3, Users: 2}, response)
+}
+
+func TestNew_StorageError(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(failingStorage{})(w, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestNew_PoolStats(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(poolStorage{})(w, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"urls": 1,
+		"users": 1,
+		"pool": {
+			"max_conns": 10,
+			"total_conns": 4,
+			"acquired_conns": 1,
+			"idle_conns": 3,
+			"wait_count": 2,
+			"wait_duration_ms": 1500
+		}
+	}`, w.Body.String())
+}
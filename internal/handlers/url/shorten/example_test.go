@@ -2,7 +2,6 @@ package shorten
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -27,8 +26,6 @@ type request struct {
 
 // ExampleNew демонстрирует использование обработчика New.
 func ExampleNew() {
-	ctx := context.Background()
-
 	// Настройка конфигурации
 	config.Config.BaseURL = "http://localhost:8080"
 
@@ -74,7 +71,7 @@ func ExampleNew() {
 	w := httptest.NewRecorder()
 
 	// Вызов обработчика
-	handler := New(ctx, urlService)
+	handler := New(urlService)
 	handler(w, req)
 
 	// Получение результата
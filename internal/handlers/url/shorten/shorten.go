@@ -2,12 +2,12 @@
 package shorten
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
@@ -24,15 +24,22 @@ import (
 // This function processes the incoming request, validates the URL, and attempts to create a shortened URL.
 // If the URL is invalid, it returns a bad request status with an error message.
 // If the URL already exists, it returns a conflict status with the existing shortened URL.
+// An optional alias is used as the short code: an invalid alias is rejected with a bad request
+// status, and an alias that is already taken with a conflict status and an error body, which
+// tells it apart from the conflict of an existing URL.
+// The link expires at the optional expires_at time or after the optional ttl in seconds;
+// an expiration in the past or both fields together are rejected with a bad request status.
+// The optional max_clicks limits the number of redirects; a negative limit is rejected
+// with a bad request status. The optional password protects the link; a password
+// longer than urlservice.MaxPasswordLength bytes is rejected with a bad request status.
 // On success, it returns the newly created shortened URL with an HTTP status of 201 Created.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - service: The URL service used to create the shortened URL.
 //
 // Returns:
 // - An HTTP handler function that processes the URL shortening request and returns the result.
-func New(ctx context.Context, service *urlservice.Service) http.HandlerFunc {
+func New(service *urlservice.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var request shorten.CreateURLRequest
 
@@ -53,14 +60,33 @@ func New(ctx context.Context, service *urlservice.Service) http.HandlerFunc {
 
 		slog.Info(fmt.Sprintf("userID requested (save.go): %s", r.Header.Get(string(constants.XUserID))))
 
-		code, err := service.Create(ctx, request.URL, r.Header.Get(string(constants.XUserID)))
+		code, err := service.Create(
+			r.Context(),
+			request.URL,
+			r.Header.Get(string(constants.XUserID)),
+			urlservice.WithAlias(request.Alias),
+			urlservice.WithExpiresAt(request.ExpiresAt),
+			urlservice.WithTTL(time.Duration(request.TTL)*time.Second),
+			urlservice.WithMaxClicks(request.MaxClicks),
+			urlservice.WithPassword(request.Password),
+		)
 		if err != nil {
 			var storageErr *storage.ExistsURLError
 
-			if errors.As(err, &storageErr) {
+			switch {
+			case errors.As(err, &storageErr):
 				httpStatus = http.StatusConflict
 				response.Result = config.Config.BaseURL + "/" + storageErr.ShortCode
-			} else {
+			case errors.Is(err, urlservice.ErrInvalidAlias),
+				errors.Is(err, urlservice.ErrInvalidExpiration),
+				errors.Is(err, urlservice.ErrInvalidMaxClicks),
+				errors.Is(err, urlservice.ErrInvalidPassword):
+				httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			case errors.Is(err, urlservice.ErrAliasTaken):
+				httpError.RespondWithError(w, http.StatusConflict, "Alias is already taken")
+				return
+			default:
 				httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to create")
 			}
 		} else {
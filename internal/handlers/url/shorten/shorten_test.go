@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -18,13 +19,18 @@ import (
 
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 	"github.com/vadicheck/shorturl/internal/services/urlservice"
 )
 
 func TestNew(t *testing.T) {
 	type request struct {
-		URL string `json:"url"`
+		URL       string `json:"url"`
+		Alias     string `json:"alias,omitempty"`
+		TTL       int64  `json:"ttl,omitempty"`
+		MaxClicks int64  `json:"max_clicks,omitempty"`
+		Password  string `json:"password,omitempty"`
 	}
 	type response struct {
 		Result string `json:"result"`
@@ -85,6 +91,106 @@ func TestNew(t *testing.T) {
 				URL: "et4bnnny4h",
 			},
 		},
+		{
+			name: "Alias",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+			},
+			request: request{
+				URL:   "https://practicum.yandex.ru/alias",
+				Alias: "q3-report",
+			},
+		},
+		{
+			name: "Invalid alias",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusBadRequest,
+				responseError: responseError{
+					Error: `invalid alias "api": alias is reserved`,
+				},
+			},
+			request: request{
+				URL:   "https://practicum.yandex.ru/reserved",
+				Alias: "api",
+			},
+		},
+		{
+			name: "TTL",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+			},
+			request: request{
+				URL: "https://practicum.yandex.ru/ttl",
+				TTL: 3600,
+			},
+		},
+		{
+			name: "Negative TTL",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusBadRequest,
+				responseError: responseError{
+					Error: "invalid expiration: ttl must be positive",
+				},
+			},
+			request: request{
+				URL: "https://practicum.yandex.ru/negative-ttl",
+				TTL: -1,
+			},
+		},
+		{
+			name: "Max clicks",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+			},
+			request: request{
+				URL:       "https://practicum.yandex.ru/max-clicks",
+				MaxClicks: 1,
+			},
+		},
+		{
+			name: "Negative max clicks",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusBadRequest,
+				responseError: responseError{
+					Error: "invalid max_clicks: must not be negative",
+				},
+			},
+			request: request{
+				URL:       "https://practicum.yandex.ru/negative-max-clicks",
+				MaxClicks: -1,
+			},
+		},
+		{
+			name: "Password",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusCreated,
+			},
+			request: request{
+				URL:      "https://practicum.yandex.ru/password",
+				Password: "hunter2",
+			},
+		},
+		{
+			name: "Password too long",
+			want: want{
+				contentType: "application/json",
+				statusCode:  http.StatusBadRequest,
+				responseError: responseError{
+					Error: "invalid password: password must be at most 72 bytes long",
+				},
+			},
+			request: request{
+				URL:      "https://practicum.yandex.ru/long-password",
+				Password: strings.Repeat("a", urlservice.MaxPasswordLength+1),
+			},
+		},
 	}
 
 	ctx := context.Background()
@@ -119,7 +225,7 @@ func TestNew(t *testing.T) {
 
 			req.Header.Set(string(constants.XUserID), uuid.New().String())
 
-			New(ctx, urlservice.New(storage))(w, req)
+			New(urlservice.New(storage))(w, req)
 
 			result := w.Result()
 			defer func() {
@@ -152,7 +258,60 @@ func TestNew(t *testing.T) {
 				mURL, err := storage.GetURLByID(ctx, id)
 				assert.NoError(t, err)
 				assert.Equal(t, tt.request.URL, mURL.URL)
+
+				if tt.request.Alias != "" {
+					assert.Equal(t, tt.request.Alias, id)
+				}
+
+				assert.Equal(t, tt.request.TTL != 0, !mURL.ExpiresAt.IsZero())
+				assert.Equal(t, tt.request.MaxClicks, mURL.MaxClicks)
+				assert.Equal(t, tt.request.Password != "", mURL.IsProtected())
 			}
 		})
 	}
 }
+
+func TestNew_AliasTaken(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	handler := New(urlservice.New(storage))
+
+	shortenURL := func(body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		req.Header.Set(string(constants.XUserID), uuid.New().String())
+
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		return w.Result()
+	}
+
+	created := shortenURL(`{"url":"https://example.com/1","alias":"vanity"}`)
+	require.NoError(t, created.Body.Close())
+	require.Equal(t, http.StatusCreated, created.StatusCode)
+
+	// The alias conflict carries an error instead of the short URL of an existing one.
+	taken := shortenURL(`{"url":"https://example.com/2","alias":"vanity"}`)
+	defer func() {
+		require.NoError(t, taken.Body.Close())
+	}()
+
+	assert.Equal(t, http.StatusConflict, taken.StatusCode)
+
+	var resError shorten.ResponseError
+	require.NoError(t, json.NewDecoder(taken.Body).Decode(&resError))
+	assert.Equal(t, "Alias is already taken", resError.Error)
+
+	// A URL that is already shortened keeps its code, whatever the alias.
+	existing := shortenURL(`{"url":"https://example.com/1","alias":"another"}`)
+	defer func() {
+		require.NoError(t, existing.Body.Close())
+	}()
+
+	assert.Equal(t, http.StatusConflict, existing.StatusCode)
+
+	var res shorten.CreateURLResponse
+	require.NoError(t, json.NewDecoder(existing.Body).Decode(&res))
+	assert.Equal(t, config.Config.BaseURL+"/vanity", res.Result)
+}
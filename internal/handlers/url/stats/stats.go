@@ -0,0 +1,107 @@
+// Package stats provides a handler for retrieving the click statistics of a user's short URL.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/config"
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// URLStorage defines the method for retrieving a URL.
+type URLStorage interface {
+	GetURLByID(ctx context.Context, code string) (models.URL, error)
+}
+
+// ClickStorage defines the method for retrieving the click statistics of a URL.
+type ClickStorage interface {
+	GetClickStats(ctx context.Context, urlID int64, interval models.StatsInterval) (models.ClickStats, error)
+}
+
+// New creates a new handler function that returns the click statistics of a short URL.
+//
+// It responds with the total number of clicks, the number of unique visitors and the
+// clicks grouped by the interval given in the optional interval query parameter,
+// "hour" or "day" (the default). Only the owner of the URL may read its statistics:
+// a URL of another user responds with 403 Forbidden and an unknown one with 404 Not Found.
+// An unknown interval is rejected with a bad request status.
+//
+// Parameters:
+// - storage: The URL storage service used to retrieve the URL.
+// - clicks: The click storage used to retrieve the statistics of the URL.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the statistics.
+func New(storage URLStorage, clicks ClickStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := r.PathValue("code")
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		interval, err := models.ParseStatsInterval(r.URL.Query().Get("interval"))
+		if err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		mURL, err := storage.GetURLByID(r.Context(), code)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to get url by id. id: %s, err: %s", code, err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get url")
+			return
+		}
+
+		if mURL.ID == 0 {
+			httpError.RespondWithError(w, http.StatusNotFound, "URL not found")
+			return
+		}
+
+		if mURL.UserID != userID {
+			slog.Info(fmt.Sprintf("Stats of another user's URL requested. id: %s, userID: %s", code, userID))
+			httpError.RespondWithError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		stats, err := clicks.GetClickStats(r.Context(), mURL.ID, interval)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to get click stats. id: %s, err: %s", code, err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get stats")
+			return
+		}
+
+		response := shorten.URLStatsResponse{
+			ShortURL:       config.Config.BaseURL + "/" + mURL.Code,
+			TotalClicks:    stats.Total,
+			UniqueVisitors: stats.UniqueVisitors,
+			Interval:       string(interval),
+			Buckets:        make([]shorten.StatsBucketResponse, 0, len(stats.Buckets)),
+		}
+		for _, bucket := range stats.Buckets {
+			response.Buckets = append(response.Buckets, shorten.StatsBucketResponse{
+				Start:  bucket.Start,
+				Clicks: bucket.Clicks,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
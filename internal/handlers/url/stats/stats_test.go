@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	id, err := storage.SaveURL(ctx, "practicum", "https://practicum.yandex.ru/", userOne, repository.URLSettings{})
+	require.NoError(t, err)
+
+	day := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	err = storage.SaveClicks(ctx, []models.Click{
+		{URLID: id, Code: "practicum", Time: day.Add(time.Hour), IPHash: "a"},
+		{URLID: id, Code: "practicum", Time: day.Add(2 * time.Hour), IPHash: "b"},
+		{URLID: id, Code: "practicum", Time: day.Add(26 * time.Hour), IPHash: "a"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		code       string
+		userID     string
+		interval   string
+		statusCode int
+		buckets    []shorten.StatsBucketResponse
+	}{
+		{
+			name:       "daily stats",
+			code:       "practicum",
+			userID:     userOne,
+			statusCode: http.StatusOK,
+			buckets: []shorten.StatsBucketResponse{
+				{Start: day, Clicks: 2},
+				{Start: day.Add(24 * time.Hour), Clicks: 1},
+			},
+		},
+		{
+			name:       "hourly stats",
+			code:       "practicum",
+			userID:     userOne,
+			interval:   "hour",
+			statusCode: http.StatusOK,
+			buckets: []shorten.StatsBucketResponse{
+				{Start: day.Add(time.Hour), Clicks: 1},
+				{Start: day.Add(2 * time.Hour), Clicks: 1},
+				{Start: day.Add(26 * time.Hour), Clicks: 1},
+			},
+		},
+		{
+			name:       "empty user",
+			code:       "practicum",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown interval",
+			code:       "practicum",
+			userID:     userOne,
+			interval:   "week",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "not found",
+			code:       "missing",
+			userID:     userOne,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "another user",
+			code:       "practicum",
+			userID:     userTwo,
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/user/urls/" + tt.code + "/stats"
+			if tt.interval != "" {
+				target += "?interval=" + tt.interval
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.SetPathValue("code", tt.code)
+			req.Header.Set(string(constants.XUserID), tt.userID)
+			w := httptest.NewRecorder()
+
+			New(storage, storage)(w, req)
+
+			require.Equal(t, tt.statusCode, w.Code)
+
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var response shorten.URLStatsResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+			assert.Equal(t, int64(3), response.TotalClicks)
+			assert.Equal(t, int64(2), response.UniqueVisitors)
+			assert.Equal(t, tt.buckets, response.Buckets)
+		})
+	}
+}
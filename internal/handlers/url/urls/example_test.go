@@ -14,6 +14,7 @@ import (
 	"github.com/vadicheck/shorturl/internal/constants"
 	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 )
 
@@ -51,7 +52,7 @@ func ExampleNew() {
 		URL:    "https://example.com/",
 		UserID: userID,
 	}
-	_, err = storage.SaveURL(ctx, testURL.Code, testURL.URL, testURL.UserID)
+	_, err = storage.SaveURL(ctx, testURL.Code, testURL.URL, testURL.UserID, repository.URLSettings{})
 	if err != nil {
 		fmt.Println("ошибка сохранения URL:", err)
 		return
@@ -65,7 +66,7 @@ func ExampleNew() {
 	w := httptest.NewRecorder()
 
 	// Вызов обработчика.
-	handler := New(ctx, storage)
+	handler := New(storage)
 	handler(w, req)
 
 	// Получение результата.
@@ -86,9 +87,11 @@ func ExampleNew() {
 	require.NoError(nil, err)
 
 	// Вывод результата.
-	fmt.Println("Ответ:", response)
+	for _, url := range response {
+		fmt.Println("Ответ:", url.ShortURL, url.OriginalURL)
+	}
 
 	// Output:
 	// Статус код: 200
-	// Ответ: [{http://localhost:8080/example https://example.com/}]
+	// Ответ: http://localhost:8080/example https://example.com/
 }
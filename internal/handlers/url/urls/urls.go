@@ -28,12 +28,11 @@ type URLStorage interface {
 // If there is an error retrieving the URLs, it returns a 500 Internal Server Error.
 //
 // Parameters:
-// - ctx: The context for managing the request lifecycle.
 // - storage: The URL storage service used to retrieve the user's URLs.
 //
 // Returns:
 // - An HTTP handler function that processes the request and returns the list of URLs for the user.
-func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
+func New(storage URLStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Header.Get(string(constants.XUserID))
 		slog.Info(fmt.Sprintf("userID requested (urls.go): %s", userID))
@@ -46,7 +45,7 @@ func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
 
 		slog.Info(fmt.Sprintf("userID requested: %s", userID))
 
-		mURLs, err := storage.GetUserURLs(ctx, userID)
+		mURLs, err := storage.GetUserURLs(r.Context(), userID)
 		if err != nil {
 			slog.Error(
 				fmt.Sprintf("Failed to get user urls. userID: %s, err: %s", userID, err),
@@ -65,10 +64,17 @@ func New(ctx context.Context, storage URLStorage) http.HandlerFunc {
 		response := make([]shorten.UserURLResponse, 0)
 
 		for _, url := range mURLs {
-			response = append(response, shorten.UserURLResponse{
+			item := shorten.UserURLResponse{
 				ShortURL:    config.Config.BaseURL + "/" + url.Code,
 				OriginalURL: url.URL,
-			})
+				ExpiresAt:   url.ExpiresAt,
+				Protected:   url.IsProtected(),
+			}
+			if url.IsClickLimited() {
+				remaining := url.RemainingClicks()
+				item.RemainingClicks = &remaining
+			}
+			response = append(response, item)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
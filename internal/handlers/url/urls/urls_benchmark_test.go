@@ -0,0 +1,89 @@
+package urls
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+// BenchmarkNew_Size measures listing a user's URLs in storages of growing size.
+// Every user owns the same number of URLs, so the time per operation should stay
+// flat as the total number of stored URLs grows.
+func BenchmarkNew_Size(b *testing.B) {
+	const perUser = 10
+
+	for _, size := range []int{1_000, 1_000_000} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			storage := seedStorage(b, size, perUser)
+			handler := New(storage)
+			users := size / perUser
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/user/urls", http.NoBody)
+				req.Header.Set(string(constants.XUserID), "user"+strconv.Itoa(i%users))
+
+				w := httptest.NewRecorder()
+
+				handler(w, req)
+			}
+		})
+	}
+}
+
+// seedStorage creates a file storage preloaded with size URLs, perUser URLs for each user.
+func seedStorage(b *testing.B, size, perUser int) *memory.Storage {
+	b.Helper()
+
+	tempFile, err := os.CreateTemp("", "tempfile-*.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	})
+
+	writer := bufio.NewWriter(tempFile)
+
+	producer, err := memory.NewProducer(writer)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := range size {
+		err = producer.WriteURL(&models.URL{
+			ID:     int64(i + 1),
+			Code:   "code" + strconv.Itoa(i),
+			URL:    "https://example.com/" + strconv.Itoa(i),
+			UserID: "user" + strconv.Itoa(i/perUser),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err = tempFile.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	storage, err := memory.New(tempFile.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return storage
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -17,6 +18,7 @@ import (
 	"github.com/vadicheck/shorturl/internal/constants"
 	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
 )
 
@@ -123,7 +125,7 @@ func TestNew(t *testing.T) {
 			require.NoError(t, err)
 
 			for _, url := range tt.urls {
-				_, err = storage.SaveURL(ctx, url.Code, url.URL, url.UserID)
+				_, err = storage.SaveURL(ctx, url.Code, url.URL, url.UserID, repository.URLSettings{})
 				require.NoError(t, err)
 			}
 
@@ -131,9 +133,9 @@ func TestNew(t *testing.T) {
 
 			if tt.name == "Failed to get urls" {
 				mockStorage := new(MockStorage)
-				New(ctx, mockStorage)(w, req)
+				New(mockStorage)(w, req)
 			} else {
-				New(ctx, storage)(w, req)
+				New(storage)(w, req)
 			}
 
 			result := w.Result()
@@ -158,3 +160,57 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestNew_RemainingClicks(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	_, err = storage.SaveURL(ctx, "limited", "https://practicum.yandex.ru/", userOne, repository.URLSettings{MaxClicks: 3})
+	require.NoError(t, err)
+
+	granted, err := storage.ConsumeClick(ctx, "limited")
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set(string(constants.XUserID), userOne)
+	w := httptest.NewRecorder()
+
+	New(storage)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response []shorten.UserURLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Len(t, response, 1)
+	require.NotNil(t, response[0].RemainingClicks)
+	assert.Equal(t, int64(2), *response[0].RemainingClicks)
+}
+
+func TestNew_Protected(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	const hash = "$2a$10$abcdefghijklmnopqrstuuJ1Mqn0YH7JqZ2Fq1bcFq0x4Q0s0y1qW"
+
+	_, err = storage.SaveURL(ctx, "secret", "https://practicum.yandex.ru/", userOne, repository.URLSettings{PasswordHash: hash})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set(string(constants.XUserID), userOne)
+	w := httptest.NewRecorder()
+
+	New(storage)(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), hash)
+
+	var response []shorten.UserURLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Len(t, response, 1)
+	assert.True(t, response[0].Protected)
+}
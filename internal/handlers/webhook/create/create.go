@@ -0,0 +1,74 @@
+// Package create provides a handler for registering a webhook of the user.
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// WebhookCreator registers webhooks.
+type WebhookCreator interface {
+	Create(ctx context.Context, userID, endpoint, secret string) (models.Webhook, error)
+}
+
+// New creates a new handler function that registers a webhook for the user.
+//
+// It reads a JSON body with the URL of the endpoint and an optional secret, and responds
+// with 201 Created and the webhook. The secret is part of this response only; a random one
+// is generated if none is given. A URL that is not an absolute http or https URL, a secret
+// of the wrong length and too many webhooks are rejected with a bad request status.
+//
+// Parameters:
+// - webhooks: The webhook service used to register the webhook.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the new webhook.
+func New(webhooks WebhookCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		var request shorten.CreateWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			httpError.RespondWithError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+
+		hook, err := webhooks.Create(r.Context(), userID, request.URL, request.Secret)
+		if errors.Is(err, webhook.ErrInvalidParams) {
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Error("failed to create webhook", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to create webhook")
+			return
+		}
+
+		response := shorten.NewWebhookResponse(hook)
+		response.Secret = hook.Secret
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
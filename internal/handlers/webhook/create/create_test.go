@@ -0,0 +1,81 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+)
+
+const userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+
+func TestNew(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	webhooks := webhook.New(storage)
+	handler := New(webhooks)
+
+	tests := []struct {
+		name   string
+		userID string
+		body   string
+		status int
+		secret string
+	}{
+		{
+			name:   "created",
+			userID: userOne,
+			body:   `{"url":"https://example.com/hook","secret":"a shared secret of mine"}`,
+			status: http.StatusCreated,
+			secret: "a shared secret of mine",
+		},
+		{name: "generated secret", userID: userOne, body: `{"url":"https://example.com/hook"}`, status: http.StatusCreated},
+		{name: "relative url", userID: userOne, body: `{"url":"/hook"}`, status: http.StatusBadRequest},
+		{name: "short secret", userID: userOne, body: `{"url":"https://example.com/hook","secret":"short"}`, status: http.StatusBadRequest},
+		{name: "invalid json", userID: userOne, body: `{`, status: http.StatusBadRequest},
+		{name: "no user", body: `{"url":"https://example.com/hook"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", strings.NewReader(tt.body))
+			req.Header.Set(string(constants.XUserID), tt.userID)
+			rec := httptest.NewRecorder()
+
+			handler(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			var response shorten.WebhookResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			assert.Equal(t, "https://example.com/hook", response.URL)
+			assert.NotEmpty(t, response.Secret, "the secret is returned on creation")
+			if tt.secret != "" {
+				assert.Equal(t, tt.secret, response.Secret)
+			}
+
+			hooks, err := webhooks.List(context.Background(), userOne)
+			require.NoError(t, err)
+			require.NotEmpty(t, hooks)
+			hook := hooks[len(hooks)-1]
+			assert.Equal(t, response.ID, hook.ID, "the webhook is stored")
+			assert.Equal(t, response.Secret, hook.Secret)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Package deadletters provides a handler for listing the events that could not be delivered
+// to the webhooks of the user.
+package deadletters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// DeadLetterReader reads the dead letters of a user.
+type DeadLetterReader interface {
+	DeadLetters(ctx context.Context, userID string, limit int) ([]models.DeadLetter, error)
+}
+
+// New creates a new handler function that returns the events that could not be delivered
+// to the webhooks of the user, newest first, as a JSON array. The limit query parameter sets
+// their number.
+//
+// Parameters:
+// - webhooks: The webhook service used to read the dead letters.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the dead letters.
+func New(webhooks DeadLetterReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		var limit int
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				httpError.RespondWithError(w, http.StatusBadRequest, "limit is invalid")
+				return
+			}
+			limit = parsed
+		}
+
+		letters, err := webhooks.DeadLetters(r.Context(), userID, limit)
+		switch {
+		case errors.Is(err, webhook.ErrInvalidParams):
+			httpError.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		case err != nil:
+			slog.Error("failed to read dead letters", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get dead letters")
+			return
+		}
+
+		if letters == nil {
+			letters = []models.DeadLetter{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err = json.NewEncoder(w).Encode(letters); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
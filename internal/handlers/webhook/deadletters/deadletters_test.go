@@ -0,0 +1,72 @@
+package deadletters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	for _, code := range []string{"code1", "code2"} {
+		require.NoError(t, storage.SaveDeadLetter(context.Background(), models.DeadLetter{
+			Time:     time.Now().UTC(),
+			UserID:   userOne,
+			URL:      "https://example.com/hook",
+			Event:    models.LinkEvent{Action: models.EventCreate, OwnerID: userOne, Code: code},
+			Attempts: 5,
+			Error:    "unexpected status 503 Service Unavailable",
+		}))
+	}
+
+	handler := New(webhook.New(storage))
+
+	call := func(userID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/webhooks/dead-letters"+query, nil)
+		if userID != "" {
+			req.Header.Set(string(constants.XUserID), userID)
+		}
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec
+	}
+
+	rec := call(userOne, "?limit=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var letters []models.DeadLetter
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&letters))
+	require.Len(t, letters, 1)
+	assert.Equal(t, "code2", letters[0].Event.Code, "the newest dead letter comes first")
+	assert.Equal(t, 5, letters[0].Attempts)
+
+	rec = call(userTwo, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, call(userOne, "?limit=abc").Code)
+	assert.Equal(t, http.StatusBadRequest, call(userOne, "?limit=-1").Code)
+	assert.Equal(t, http.StatusBadRequest, call("", "").Code)
+}
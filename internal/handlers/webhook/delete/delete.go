@@ -0,0 +1,55 @@
+// Package delete provides a handler for deleting a webhook of the user.
+package delete
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// WebhookDeleter deletes webhooks.
+type WebhookDeleter interface {
+	Delete(ctx context.Context, userID, id string) error
+}
+
+// New creates a new handler function that deletes the webhook with the ID in the path.
+//
+// It responds with 204 No Content once the webhook is deleted; no events are posted to it
+// from then on. A webhook that is unknown, already deleted or owned by another user responds
+// with 404 Not Found.
+//
+// Parameters:
+// - webhooks: The webhook service used to delete the webhook.
+//
+// Returns:
+// - An HTTP handler function that processes the request.
+func New(webhooks WebhookDeleter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		err := webhooks.Delete(r.Context(), userID, r.PathValue("id"))
+		if errors.Is(err, webhook.ErrNotFound) {
+			httpError.RespondWithError(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		if err != nil {
+			slog.Error("failed to delete webhook", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to delete webhook")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,55 @@
+package delete
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	webhooks := webhook.New(storage)
+	created, err := webhooks.Create(ctx, userOne, "https://example.com/hook", "")
+	require.NoError(t, err)
+
+	handler := New(webhooks)
+
+	remove := func(userID, id string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/webhooks/"+id, nil)
+		req.SetPathValue("id", id)
+		req.Header.Set(string(constants.XUserID), userID)
+		rec := httptest.NewRecorder()
+
+		handler(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, remove(userTwo, created.ID), "a webhook of another user cannot be deleted")
+	assert.Equal(t, http.StatusNotFound, remove(userOne, "unknown"))
+	assert.Equal(t, http.StatusNoContent, remove(userOne, created.ID))
+	assert.Equal(t, http.StatusNotFound, remove(userOne, created.ID), "a webhook is deleted only once")
+
+	hooks, err := webhooks.List(ctx, userOne)
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+}
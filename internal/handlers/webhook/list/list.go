@@ -0,0 +1,67 @@
+// Package list provides a handler for listing the webhooks of the user.
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// WebhookLister lists the webhooks of a user.
+type WebhookLister interface {
+	List(ctx context.Context, userID string) ([]models.Webhook, error)
+}
+
+// New creates a new handler function that lists the webhooks of the user, oldest first.
+//
+// The secrets are never returned. If the user has no webhooks, it responds with 204 No Content.
+//
+// Parameters:
+// - webhooks: The webhook service used to list the webhooks.
+//
+// Returns:
+// - An HTTP handler function that processes the request and returns the webhooks.
+func New(webhooks WebhookLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get(string(constants.XUserID))
+
+		if userID == "" {
+			slog.Error("userID is empty")
+			httpError.RespondWithError(w, http.StatusBadRequest, "userID is empty")
+			return
+		}
+
+		hooks, err := webhooks.List(r.Context(), userID)
+		if err != nil {
+			slog.Error("failed to list webhooks", sl.Err(err))
+			httpError.RespondWithError(w, http.StatusInternalServerError, "Failed to get webhooks")
+			return
+		}
+
+		if len(hooks) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := make([]shorten.WebhookResponse, 0, len(hooks))
+		for _, hook := range hooks {
+			response = append(response, shorten.NewWebhookResponse(hook))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("error encoding response", sl.Err(err))
+			return
+		}
+	}
+}
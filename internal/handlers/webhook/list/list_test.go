@@ -0,0 +1,58 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models/shorten"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+)
+
+const (
+	userOne = "da9da41c-8f65-4ed3-abea-d58f57c41562"
+	userTwo = "da1da41c-8f69-4ed3-abea-d58f57c41409"
+)
+
+func TestNew(t *testing.T) {
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	webhooks := webhook.New(storage)
+	created, err := webhooks.Create(context.Background(), userOne, "https://example.com/hook", "")
+	require.NoError(t, err)
+
+	handler := New(webhooks)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/webhooks", nil)
+	req.Header.Set(string(constants.XUserID), userOne)
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Secret, "the secret is never listed")
+
+	var response []shorten.WebhookResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	require.Len(t, response, 1)
+	assert.Equal(t, created.ID, response[0].ID)
+	assert.Equal(t, created.URL, response[0].URL)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/webhooks", nil)
+	req.Header.Set(string(constants.XUserID), userTwo)
+	rec = httptest.NewRecorder()
+
+	handler(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
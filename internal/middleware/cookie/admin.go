@@ -0,0 +1,45 @@
+package cookie
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// Admins tells the accounts with the admin role apart.
+type Admins interface {
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+}
+
+// RequireAdmin returns a middleware that admits only the requests of an account with the admin
+// role, responding with 403 Forbidden otherwise. It relies on the UserID set by the middleware
+// returned by New, so it must be mounted after it. Anonymous users are never admins, and API
+// keys are turned away from the admin API before this check.
+func RequireAdmin(admins Admins) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Header.Get(string(constants.XUserID))
+
+			isAdmin, err := admins.IsAdmin(r.Context(), userID)
+			if err != nil {
+				slog.Error("can't check admin role", sl.Err(err))
+				httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
+				return
+			}
+
+			if !isAdmin {
+				slog.Info("admin request denied", slog.String("user_id", userID))
+				httpError.RespondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
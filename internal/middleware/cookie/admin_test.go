@@ -0,0 +1,56 @@
+package cookie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+)
+
+// mockAdmins reports the listed users as admins and fails for the user "broken".
+type mockAdmins map[string]bool
+
+func (m mockAdmins) IsAdmin(_ context.Context, userID string) (bool, error) {
+	if userID == "broken" {
+		return false, errors.New("storage is down")
+	}
+
+	return m[userID], nil
+}
+
+func TestRequireAdmin(t *testing.T) {
+	admins := mockAdmins{"admin": true, "user": false}
+
+	handler := RequireAdmin(admins)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		userID string
+		status int
+	}{
+		{name: "admin", userID: "admin", status: http.StatusOK},
+		{name: "user", userID: "user", status: http.StatusForbidden},
+		{name: "anonymous", userID: "anonymous", status: http.StatusForbidden},
+		{name: "no user", status: http.StatusForbidden},
+		{name: "storage error", userID: "broken", status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, adminPath+"/urls", nil)
+			req.Header.Set(string(constants.XUserID), tt.userID)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
@@ -1,18 +1,28 @@
 // Package cookie provides a middleware to handle secure cookies for user authentication.
 // It checks for the presence of a "user" cookie, creates a new one if absent, and decodes
 // the cookie to retrieve the user information (UserID) for the request context.
+// Clients without a cookie jar may identify themselves with a bearer JWT instead, and
+// server-to-server clients with an API key.
+//
+// Cookies and tokens are signed with the keys of a keyring; the ones signed with a previous
+// key are re-issued with the current key, so a key rotation does not log anybody out.
 package cookie
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/securecookie"
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
 	httpError "github.com/vadicheck/shorturl/internal/http/error"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/keyring"
 	"github.com/vadicheck/shorturl/pkg/logger/sl"
 )
 
@@ -23,11 +33,54 @@ type user struct {
 
 const userUrls = "/api/user/urls"
 
+// userKeys is the path of the API key management, which API keys themselves cannot reach.
+const userKeys = "/api/user/keys"
+
+// userLogin and userRegister are the paths of the account handlers, which attach the caller's
+// identity to an account and so cannot be reached with an API key either.
+const (
+	userLogin    = "/api/user/login"
+	userRegister = "/api/user/register"
+)
+
+// adminPath is the prefix of the admin API, which API keys cannot reach either.
+const adminPath = "/api/admin"
+
+// bearerPrefix is the scheme prefix of the Authorization header carrying a token.
+const bearerPrefix = "Bearer "
+
+// Cookies encodes and decodes the values of the "user" cookie.
+type Cookies interface {
+	Encode(name string, value any) (string, error)
+	Decode(name, value string, dst any) (stale bool, err error)
+}
+
+// Tokens issues and verifies the bearer tokens identifying users.
+type Tokens interface {
+	Issue(userID string) (string, error)
+	Parse(token string) (userID string, stale bool, err error)
+}
+
+// APIKeys verifies the API keys of server-to-server clients.
+type APIKeys interface {
+	Authenticate(ctx context.Context, secret string) (models.APIKey, error)
+}
+
 // New returns a middleware function for secure cookie authentication.
 //
-// The middleware checks if the "user" cookie exists in the incoming request. If the cookie
-// is missing, a new "user" cookie is created with a new unique UserID. If the cookie exists,
-// it is decoded, and the UserID is extracted and added to the request's header.
+// A request carrying an `X-API-Key` header is identified by the key alone: an invalid key is
+// rejected with 401 Unauthorized, and a request the key has no scope for, or one managing the
+// API keys, logging in, registering or calling the admin API, with 403 Forbidden. GET and HEAD
+// need the read scope, DELETE the delete scope and any other method the write scope.
+//
+// A request carrying an `Authorization: Bearer` token is identified by the token alone;
+// an invalid token is rejected with 401 Unauthorized. Otherwise the middleware checks
+// if the "user" cookie exists in the incoming request. If the cookie is missing, a new
+// "user" cookie is created with a new unique UserID, and a token for the same UserID is
+// returned in the `Authorization` response header. If the cookie exists, it is decoded,
+// and the UserID is extracted and added to the request's header. A cookie or token signed
+// with a previous key is sent back re-signed with the current one. A cookie signed with a
+// key that has expired or been removed is treated as a missing one.
 //
 // The UserID is set in the `X-User-ID` header for the downstream handlers to use. If the cookie
 // is absent, the middleware sets a new cookie in the response and continues processing the request.
@@ -36,22 +89,72 @@ const userUrls = "/api/user/urls"
 // to the client with an appropriate status code.
 //
 // Parameters:
-//   - None (this is a middleware factory function)
+//   - cookies: The codec of the "user" cookie.
+//   - tokens: The issuer and verifier of the bearer tokens.
+//   - keys: The verifier of the API keys.
 //
 // Returns:
 //   - A middleware function that can be used with `http.Handle` or other HTTP routers.
-func New() func(next http.Handler) http.Handler {
+func New(cookies Cookies, tokens Tokens, keys APIKeys) func(next http.Handler) http.Handler {
 	slog.Info("cookie middleware enabled")
 
-	var (
-		s = securecookie.New(
-			[]byte(config.Config.SecureCookieHashKey),
-			[]byte(config.Config.SecureCookieBlockKey),
-		)
-	)
+	sessions := NewSessions(cookies, tokens)
 
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
+			if secret := r.Header.Get(string(constants.XAPIKey)); secret != "" {
+				key, err := keys.Authenticate(r.Context(), secret)
+				if errors.Is(err, apikey.ErrInvalidKey) {
+					slog.Info("invalid api key")
+					httpError.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				if err != nil {
+					slog.Error("can't verify api key", sl.Err(err))
+					httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
+					return
+				}
+
+				if !key.HasScope(models.MethodScope(r.Method)) || strings.HasPrefix(r.URL.Path, userKeys) ||
+					r.URL.Path == userLogin || r.URL.Path == userRegister ||
+					strings.HasPrefix(r.URL.Path, adminPath) {
+					slog.Info("api key out of scope", slog.String("key", key.ID), slog.String("method", r.Method))
+					httpError.RespondWithError(w, http.StatusForbidden, "Forbidden")
+					return
+				}
+
+				r.Header.Set(string(constants.XUserID), key.UserID)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if authorization := r.Header.Get("Authorization"); authorization != "" {
+				raw, ok := strings.CutPrefix(authorization, bearerPrefix)
+				if !ok {
+					httpError.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+
+				userID, stale, err := tokens.Parse(raw)
+				if err != nil {
+					slog.Info("invalid bearer token", sl.Err(err))
+					httpError.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+
+				if stale {
+					if token, errToken := tokens.Issue(userID); errToken != nil {
+						slog.Error("can't re-issue user token", sl.Err(errToken))
+					} else {
+						w.Header().Set("Authorization", bearerPrefix+token)
+					}
+				}
+
+				r.Header.Set(string(constants.XUserID), userID)
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			userCookie, err := r.Cookie("user")
 			if err != nil && !errors.Is(err, http.ErrNoCookie) {
 				slog.Error("\"user\" cookie not found")
@@ -59,27 +162,32 @@ func New() func(next http.Handler) http.Handler {
 				return
 			}
 
-			if userCookie == nil || errors.Is(err, http.ErrNoCookie) {
-				user := &user{
-					UserID: uuid.New().String(),
-				}
+			u := &user{}
+			var stale bool
 
-				encoded, errCookieEncode := s.Encode("user", user)
-				if errCookieEncode != nil {
-					slog.Error("can't build secure cookie", sl.Err(errCookieEncode))
+			if userCookie != nil {
+				stale, err = cookies.Decode("user", userCookie.Value, u)
+				if errors.Is(err, keyring.ErrUnknownKey) {
+					// The cookie was signed with a key that has expired or been removed.
+					slog.Info("user cookie of an unknown key, issuing a new one", sl.Err(err))
+					userCookie = nil
+				} else if err != nil {
+					slog.Error("can't decode user cookie", sl.Err(err))
 					httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
 					return
 				}
+			}
 
-				cookie := &http.Cookie{
-					Name:   "user",
-					Value:  encoded,
-					Path:   "/",
-					MaxAge: int(config.Config.SecureCookieExpire.Seconds()),
+			if userCookie == nil {
+				userID := uuid.New().String()
+
+				if errIssue := sessions.Issue(w, userID); errIssue != nil {
+					slog.Error("can't issue user session", sl.Err(errIssue))
+					httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
+					return
 				}
-				http.SetCookie(w, cookie)
 
-				r.Header.Set(string(constants.XUserID), user.UserID)
+				r.Header.Set(string(constants.XUserID), userID)
 
 				if userUrls == r.URL.String() {
 					w.Header().Set("Content-Type", "application/json")
@@ -91,19 +199,20 @@ func New() func(next http.Handler) http.Handler {
 				return
 			}
 
-			u := &user{}
-			if err = s.Decode("user", userCookie.Value, u); err != nil {
-				slog.Error("can't decode user cookie", sl.Err(err))
-				httpError.RespondWithError(w, http.StatusInternalServerError, "Auth error")
-				return
-			}
-
 			if u.UserID == "" {
 				slog.Error("user_id is absent in cookie")
 				httpError.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 				return
 			}
 
+			if stale {
+				if encoded, errEncode := cookies.Encode("user", u); errEncode != nil {
+					slog.Error("can't re-issue secure cookie", sl.Err(errEncode))
+				} else {
+					setUserCookie(w, encoded)
+				}
+			}
+
 			r.Header.Set(string(constants.XUserID), u.UserID)
 			next.ServeHTTP(w, r)
 		}
@@ -111,3 +220,44 @@ func New() func(next http.Handler) http.Handler {
 		return http.HandlerFunc(fn)
 	}
 }
+
+// Sessions issues the "user" cookie and the bearer token identifying a user. Besides the
+// middleware, it is used by the handlers that switch the identity of a client, such as the login.
+type Sessions struct {
+	cookies Cookies
+	tokens  Tokens
+}
+
+// NewSessions creates a session issuer signing with cookies and tokens.
+func NewSessions(cookies Cookies, tokens Tokens) *Sessions {
+	return &Sessions{cookies: cookies, tokens: tokens}
+}
+
+// Issue sets the "user" cookie and the `Authorization` header identifying userID on the response.
+// Nothing is set if either cannot be built.
+func (s *Sessions) Issue(w http.ResponseWriter, userID string) error {
+	encoded, err := s.cookies.Encode("user", &user{UserID: userID})
+	if err != nil {
+		return fmt.Errorf("can't build secure cookie: %w", err)
+	}
+
+	token, err := s.tokens.Issue(userID)
+	if err != nil {
+		return fmt.Errorf("can't issue user token: %w", err)
+	}
+
+	setUserCookie(w, encoded)
+	w.Header().Set("Authorization", bearerPrefix+token)
+
+	return nil
+}
+
+// setUserCookie sets the "user" cookie to the encoded value.
+func setUserCookie(w http.ResponseWriter, encoded string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   "user",
+		Value:  encoded,
+		Path:   "/",
+		MaxAge: int(config.Config.SecureCookieExpire.Seconds()),
+	})
+}
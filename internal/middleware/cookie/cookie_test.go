@@ -1,29 +1,83 @@
 package cookie
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/gorilla/securecookie"
-
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vadicheck/shorturl/internal/config"
 	"github.com/vadicheck/shorturl/internal/constants"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+	"github.com/vadicheck/shorturl/internal/services/token"
 )
 
 func init() {
-	config.Config.SecureCookieHashKey = "very-secret"
-	config.Config.SecureCookieBlockKey = "alotsecretalotsecretalotsecretgr"
 	config.Config.SecureCookieExpire = 3600
 }
 
+var oldKey = keyring.Key{
+	ID:        "old",
+	HashKey:   "very-secret",
+	BlockKey:  "alotsecretalotsecretalotsecretgr",
+	JwtSecret: "secret",
+}
+
+// newAuth returns the cookie codec and the token manager over a keyring of keys,
+// by default of oldKey alone.
+func newAuth(t *testing.T, keys ...keyring.Key) (*keyring.Cookies, *token.Manager) {
+	t.Helper()
+
+	if len(keys) == 0 {
+		keys = []keyring.Key{oldKey}
+	}
+
+	ring, err := keyring.New(keys)
+	require.NoError(t, err)
+
+	tokens, err := token.New(token.Config{TTL: time.Hour}, ring)
+	require.NoError(t, err)
+
+	return keyring.NewCookies(ring), tokens
+}
+
+// newMiddleware returns the middleware over a keyring of oldKey alone, without API keys.
+func newMiddleware(t *testing.T) func(http.Handler) http.Handler {
+	t.Helper()
+
+	cookies, tokens := newAuth(t)
+
+	return New(cookies, tokens, mockKeys{})
+}
+
+// mockKeys holds the API keys by secret. The secret "broken" fails as a storage error would.
+type mockKeys map[string]models.APIKey
+
+func (m mockKeys) Authenticate(_ context.Context, secret string) (models.APIKey, error) {
+	if secret == "broken" {
+		return models.APIKey{}, errors.New("storage is down")
+	}
+
+	key, ok := m[secret]
+	if !ok {
+		return models.APIKey{}, apikey.ErrInvalidKey
+	}
+
+	return key, nil
+}
+
 func TestMiddleware_NewUserCookie(t *testing.T) {
-	middleware := New()
+	middleware := newMiddleware(t)
 	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Header.Get(string(constants.XUserID))
 
@@ -58,11 +112,11 @@ func TestMiddleware_NewUserCookie(t *testing.T) {
 }
 
 func TestMiddleware_ExistingUserCookie(t *testing.T) {
-	middleware := New()
-	s := securecookie.New([]byte(config.Config.SecureCookieHashKey), []byte(config.Config.SecureCookieBlockKey))
+	cookies, tokens := newAuth(t)
+	middleware := New(cookies, tokens, mockKeys{})
 
 	userID := uuid.New().String()
-	encoded, err := s.Encode("user", user{UserID: userID})
+	encoded, err := cookies.Encode("user", user{UserID: userID})
 	require.NoError(t, err)
 
 	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -87,30 +141,57 @@ func TestMiddleware_ExistingUserCookie(t *testing.T) {
 }
 
 func TestMiddleware_InvalidUserCookie(t *testing.T) {
-	middleware := New()
+	oldCookies, _ := newAuth(t)
 
-	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
+	expired := oldKey
+	expired.NotBefore = time.Now().Add(-2 * time.Hour)
+	expired.NotAfter = time.Now().Add(-time.Hour)
+	newKey := keyring.Key{ID: "new", HashKey: "another-secret", JwtSecret: "another secret"}
+	cookies, tokens := newAuth(t, expired, newKey)
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	req.AddCookie(&http.Cookie{Name: "user", Value: "invalid-cookie-value"})
+	userID := uuid.New().String()
+	encoded, err := oldCookies.Encode("user", user{UserID: userID})
+	require.NoError(t, err)
 
-	rec := httptest.NewRecorder()
-	handler.ServeHTTP(rec, req)
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "expired key", value: encoded},
+		{name: "forged", value: "invalid-cookie-value"},
+	}
 
-	resp := rec.Result()
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			slog.Error(fmt.Sprintf("failed to close body: %v", err))
-		}
-	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := New(cookies, tokens, mockKeys{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(string(constants.XUserID))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "user", Value: tt.value})
+			rec := httptest.NewRecorder()
 
-	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.NotEmpty(t, got)
+			assert.NotEqual(t, userID, got, "a new identity is issued")
+
+			issued := rec.Result().Cookies()
+			require.Len(t, issued, 1)
+
+			u := &user{}
+			_, err := cookies.Decode("user", issued[0].Value, u)
+			require.NoError(t, err)
+			assert.Equal(t, got, u.UserID)
+		})
+	}
 }
 
 func TestMiddleware_RequestToUserUrlsWithoutCookie(t *testing.T) {
-	middleware := New()
+	middleware := newMiddleware(t)
 
 	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -130,3 +211,206 @@ func TestMiddleware_RequestToUserUrlsWithoutCookie(t *testing.T) {
 
 	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
 }
+
+func TestMiddleware_NewUserToken(t *testing.T) {
+	cookies, tokens := newAuth(t)
+
+	var userID string
+	handler := New(cookies, tokens, mockKeys{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID = r.Header.Get(string(constants.XUserID))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	raw, ok := strings.CutPrefix(rec.Header().Get("Authorization"), "Bearer ")
+	require.True(t, ok)
+
+	tokenUserID, _, err := tokens.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, userID, tokenUserID)
+}
+
+func TestMiddleware_BearerToken(t *testing.T) {
+	cookies, tokens := newAuth(t)
+
+	userID := uuid.New().String()
+	signed, err := tokens.Issue(userID)
+	require.NoError(t, err)
+
+	handler := New(cookies, tokens, mockKeys{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userID, r.Header.Get(string(constants.XUserID)))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Result().Cookies(), "a token holder gets no new identity")
+	assert.Empty(t, rec.Header().Get("Authorization"))
+}
+
+func TestMiddleware_InvalidBearerToken(t *testing.T) {
+	handler := newMiddleware(t)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be called")
+	}))
+
+	for _, authorization := range []string{"Bearer invalid", "Basic dXNlcjpwYXNz"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+	}
+}
+
+func TestMiddleware_RotatedKey(t *testing.T) {
+	oldCookies, oldTokens := newAuth(t)
+
+	newKey := keyring.Key{ID: "new", HashKey: "another-secret", JwtSecret: "another secret", NotBefore: time.Now()}
+	cookies, tokens := newAuth(t, oldKey, newKey)
+
+	userID := uuid.New().String()
+	handler := New(cookies, tokens, mockKeys{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userID, r.Header.Get(string(constants.XUserID)), "a session of the previous key is accepted")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("cookie", func(t *testing.T) {
+		encoded, err := oldCookies.Encode("user", user{UserID: userID})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "user", Value: encoded})
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		reissued := rec.Result().Cookies()
+		require.Len(t, reissued, 1, "the cookie is re-issued")
+
+		u := &user{}
+		stale, err := cookies.Decode("user", reissued[0].Value, u)
+		require.NoError(t, err)
+		assert.False(t, stale, "the re-issued cookie is signed with the current key")
+		assert.Equal(t, userID, u.UserID)
+
+		_, err = oldCookies.Decode("user", reissued[0].Value, &user{})
+		assert.Error(t, err)
+	})
+
+	t.Run("bearer token", func(t *testing.T) {
+		signed, err := oldTokens.Issue(userID)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		raw, ok := strings.CutPrefix(rec.Header().Get("Authorization"), "Bearer ")
+		require.True(t, ok, "the token is re-issued")
+
+		tokenUserID, stale, err := tokens.Parse(raw)
+		require.NoError(t, err)
+		assert.False(t, stale, "the re-issued token is signed with the current key")
+		assert.Equal(t, userID, tokenUserID)
+	})
+
+	t.Run("current key", func(t *testing.T) {
+		signed, err := tokens.Issue(userID)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Empty(t, rec.Header().Get("Authorization"), "a token of the current key is not re-issued")
+	})
+}
+
+func TestMiddleware_APIKey(t *testing.T) {
+	cookies, tokens := newAuth(t)
+	keys := mockKeys{
+		"sk_reader": {ID: "key1", UserID: "user1", Scopes: []models.APIKeyScope{models.ScopeRead}},
+		"sk_writer": {ID: "key2", UserID: "user2", Scopes: []models.APIKeyScope{models.ScopeWrite, models.ScopeDelete}},
+	}
+
+	handler := New(cookies, tokens, keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-User-ID", r.Header.Get(string(constants.XUserID)))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		secret string
+		method string
+		path   string
+		status int
+		userID string
+	}{
+		{name: "read", secret: "sk_reader", method: http.MethodGet, path: userUrls, status: http.StatusOK, userID: "user1"},
+		{name: "write", secret: "sk_writer", method: http.MethodPost, path: "/api/shorten", status: http.StatusOK, userID: "user2"},
+		{name: "delete", secret: "sk_writer", method: http.MethodDelete, path: userUrls, status: http.StatusOK, userID: "user2"},
+		{name: "out of scope", secret: "sk_reader", method: http.MethodPost, path: "/api/shorten", status: http.StatusForbidden},
+		{name: "key management", secret: "sk_reader", method: http.MethodGet, path: userKeys, status: http.StatusForbidden},
+		{name: "login", secret: "sk_writer", method: http.MethodPost, path: userLogin, status: http.StatusForbidden},
+		{name: "register", secret: "sk_writer", method: http.MethodPost, path: userRegister, status: http.StatusForbidden},
+		{name: "admin api", secret: "sk_reader", method: http.MethodGet, path: adminPath + "/urls", status: http.StatusForbidden},
+		{name: "unknown key", secret: "sk_unknown", method: http.MethodGet, path: userUrls, status: http.StatusUnauthorized},
+		{name: "storage error", secret: "broken", method: http.MethodGet, path: userUrls, status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(string(constants.XAPIKey), tt.secret)
+			req.Header.Set(string(constants.XUserID), "spoofed")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.userID, rec.Header().Get("X-Seen-User-ID"))
+			assert.Empty(t, rec.Result().Cookies(), "an API key holder gets no cookie")
+		})
+	}
+}
+
+func TestSessions_Issue(t *testing.T) {
+	cookies, tokens := newAuth(t)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, NewSessions(cookies, tokens).Issue(rec, "account"))
+
+	issued := rec.Result().Cookies()
+	require.Len(t, issued, 1)
+
+	u := &user{}
+	_, err := cookies.Decode("user", issued[0].Value, u)
+	require.NoError(t, err)
+	assert.Equal(t, "account", u.UserID)
+
+	raw, ok := strings.CutPrefix(rec.Header().Get("Authorization"), "Bearer ")
+	require.True(t, ok)
+
+	userID, _, err := tokens.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "account", userID)
+}
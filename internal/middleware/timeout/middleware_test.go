@@ -0,0 +1,63 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutMiddleware_InTime(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.True(t, ok)
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("OK"))
+	})
+
+	rr := httptest.NewRecorder()
+	New(time.Second)(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "OK", rr.Body.String())
+}
+
+func TestTimeoutMiddleware_Expired(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+
+		w.Header().Set("Location", "https://example.com")
+		http.Error(w, "Failed to get url", http.StatusInternalServerError)
+	})
+
+	rr := httptest.NewRecorder()
+	New(10*time.Millisecond)(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Empty(t, rr.Header().Get("Location"))
+	assert.JSONEq(t, `{"error":"Request timed out"}`, rr.Body.String())
+}
+
+func TestTimeoutMiddleware_ExpiredWithoutResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	rr := httptest.NewRecorder()
+	New(10*time.Millisecond)(handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+}
+
+func TestTimeoutMiddleware_Disabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		assert.False(t, ok)
+	})
+
+	New(0)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
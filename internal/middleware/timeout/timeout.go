@@ -0,0 +1,85 @@
+// Package timeout provides a middleware that bounds the time a request may take.
+// The deadline is set on the request context, so it cancels the service and storage
+// calls made with that context, and a request that runs out of time is answered
+// with 504 Gateway Timeout.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+)
+
+// timeoutWriter replaces the response with a 504 Gateway Timeout
+// if the handler starts writing it after the deadline has passed.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+// WriteHeader writes the handler's status code, or the timeout response once the deadline has passed.
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+
+		// Drop the headers set by the handler, such as Location.
+		header := w.ResponseWriter.Header()
+		for key := range header {
+			delete(header, key)
+		}
+
+		httpError.RespondWithError(w.ResponseWriter, http.StatusGatewayTimeout, "Request timed out")
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the response body, discarding it if the request has timed out.
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.timedOut {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// New returns a middleware that cancels the request context after the given timeout.
+// A zero or negative timeout disables the middleware.
+//
+// If the deadline passes before the handler has started the response, the response
+// the handler writes afterwards is replaced with a 504 Gateway Timeout.
+func New(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w, ctx: ctx}
+
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				tw.WriteHeader(http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// Package trusted provides a middleware that admits only the requests coming from a trusted subnet.
+//
+// The client IP is taken from the X-Real-IP header set by the proxy in front of the service,
+// so the middleware must only be used behind a proxy that overwrites the header.
+package trusted
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+	httpError "github.com/vadicheck/shorturl/internal/http/error"
+)
+
+// New returns a middleware that responds with 403 Forbidden unless the X-Real-IP
+// of the request falls into subnet. A nil subnet denies every request.
+func New(subnet *net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(r.Header.Get(string(constants.XRealIP)))
+
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				slog.Info("untrusted request denied", slog.String("ip", r.Header.Get(string(constants.XRealIP))))
+				httpError.RespondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ParseSubnet parses a subnet in CIDR notation. An empty string yields a nil subnet.
+func ParseSubnet(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	return subnet, nil
+}
@@ -0,0 +1,66 @@
+package trusted
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/constants"
+)
+
+func TestTrustedMiddleware(t *testing.T) {
+	subnet, err := ParseSubnet("192.168.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		subnet     string
+		realIP     string
+		statusCode int
+	}{
+		{name: "inside subnet", subnet: "192.168.1.0/24", realIP: "192.168.1.17", statusCode: http.StatusOK},
+		{name: "outside subnet", subnet: "192.168.1.0/24", realIP: "192.168.2.17", statusCode: http.StatusForbidden},
+		{name: "no header", subnet: "192.168.1.0/24", statusCode: http.StatusForbidden},
+		{name: "malformed header", subnet: "192.168.1.0/24", realIP: "192.168.1", statusCode: http.StatusForbidden},
+		{name: "no subnet", realIP: "192.168.1.17", statusCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			if tt.realIP != "" {
+				req.Header.Set(string(constants.XRealIP), tt.realIP)
+			}
+
+			trustedSubnet := subnet
+			if tt.subnet == "" {
+				trustedSubnet = nil
+			}
+
+			rr := httptest.NewRecorder()
+			New(trustedSubnet)(handler).ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.statusCode, rr.Code)
+		})
+	}
+}
+
+func TestParseSubnet(t *testing.T) {
+	subnet, err := ParseSubnet("")
+	require.NoError(t, err)
+	assert.Nil(t, subnet)
+
+	subnet, err = ParseSubnet("10.0.0.0/8")
+	require.NoError(t, err)
+	assert.True(t, subnet.Contains([]byte{10, 1, 2, 3}))
+
+	_, err = ParseSubnet("10.0.0.0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// URLFilter selects URLs across all users. Empty fields match every URL.
+type URLFilter struct {
+	// Code matches the URL with the short code.
+	Code string
+
+	// URL matches the URL with the original URL.
+	URL string
+
+	// UserID matches the URLs owned by the user.
+	UserID string
+
+	// Deleted, if set, matches the URLs that are or are not soft deleted.
+	Deleted *bool
+
+	// Disabled, if set, matches the URLs that are or are not disabled.
+	Disabled *bool
+
+	// Limit is the maximum number of URLs returned. Zero means no limit.
+	Limit int
+
+	// Offset is the number of matching URLs skipped, in the order of their IDs.
+	Offset int
+}
+
+// Match reports whether the URL matches the filter, ignoring Limit and Offset.
+func (f URLFilter) Match(url URL) bool {
+	return (f.Code == "" || url.Code == f.Code) &&
+		(f.URL == "" || url.URL == f.URL) &&
+		(f.UserID == "" || url.UserID == f.UserID) &&
+		(f.Deleted == nil || url.IsDeleted == *f.Deleted) &&
+		(f.Disabled == nil || url.IsDisabled == *f.Disabled)
+}
+
+// The actions of admins recorded in the audit trail.
+const (
+	AuditDisableURL   = "disable_url"
+	AuditEnableURL    = "enable_url"
+	AuditReassignUser = "reassign_user"
+	AuditPurgeUser    = "purge_user"
+	AuditSetRole      = "set_role"
+)
+
+// AuditEntry records an action of an admin.
+type AuditEntry struct {
+	// Time is the time of the action in UTC.
+	Time time.Time `json:"time"`
+
+	// ActorID is the ID of the admin who acted.
+	ActorID string `json:"actor_id"`
+
+	// Action is the kind of action, one of the Audit constants.
+	Action string `json:"action"`
+
+	// Target is the short code or the user ID acted on.
+	Target string `json:"target"`
+
+	// Details describes the outcome of the action, if any.
+	Details string `json:"details,omitempty"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// APIKeyScope is a kind of operation an API key is allowed to perform.
+type APIKeyScope string
+
+const (
+	// ScopeRead allows the requests that only read, GET and HEAD.
+	ScopeRead APIKeyScope = "read"
+
+	// ScopeWrite allows the requests that create or change, POST, PUT and PATCH.
+	ScopeWrite APIKeyScope = "write"
+
+	// ScopeDelete allows the DELETE requests.
+	ScopeDelete APIKeyScope = "delete"
+)
+
+// ParseAPIKeyScope parses an API key scope.
+func ParseAPIKeyScope(scope string) (APIKeyScope, error) {
+	switch APIKeyScope(scope) {
+	case ScopeRead, ScopeWrite, ScopeDelete:
+		return APIKeyScope(scope), nil
+	default:
+		return "", fmt.Errorf("unknown scope %q, expected %q, %q or %q", scope, ScopeRead, ScopeWrite, ScopeDelete)
+	}
+}
+
+// MethodScope returns the scope a request with the given HTTP method requires.
+func MethodScope(method string) APIKeyScope {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	case http.MethodDelete:
+		return ScopeDelete
+	default:
+		return ScopeWrite
+	}
+}
+
+// APIKey is a key a server-to-server client authenticates with on behalf of a user.
+// Only the hash of the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	// ID identifies the key, for listing and revoking it.
+	ID string `json:"id"`
+
+	// UserID is the ID of the user the key acts for.
+	UserID string `json:"user_id"`
+
+	// Name is an optional label chosen by the user.
+	Name string `json:"name,omitempty"`
+
+	// Prefix is the beginning of the key, for the user to tell their keys apart.
+	Prefix string `json:"prefix"`
+
+	// Hash is the hex SHA-256 hash of the key.
+	Hash string `json:"hash"`
+
+	// Scopes are the kinds of operations the key is allowed to perform.
+	Scopes []APIKeyScope `json:"scopes"`
+
+	// CreatedAt is the time the key was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// RevokedAt is the time the key was revoked. The zero value means it is active.
+	RevokedAt time.Time `json:"revoked_at,omitzero"`
+}
+
+// IsRevoked reports whether the key has been revoked.
+func (k APIKey) IsRevoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// HasScope reports whether the key is allowed to perform operations of scope.
+func (k APIKey) HasScope(scope APIKeyScope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Click is a single resolve of a short URL.
+type Click struct {
+	// URLID is the ID of the resolved URL. Clicks are tied to the ID rather than the code,
+	// so a code reused after a purge starts with no clicks.
+	URLID int64 `json:"url_id"`
+
+	// Code is the short code that was resolved.
+	Code string `json:"code"`
+
+	// Time is the time of the resolve in UTC.
+	Time time.Time `json:"time"`
+
+	// Referrer is the Referer header of the request, if any.
+	Referrer string `json:"referrer,omitempty"`
+
+	// UserAgent is the User-Agent of the client, if any.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// IPHash is the keyed hash of the client IP. The IP itself is never stored.
+	IPHash string `json:"ip_hash,omitempty"`
+}
+
+// StatsInterval is the size of the time buckets click statistics are grouped by.
+type StatsInterval string
+
+const (
+	// StatsHour groups clicks by the hour.
+	StatsHour StatsInterval = "hour"
+
+	// StatsDay groups clicks by the day.
+	StatsDay StatsInterval = "day"
+)
+
+// ParseStatsInterval parses a statistics interval. An empty string means StatsDay.
+func ParseStatsInterval(interval string) (StatsInterval, error) {
+	switch StatsInterval(interval) {
+	case "", StatsDay:
+		return StatsDay, nil
+	case StatsHour:
+		return StatsHour, nil
+	default:
+		return "", fmt.Errorf("unknown stats interval %q, expected %q or %q", interval, StatsHour, StatsDay)
+	}
+}
+
+// Truncate returns the start of the UTC bucket t falls into.
+func (i StatsInterval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+
+	if i == StatsHour {
+		return t.Truncate(time.Hour)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ClickStats aggregates the clicks of a short URL.
+type ClickStats struct {
+	// Total is the number of clicks.
+	Total int64
+
+	// UniqueVisitors is the number of distinct client IPs.
+	UniqueVisitors int64
+
+	// Buckets holds the number of clicks per interval, oldest first. Empty intervals are omitted.
+	Buckets []ClickBucket
+}
+
+// ClickBucket is the number of clicks within one interval.
+type ClickBucket struct {
+	// Start is the start of the interval in UTC.
+	Start time.Time
+
+	// Clicks is the number of clicks within the interval.
+	Clicks int64
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// The lifecycle events of a short URL. The admin actions on a URL are recorded under
+// the Audit constants.
+const (
+	EventCreate      = "create"
+	EventBatchCreate = "batch_create"
+	EventDelete      = "delete"
+	EventRestore     = "restore"
+)
+
+// EventClick is a redirect through a short URL. It changes nothing, so it is delivered to
+// the webhooks of the owner but kept out of the history.
+const EventClick = "click"
+
+// LinkEvent records a change to a short URL.
+type LinkEvent struct {
+	// Time is the time of the change in UTC.
+	Time time.Time `json:"time"`
+
+	// Action is the kind of change, one of the Event or Audit constants.
+	Action string `json:"action"`
+
+	// ActorID is the ID of the user who made the change: the owner, or an admin.
+	ActorID string `json:"actor_id"`
+
+	// OwnerID is the ID of the user owning the URL after the change.
+	OwnerID string `json:"owner_id"`
+
+	// Code is the short code of the URL.
+	Code string `json:"code"`
+
+	// URL is the original URL.
+	URL string `json:"url"`
+
+	// RequestID is the ID of the request that made the change, if known.
+	RequestID string `json:"request_id,omitempty"`
+}
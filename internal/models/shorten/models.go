@@ -2,11 +2,33 @@
 // as well as helper functions for handling errors.
 package shorten
 
+import (
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
 // CreateURLRequest represents the request body for creating a shortened URL.
-// It contains the original URL that needs to be shortened.
+// It contains the original URL that needs to be shortened, an optional custom alias,
+// an optional expiration, an optional click limit and an optional password.
 type CreateURLRequest struct {
 	// URL is the original URL to be shortened.
 	URL string `json:"url"`
+
+	// Alias is the custom short code to use instead of a generated one.
+	Alias string `json:"alias,omitempty"`
+
+	// ExpiresAt is the time the short URL stops resolving.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// TTL is the lifetime of the short URL in seconds. It cannot be combined with ExpiresAt.
+	TTL int64 `json:"ttl,omitempty"`
+
+	// MaxClicks is the number of redirects the short URL allows; 1 makes a one-time link.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+
+	// Password is required to follow the short URL. It is only stored hashed.
+	Password string `json:"password,omitempty"`
 }
 
 // CreateURLResponse represents the response body when a single URL is successfully shortened.
@@ -17,13 +39,29 @@ type CreateURLResponse struct {
 }
 
 // CreateBatchURLRequest represents the request body for creating multiple shortened URLs in batch.
-// It contains a correlation ID for tracking and the original URL to be shortened.
+// It contains a correlation ID for tracking, the original URL to be shortened, an optional custom alias,
+// an optional expiration, an optional click limit and an optional password.
 type CreateBatchURLRequest struct {
 	// CorrelationID is a unique ID for tracking the batch request.
 	CorrelationID string `json:"correlation_id"`
 
 	// OriginalURL is the original URL to be shortened.
 	OriginalURL string `json:"original_url"`
+
+	// Alias is the custom short code to use instead of a generated one.
+	Alias string `json:"alias,omitempty"`
+
+	// ExpiresAt is the time the short URL stops resolving.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// TTL is the lifetime of the short URL in seconds. It cannot be combined with ExpiresAt.
+	TTL int64 `json:"ttl,omitempty"`
+
+	// MaxClicks is the number of redirects the short URL allows; 1 makes a one-time link.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+
+	// Password is required to follow the short URL. It is only stored hashed.
+	Password string `json:"password,omitempty"`
 }
 
 // CreateBatchURLResponse represents the response body when a batch of URLs has been successfully shortened.
@@ -44,6 +82,42 @@ type UserURLResponse struct {
 
 	// OriginalURL is the original URL corresponding to the shortened URL.
 	OriginalURL string `json:"original_url"`
+
+	// ExpiresAt is the time the short URL stops resolving, if it expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// RemainingClicks is the number of redirects left, if the short URL is click-limited.
+	RemainingClicks *int64 `json:"remaining_clicks,omitempty"`
+
+	// Protected tells whether the short URL requires a password. The password itself is never returned.
+	Protected bool `json:"protected"`
+}
+
+// URLStatsResponse represents the response body with the click statistics of a short URL.
+type URLStatsResponse struct {
+	// ShortURL is the shortened URL.
+	ShortURL string `json:"short_url"`
+
+	// TotalClicks is the number of redirects.
+	TotalClicks int64 `json:"total_clicks"`
+
+	// UniqueVisitors is the number of distinct clients that were redirected.
+	UniqueVisitors int64 `json:"unique_visitors"`
+
+	// Interval is the size of the buckets, "hour" or "day".
+	Interval string `json:"interval"`
+
+	// Buckets holds the number of clicks per interval, oldest first. Empty intervals are omitted.
+	Buckets []StatsBucketResponse `json:"buckets"`
+}
+
+// StatsBucketResponse represents the number of clicks within one interval.
+type StatsBucketResponse struct {
+	// Start is the start of the interval in UTC.
+	Start time.Time `json:"start"`
+
+	// Clicks is the number of clicks within the interval.
+	Clicks int64 `json:"clicks"`
 }
 
 // ResponseError represents an error response with a message.
@@ -59,3 +133,201 @@ func NewError(err string) ResponseError {
 		Error: err,
 	}
 }
+
+// ServiceStatsResponse represents the response body with the statistics of the whole service.
+type ServiceStatsResponse struct {
+	// URLs is the number of shortened URLs.
+	URLs int64 `json:"urls"`
+
+	// Users is the number of users that have shortened a URL.
+	Users int64 `json:"users"`
+
+	// Pool holds the connection pool statistics, for the storages that have a pool.
+	Pool *PoolStatsResponse `json:"pool,omitempty"`
+}
+
+// PoolStatsResponse represents the connection pool statistics of the storage.
+type PoolStatsResponse struct {
+	// MaxConns is the maximum size of the pool.
+	MaxConns int32 `json:"max_conns"`
+
+	// TotalConns is the number of open connections, both idle and in use.
+	TotalConns int32 `json:"total_conns"`
+
+	// AcquiredConns is the number of connections in use.
+	AcquiredConns int32 `json:"acquired_conns"`
+
+	// IdleConns is the number of idle connections.
+	IdleConns int32 `json:"idle_conns"`
+
+	// WaitCount is the number of acquires that had to wait for a connection.
+	WaitCount int64 `json:"wait_count"`
+
+	// WaitDurationMs is the total time spent waiting for a connection, in milliseconds.
+	WaitDurationMs int64 `json:"wait_duration_ms"`
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key.
+type CreateAPIKeyRequest struct {
+	// Name is an optional label for the key.
+	Name string `json:"name,omitempty"`
+
+	// Scopes are the kinds of operations the key is allowed: "read", "write" and/or "delete".
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponse represents an API key of the user.
+type APIKeyResponse struct {
+	// ID identifies the key, for revoking it.
+	ID string `json:"id"`
+
+	// Name is the label of the key, if any.
+	Name string `json:"name,omitempty"`
+
+	// Prefix is the beginning of the key, for telling keys apart.
+	Prefix string `json:"prefix"`
+
+	// Scopes are the kinds of operations the key is allowed.
+	Scopes []string `json:"scopes"`
+
+	// CreatedAt is the time the key was created.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Key is the key itself. It is only returned once, in the response creating the key.
+	Key string `json:"key,omitempty"`
+}
+
+// NewAPIKeyResponse maps a stored API key to its response, without the key itself.
+func NewAPIKeyResponse(key models.APIKey) APIKeyResponse {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    scopes,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+// CredentialsRequest represents the request body for registering or logging in to an account.
+type CredentialsRequest struct {
+	// Login is the name of the account.
+	Login string `json:"login"`
+
+	// Password is the password of the account.
+	Password string `json:"password"`
+}
+
+// AccountResponse represents the account a client is registered or logged in as.
+type AccountResponse struct {
+	// UserID is the ID of the account, which owns its URLs.
+	UserID string `json:"user_id"`
+
+	// Login is the name of the account.
+	Login string `json:"login"`
+}
+
+// AdminURLResponse represents a short URL of any user as seen by an admin.
+type AdminURLResponse struct {
+	// Code is the short code.
+	Code string `json:"code"`
+
+	// ShortURL is the shortened URL.
+	ShortURL string `json:"short_url"`
+
+	// OriginalURL is the original URL corresponding to the shortened URL.
+	OriginalURL string `json:"original_url"`
+
+	// UserID is the ID of the user owning the short URL.
+	UserID string `json:"user_id"`
+
+	// IsDeleted tells whether the owner deleted the short URL.
+	IsDeleted bool `json:"is_deleted"`
+
+	// IsDisabled tells whether an admin disabled the short URL.
+	IsDisabled bool `json:"is_disabled"`
+
+	// ExpiresAt is the time the short URL stops resolving, if it expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// MaxClicks is the number of redirects the short URL allows, if it is click-limited.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+
+	// Clicks is the number of redirects used up, if the short URL is click-limited.
+	Clicks int64 `json:"clicks,omitempty"`
+
+	// Protected tells whether the short URL requires a password.
+	Protected bool `json:"protected"`
+}
+
+// NewAdminURLResponse maps a stored URL to its admin response, the short URL built on baseURL.
+func NewAdminURLResponse(url models.URL, baseURL string) AdminURLResponse {
+	return AdminURLResponse{
+		Code:        url.Code,
+		ShortURL:    baseURL + "/" + url.Code,
+		OriginalURL: url.URL,
+		UserID:      url.UserID,
+		IsDeleted:   url.IsDeleted,
+		IsDisabled:  url.IsDisabled,
+		ExpiresAt:   url.ExpiresAt,
+		MaxClicks:   url.MaxClicks,
+		Clicks:      url.Clicks,
+		Protected:   url.IsProtected(),
+	}
+}
+
+// ReassignUserRequest represents the request body for moving the URLs of a user to another one.
+type ReassignUserRequest struct {
+	// ToUserID is the ID of the user receiving the URLs.
+	ToUserID string `json:"to_user_id"`
+}
+
+// SetRoleRequest represents the request body for changing the role of an account.
+type SetRoleRequest struct {
+	// Role is the new role, "user" or "admin".
+	Role string `json:"role"`
+}
+
+// AffectedURLsResponse represents the number of URLs an admin action moved or removed.
+type AffectedURLsResponse struct {
+	// URLs is the number of URLs affected.
+	URLs int64 `json:"urls"`
+}
+
+// CreateWebhookRequest represents the request body for registering a webhook.
+type CreateWebhookRequest struct {
+	// URL is the endpoint the events are posted to.
+	URL string `json:"url"`
+
+	// Secret is the key the payloads are signed with. A random one is generated if it is empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookResponse represents a webhook of the user.
+type WebhookResponse struct {
+	// ID identifies the webhook, for deleting it.
+	ID string `json:"id"`
+
+	// URL is the endpoint the events are posted to.
+	URL string `json:"url"`
+
+	// CreatedAt is the time the webhook was registered.
+	CreatedAt time.Time `json:"created_at"`
+
+	// Secret is the key the payloads are signed with. It is only returned in the response
+	// registering the webhook.
+	Secret string `json:"secret,omitempty"`
+}
+
+// NewWebhookResponse maps a stored webhook to its response, without the secret.
+func NewWebhookResponse(hook models.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        hook.ID,
+		URL:       hook.URL,
+		CreatedAt: hook.CreatedAt,
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ServiceStats aggregates the whole service.
+type ServiceStats struct {
+	// URLs is the number of stored short URLs, including the deleted ones.
+	URLs int64
+
+	// Users is the number of distinct users that have shortened a URL.
+	Users int64
+}
+
+// PoolStats is a snapshot of the connection pool statistics.
+type PoolStats struct {
+	// MaxConns is the maximum size of the pool.
+	MaxConns int32
+
+	// TotalConns is the number of open connections, both idle and in use.
+	TotalConns int32
+
+	// AcquiredConns is the number of connections in use.
+	AcquiredConns int32
+
+	// IdleConns is the number of idle connections.
+	IdleConns int32
+
+	// WaitCount is the number of acquires that had to wait for a connection.
+	WaitCount int64
+
+	// WaitDuration is the total time spent waiting for a connection.
+	WaitDuration time.Duration
+}
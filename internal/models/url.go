@@ -1,6 +1,8 @@
 // Package models defines the data structures used in the application, including the URL model.
 package models
 
+import "time"
+
 // URL represents a shortened URL entry in the database.
 // It contains information about the original URL, its shortened code, and the associated user ID.
 type URL struct {
@@ -19,4 +21,40 @@ type URL struct {
 	// IsDeleted indicates whether the URL has been deleted.
 	// If true, the URL has been marked as deleted.
 	IsDeleted bool `json:"is_deleted"`
+
+	// IsDisabled indicates whether an admin disabled the URL. A disabled URL does not resolve.
+	IsDisabled bool `json:"is_disabled,omitempty"`
+
+	// ExpiresAt is the time the URL stops resolving. The zero value means it never expires.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// MaxClicks is the number of redirects the URL allows. Zero means unlimited.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+
+	// Clicks is the number of redirects made so far. It is only counted for click-limited URLs.
+	Clicks int64 `json:"clicks,omitempty"`
+
+	// PasswordHash is the bcrypt hash of the password that unlocks the URL.
+	// It is empty for a URL without a password.
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+// IsExpired reports whether the URL has an expiration time that is not after now.
+func (u URL) IsExpired(now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !now.Before(u.ExpiresAt)
+}
+
+// IsClickLimited reports whether the URL allows a limited number of redirects.
+func (u URL) IsClickLimited() bool {
+	return u.MaxClicks > 0
+}
+
+// IsProtected reports whether the URL requires a password to resolve.
+func (u URL) IsProtected() bool {
+	return u.PasswordHash != ""
+}
+
+// RemainingClicks returns the number of redirects the click-limited URL still allows.
+func (u URL) RemainingClicks() int64 {
+	return max(u.MaxClicks-u.Clicks, 0)
 }
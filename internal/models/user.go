@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Role is the set of permissions of an account.
+type Role string
+
+const (
+	// RoleUser may only manage the URLs it owns.
+	RoleUser Role = "user"
+
+	// RoleAdmin may also look up and moderate the URLs of every user.
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole parses a role.
+func ParseRole(role string) (Role, error) {
+	switch Role(role) {
+	case RoleUser, RoleAdmin:
+		return Role(role), nil
+	default:
+		return "", fmt.Errorf("unknown role %q, expected %q or %q", role, RoleUser, RoleAdmin)
+	}
+}
+
+// User is a named account. Its ID takes the place of the random UserID of an anonymous
+// client once the client logs in, so the URLs of an account are owned by the account ID.
+type User struct {
+	// ID is the UUID of the account.
+	ID string `json:"id"`
+
+	// Login is the unique name the account logs in with, in lower case.
+	Login string `json:"login"`
+
+	// PasswordHash is the bcrypt hash of the account password.
+	PasswordHash string `json:"password_hash"`
+
+	// Role is the role of the account. The empty role is RoleUser.
+	Role Role `json:"role,omitempty"`
+
+	// CreatedAt is the time the account was registered.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsAdmin reports whether the account has the admin role.
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
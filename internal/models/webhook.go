@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Webhook is an endpoint a user has registered to be notified of the events of their URLs.
+// The payloads are signed with the secret, so it is kept as is, unlike an API key.
+type Webhook struct {
+	// ID identifies the webhook, for listing and deleting it.
+	ID string `json:"id"`
+
+	// UserID is the ID of the user whose URLs the webhook is notified of.
+	UserID string `json:"user_id"`
+
+	// URL is the endpoint the events are posted to.
+	URL string `json:"url"`
+
+	// Secret is the key the payloads are signed with, shared with the receiver.
+	Secret string `json:"secret"`
+
+	// CreatedAt is the time the webhook was registered.
+	CreatedAt time.Time `json:"created_at"`
+
+	// DeletedAt is the time the webhook was deleted. The zero value means it is active.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// IsDeleted reports whether the webhook has been deleted.
+func (w Webhook) IsDeleted() bool {
+	return !w.DeletedAt.IsZero()
+}
+
+// DeadLetter records an event that could not be delivered to a webhook.
+type DeadLetter struct {
+	// Time is the time the delivery was given up.
+	Time time.Time `json:"time"`
+
+	// WebhookID is the ID of the webhook the event was for.
+	WebhookID string `json:"webhook_id"`
+
+	// UserID is the ID of the user owning the webhook.
+	UserID string `json:"user_id"`
+
+	// URL is the endpoint of the webhook at the time of the delivery.
+	URL string `json:"url"`
+
+	// Event is the event that was not delivered.
+	Event LinkEvent `json:"event"`
+
+	// Attempts is the number of delivery attempts made.
+	Attempts int `json:"attempts"`
+
+	// Error describes the failure of the last attempt.
+	Error string `json:"error"`
+}
@@ -1,6 +1,20 @@
 // Package repository defines the data structures related to batch URL processing.
 package repository
 
+import "time"
+
+// URLSettings holds the optional settings of a short URL that are stored along with it.
+type URLSettings struct {
+	// ExpiresAt is the time the URL stops resolving. The zero value means it never expires.
+	ExpiresAt time.Time
+
+	// MaxClicks is the number of redirects the URL allows. Zero means unlimited.
+	MaxClicks int64
+
+	// PasswordHash is the bcrypt hash of the password that unlocks the URL. Empty means no password.
+	PasswordHash string
+}
+
 // BatchURL represents a shortened URL entry with a correlation ID for batch processing.
 // It contains the correlation ID and the shortened code.
 type BatchURL struct {
@@ -12,7 +26,7 @@ type BatchURL struct {
 }
 
 // BatchURLDto is a data transfer object (DTO) used for representing the details of a batch URL.
-// It includes the correlation ID, the original URL, and the shortened code.
+// It includes the correlation ID, the original URL, the shortened code and the URL settings.
 type BatchURLDto struct {
 	// CorrelationID is a unique identifier for a batch operation.
 	CorrelationID string `json:"correlation_id"`
@@ -22,4 +36,6 @@ type BatchURLDto struct {
 
 	// ShortCode is the shortened code generated for the original URL.
 	ShortCode string `json:"short_code"`
+
+	URLSettings
 }
@@ -0,0 +1,264 @@
+// Package account registers and logs in named user accounts.
+//
+// An anonymous client is identified by a random UserID only. When such a client registers
+// or logs in, its URLs and API keys are moved over to the account, which is how an
+// anonymous identity is claimed. The identity of another account is never claimed. With
+// WithEvents, the URLs claimed are reported the way an admin reassignment is, so they stay
+// in the history of their new owner.
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+const (
+	// MinPasswordLength is the minimum length of an account password.
+	MinPasswordLength = 8
+
+	// MaxPasswordLength is the maximum length of an account password, the most bcrypt hashes.
+	MaxPasswordLength = 72
+)
+
+var (
+	// ErrInvalidParams is returned when registering with a malformed login or password.
+	ErrInvalidParams = errors.New("invalid account parameters")
+
+	// ErrLoginTaken is returned when registering a login that is already taken.
+	ErrLoginTaken = errors.New("login is taken")
+
+	// ErrInvalidCredentials is returned when logging in with an unknown login or a wrong password.
+	ErrInvalidCredentials = errors.New("invalid login or password")
+)
+
+// loginPattern matches the allowed logins.
+var loginPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,63}$`)
+
+// dummyHash is compared against when the login is unknown, so an unknown login takes as long
+// to reject as a wrong password and logins cannot be probed by timing.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Storage defines the methods for storing accounts and moving URLs between users. They go
+// beyond urlservice.URLStorage, so only the storages implementing them support accounts.
+type Storage interface {
+	// CreateUser stores a new account. It returns storage.ErrUserExists if the login is taken.
+	CreateUser(ctx context.Context, user models.User) error
+
+	// GetUserByLogin retrieves the account with the given login.
+	// It returns storage.ErrUserNotFound if there is none.
+	GetUserByLogin(ctx context.Context, login string) (models.User, error)
+
+	// GetUserByID retrieves the account with the given ID.
+	// It returns storage.ErrUserNotFound if there is none.
+	GetUserByID(ctx context.Context, id string) (models.User, error)
+
+	// SetUserRole changes the role of the account with the given ID.
+	// It returns storage.ErrUserNotFound if there is none.
+	SetUserRole(ctx context.Context, id string, role models.Role) error
+
+	// ReassignUser moves the URLs and API keys of one user ID over to another
+	// and returns the number of URLs moved.
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) (int64, error)
+
+	// GetUserURLs retrieves all URLs associated with a specific user ID.
+	GetUserURLs(ctx context.Context, userID string) ([]models.URL, error)
+}
+
+// Events receives the URLs claimed by an account to emit them as lifecycle events.
+type Events interface {
+	RecordAdminAction(ctx context.Context, actorID, action string, urls []models.URL)
+}
+
+// Service manages the accounts.
+type Service struct {
+	storage Storage
+
+	// admins holds the logins that are given the admin role.
+	admins map[string]struct{}
+
+	// events receives the URLs claimed. Nil disables them.
+	events Events
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Option configures the account service.
+type Option func(*Service)
+
+// WithAdmins gives the admin role to the accounts with the given logins. An account is made
+// an admin when it is registered, or on its next login if it already exists.
+func WithAdmins(logins []string) Option {
+	return func(s *Service) {
+		for _, login := range logins {
+			if login = normalizeLogin(login); login != "" {
+				s.admins[login] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithEvents reports the URLs claimed by an account to events.
+func WithEvents(events Events) Option {
+	return func(s *Service) {
+		s.events = events
+	}
+}
+
+// New creates an account service backed by storage.
+func New(storage Storage, opts ...Option) *Service {
+	s := &Service{storage: storage, admins: make(map[string]struct{}), now: time.Now}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Register creates an account and claims the identity of currentUserID, if any.
+// The login is case-insensitive: 3 to 64 letters, digits, dots, dashes and underscores.
+func (s *Service) Register(ctx context.Context, login, password, currentUserID string) (models.User, error) {
+	const op = "account.Register"
+
+	login = normalizeLogin(login)
+	if !loginPattern.MatchString(login) {
+		return models.User{}, fmt.Errorf(
+			"%w: login must be 3 to 64 letters, digits, dots, dashes or underscores", ErrInvalidParams,
+		)
+	}
+
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return models.User{}, fmt.Errorf(
+			"%w: password must be %d to %d bytes long", ErrInvalidParams, MinPasswordLength, MaxPasswordLength,
+		)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user := models.User{
+		ID:           uuid.New().String(),
+		Login:        login,
+		PasswordHash: string(hash),
+		Role:         models.RoleUser,
+		CreatedAt:    s.now().UTC(),
+	}
+	if s.isAdmin(login) {
+		user.Role = models.RoleAdmin
+	}
+
+	if err = s.storage.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			return models.User{}, ErrLoginTaken
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.claim(ctx, currentUserID, user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// Login verifies the credentials of an account and claims the identity of currentUserID, if any.
+func (s *Service) Login(ctx context.Context, login, password, currentUserID string) (models.User, error) {
+	const op = "account.Login"
+
+	user, err := s.storage.GetUserByLogin(ctx, normalizeLogin(login))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	if s.isAdmin(user.Login) && !user.IsAdmin() {
+		if err = s.storage.SetUserRole(ctx, user.ID, models.RoleAdmin); err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Role = models.RoleAdmin
+
+		slog.Info("account promoted to admin", slog.String("user_id", user.ID))
+	}
+
+	if err = s.claim(ctx, currentUserID, user); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// claim moves the URLs of the anonymous identity currentUserID over to user. It does nothing
+// without a current identity, or if the current identity is an account itself.
+func (s *Service) claim(ctx context.Context, currentUserID string, user models.User) error {
+	if currentUserID == "" || currentUserID == user.ID {
+		return nil
+	}
+
+	_, err := s.storage.GetUserByID(ctx, currentUserID)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrUserNotFound) {
+		return err
+	}
+
+	var urls []models.URL
+	if s.events != nil {
+		if urls, err = s.storage.GetUserURLs(ctx, currentUserID); err != nil {
+			return err
+		}
+	}
+
+	moved, err := s.storage.ReassignUser(ctx, currentUserID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(urls) > 0 {
+		for i := range urls {
+			urls[i].UserID = user.ID
+		}
+		s.events.RecordAdminAction(ctx, user.ID, models.AuditReassignUser, urls)
+	}
+
+	slog.Info("anonymous identity claimed",
+		slog.String("from", currentUserID),
+		slog.String("to", user.ID),
+		slog.Int64("urls", moved),
+	)
+
+	return nil
+}
+
+// isAdmin reports whether the login is to be given the admin role.
+func (s *Service) isAdmin(login string) bool {
+	_, ok := s.admins[login]
+	return ok
+}
+
+// normalizeLogin trims and lower-cases a login.
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
@@ -0,0 +1,183 @@
+package account
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+func newStorage(t *testing.T) *memory.Storage {
+	t.Helper()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, storage.Close())
+	})
+
+	return storage
+}
+
+// event is an action reported to mockEvents.
+type event struct {
+	actorID string
+	action  string
+	urls    []models.URL
+}
+
+// mockEvents records the reported actions.
+type mockEvents struct {
+	events []event
+}
+
+func (m *mockEvents) RecordAdminAction(_ context.Context, actorID, action string, urls []models.URL) {
+	m.events = append(m.events, event{actorID: actorID, action: action, urls: urls})
+}
+
+func TestService_RegisterAndLogin(t *testing.T) {
+	s := New(newStorage(t))
+	ctx := context.Background()
+
+	user, err := s.Register(ctx, " Alice ", "correct horse", "")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Login)
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+
+	_, err = s.Register(ctx, "ALICE", "another password", "")
+	assert.ErrorIs(t, err, ErrLoginTaken)
+
+	loggedIn, err := s.Login(ctx, "alice", "correct horse", "")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, loggedIn.ID)
+
+	_, err = s.Login(ctx, "alice", "wrong password", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = s.Login(ctx, "bob", "correct horse", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestService_RegisterInvalid(t *testing.T) {
+	s := New(newStorage(t))
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+	}{
+		{name: "short login", login: "al", password: "correct horse"},
+		{name: "bad login", login: "al ice", password: "correct horse"},
+		{name: "short password", login: "alice", password: "short"},
+		{name: "long password", login: "alice", password: strings.Repeat("a", MaxPasswordLength+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Register(context.Background(), tt.login, tt.password, "")
+			assert.ErrorIs(t, err, ErrInvalidParams)
+		})
+	}
+}
+
+func TestService_Claim(t *testing.T) {
+	storage := newStorage(t)
+	s := New(storage)
+	ctx := context.Background()
+
+	anonymous := uuid.New().String()
+	_, err := storage.SaveURL(ctx, "code1", "http://example.com/1", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+
+	alice, err := s.Register(ctx, "alice", "correct horse", anonymous)
+	require.NoError(t, err)
+
+	urls, err := storage.GetUserURLs(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "registering claims the anonymous identity")
+
+	another := uuid.New().String()
+	_, err = storage.SaveURL(ctx, "code2", "http://example.com/2", another, repository.URLSettings{})
+	require.NoError(t, err)
+
+	_, err = s.Login(ctx, "alice", "correct horse", another)
+	require.NoError(t, err)
+
+	urls, err = storage.GetUserURLs(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 2, "logging in claims the anonymous identity")
+
+	bob, err := s.Register(ctx, "bob", "battery staple", "")
+	require.NoError(t, err)
+
+	_, err = s.Login(ctx, "bob", "battery staple", alice.ID)
+	require.NoError(t, err)
+
+	urls, err = storage.GetUserURLs(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.Empty(t, urls, "the identity of another account is not claimed")
+}
+
+func TestService_ClaimEvents(t *testing.T) {
+	storage := newStorage(t)
+	events := &mockEvents{}
+	s := New(storage, WithEvents(events))
+	ctx := context.Background()
+
+	anonymous := uuid.New().String()
+	_, err := storage.SaveURL(ctx, "code1", "http://example.com/1", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "code2", "http://example.com/2", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, storage.DeleteShortURLs(ctx, []string{"code2"}, anonymous))
+
+	alice, err := s.Register(ctx, "alice", "correct horse", anonymous)
+	require.NoError(t, err)
+
+	require.Len(t, events.events, 1)
+	assert.Equal(t, alice.ID, events.events[0].actorID)
+	assert.Equal(t, models.AuditReassignUser, events.events[0].action)
+	require.Len(t, events.events[0].urls, 2, "deleted URLs are claimed too")
+	for _, url := range events.events[0].urls {
+		assert.Equal(t, alice.ID, url.UserID, "the URLs are reported with their new owner")
+	}
+
+	_, err = s.Login(ctx, "alice", "correct horse", uuid.New().String())
+	require.NoError(t, err)
+	assert.Len(t, events.events, 1, "an identity without URLs reports nothing")
+}
+
+func TestService_Admins(t *testing.T) {
+	storage := newStorage(t)
+	ctx := context.Background()
+
+	user, err := New(storage).Register(ctx, "alice", "correct horse", "")
+	require.NoError(t, err)
+	assert.False(t, user.IsAdmin())
+
+	s := New(storage, WithAdmins([]string{" Alice ", "bob"}))
+
+	loggedIn, err := s.Login(ctx, "alice", "correct horse", "")
+	require.NoError(t, err)
+	assert.True(t, loggedIn.IsAdmin(), "an existing account is promoted on login")
+
+	stored, err := storage.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsAdmin())
+
+	bob, err := s.Register(ctx, "bob", "correct horse", "")
+	require.NoError(t, err)
+	assert.True(t, bob.IsAdmin(), "a listed login is an admin from the start")
+
+	carol, err := s.Register(ctx, "carol", "correct horse", "")
+	require.NoError(t, err)
+	assert.False(t, carol.IsAdmin())
+}
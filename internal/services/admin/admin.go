@@ -0,0 +1,309 @@
+// Package admin lets operators moderate the URLs of every user.
+//
+// Admins look URLs up across users, disable abusive ones and move or purge the URLs of a user.
+// Every change an admin makes is recorded in an audit trail along with the admin's UserID,
+// and reported as a lifecycle event of each URL it affects.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+const (
+	// DefaultLimit is the number of URLs or audit entries returned when no limit is given.
+	DefaultLimit = 100
+
+	// MaxLimit is the largest number of URLs or audit entries returned at once.
+	MaxLimit = 1000
+)
+
+var (
+	// ErrInvalidParams is returned for a malformed filter, role or user ID.
+	ErrInvalidParams = errors.New("invalid admin parameters")
+
+	// ErrNotFound is returned when the URL or the account acted on does not exist.
+	ErrNotFound = errors.New("not found")
+)
+
+// Storage defines the methods for moderating the URLs of all users. They go beyond
+// urlservice.URLStorage, so only the storages implementing them support the admin API.
+type Storage interface {
+	GetUserByID(ctx context.Context, id string) (models.User, error)
+	SetUserRole(ctx context.Context, id string, role models.Role) error
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) (int64, error)
+	FindURLs(ctx context.Context, filter models.URLFilter) ([]models.URL, error)
+	SetURLDisabled(ctx context.Context, code string, disabled bool) (bool, error)
+	PurgeUserURLs(ctx context.Context, userID string) (int64, error)
+	SaveAuditEntry(ctx context.Context, entry models.AuditEntry) error
+	GetAuditEntries(ctx context.Context, limit int) ([]models.AuditEntry, error)
+}
+
+// Events receives the admin actions on URLs to emit them as lifecycle events.
+type Events interface {
+	RecordAdminAction(ctx context.Context, actorID, action string, urls []models.URL)
+}
+
+// Service runs the admin actions.
+type Service struct {
+	storage Storage
+
+	// events receives the actions on URLs. Nil disables them.
+	events Events
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Option configures the admin service.
+type Option func(*Service)
+
+// WithEvents reports every action on URLs to events.
+func WithEvents(events Events) Option {
+	return func(s *Service) {
+		s.events = events
+	}
+}
+
+// New creates an admin service backed by storage.
+func New(storage Storage, opts ...Option) *Service {
+	s := &Service{storage: storage, now: time.Now}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// IsAdmin reports whether userID is an account with the admin role.
+func (s *Service) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	if !isUserID(userID) {
+		return false, nil
+	}
+
+	user, err := s.storage.GetUserByID(ctx, userID)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("admin.IsAdmin: %w", err)
+	}
+
+	return user.IsAdmin(), nil
+}
+
+// FindURLs returns the URLs of all users matching the filter, in the order of their IDs.
+// A zero limit returns DefaultLimit URLs; a larger limit than MaxLimit is rejected.
+func (s *Service) FindURLs(ctx context.Context, filter models.URLFilter) ([]models.URL, error) {
+	if filter.Limit < 0 || filter.Limit > MaxLimit || filter.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit must be 0 to %d and offset not negative", ErrInvalidParams, MaxLimit)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultLimit
+	}
+
+	urls, err := s.storage.FindURLs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("admin.FindURLs: %w", err)
+	}
+
+	return urls, nil
+}
+
+// SetURLDisabled disables or re-enables the URL with the given code on behalf of actorID.
+// A disabled URL no longer redirects, but stays owned by its user.
+func (s *Service) SetURLDisabled(ctx context.Context, actorID, code string, disabled bool) error {
+	const op = "admin.SetURLDisabled"
+
+	found, err := s.storage.SetURLDisabled(ctx, code, disabled)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	action := models.AuditEnableURL
+	if disabled {
+		action = models.AuditDisableURL
+	}
+
+	if err = s.record(ctx, actorID, action, code, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.report(ctx, actorID, action, models.URLFilter{Code: code}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReassignUser moves the URLs and API keys of fromUserID over to toUserID on behalf of actorID
+// and returns the number of URLs moved.
+func (s *Service) ReassignUser(ctx context.Context, actorID, fromUserID, toUserID string) (int64, error) {
+	const op = "admin.ReassignUser"
+
+	if !isUserID(fromUserID) || !isUserID(toUserID) || fromUserID == toUserID {
+		return 0, fmt.Errorf("%w: the users must be distinct UUIDs", ErrInvalidParams)
+	}
+
+	urls, err := s.affected(ctx, fromUserID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	moved, err := s.storage.ReassignUser(ctx, fromUserID, toUserID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range urls {
+		urls[i].UserID = toUserID
+	}
+	s.emit(ctx, actorID, models.AuditReassignUser, urls)
+
+	details := "to " + toUserID + ", " + strconv.FormatInt(moved, 10) + " urls"
+	if err = s.record(ctx, actorID, models.AuditReassignUser, fromUserID, details); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return moved, nil
+}
+
+// PurgeUser removes all URLs of userID, including soft-deleted ones, on behalf of actorID
+// and returns the number of URLs removed.
+func (s *Service) PurgeUser(ctx context.Context, actorID, userID string) (int64, error) {
+	const op = "admin.PurgeUser"
+
+	if !isUserID(userID) {
+		return 0, fmt.Errorf("%w: the user must be a UUID", ErrInvalidParams)
+	}
+
+	urls, err := s.affected(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purged, err := s.storage.PurgeUserURLs(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.emit(ctx, actorID, models.AuditPurgeUser, urls)
+
+	details := strconv.FormatInt(purged, 10) + " urls"
+	if err = s.record(ctx, actorID, models.AuditPurgeUser, userID, details); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// SetRole changes the role of the account userID on behalf of actorID.
+// An admin cannot take the admin role from themself, so there is always one admin left.
+func (s *Service) SetRole(ctx context.Context, actorID, userID, role string) error {
+	const op = "admin.SetRole"
+
+	if !isUserID(userID) {
+		return fmt.Errorf("%w: the user must be a UUID", ErrInvalidParams)
+	}
+
+	parsed, err := models.ParseRole(role)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidParams, err)
+	}
+	if userID == actorID && parsed != models.RoleAdmin {
+		return fmt.Errorf("%w: an admin cannot demote themself", ErrInvalidParams)
+	}
+
+	if err = s.storage.SetUserRole(ctx, userID, parsed); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return ErrNotFound
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.record(ctx, actorID, models.AuditSetRole, userID, string(parsed)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Audit returns the latest entries of the audit trail, newest first.
+// A zero limit returns DefaultLimit entries; a larger limit than MaxLimit is rejected.
+func (s *Service) Audit(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	if limit < 0 || limit > MaxLimit {
+		return nil, fmt.Errorf("%w: limit must be 0 to %d", ErrInvalidParams, MaxLimit)
+	}
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	entries, err := s.storage.GetAuditEntries(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("admin.Audit: %w", err)
+	}
+
+	return entries, nil
+}
+
+// record appends an action of actorID to the audit trail.
+func (s *Service) record(ctx context.Context, actorID, action, target, details string) error {
+	return s.storage.SaveAuditEntry(ctx, models.AuditEntry{
+		Time:    s.now().UTC(),
+		ActorID: actorID,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+}
+
+// affected returns the URLs of userID an action is about to change, if the actions are reported.
+func (s *Service) affected(ctx context.Context, userID string) ([]models.URL, error) {
+	if s.events == nil {
+		return nil, nil
+	}
+
+	return s.storage.FindURLs(ctx, models.URLFilter{UserID: userID})
+}
+
+// report reports an action on the URLs matching the filter, if the actions are reported.
+func (s *Service) report(ctx context.Context, actorID, action string, filter models.URLFilter) error {
+	if s.events == nil {
+		return nil
+	}
+
+	urls, err := s.storage.FindURLs(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	s.emit(ctx, actorID, action, urls)
+
+	return nil
+}
+
+// emit reports an action on the URLs, if the actions are reported.
+func (s *Service) emit(ctx context.Context, actorID, action string, urls []models.URL) {
+	if s.events != nil && len(urls) > 0 {
+		s.events.RecordAdminAction(ctx, actorID, action, urls)
+	}
+}
+
+// isUserID reports whether id is a well-formed UserID, which is a UUID.
+func isUserID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+func newStorage(t *testing.T) *memory.Storage {
+	t.Helper()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, storage.Close())
+	})
+
+	return storage
+}
+
+// event is an action reported to mockEvents.
+type event struct {
+	actorID string
+	action  string
+	urls    []models.URL
+}
+
+// mockEvents records the reported actions.
+type mockEvents struct {
+	events []event
+}
+
+func (m *mockEvents) RecordAdminAction(_ context.Context, actorID, action string, urls []models.URL) {
+	m.events = append(m.events, event{actorID: actorID, action: action, urls: urls})
+}
+
+func TestService_IsAdmin(t *testing.T) {
+	storage := newStorage(t)
+	s := New(storage)
+	ctx := context.Background()
+
+	admin := models.User{ID: uuid.New().String(), Login: "admin", Role: models.RoleAdmin}
+	user := models.User{ID: uuid.New().String(), Login: "user", Role: models.RoleUser}
+	require.NoError(t, storage.CreateUser(ctx, admin))
+	require.NoError(t, storage.CreateUser(ctx, user))
+
+	for userID, want := range map[string]bool{admin.ID: true, user.ID: false, uuid.New().String(): false} {
+		got, err := s.IsAdmin(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, userID)
+	}
+}
+
+func TestService_Moderation(t *testing.T) {
+	storage := newStorage(t)
+	s := New(storage)
+	s.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	ctx := context.Background()
+
+	actor := uuid.New().String()
+	owner := uuid.New().String()
+	other := uuid.New().String()
+
+	_, err := storage.SaveURL(ctx, "code1", "http://example.com/1", owner, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = storage.SaveURL(ctx, "code2", "http://example.com/2", owner, repository.URLSettings{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetURLDisabled(ctx, actor, "code1", true))
+	assert.ErrorIs(t, s.SetURLDisabled(ctx, actor, "missing", true), ErrNotFound)
+
+	disabled := true
+	urls, err := s.FindURLs(ctx, models.URLFilter{Disabled: &disabled})
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "code1", urls[0].Code)
+
+	moved, err := s.ReassignUser(ctx, actor, owner, other)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), moved)
+
+	purged, err := s.PurgeUser(ctx, actor, other)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	urls, err = s.FindURLs(ctx, models.URLFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	entries, err := s.Audit(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, models.AuditEntry{
+		Time:    s.now(),
+		ActorID: actor,
+		Action:  models.AuditPurgeUser,
+		Target:  other,
+		Details: "2 urls",
+	}, entries[0], "the newest entry comes first")
+	assert.Equal(t, models.AuditReassignUser, entries[1].Action)
+	assert.Equal(t, models.AuditDisableURL, entries[2].Action)
+	assert.Equal(t, "code1", entries[2].Target)
+}
+
+func TestService_Events(t *testing.T) {
+	storage := newStorage(t)
+	events := &mockEvents{}
+	s := New(storage, WithEvents(events))
+	ctx := context.Background()
+
+	actor := uuid.New().String()
+	owner := uuid.New().String()
+	other := uuid.New().String()
+
+	_, err := storage.SaveURL(ctx, "code1", "http://example.com/1", owner, repository.URLSettings{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetURLDisabled(ctx, actor, "code1", true))
+	require.NoError(t, s.SetURLDisabled(ctx, actor, "code1", false))
+
+	_, err = s.ReassignUser(ctx, actor, owner, other)
+	require.NoError(t, err)
+
+	_, err = s.ReassignUser(ctx, actor, owner, other)
+	require.NoError(t, err, "a user without URLs has nothing to report")
+
+	_, err = s.PurgeUser(ctx, actor, other)
+	require.NoError(t, err)
+
+	require.Len(t, events.events, 4)
+
+	for i, action := range []string{
+		models.AuditDisableURL,
+		models.AuditEnableURL,
+		models.AuditReassignUser,
+		models.AuditPurgeUser,
+	} {
+		assert.Equal(t, actor, events.events[i].actorID)
+		assert.Equal(t, action, events.events[i].action)
+		require.Len(t, events.events[i].urls, 1)
+		assert.Equal(t, "code1", events.events[i].urls[0].Code)
+	}
+
+	assert.True(t, events.events[0].urls[0].IsDisabled, "the URLs are reported as they are after the action")
+	assert.False(t, events.events[1].urls[0].IsDisabled)
+	assert.Equal(t, other, events.events[2].urls[0].UserID, "reassigned URLs are reported with their new owner")
+	assert.Equal(t, other, events.events[3].urls[0].UserID)
+}
+
+func TestService_SetRole(t *testing.T) {
+	storage := newStorage(t)
+	s := New(storage)
+	ctx := context.Background()
+
+	actor := uuid.New().String()
+	user := models.User{ID: uuid.New().String(), Login: "user", Role: models.RoleUser}
+	require.NoError(t, storage.CreateUser(ctx, user))
+
+	require.NoError(t, s.SetRole(ctx, actor, user.ID, "admin"))
+
+	isAdmin, err := s.IsAdmin(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	assert.ErrorIs(t, s.SetRole(ctx, actor, uuid.New().String(), "admin"), ErrNotFound)
+	assert.ErrorIs(t, s.SetRole(ctx, actor, user.ID, "root"), ErrInvalidParams)
+	assert.ErrorIs(t, s.SetRole(ctx, user.ID, user.ID, "user"), ErrInvalidParams, "an admin cannot demote themself")
+}
+
+func TestService_InvalidParams(t *testing.T) {
+	s := New(newStorage(t))
+	ctx := context.Background()
+
+	actor := uuid.New().String()
+	user := uuid.New().String()
+
+	_, err := s.FindURLs(ctx, models.URLFilter{Limit: MaxLimit + 1})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.FindURLs(ctx, models.URLFilter{Offset: -1})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.ReassignUser(ctx, actor, user, user)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.ReassignUser(ctx, actor, "not-a-uuid", user)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.PurgeUser(ctx, actor, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.Audit(ctx, -1)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+}
@@ -0,0 +1,282 @@
+// Package analytics implements the recording of click events.
+//
+// A resolve hands its click to the pipeline without blocking: the event is put into a
+// bounded buffer, and a background worker writes the buffered clicks to the storage in
+// batches, once a batch is full or when the flush interval elapses. When the buffer is
+// full the click is dropped, so a slow storage never delays a redirect.
+//
+// Client IPs are never stored; a click carries a keyed hash of the IP instead, which
+// still allows counting unique visitors.
+//
+// With WithEvents, every written batch is also announced as click events, without the
+// visitor details, so the owners of the URLs can be notified.
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// ipHashSize is the number of bytes of the HMAC kept in a click.
+const ipHashSize = 16
+
+// Saver stores click events.
+type Saver interface {
+	SaveClicks(ctx context.Context, clicks []models.Click) error
+}
+
+// Storage stores the click events and aggregates them. It goes beyond
+// urlservice.URLStorage, so only the storages implementing it support the click statistics.
+type Storage interface {
+	Saver
+
+	// GetClickStats aggregates the clicks of the URL with the given ID by interval.
+	GetClickStats(ctx context.Context, urlID int64, interval models.StatsInterval) (models.ClickStats, error)
+}
+
+// Visit describes the client that resolved a short URL.
+type Visit struct {
+	// Referrer is the page the client came from, if known.
+	Referrer string
+
+	// UserAgent is the User-Agent of the client, if known.
+	UserAgent string
+
+	// IP is the client IP. It is hashed before it leaves Record.
+	IP string
+}
+
+// Config holds the pipeline settings.
+type Config struct {
+	// Size is the number of clicks the buffer holds before new ones are dropped.
+	Size int
+
+	// BatchSize is the number of clicks that triggers a flush.
+	BatchSize int
+
+	// FlushInterval is the longest time a click waits in the buffer.
+	FlushInterval time.Duration
+
+	// HashKey is the key the client IPs are hashed with.
+	HashKey string
+}
+
+// entry is a buffered click along with the URL it was made on.
+type entry struct {
+	click models.Click
+
+	// ownerID and url are the owner and the original URL of the short URL, for the click event.
+	ownerID string
+	url     string
+}
+
+// Pipeline buffers click events and writes them to the storage in the background.
+type Pipeline struct {
+	saver Saver
+	cfg   Config
+
+	// sink receives the click events of the written batches. Nil disables them.
+	sink audit.Sink
+
+	// mu guards closed and the sends to clicks against the close of the channel.
+	mu     sync.RWMutex
+	closed bool
+	clicks chan entry
+
+	// dropped is the number of clicks dropped since the last flush.
+	dropped atomic.Int64
+
+	// ctx is canceled when the shutdown deadline passes.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed once the worker has stopped. It is nil until Start is called.
+	done chan struct{}
+}
+
+// Option configures the Pipeline.
+type Option func(*Pipeline)
+
+// WithEvents makes the pipeline emit a click event to sink for every click it writes.
+func WithEvents(sink audit.Sink) Option {
+	return func(p *Pipeline) {
+		p.sink = sink
+	}
+}
+
+// New creates a pipeline that writes clicks with saver. Start must be called to run it.
+func New(saver Saver, cfg Config, opts ...Option) *Pipeline {
+	cfg.Size = max(cfg.Size, 1)
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &Pipeline{
+		saver:  saver,
+		cfg:    cfg,
+		clicks: make(chan entry, cfg.Size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Start runs the worker in the background.
+func (p *Pipeline) Start() {
+	p.done = make(chan struct{})
+
+	go p.worker()
+}
+
+// Record emits a click on url by the visit without blocking.
+// The click is dropped if the buffer is full or the pipeline is shutting down.
+func (p *Pipeline) Record(url models.URL, visit Visit) {
+	click := models.Click{
+		URLID:     url.ID,
+		Code:      url.Code,
+		Time:      time.Now().UTC(),
+		Referrer:  visit.Referrer,
+		UserAgent: visit.UserAgent,
+		IPHash:    p.hashIP(visit.IP),
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+
+	select {
+	case p.clicks <- entry{click: click, ownerID: url.UserID, url: url.URL}:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Shutdown stops accepting clicks and waits for the worker to write the buffered ones.
+// If ctx is done first, the remaining clicks are abandoned and ctx.Err() is returned.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.clicks)
+	}
+	p.mu.Unlock()
+
+	if p.done == nil {
+		p.cancel()
+		return nil
+	}
+
+	select {
+	case <-p.done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-p.done
+		return ctx.Err()
+	}
+}
+
+// worker collects clicks into a batch and flushes it by size or by time.
+func (p *Pipeline) worker() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entry, 0, p.cfg.BatchSize)
+
+	for {
+		select {
+		case e, ok := <-p.clicks:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+
+			batch = append(batch, e)
+			if len(batch) >= p.cfg.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes the batch to the storage and emits its click events. A batch that fails
+// is dropped; clicks are not worth holding up the pipeline for.
+func (p *Pipeline) flush(batch []entry) {
+	if dropped := p.dropped.Swap(0); dropped > 0 {
+		slog.Warn("dropped clicks", slog.Int64("clicks", dropped))
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	clicks := make([]models.Click, 0, len(batch))
+	for _, e := range batch {
+		clicks = append(clicks, e.click)
+	}
+
+	if err := p.saver.SaveClicks(p.ctx, clicks); err != nil {
+		slog.Error("failed to save clicks", slog.Int("clicks", len(clicks)), sl.Err(err))
+		return
+	}
+
+	if p.sink == nil {
+		return
+	}
+
+	events := make([]models.LinkEvent, 0, len(batch))
+	for _, e := range batch {
+		events = append(events, models.LinkEvent{
+			Time:    e.click.Time,
+			Action:  models.EventClick,
+			OwnerID: e.ownerID,
+			Code:    e.click.Code,
+			URL:     e.url,
+		})
+	}
+
+	if err := p.sink.Emit(p.ctx, events); err != nil {
+		slog.Error("failed to emit click events", slog.Int("clicks", len(events)), sl.Err(err))
+	}
+}
+
+// hashIP returns the hex encoded keyed hash of ip, or an empty string for an unknown IP.
+func (p *Pipeline) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.cfg.HashKey))
+	mac.Write([]byte(ip))
+
+	return hex.EncodeToString(mac.Sum(nil)[:ipHashSize])
+}
@@ -0,0 +1,182 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// mockSaver records the saved batches and optionally blocks until the context is done.
+type mockSaver struct {
+	mu      sync.Mutex
+	batches [][]models.Click
+	block   bool
+}
+
+func (m *mockSaver) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	m.mu.Lock()
+	m.batches = append(m.batches, append([]models.Click(nil), clicks...))
+	m.mu.Unlock()
+
+	if m.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (m *mockSaver) Batches() [][]models.Click {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([][]models.Click(nil), m.batches...)
+}
+
+func (m *mockSaver) Clicks() int {
+	clicks := 0
+	for _, batch := range m.Batches() {
+		clicks += len(batch)
+	}
+
+	return clicks
+}
+
+var url = models.URL{ID: 1, Code: "code"}
+
+func TestPipeline_FlushBySize(t *testing.T) {
+	saver := &mockSaver{}
+	p := New(saver, Config{Size: 10, BatchSize: 2, FlushInterval: time.Hour})
+	p.Start()
+	defer p.Shutdown(context.Background())
+
+	p.Record(url, Visit{})
+	p.Record(url, Visit{})
+
+	assert.Eventually(t, func() bool {
+		return len(saver.Batches()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestPipeline_FlushByTime(t *testing.T) {
+	saver := &mockSaver{}
+	p := New(saver, Config{Size: 10, BatchSize: 100, FlushInterval: 5 * time.Millisecond})
+	p.Start()
+	defer p.Shutdown(context.Background())
+
+	p.Record(url, Visit{Referrer: "https://example.com", UserAgent: "curl/8.0"})
+
+	assert.Eventually(t, func() bool {
+		return saver.Clicks() == 1
+	}, time.Second, time.Millisecond)
+
+	click := saver.Batches()[0][0]
+	assert.Equal(t, url.ID, click.URLID)
+	assert.Equal(t, url.Code, click.Code)
+	assert.Equal(t, "https://example.com", click.Referrer)
+	assert.Equal(t, "curl/8.0", click.UserAgent)
+	assert.WithinDuration(t, time.Now(), click.Time, time.Second)
+}
+
+func TestPipeline_ShutdownDrains(t *testing.T) {
+	saver := &mockSaver{}
+	p := New(saver, Config{Size: 10, BatchSize: 100, FlushInterval: time.Hour})
+	p.Start()
+
+	for range 3 {
+		p.Record(url, Visit{})
+	}
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.Equal(t, 3, saver.Clicks())
+
+	p.Record(url, Visit{})
+	assert.Equal(t, 3, saver.Clicks(), "clicks after shutdown are dropped")
+}
+
+func TestPipeline_ShutdownDeadline(t *testing.T) {
+	saver := &mockSaver{block: true}
+	p := New(saver, Config{Size: 10, BatchSize: 1, FlushInterval: time.Hour})
+	p.Start()
+
+	p.Record(url, Visit{})
+
+	assert.Eventually(t, func() bool {
+		return len(saver.Batches()) == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+}
+
+func TestPipeline_DropsWhenFull(t *testing.T) {
+	saver := &mockSaver{}
+	p := New(saver, Config{Size: 2, BatchSize: 100, FlushInterval: time.Hour})
+
+	// The worker is not running, so nothing drains the buffer.
+	for range 5 {
+		p.Record(url, Visit{})
+	}
+
+	assert.Equal(t, int64(3), p.dropped.Load())
+
+	p.Start()
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.Equal(t, 2, saver.Clicks())
+}
+
+func TestPipeline_HashIP(t *testing.T) {
+	p := New(&mockSaver{}, Config{HashKey: "secret"})
+	other := New(&mockSaver{}, Config{HashKey: "another secret"})
+
+	hash := p.hashIP("192.0.2.1")
+
+	assert.Len(t, hash, 2*ipHashSize)
+	assert.NotContains(t, hash, "192.0.2.1")
+	assert.Equal(t, hash, p.hashIP("192.0.2.1"))
+	assert.NotEqual(t, hash, p.hashIP("192.0.2.2"))
+	assert.NotEqual(t, hash, other.hashIP("192.0.2.1"))
+	assert.Empty(t, p.hashIP(""))
+}
+
+// mockSink records the emitted events.
+type mockSink struct {
+	mu     sync.Mutex
+	events []models.LinkEvent
+}
+
+func (m *mockSink) Emit(_ context.Context, events []models.LinkEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, events...)
+
+	return nil
+}
+
+func TestPipeline_Events(t *testing.T) {
+	sink := &mockSink{}
+	p := New(&mockSaver{}, Config{Size: 10, BatchSize: 100, FlushInterval: time.Hour}, WithEvents(sink))
+	p.Start()
+
+	p.Record(models.URL{ID: 1, Code: "code", URL: "https://example.com", UserID: "user1"}, Visit{IP: "192.0.2.1"})
+
+	require.NoError(t, p.Shutdown(context.Background()))
+
+	require.Len(t, sink.events, 1)
+	event := sink.events[0]
+	assert.Equal(t, models.EventClick, event.Action)
+	assert.Equal(t, "user1", event.OwnerID)
+	assert.Equal(t, "code", event.Code)
+	assert.Equal(t, "https://example.com", event.URL)
+	assert.Empty(t, event.ActorID, "the visitor is not identified")
+	assert.WithinDuration(t, time.Now(), event.Time, time.Second)
+}
@@ -0,0 +1,178 @@
+// Package apikey issues and verifies the API keys server-to-server clients authenticate with.
+//
+// A key is a random secret shown to its owner once, when it is created. Only its SHA-256
+// hash is stored: the secret carries 256 bits of entropy, so a fast hash is as safe as a
+// password hash here, and it lets a key be looked up by its hash on every request.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+const (
+	// secretPrefix starts every key, so leaked keys are easy to recognize.
+	secretPrefix = "sk_"
+
+	// secretSize is the number of random bytes of a key.
+	secretSize = 32
+
+	// prefixLength is the length of the beginning of a key kept to tell keys apart.
+	prefixLength = len(secretPrefix) + 6
+
+	// maxNameLength is the maximum length of a key name.
+	maxNameLength = 100
+)
+
+// ErrInvalidKey is returned for a key that is malformed, unknown or revoked.
+var ErrInvalidKey = errors.New("invalid api key")
+
+// ErrInvalidParams is returned when creating a key with a name that is too long or with missing or unknown scopes.
+var ErrInvalidParams = errors.New("invalid api key parameters")
+
+// ErrNotFound is returned when revoking a key that is not an active key of the user.
+var ErrNotFound = errors.New("api key not found")
+
+// Storage defines the methods for storing and looking up API keys. They go beyond
+// urlservice.URLStorage, so only the storages implementing them support API keys.
+type Storage interface {
+	// SaveAPIKey stores a new API key.
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+
+	// GetAPIKeyByHash retrieves the active API key with the given hash.
+	// It returns storage.ErrAPIKeyNotFound if there is none.
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+
+	// GetUserAPIKeys retrieves the active API keys of a user, oldest first.
+	GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+
+	// RevokeAPIKey revokes the active API key with the given ID owned by the user.
+	// It returns storage.ErrAPIKeyNotFound if there is none.
+	RevokeAPIKey(ctx context.Context, id string, userID string, now time.Time) error
+}
+
+// Service manages the API keys of the users.
+type Service struct {
+	storage Storage
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// New creates an API key service backed by storage.
+func New(storage Storage) *Service {
+	return &Service{storage: storage, now: time.Now}
+}
+
+// Create issues a new key acting for userID with the given scopes. It returns the stored key
+// and the secret, which is not kept anywhere and cannot be retrieved again.
+func (s *Service) Create(
+	ctx context.Context,
+	userID string,
+	name string,
+	scopes []models.APIKeyScope,
+) (models.APIKey, string, error) {
+	const op = "apikey.Create"
+
+	if len(name) > maxNameLength {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w: name is longer than %d characters", op, ErrInvalidParams, maxNameLength)
+	}
+
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w: at least one scope is required", op, ErrInvalidParams)
+	}
+
+	unique := make([]models.APIKeyScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if _, err := models.ParseAPIKeyScope(string(scope)); err != nil {
+			return models.APIKey{}, "", fmt.Errorf("%s: %w: %w", op, ErrInvalidParams, err)
+		}
+		if !slices.Contains(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+
+	random := make([]byte, secretSize)
+	if _, err := rand.Read(random); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	key := models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:prefixLength],
+		Hash:      Hash(secret),
+		Scopes:    unique,
+		CreatedAt: s.now().UTC(),
+	}
+
+	if err := s.storage.SaveAPIKey(ctx, key); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, secret, nil
+}
+
+// List returns the active keys of userID, oldest first.
+func (s *Service) List(ctx context.Context, userID string) ([]models.APIKey, error) {
+	keys, err := s.storage.GetUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("apikey.List: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke revokes the key with the given ID. It returns ErrNotFound unless it is an active key of userID.
+func (s *Service) Revoke(ctx context.Context, userID string, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	err := s.storage.RevokeAPIKey(ctx, id, userID, s.now().UTC())
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("apikey.Revoke: %w", err)
+	}
+
+	return nil
+}
+
+// Authenticate returns the active key matching secret, or ErrInvalidKey.
+func (s *Service) Authenticate(ctx context.Context, secret string) (models.APIKey, error) {
+	if len(secret) <= prefixLength || secret[:len(secretPrefix)] != secretPrefix {
+		return models.APIKey{}, ErrInvalidKey
+	}
+
+	key, err := s.storage.GetAPIKeyByHash(ctx, Hash(secret))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return models.APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("apikey.Authenticate: %w", err)
+	}
+
+	return key, nil
+}
+
+// Hash returns the hex SHA-256 hash a key is stored under.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+func newService(t *testing.T) *Service {
+	t.Helper()
+
+	storage, err := memory.New(t.TempDir() + "/storage.json")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, storage.Close())
+	})
+
+	return New(storage)
+}
+
+func TestService_CreateAndAuthenticate(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	key, secret, err := s.Create(ctx, "user1", "backend",
+		[]models.APIKeyScope{models.ScopeRead, models.ScopeWrite, models.ScopeRead})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(secret, secretPrefix))
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.NotContains(t, key.Hash, secret, "the secret is not stored")
+	assert.Equal(t, []models.APIKeyScope{models.ScopeRead, models.ScopeWrite}, key.Scopes)
+
+	authenticated, err := s.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, authenticated.ID)
+	assert.Equal(t, "user1", authenticated.UserID)
+
+	for _, invalid := range []string{"", "sk_", secret[:len(secret)-1], "pk" + secret[2:]} {
+		_, err = s.Authenticate(ctx, invalid)
+		assert.ErrorIs(t, err, ErrInvalidKey, invalid)
+	}
+}
+
+func TestService_CreateInvalid(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		keyName string
+		scopes  []models.APIKeyScope
+	}{
+		{name: "no scopes"},
+		{name: "unknown scope", scopes: []models.APIKeyScope{"admin"}},
+		{name: "long name", keyName: strings.Repeat("a", maxNameLength+1), scopes: []models.APIKeyScope{models.ScopeRead}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.Create(ctx, "user1", tt.keyName, tt.scopes)
+			assert.ErrorIs(t, err, ErrInvalidParams)
+		})
+	}
+}
+
+func TestService_ListAndRevoke(t *testing.T) {
+	s := newService(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	first, secret, err := s.Create(ctx, "user1", "", []models.APIKeyScope{models.ScopeRead})
+	require.NoError(t, err)
+
+	now = now.Add(time.Second)
+	second, _, err := s.Create(ctx, "user1", "", []models.APIKeyScope{models.ScopeDelete})
+	require.NoError(t, err)
+
+	_, _, err = s.Create(ctx, "user2", "", []models.APIKeyScope{models.ScopeRead})
+	require.NoError(t, err)
+
+	keys, err := s.List(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, first.ID, keys[0].ID)
+	assert.Equal(t, second.ID, keys[1].ID)
+
+	assert.ErrorIs(t, s.Revoke(ctx, "user2", first.ID), ErrNotFound, "a key of another user cannot be revoked")
+	require.NoError(t, s.Revoke(ctx, "user1", first.ID))
+	assert.ErrorIs(t, s.Revoke(ctx, "user1", first.ID), ErrNotFound)
+	assert.ErrorIs(t, s.Revoke(ctx, "user1", "not-a-uuid"), ErrNotFound)
+
+	_, err = s.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrInvalidKey, "a revoked key is rejected")
+
+	keys, err = s.List(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, second.ID, keys[0].ID)
+}
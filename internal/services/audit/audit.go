@@ -0,0 +1,93 @@
+// Package audit delivers the lifecycle events of short URLs to pluggable sinks.
+//
+// A sink receives the events in the order they happen. The file sink appends them to a
+// JSON lines file and can read the history of a user's URLs back; the webhook sink posts
+// them to an HTTP endpoint. Sinks are combined with Multi.
+//
+// Events carry the ID of the request that caused them. It is kept in the context under
+// the key of the chi RequestID middleware, so HTTP requests carry it as is, and the other
+// entry points set it with WithRequestID. A change made for several requests at once, such
+// as a batch of queued deletions, carries the request ID of each code with WithCodeRequestIDs.
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// codeRequestIDsKey is the context key of the request IDs of the codes.
+type codeRequestIDsKey struct{}
+
+// ErrNoHistory is returned by Multi.History when none of its sinks can read events back.
+var ErrNoHistory = errors.New("no audit sink keeps a history")
+
+// Sink receives the lifecycle events of short URLs.
+type Sink interface {
+	Emit(ctx context.Context, events []models.LinkEvent) error
+}
+
+// Reader reads the recorded events back.
+type Reader interface {
+	// History returns the latest events of the URLs owned by ownerID, newest first,
+	// limited to the URL with the given code unless it is empty. A limit of zero
+	// returns all events.
+	History(ctx context.Context, ownerID, code string, limit int) ([]models.LinkEvent, error)
+}
+
+// Multi fans the events out to several sinks.
+type Multi []Sink
+
+// Emit passes the events to every sink, even if some of them fail, and returns their errors joined.
+func (m Multi) Emit(ctx context.Context, events []models.LinkEvent) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Emit(ctx, events); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// History reads the history from the first sink that is a Reader.
+func (m Multi) History(ctx context.Context, ownerID, code string, limit int) ([]models.LinkEvent, error) {
+	for _, sink := range m {
+		if reader, ok := sink.(Reader); ok {
+			return reader.History(ctx, ownerID, code, limit)
+		}
+	}
+
+	return nil, ErrNoHistory
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, middleware.RequestIDKey, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	return middleware.GetReqID(ctx)
+}
+
+// WithCodeRequestIDs returns a copy of ctx carrying the ID of the request that asked for
+// the change of each code.
+func WithCodeRequestIDs(ctx context.Context, requestIDs map[string]string) context.Context {
+	return context.WithValue(ctx, codeRequestIDsKey{}, requestIDs)
+}
+
+// CodeRequestID returns the ID of the request that asked for the change of code, falling
+// back to the request ID carried by ctx.
+func CodeRequestID(ctx context.Context, code string) string {
+	if requestIDs, ok := ctx.Value(codeRequestIDsKey{}).(map[string]string); ok {
+		if requestID, ok := requestIDs[code]; ok {
+			return requestID
+		}
+	}
+
+	return RequestID(ctx)
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// failingSink is a sink that always fails.
+type failingSink struct{}
+
+func (failingSink) Emit(context.Context, []models.LinkEvent) error {
+	return errors.New("sink is down")
+}
+
+func newFileSink(t *testing.T) *FileSink {
+	t.Helper()
+
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sink.Close() })
+
+	return sink
+}
+
+func TestFileSink_History(t *testing.T) {
+	ctx := context.Background()
+	sink := newFileSink(t)
+
+	events, err := sink.History(ctx, "user1", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	require.NoError(t, sink.Emit(ctx, []models.LinkEvent{
+		{Action: models.EventCreate, ActorID: "user1", OwnerID: "user1", Code: "code1", URL: "https://example.com/1"},
+		{Action: models.EventCreate, ActorID: "user2", OwnerID: "user2", Code: "code2", URL: "https://example.com/2"},
+	}))
+	require.NoError(t, sink.Emit(ctx, []models.LinkEvent{
+		{Action: models.EventDelete, ActorID: "admin", OwnerID: "user1", Code: "code1", RequestID: "req-1"},
+		{Action: models.EventCreate, ActorID: "user1", OwnerID: "user1", Code: "code3", URL: "https://example.com/3"},
+	}))
+
+	events, err = sink.History(ctx, "user1", "", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 3, "only the events of the owner's URLs are returned")
+	assert.Equal(t, "code3", events[0].Code, "the newest event comes first")
+	assert.Equal(t, models.EventDelete, events[1].Action)
+	assert.Equal(t, "admin", events[1].ActorID)
+	assert.Equal(t, "req-1", events[1].RequestID)
+
+	events, err = sink.History(ctx, "user1", "code1", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.EventDelete, events[0].Action)
+	assert.Equal(t, models.EventCreate, events[1].Action)
+
+	events, err = sink.History(ctx, "user1", "", 1)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestFileSink_SkipsTornLines(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"action":"create","owner_id":"user1","co`+"\n"), 0o600))
+
+	sink, err := NewFileSink(path)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	require.NoError(t, sink.Emit(ctx, []models.LinkEvent{{Action: models.EventCreate, OwnerID: "user1", Code: "code1"}}))
+
+	events, err := sink.History(ctx, "user1", "", 0)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "code1", events[0].Code)
+}
+
+func TestWebhookSink_Emit(t *testing.T) {
+	var received []models.LinkEvent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, time.Second)
+
+	err := sink.Emit(context.Background(), []models.LinkEvent{{Action: models.EventCreate, Code: "code1"}})
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, "code1", received[0].Code)
+}
+
+func TestWebhookSink_Status(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := NewWebhookSink(server.URL, time.Second).Emit(context.Background(), []models.LinkEvent{{}})
+	assert.Error(t, err)
+}
+
+func TestMulti(t *testing.T) {
+	ctx := context.Background()
+	file := newFileSink(t)
+
+	sinks := Multi{failingSink{}, file}
+
+	err := sinks.Emit(ctx, []models.LinkEvent{{Action: models.EventCreate, OwnerID: "user1", Code: "code1"}})
+	assert.Error(t, err, "the error of a sink is reported")
+
+	events, err := sinks.History(ctx, "user1", "", 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "the other sinks still receive the events")
+
+	_, err = Multi{failingSink{}}.History(ctx, "user1", "", 0)
+	assert.ErrorIs(t, err, ErrNoHistory)
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Empty(t, RequestID(context.Background()))
+	assert.Equal(t, "req-1", RequestID(WithRequestID(context.Background(), "req-1")))
+}
+
+func TestCodeRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-0")
+	assert.Equal(t, "req-0", CodeRequestID(ctx, "code1"))
+
+	ctx = WithCodeRequestIDs(ctx, map[string]string{"code1": "req-1"})
+	assert.Equal(t, "req-1", CodeRequestID(ctx, "code1"))
+	assert.Equal(t, "req-0", CodeRequestID(ctx, "code2"))
+	assert.Empty(t, CodeRequestID(WithCodeRequestIDs(context.Background(), nil), "code1"))
+}
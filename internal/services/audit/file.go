@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+const permission = 0600
+
+// FileSink appends the events to a file, one JSON object per line.
+type FileSink struct {
+	// mu serializes the writes and keeps History from reading a half-written line.
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileSink opens the file at path for appending, creating it if needed.
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permission)
+	if err != nil {
+		return nil, fmt.Errorf("audit.NewFileSink: %w", err)
+	}
+
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Emit appends the events to the file with a single write.
+func (f *FileSink) Emit(_ context.Context, events []models.LinkEvent) error {
+	var buf []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("audit.FileSink.Emit: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Write(buf); err != nil {
+		return fmt.Errorf("audit.FileSink.Emit: %w", err)
+	}
+
+	return nil
+}
+
+// History scans the file for the events of the URLs owned by ownerID. Lines that cannot be
+// decoded, such as one torn by a crash, are skipped.
+func (f *FileSink) History(ctx context.Context, ownerID, code string, limit int) ([]models.LinkEvent, error) {
+	const op = "audit.FileSink.History"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	var events []models.LinkEvent
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var event models.LinkEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+
+		if event.OwnerID == ownerID && (code == "" || event.Code == code) {
+			events = append(events, event)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	slices.Reverse(events)
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// Close closes the file.
+func (f *FileSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// WebhookSink posts the events to an HTTP endpoint as a JSON array.
+//
+// It is a stand-in for a proper delivery pipeline: every Emit is a single attempt made
+// while the caller waits, and an event that fails to be delivered is lost.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting to url, giving up on a delivery after timeout.
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Emit posts the events. Any response status but 2xx is an error.
+func (w *WebhookSink) Emit(ctx context.Context, events []models.LinkEvent) error {
+	const op = "audit.WebhookSink.Emit"
+
+	body, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s: unexpected status %s", op, res.Status)
+	}
+
+	return nil
+}
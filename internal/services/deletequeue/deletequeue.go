@@ -0,0 +1,422 @@
+// Package deletequeue implements the asynchronous deletion of short URLs.
+//
+// Deletion requests are put into a bounded queue and processed by a pool of workers.
+// A worker merges the requests it receives, from any number of users, into a batch that is
+// flushed once it holds enough codes or when the flush interval elapses; the codes of each
+// user in the batch are then deleted with a single call. The call carries the ID of the
+// request that asked for the deletion of each code, so the deletions can still be traced back
+// to their requests. Failed deletions are retried with exponential backoff, and the requests
+// that still fail once the retries are exhausted are queued again every requeue interval.
+//
+// Every accepted request is first appended to a journal, the pending file, and marked done
+// there once its codes are deleted. The requests that were not, because the process was
+// killed, the shutdown deadline passed or the deletion kept failing, are re-queued on the
+// next start. On shutdown the queue is drained.
+package deletequeue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when the queue has no room for the request.
+	ErrQueueFull = errors.New("delete queue is full")
+
+	// ErrClosed is returned by Enqueue once the queue is shutting down.
+	ErrClosed = errors.New("delete queue is closed")
+
+	// ErrPending is returned by Wait when deletions of the user failed and are left
+	// pending until they are queued again.
+	ErrPending = errors.New("deletions are pending")
+)
+
+const permission = 0600
+
+// Deleter deletes the short URLs of a user.
+type Deleter interface {
+	Delete(ctx context.Context, codes []string, userID string) error
+}
+
+// Request is a request to delete the short URLs of a user.
+type Request struct {
+	// UserID is the owner of the short URLs.
+	UserID string `json:"user_id"`
+
+	// Codes are the short codes to delete.
+	Codes []string `json:"codes"`
+
+	// RequestID is the ID of the request that asked for the deletion, if known.
+	RequestID string `json:"request_id,omitempty"`
+
+	// seq is the sequence number of the request in the journal.
+	seq uint64
+}
+
+// Config holds the queue settings.
+type Config struct {
+	// Size is the number of requests the queue holds before Enqueue fails.
+	Size int
+
+	// Workers is the number of workers processing the queue.
+	Workers int
+
+	// BatchSize is the number of codes that triggers a flush of a worker batch.
+	BatchSize int
+
+	// FlushInterval is the longest time a request waits in a worker batch.
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of times a failed deletion is retried.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry. It doubles with every retry.
+	RetryBackoff time.Duration
+
+	// RequeueInterval is how often the requests whose deletion failed after all retries
+	// are queued again.
+	RequeueInterval time.Duration
+
+	// PendingPath is the journal of the requests not processed yet.
+	// An empty path disables persistence.
+	PendingPath string
+}
+
+// Queue is the asynchronous deletion queue.
+type Queue struct {
+	deleter Deleter
+	cfg     Config
+
+	// mu guards closed and the sends to requests against the close of the channel.
+	mu       sync.RWMutex
+	closed   bool
+	requests chan Request
+
+	// stop is closed along with requests, to stop requeueing the failed requests.
+	stop chan struct{}
+
+	// ctx is canceled when the shutdown deadline passes.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// journal holds the requests accepted and not processed yet.
+	journal *journal
+}
+
+// New creates a queue that deletes URLs with deleter. Start must be called to process it.
+func New(deleter Deleter, cfg Config) *Queue {
+	cfg.Size = max(cfg.Size, 1)
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.RequeueInterval <= 0 {
+		cfg.RequeueInterval = time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		deleter:  deleter,
+		cfg:      cfg,
+		requests: make(chan Request, cfg.Size),
+		stop:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		journal:  newJournal(cfg.PendingPath),
+	}
+}
+
+// Start starts the workers and re-queues the requests left pending by the previous run.
+// It must be called before Enqueue.
+func (q *Queue) Start() error {
+	pending, err := q.journal.open()
+	if err != nil {
+		return err
+	}
+
+	for range q.cfg.Workers {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	q.wg.Add(1)
+	go q.requeuer()
+
+	if len(pending) > 0 {
+		slog.Info("re-queueing pending deletions", slog.Int("requests", len(pending)))
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	// The workers are running, so a blocking send cannot deadlock even if
+	// there are more pending requests than the queue holds.
+	for _, req := range pending {
+		q.requests <- req
+	}
+
+	return nil
+}
+
+// Enqueue adds a deletion request to the queue without blocking, once it is saved to the
+// journal. The request ID carried by ctx is passed on to the deleter; ctx is not used otherwise.
+// It returns ErrQueueFull if the queue is full and ErrClosed if it is shutting down.
+func (q *Queue) Enqueue(ctx context.Context, userID string, codes []string) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	if len(q.requests) == cap(q.requests) {
+		return ErrQueueFull
+	}
+
+	req, err := q.journal.add(Request{UserID: userID, Codes: codes, RequestID: audit.RequestID(ctx)})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q.requests <- req:
+		return nil
+	default:
+		// Another request took the room left.
+		if err = q.journal.done(req.seq); err != nil {
+			slog.Error("failed to drop rejected deletion from the journal", sl.Err(err))
+		}
+		return ErrQueueFull
+	}
+}
+
+// Wait blocks until the deletions of userID enqueued before the call are done, so a change
+// made after it, such as restoring some of the URLs, cannot be undone by them. It returns
+// ErrPending if some of them failed, and the error of ctx if it is done first.
+func (q *Queue) Wait(ctx context.Context, userID string) error {
+	return q.journal.wait(ctx, userID)
+}
+
+// Shutdown stops accepting requests and waits for the workers to drain the queue.
+// If ctx is done first, the remaining deletions are abandoned. The requests that were
+// not processed are left in the journal, to be re-queued by the next Start.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.requests)
+		close(q.stop)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	var errShutdown error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		errShutdown = ctx.Err()
+	}
+
+	q.cancel()
+
+	// Requests are left in the queue only if the workers were never started.
+	for range q.requests {
+	}
+
+	if pending := q.journal.pending(); len(pending) > 0 {
+		if q.cfg.PendingPath == "" {
+			slog.Error("dropping pending deletions", slog.Int("requests", len(pending)))
+		} else {
+			slog.Info("saved pending deletions", slog.Int("requests", len(pending)))
+		}
+	}
+
+	if err := q.journal.close(); err != nil {
+		return errors.Join(errShutdown, err)
+	}
+
+	return errShutdown
+}
+
+// userBatch holds the codes of a user collected in a batch, the ID of the request that
+// asked for the deletion of each code and the sequence numbers of those requests.
+type userBatch struct {
+	codes      []string
+	requestIDs map[string]string
+	seqs       []uint64
+}
+
+// batch holds the codes a worker collected, grouped by user.
+type batch struct {
+	users map[string]*userBatch
+	size  int
+}
+
+func (b *batch) add(req Request) {
+	if b.users == nil {
+		b.users = make(map[string]*userBatch)
+	}
+
+	user, ok := b.users[req.UserID]
+	if !ok {
+		user = &userBatch{requestIDs: make(map[string]string)}
+		b.users[req.UserID] = user
+	}
+
+	user.codes = append(user.codes, req.Codes...)
+	user.seqs = append(user.seqs, req.seq)
+	if req.RequestID != "" {
+		for _, code := range req.Codes {
+			user.requestIDs[code] = req.RequestID
+		}
+	}
+	b.size += len(req.Codes)
+}
+
+// worker collects requests into a batch and flushes it by size or by time.
+func (q *Queue) worker() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var b batch
+
+	for {
+		select {
+		case req, ok := <-q.requests:
+			if !ok {
+				q.flush(&b)
+				return
+			}
+
+			b.add(req)
+			if b.size >= q.cfg.BatchSize {
+				q.flush(&b)
+			}
+		case <-ticker.C:
+			q.flush(&b)
+		}
+	}
+}
+
+// requeuer queues the failed requests again every RequeueInterval until the queue is shut down.
+func (q *Queue) requeuer() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.RequeueInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.requeue()
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// requeue queues the failed requests again, as far as the queue has room for them.
+// The others stay failed until the next attempt.
+func (q *Queue) requeue() {
+	failed := q.journal.retry()
+	if len(failed) == 0 {
+		return
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	requeued := 0
+	for _, req := range failed {
+		if !q.closed {
+			select {
+			case q.requests <- req:
+				requeued++
+				continue
+			default:
+			}
+		}
+		q.journal.fail(req.seq)
+	}
+
+	slog.Info("re-queueing failed deletions", slog.Int("requests", requeued), slog.Int("left", len(failed)-requeued))
+}
+
+// flush deletes the codes of every user in the batch, marks their requests done in the
+// journal and empties the batch. The requests whose codes could not be deleted stay in
+// the journal and are queued again by the requeuer.
+func (q *Queue) flush(b *batch) {
+	for userID, user := range b.users {
+		if err := q.delete(userID, user); err != nil {
+			slog.Error("failed to delete URLs, keeping them pending",
+				slog.String("user_id", userID),
+				slog.Int("codes", len(user.codes)),
+				sl.Err(err),
+			)
+			q.journal.fail(user.seqs...)
+			continue
+		}
+
+		if err := q.journal.done(user.seqs...); err != nil {
+			slog.Error("failed to mark deletions done", slog.String("user_id", userID), sl.Err(err))
+		}
+	}
+
+	*b = batch{}
+}
+
+// delete deletes the codes of a user, retrying with exponential backoff. The deleter gets
+// the ID of the request that asked for the deletion of each code in the context.
+func (q *Queue) delete(userID string, user *userBatch) error {
+	backoff := q.cfg.RetryBackoff
+
+	ctx := audit.WithCodeRequestIDs(q.ctx, user.requestIDs)
+
+	for attempt := 0; ; attempt++ {
+		if err := q.ctx.Err(); err != nil {
+			return err
+		}
+
+		err := q.deleter.Delete(ctx, user.codes, userID)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= q.cfg.MaxRetries {
+			return err
+		}
+
+		slog.Warn("failed to delete URLs, retrying", slog.Int("attempt", attempt+1), sl.Err(err))
+
+		select {
+		case <-time.After(backoff):
+		case <-q.ctx.Done():
+			return q.ctx.Err()
+		}
+
+		backoff *= 2
+	}
+}
@@ -0,0 +1,280 @@
+package deletequeue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/services/audit"
+)
+
+type call struct {
+	userID string
+	codes  []string
+
+	// requestIDs holds the request ID the deleter got for each code, if any.
+	requestIDs map[string]string
+}
+
+// mockDeleter records the calls and fails the first failures of them.
+type mockDeleter struct {
+	mu       sync.Mutex
+	calls    []call
+	failures int
+	block    bool
+}
+
+func (m *mockDeleter) Delete(ctx context.Context, codes []string, userID string) error {
+	var requestIDs map[string]string
+	for _, code := range codes {
+		if requestID := audit.CodeRequestID(ctx, code); requestID != "" {
+			if requestIDs == nil {
+				requestIDs = make(map[string]string)
+			}
+			requestIDs[code] = requestID
+		}
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, call{userID: userID, codes: codes, requestIDs: requestIDs})
+	fail := m.failures > 0
+	m.failures--
+	block := m.block
+	m.mu.Unlock()
+
+	if block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if fail {
+		return errors.New("storage is unavailable")
+	}
+
+	return nil
+}
+
+func (m *mockDeleter) Calls() []call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]call(nil), m.calls...)
+}
+
+func TestQueue_MergesRequestsByUser(t *testing.T) {
+	deleter := &mockDeleter{}
+	q := New(deleter, Config{Size: 10, BatchSize: 100, FlushInterval: time.Hour})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a", "b"}))
+	require.NoError(t, q.Enqueue(context.Background(), "user2", []string{"c"}))
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"d"}))
+
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.ElementsMatch(t, []call{
+		{userID: "user1", codes: []string{"a", "b", "d"}},
+		{userID: "user2", codes: []string{"c"}},
+	}, deleter.Calls())
+}
+
+func TestQueue_KeepsRequestID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	failing := &mockDeleter{failures: 1}
+	q := New(failing, Config{Size: 10, Workers: 1, BatchSize: 100, FlushInterval: time.Hour, PendingPath: path})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(audit.WithRequestID(context.Background(), "req-1"), "user1", []string{"a"}))
+	require.NoError(t, q.Enqueue(audit.WithRequestID(context.Background(), "req-2"), "user1", []string{"b"}))
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.Equal(t, []call{{
+		userID:     "user1",
+		codes:      []string{"a", "b"},
+		requestIDs: map[string]string{"a": "req-1", "b": "req-2"},
+	}}, failing.Calls(), "the deletions of different requests are merged, keeping the ID of each")
+
+	deleter := &mockDeleter{}
+	next := New(deleter, Config{Size: 10, BatchSize: 100, FlushInterval: time.Hour, PendingPath: path})
+	require.NoError(t, next.Start())
+	require.NoError(t, next.Shutdown(context.Background()))
+
+	require.Len(t, deleter.Calls(), 1, "the failed deletion is re-run on start")
+	assert.Equal(t, failing.Calls()[0], deleter.Calls()[0], "along with its request IDs")
+}
+
+func TestQueue_FlushBySize(t *testing.T) {
+	deleter := &mockDeleter{}
+	q := New(deleter, Config{Size: 10, BatchSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, q.Start())
+	defer q.Shutdown(context.Background())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a", "b"}))
+
+	assert.Eventually(t, func() bool {
+		return len(deleter.Calls()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestQueue_FlushByTime(t *testing.T) {
+	deleter := &mockDeleter{}
+	q := New(deleter, Config{Size: 10, BatchSize: 100, FlushInterval: 5 * time.Millisecond})
+	require.NoError(t, q.Start())
+	defer q.Shutdown(context.Background())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+
+	assert.Eventually(t, func() bool {
+		return len(deleter.Calls()) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestQueue_Retry(t *testing.T) {
+	deleter := &mockDeleter{failures: 2}
+	q := New(deleter, Config{Size: 10, MaxRetries: 3, RetryBackoff: time.Millisecond})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.Len(t, deleter.Calls(), 3)
+	assert.Empty(t, q.journal.pending())
+}
+
+func TestQueue_PendingIsRerunOnStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	failing := &mockDeleter{failures: 2}
+	q := New(failing, Config{Size: 10, MaxRetries: 1, RetryBackoff: time.Millisecond, PendingPath: path})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+	require.NoError(t, q.Shutdown(context.Background()))
+	assert.FileExists(t, path)
+
+	deleter := &mockDeleter{}
+	next := New(deleter, Config{Size: 10, PendingPath: path})
+	require.NoError(t, next.Start())
+	assert.FileExists(t, path, "the journal is kept until the deletion is done")
+
+	require.NoError(t, next.Shutdown(context.Background()))
+	assert.Equal(t, []call{{userID: "user1", codes: []string{"a"}}}, deleter.Calls())
+	assert.NoFileExists(t, path)
+}
+
+func TestQueue_ShutdownDeadline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	q := New(&mockDeleter{block: true}, Config{Size: 10, PendingPath: path})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+	require.NoError(t, q.Enqueue(context.Background(), "user2", []string{"b"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+
+	pending, err := newJournal(path).open()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, "user1", pending[0].UserID)
+	assert.Equal(t, []string{"a"}, pending[0].Codes)
+	assert.Equal(t, "user2", pending[1].UserID)
+	assert.Equal(t, []string{"b"}, pending[1].Codes)
+}
+
+func TestQueue_Crash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	deleter := &mockDeleter{}
+	q := New(deleter, Config{Size: 10, BatchSize: 1, FlushInterval: time.Hour, PendingPath: path})
+	require.NoError(t, q.Start())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+	require.Eventually(t, func() bool {
+		return len(deleter.Calls()) == 1 && len(q.journal.pending()) == 0
+	}, time.Second, time.Millisecond)
+
+	// The process is killed while the next deletion runs: the queue is never shut down.
+	deleter.mu.Lock()
+	deleter.block = true
+	deleter.mu.Unlock()
+
+	require.NoError(t, q.Enqueue(context.Background(), "user2", []string{"b"}))
+	require.Eventually(t, func() bool {
+		return len(deleter.Calls()) == 2
+	}, time.Second, time.Millisecond)
+
+	next := &mockDeleter{}
+	restarted := New(next, Config{Size: 10, PendingPath: path})
+	require.NoError(t, restarted.Start())
+	require.NoError(t, restarted.Shutdown(context.Background()))
+
+	assert.Equal(t, []call{{userID: "user2", codes: []string{"b"}}}, next.Calls(),
+		"only the deletion not done is re-run")
+	assert.NoFileExists(t, path)
+
+	q.cancel()
+}
+
+func TestQueue_Wait(t *testing.T) {
+	deleter := &mockDeleter{failures: 1}
+	q := New(deleter, Config{Size: 10, BatchSize: 100, FlushInterval: 50 * time.Millisecond})
+	require.NoError(t, q.Start())
+	defer q.Shutdown(context.Background())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+
+	require.NoError(t, q.Wait(context.Background(), "user2"), "the deletions of other users are not waited for")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Wait(ctx, "user1"), context.DeadlineExceeded)
+
+	assert.ErrorIs(t, q.Wait(context.Background(), "user1"), ErrPending, "the deletion failed")
+
+	require.NoError(t, q.Enqueue(context.Background(), "user2", []string{"b"}))
+	require.NoError(t, q.Wait(context.Background(), "user2"))
+	assert.Len(t, deleter.Calls(), 2, "the deletion is done once waited for")
+}
+
+func TestQueue_RequeuesFailed(t *testing.T) {
+	deleter := &mockDeleter{failures: 1}
+	q := New(deleter, Config{Size: 10, FlushInterval: time.Millisecond, RequeueInterval: 20 * time.Millisecond})
+	require.NoError(t, q.Start())
+	defer q.Shutdown(context.Background())
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+
+	assert.ErrorIs(t, q.Wait(context.Background(), "user1"), ErrPending, "the deletion failed")
+
+	require.Eventually(t, func() bool {
+		return q.Wait(context.Background(), "user1") == nil
+	}, time.Second, 5*time.Millisecond, "the failed deletion is queued again")
+
+	assert.Len(t, deleter.Calls(), 2)
+	assert.Empty(t, q.journal.pending())
+}
+
+func TestQueue_Full(t *testing.T) {
+	q := New(&mockDeleter{}, Config{Size: 1})
+
+	require.NoError(t, q.Enqueue(context.Background(), "user1", []string{"a"}))
+	assert.ErrorIs(t, q.Enqueue(context.Background(), "user1", []string{"b"}), ErrQueueFull)
+}
+
+func TestQueue_Closed(t *testing.T) {
+	q := New(&mockDeleter{}, Config{})
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.ErrorIs(t, q.Enqueue(context.Background(), "user1", []string{"a"}), ErrClosed)
+}
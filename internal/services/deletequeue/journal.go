@@ -0,0 +1,405 @@
+package deletequeue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// compactEntries is the number of done entries the journal holds before it is rewritten
+// with the outstanding requests only.
+const compactEntries = 1024
+
+// entry is a line of the journal: a request accepted under Seq, or, with Done, the mark
+// that the request with that Seq was applied.
+type entry struct {
+	Seq     uint64   `json:"seq"`
+	Request *Request `json:"request,omitempty"`
+	Done    bool     `json:"done,omitempty"`
+}
+
+// journal is the write-ahead log of the deletion requests. A request is appended and synced
+// when it is accepted, and marked done once it is applied, so the requests that were not
+// applied when the process stopped, for any reason, are re-queued by the next start.
+// With an empty path the requests are tracked in memory only.
+type journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+
+	// nextSeq is the sequence number of the next request.
+	nextSeq uint64
+
+	// outstanding holds the requests not applied yet, keyed by sequence number.
+	outstanding map[uint64]Request
+
+	// entries is the number of lines in the file.
+	entries int
+
+	// failed holds the sequence numbers of the outstanding requests whose deletion failed
+	// and that are not queued again yet.
+	failed map[uint64]struct{}
+
+	// changed is closed, and replaced, whenever requests are applied or fail.
+	changed chan struct{}
+}
+
+func newJournal(path string) *journal {
+	return &journal{
+		path:        path,
+		nextSeq:     1,
+		outstanding: make(map[uint64]Request),
+		failed:      make(map[uint64]struct{}),
+		changed:     make(chan struct{}),
+	}
+}
+
+// open reads the journal left by the previous run and rewrites it with the outstanding
+// requests only. It returns them in the order they were accepted. A line torn by a crash
+// at the end of the journal is dropped.
+func (j *journal) open() ([]Request, error) {
+	const op = "deletequeue.journal.open"
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.path == "" {
+		return nil, nil
+	}
+
+	if err := j.replay(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := j.rewrite(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return j.sorted(), nil
+}
+
+// replay applies the entries of the file. The caller must hold j.mu.
+func (j *journal) replay() error {
+	file, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			slog.Error("failed to close pending deletions", sl.Err(errClose))
+		}
+	}()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var line json.RawMessage
+		if err = decoder.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				slog.Warn("discarding torn entry at the end of pending deletions", sl.Err(err))
+				return nil
+			}
+			return err
+		}
+
+		var e entry
+		if err = json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+
+		switch {
+		case e.Seq == 0:
+			// A request saved by a version without a journal.
+			var req Request
+			if err = json.Unmarshal(line, &req); err != nil {
+				return err
+			}
+			req.seq = j.nextSeq
+			j.outstanding[req.seq] = req
+			j.nextSeq++
+			continue
+		case e.Done:
+			delete(j.outstanding, e.Seq)
+		case e.Request != nil:
+			req := *e.Request
+			req.seq = e.Seq
+			j.outstanding[e.Seq] = req
+		}
+
+		j.nextSeq = max(j.nextSeq, e.Seq+1)
+	}
+}
+
+// add assigns the next sequence number to the request and appends it to the journal.
+func (j *journal) add(req Request) (Request, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	req.seq = j.nextSeq
+
+	if err := j.append(entry{Seq: req.seq, Request: &req}); err != nil {
+		return Request{}, fmt.Errorf("deletequeue.journal.add: %w", err)
+	}
+
+	j.nextSeq++
+	j.outstanding[req.seq] = req
+
+	return req, nil
+}
+
+// done marks the requests with the given sequence numbers as applied.
+func (j *journal) done(seqs ...uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]entry, 0, len(seqs))
+	for _, seq := range seqs {
+		entries = append(entries, entry{Seq: seq, Done: true})
+	}
+
+	if err := j.append(entries...); err != nil {
+		return fmt.Errorf("deletequeue.journal.done: %w", err)
+	}
+
+	for _, seq := range seqs {
+		delete(j.outstanding, seq)
+		delete(j.failed, seq)
+	}
+	j.notify()
+
+	if len(j.outstanding) == 0 || j.entries >= 2*len(j.outstanding)+compactEntries {
+		if err := j.rewrite(); err != nil {
+			return fmt.Errorf("deletequeue.journal.done: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fail marks the requests with the given sequence numbers as failed: they stay outstanding
+// until retry hands them out again.
+func (j *journal) fail(seqs ...uint64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, seq := range seqs {
+		if _, ok := j.outstanding[seq]; ok {
+			j.failed[seq] = struct{}{}
+		}
+	}
+	j.notify()
+}
+
+// retry clears the failed marks and returns the requests that had them, in the order they
+// were accepted, to be queued again.
+func (j *journal) retry() []Request {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	requests := make([]Request, 0, len(j.failed))
+	for _, seq := range slices.Sorted(maps.Keys(j.failed)) {
+		requests = append(requests, j.outstanding[seq])
+	}
+	clear(j.failed)
+
+	return requests
+}
+
+// wait blocks until the requests of userID accepted before the call are applied. It returns
+// ErrPending if some of them failed, and the error of ctx if it is done first.
+func (j *journal) wait(ctx context.Context, userID string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	last := j.nextSeq
+	for {
+		running, failed := false, false
+		for seq, req := range j.outstanding {
+			if seq >= last || req.UserID != userID {
+				continue
+			}
+			if _, ok := j.failed[seq]; ok {
+				failed = true
+			} else {
+				running = true
+			}
+		}
+
+		switch {
+		case running:
+		case failed:
+			return ErrPending
+		default:
+			return nil
+		}
+
+		changed := j.changed
+		j.mu.Unlock()
+
+		select {
+		case <-changed:
+			j.mu.Lock()
+		case <-ctx.Done():
+			j.mu.Lock()
+			return ctx.Err()
+		}
+	}
+}
+
+// notify wakes up the callers of wait. The caller must hold j.mu.
+func (j *journal) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// pending returns the outstanding requests in the order they were accepted.
+func (j *journal) pending() []Request {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.sorted()
+}
+
+// close rewrites the journal with the outstanding requests only and closes it.
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.path == "" {
+		return nil
+	}
+
+	if err := j.rewrite(); err != nil {
+		return fmt.Errorf("deletequeue.journal.close: %w", err)
+	}
+
+	return j.closeFile()
+}
+
+// append writes the entries to the file and syncs it. The file is opened, and created if
+// needed, on the first entry. The caller must hold j.mu.
+func (j *journal) append(entries ...entry) error {
+	if j.path == "" {
+		return nil
+	}
+
+	if j.file == nil {
+		file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permission)
+		if err != nil {
+			return err
+		}
+		j.file = file
+	}
+
+	var data []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	if _, err := j.file.Write(data); err != nil {
+		return err
+	}
+
+	j.entries += len(entries)
+
+	return j.file.Sync()
+}
+
+// rewrite replaces the file with one holding the outstanding requests only, or removes it
+// if there are none. The caller must hold j.mu.
+func (j *journal) rewrite() error {
+	if j.path == "" {
+		return nil
+	}
+
+	if err := j.closeFile(); err != nil {
+		return err
+	}
+
+	if len(j.outstanding) == 0 {
+		j.entries = 0
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	tempPath := j.path + ".tmp"
+
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, permission)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, req := range j.sorted() {
+		if err = encoder.Encode(entry{Seq: req.seq, Request: &req}); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if errClose := file.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tempPath, j.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	j.entries = len(j.outstanding)
+
+	return nil
+}
+
+// closeFile closes the file if it is open. The caller must hold j.mu.
+func (j *journal) closeFile() error {
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Close()
+	j.file = nil
+
+	return err
+}
+
+// sorted returns the outstanding requests in the order they were accepted.
+// The caller must hold j.mu.
+func (j *journal) sorted() []Request {
+	requests := make([]Request, 0, len(j.outstanding))
+	for _, seq := range slices.Sorted(maps.Keys(j.outstanding)) {
+		requests = append(requests, j.outstanding[seq])
+	}
+
+	return requests
+}
@@ -0,0 +1,75 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/securecookie"
+)
+
+// Cookies encodes and decodes secure cookie values with the keys of a keyring.
+type Cookies struct {
+	ring *Keyring
+
+	// codecs holds a codec per key ID.
+	codecs map[string]*securecookie.SecureCookie
+}
+
+// NewCookies creates a cookie codec over the keys of ring.
+func NewCookies(ring *Keyring) *Cookies {
+	codecs := make(map[string]*securecookie.SecureCookie, len(ring.keys))
+	for _, key := range ring.keys {
+		var blockKey []byte
+		if key.BlockKey != "" {
+			blockKey = []byte(key.BlockKey)
+		}
+
+		codecs[key.ID] = securecookie.New([]byte(key.HashKey), blockKey)
+	}
+
+	return &Cookies{ring: ring, codecs: codecs}
+}
+
+// Encode encodes value with the current key.
+func (c *Cookies) Encode(name string, value any) (string, error) {
+	key, err := c.ring.Current()
+	if err != nil {
+		return "", fmt.Errorf("keyring.Cookies.Encode: %w", err)
+	}
+
+	return c.codecs[key.ID].Encode(name, value)
+}
+
+// Decode decodes value into dst with the keys valid now, trying the current key first.
+// stale reports whether value was encoded with another key than the current one,
+// in which case it should be encoded again. A value none of the keys valid now decode,
+// such as one encoded with a key that has expired or been removed, fails with ErrUnknownKey.
+func (c *Cookies) Decode(name, value string, dst any) (stale bool, err error) {
+	active := c.ring.Active()
+	if len(active) == 0 {
+		return false, fmt.Errorf("keyring.Cookies.Decode: %w", ErrNoActiveKey)
+	}
+
+	var errs []error
+	invalid := true
+	for i, key := range active {
+		err = c.codecs[key.ID].Decode(name, value, dst)
+		if err == nil {
+			return i > 0, nil
+		}
+
+		// Cookies carry no key ID, so a value of a key no longer valid cannot be told from
+		// a forged one: both fail to decode with every key.
+		var cookieErr securecookie.Error
+		if !errors.As(err, &cookieErr) || !cookieErr.IsDecode() {
+			invalid = false
+		}
+		errs = append(errs, err)
+	}
+
+	if invalid {
+		return false, fmt.Errorf("keyring.Cookies.Decode: %w: %w", ErrUnknownKey, errors.Join(errs...))
+	}
+
+	return false, fmt.Errorf("keyring.Cookies.Decode: %w", errors.Join(errs...))
+}
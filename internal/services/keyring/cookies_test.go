@@ -0,0 +1,66 @@
+package keyring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type session struct {
+	UserID string `json:"user_id"`
+}
+
+func TestCookies_Rotation(t *testing.T) {
+	oldKey := Key{ID: "old", HashKey: "old hash", BlockKey: "alotsecretalotsecretalotsecretgr", NotAfter: epoch.Add(48 * time.Hour)}
+	newKey := Key{ID: "new", HashKey: "new hash", BlockKey: "0123456789abcdef", NotBefore: epoch.Add(24 * time.Hour)}
+
+	before := NewCookies(at(t, epoch, oldKey))
+
+	encoded, err := before.Encode("user", session{UserID: "user1"})
+	require.NoError(t, err)
+
+	var decoded session
+	stale, err := before.Decode("user", encoded, &decoded)
+	require.NoError(t, err)
+	assert.False(t, stale)
+	assert.Equal(t, "user1", decoded.UserID)
+
+	// The new key is current, the old one still verifies.
+	ring := at(t, epoch.Add(36*time.Hour), oldKey, newKey)
+	during := NewCookies(ring)
+
+	decoded = session{}
+	stale, err = during.Decode("user", encoded, &decoded)
+	require.NoError(t, err)
+	assert.True(t, stale, "a cookie of a previous key is to be re-issued")
+	assert.Equal(t, "user1", decoded.UserID)
+
+	reissued, err := during.Encode("user", decoded)
+	require.NoError(t, err)
+
+	stale, err = during.Decode("user", reissued, &decoded)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	// Once the old key has expired, its cookies are rejected.
+	ring.now = func() time.Time { return epoch.Add(60 * time.Hour) }
+
+	_, err = during.Decode("user", encoded, &decoded)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	stale, err = during.Decode("user", reissued, &decoded)
+	require.NoError(t, err)
+	assert.False(t, stale)
+}
+
+func TestCookies_NoActiveKey(t *testing.T) {
+	cookies := NewCookies(at(t, epoch, Key{ID: "a", HashKey: "hash", NotBefore: epoch.Add(time.Hour)}))
+
+	_, err := cookies.Encode("user", session{UserID: "user1"})
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+
+	_, err = cookies.Decode("user", "value", &session{})
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+}
@@ -0,0 +1,177 @@
+// Package keyring holds the secret keys that sign the user sessions, so the keys can be rotated
+// without logging users out.
+//
+// Every key has an ID and an optional validity window. Of the keys valid at a given time,
+// the one that became valid last is the current key: new sessions are signed with it,
+// while sessions signed with the other valid keys are still accepted and re-issued with
+// the current key. Once a key's window closes, the sessions it signed are rejected.
+//
+// A rotation therefore takes two steps: add the new key with a not_before in the future,
+// or right away, and drop the old key, or let it expire, once the sessions it signed
+// have been re-issued.
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrNoActiveKey is returned when no key of the keyring is valid.
+var ErrNoActiveKey = errors.New("no active key")
+
+// ErrUnknownKey is returned when a value is not signed with any of the keys valid now,
+// because the key it was signed with has expired or been removed, or it was forged.
+var ErrUnknownKey = errors.New("not signed with an active key")
+
+// Key is a set of secrets identified by an ID.
+type Key struct {
+	// ID identifies the key. It is sent along with the tokens the key signs.
+	ID string `json:"id"`
+
+	// HashKey authenticates the session cookies.
+	HashKey string `json:"hash_key"`
+
+	// BlockKey encrypts the session cookies. It must be empty or 16, 24 or 32 bytes long.
+	BlockKey string `json:"block_key,omitempty"`
+
+	// JwtSecret signs the HS256 bearer tokens.
+	JwtSecret string `json:"jwt_secret,omitempty"`
+
+	// JwtKeyPath is the path to the PEM private key signing the RS256 and EdDSA bearer tokens.
+	JwtKeyPath string `json:"jwt_key_path,omitempty"`
+
+	// NotBefore is the time the key becomes valid. The zero time means it always was.
+	NotBefore time.Time `json:"not_before,omitzero"`
+
+	// NotAfter is the time the key stops being valid. The zero time means it never does.
+	NotAfter time.Time `json:"not_after,omitzero"`
+}
+
+// ValidAt reports whether the key is valid at t.
+func (k Key) ValidAt(t time.Time) bool {
+	return (k.NotBefore.IsZero() || !t.Before(k.NotBefore)) && (k.NotAfter.IsZero() || t.Before(k.NotAfter))
+}
+
+// Keyring is a set of keys. It is safe for concurrent use.
+type Keyring struct {
+	keys []Key
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// New creates a keyring holding keys. The IDs must be unique, and every key needs a hash key.
+func New(keys []Key) (*Keyring, error) {
+	const op = "keyring.New"
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", op)
+	}
+
+	ids := make(map[string]struct{}, len(keys))
+	for i, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("%s: key %d has no id", op, i)
+		}
+		if _, ok := ids[key.ID]; ok {
+			return nil, fmt.Errorf("%s: duplicate key id %q", op, key.ID)
+		}
+		ids[key.ID] = struct{}{}
+
+		if key.HashKey == "" {
+			return nil, fmt.Errorf("%s: key %q has no hash key", op, key.ID)
+		}
+		switch len(key.BlockKey) {
+		case 0, 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("%s: block key of key %q must be 16, 24 or 32 bytes long", op, key.ID)
+		}
+		if !key.NotAfter.IsZero() && !key.NotAfter.After(key.NotBefore) {
+			return nil, fmt.Errorf("%s: key %q expires before it becomes valid", op, key.ID)
+		}
+	}
+
+	return &Keyring{keys: append([]Key(nil), keys...), now: time.Now}, nil
+}
+
+// Parse decodes a JSON array of keys.
+func Parse(data []byte) ([]Key, error) {
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("keyring.Parse: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Load reads a JSON array of keys from the file at path.
+func Load(path string) ([]Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keyring.Load: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Keys returns all keys of the keyring, valid or not.
+func (r *Keyring) Keys() []Key {
+	return append([]Key(nil), r.keys...)
+}
+
+// Current returns the key new sessions are signed with: of the keys valid now, the one that
+// became valid last. Of keys that became valid at the same time, the last one listed wins.
+func (r *Keyring) Current() (Key, error) {
+	now := r.now()
+
+	var (
+		current Key
+		found   bool
+	)
+	for _, key := range r.keys {
+		if key.ValidAt(now) && (!found || !key.NotBefore.Before(current.NotBefore)) {
+			current, found = key, true
+		}
+	}
+
+	if !found {
+		return Key{}, ErrNoActiveKey
+	}
+
+	return current, nil
+}
+
+// Get returns the key with the given ID if it is valid now.
+func (r *Keyring) Get(id string) (Key, bool) {
+	now := r.now()
+
+	for _, key := range r.keys {
+		if key.ID == id {
+			return key, key.ValidAt(now)
+		}
+	}
+
+	return Key{}, false
+}
+
+// Active returns the keys valid now, the current key first.
+func (r *Keyring) Active() []Key {
+	current, err := r.Current()
+	if err != nil {
+		return nil
+	}
+
+	now := r.now()
+
+	active := []Key{current}
+	for _, key := range r.keys {
+		if key.ID != current.ID && key.ValidAt(now) {
+			active = append(active, key)
+		}
+	}
+
+	return active
+}
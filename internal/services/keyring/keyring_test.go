@@ -0,0 +1,139 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// at returns a keyring of keys whose clock is stopped at t.
+func at(t *testing.T, now time.Time, keys ...Key) *Keyring {
+	t.Helper()
+
+	ring, err := New(keys)
+	require.NoError(t, err)
+	ring.now = func() time.Time { return now }
+
+	return ring
+}
+
+func TestNew_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{name: "no keys"},
+		{name: "no id", keys: []Key{{HashKey: "hash"}}},
+		{name: "duplicate id", keys: []Key{{ID: "a", HashKey: "hash"}, {ID: "a", HashKey: "hash"}}},
+		{name: "no hash key", keys: []Key{{ID: "a"}}},
+		{name: "bad block key", keys: []Key{{ID: "a", HashKey: "hash", BlockKey: "short"}}},
+		{name: "empty window", keys: []Key{{ID: "a", HashKey: "hash", NotBefore: epoch, NotAfter: epoch}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.keys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyring_Current(t *testing.T) {
+	keys := []Key{
+		{ID: "first", HashKey: "hash", NotAfter: epoch.Add(48 * time.Hour)},
+		{ID: "second", HashKey: "hash", NotBefore: epoch.Add(24 * time.Hour)},
+		{ID: "third", HashKey: "hash", NotBefore: epoch.Add(72 * time.Hour)},
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		current string
+		active  []string
+	}{
+		{name: "only the first key is valid", now: epoch, current: "first", active: []string{"first"}},
+		{name: "the newer key wins", now: epoch.Add(36 * time.Hour), current: "second", active: []string{"second", "first"}},
+		{name: "the first key has expired", now: epoch.Add(60 * time.Hour), current: "second", active: []string{"second"}},
+		{name: "the third key is due", now: epoch.Add(96 * time.Hour), current: "third", active: []string{"third", "second"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring := at(t, tt.now, keys...)
+
+			current, err := ring.Current()
+			require.NoError(t, err)
+			assert.Equal(t, tt.current, current.ID)
+
+			var active []string
+			for _, key := range ring.Active() {
+				active = append(active, key.ID)
+			}
+			assert.Equal(t, tt.active, active)
+		})
+	}
+}
+
+func TestKeyring_CurrentTie(t *testing.T) {
+	ring := at(t, epoch, Key{ID: "a", HashKey: "hash"}, Key{ID: "b", HashKey: "hash"})
+
+	current, err := ring.Current()
+	require.NoError(t, err)
+	assert.Equal(t, "b", current.ID, "the last listed key wins a tie")
+}
+
+func TestKeyring_NoActiveKey(t *testing.T) {
+	ring := at(t, epoch, Key{ID: "a", HashKey: "hash", NotBefore: epoch.Add(time.Hour)})
+
+	_, err := ring.Current()
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+	assert.Empty(t, ring.Active())
+}
+
+func TestKeyring_Get(t *testing.T) {
+	ring := at(t, epoch,
+		Key{ID: "valid", HashKey: "hash"},
+		Key{ID: "expired", HashKey: "hash", NotAfter: epoch},
+	)
+
+	key, ok := ring.Get("valid")
+	assert.True(t, ok)
+	assert.Equal(t, "valid", key.ID)
+
+	_, ok = ring.Get("expired")
+	assert.False(t, ok)
+
+	_, ok = ring.Get("unknown")
+	assert.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": "2025-01", "hash_key": "hash", "block_key": "0123456789abcdef", "jwt_secret": "secret",
+		 "not_before": "2025-01-01T00:00:00Z", "not_after": "2025-03-01T00:00:00Z"}
+	]`), 0o600))
+
+	keys, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Key{{
+		ID:        "2025-01",
+		HashKey:   "hash",
+		BlockKey:  "0123456789abcdef",
+		JwtSecret: "secret",
+		NotBefore: epoch,
+		NotAfter:  time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}}, keys)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"id": "not an array"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+// Package reaper implements the periodic removal of expired short URLs.
+//
+// Expired URLs stop resolving as soon as they expire; the reaper removes them from
+// the storage afterwards, so their codes and original URLs can be used again.
+package reaper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// Purger removes the expired short URLs.
+type Purger interface {
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// Reaper purges the expired short URLs at a fixed interval.
+type Reaper struct {
+	purger   Purger
+	interval time.Duration
+
+	// ctx is canceled on shutdown, aborting a purge in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed once the reaper has stopped. It is nil until Start is called.
+	done chan struct{}
+}
+
+// New creates a reaper that purges expired URLs with purger every interval.
+// Start must be called to run it.
+func New(purger Purger, interval time.Duration) *Reaper {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Reaper{
+		purger:   purger,
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start runs the reaper in the background.
+func (r *Reaper) Start() {
+	r.done = make(chan struct{})
+
+	go r.run()
+}
+
+// Shutdown stops the reaper and waits for a purge in progress to be aborted.
+// It returns ctx.Err() if ctx is done first.
+func (r *Reaper) Shutdown(ctx context.Context) error {
+	r.cancel()
+
+	if r.done == nil {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reaper) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reap()
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// reap purges the URLs that have expired by now.
+func (r *Reaper) reap() {
+	purged, err := r.purger.PurgeExpired(r.ctx)
+	if err != nil {
+		if r.ctx.Err() == nil {
+			slog.Error("failed to purge expired URLs", sl.Err(err))
+		}
+		return
+	}
+
+	if purged > 0 {
+		slog.Info("purged expired URLs", slog.Int64("urls", purged))
+	}
+}
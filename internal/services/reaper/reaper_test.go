@@ -0,0 +1,82 @@
+package reaper
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockPurger struct {
+	calls atomic.Int32
+	err   error
+	block bool
+}
+
+func (m *mockPurger) PurgeExpired(ctx context.Context) (int64, error) {
+	m.calls.Add(1)
+
+	if m.block {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	return 1, m.err
+}
+
+func TestReaper_PurgesPeriodically(t *testing.T) {
+	purger := &mockPurger{}
+
+	r := New(purger, time.Millisecond)
+	r.Start()
+
+	assert.Eventually(t, func() bool {
+		return purger.calls.Load() >= 3
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, r.Shutdown(context.Background()))
+
+	// Nothing is purged after the shutdown.
+	calls := purger.calls.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, calls, purger.calls.Load())
+}
+
+func TestReaper_KeepsRunningAfterError(t *testing.T) {
+	purger := &mockPurger{err: errors.New("storage is down")}
+
+	r := New(purger, time.Millisecond)
+	r.Start()
+
+	assert.Eventually(t, func() bool {
+		return purger.calls.Load() >= 2
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, r.Shutdown(context.Background()))
+}
+
+func TestReaper_ShutdownAbortsPurge(t *testing.T) {
+	purger := &mockPurger{block: true}
+
+	r := New(purger, time.Millisecond)
+	r.Start()
+
+	assert.Eventually(t, func() bool {
+		return purger.calls.Load() == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, r.Shutdown(ctx))
+}
+
+func TestReaper_ShutdownWithoutStart(t *testing.T) {
+	r := New(&mockPurger{}, time.Millisecond)
+
+	assert.NoError(t, r.Shutdown(context.Background()))
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// auditLog holds the audit trail of the admin actions. Entries are appended to a log of their
+// own next to the record log and fsynced, and are never rewritten.
+type auditLog struct {
+	// mu guards the fields below. It is independent of the storage write lock.
+	mu sync.Mutex
+
+	// name is the path of the log. The file is created on the first entry.
+	name string
+
+	file *os.File
+
+	// entries holds the entries, oldest first.
+	entries []models.AuditEntry
+}
+
+// auditName returns the path of the audit log.
+func (s *Storage) auditName() string {
+	return s.fileName + ".audit"
+}
+
+// loadAudit reads the audit log. A record torn by a crash at the end of the log is cut off.
+func (s *Storage) loadAudit() error {
+	a := &auditLog{name: s.auditName()}
+	s.audit = a
+
+	err := replayEntries(a.name, func(entry models.AuditEntry) {
+		a.entries = append(a.entries, entry)
+	})
+	if err != nil {
+		return fmt.Errorf("storage.memory.loadAudit: %w", err)
+	}
+
+	return nil
+}
+
+// FindURLs returns the URLs of all users matching the filter, in the order of their IDs.
+func (s *Storage) FindURLs(ctx context.Context, filter models.URLFilter) ([]models.URL, error) {
+	var urls []models.URL
+
+	collect := func(url models.URL) {
+		if filter.Match(url) {
+			urls = append(urls, url)
+		}
+	}
+
+	switch {
+	case filter.Code != "":
+		if url, ok := s.get(filter.Code); ok {
+			collect(url)
+		}
+	case filter.URL != "":
+		if url, ok := s.findByURL(filter.URL); ok {
+			collect(url)
+		}
+	case filter.UserID != "":
+		for _, code := range s.index.codesByUser(filter.UserID) {
+			if url, ok := s.get(code); ok {
+				collect(url)
+			}
+		}
+	default:
+		for _, sh := range s.shards {
+			sh.mu.RLock()
+			for _, url := range sh.urls {
+				collect(url)
+			}
+			sh.mu.RUnlock()
+		}
+	}
+
+	slices.SortFunc(urls, func(a, b models.URL) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return page(urls, filter.Offset, filter.Limit), nil
+}
+
+// SetURLDisabled disables or re-enables the URL with the given code and appends an update record.
+// It returns false if there is no such URL.
+func (s *Storage) SetURLDisabled(ctx context.Context, code string, disabled bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.get(code)
+	if !ok {
+		return false, nil
+	}
+
+	if url.IsDisabled == disabled {
+		return true, nil
+	}
+
+	url.IsDisabled = disabled
+
+	if err := s.write(RecordUpdate, url); err != nil {
+		return false, fmt.Errorf("storage.memory.SetURLDisabled: %w", err)
+	}
+
+	return true, nil
+}
+
+// PurgeUserURLs removes all URLs of the user, including soft-deleted ones, and appends a purge
+// record for each of them. It returns the number of URLs removed.
+func (s *Storage) PurgeUserURLs(ctx context.Context, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for _, code := range s.index.codesByUser(userID) {
+		url, ok := s.get(code)
+		if !ok {
+			continue
+		}
+
+		if err := s.write(RecordPurge, url); err != nil {
+			return purged, fmt.Errorf("storage.memory.PurgeUserURLs: %w", err)
+		}
+
+		s.index.remove(url)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// SaveAuditEntry appends an entry to the audit trail.
+func (s *Storage) SaveAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	a := s.audit
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := appendEntry(&a.file, a.name, entry); err != nil {
+		return fmt.Errorf("storage.memory.SaveAuditEntry: %w", err)
+	}
+
+	a.entries = append(a.entries, entry)
+
+	return nil
+}
+
+// GetAuditEntries returns the latest entries of the audit trail, newest first.
+// A limit of zero returns all entries.
+func (s *Storage) GetAuditEntries(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	a := s.audit
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries := slices.Clone(a.entries)
+	slices.Reverse(entries)
+
+	return page(entries, 0, limit), nil
+}
+
+// close closes the audit log.
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return closeEntries(&a.file)
+}
+
+// page returns the items after offset, at most limit of them unless limit is zero.
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+// keyLog holds the API keys. They are appended to a log of their own next to the record log.
+// Like a URL record, every entry carries the complete state of a key, so the last entry of a
+// key wins on replay. Keys change rarely and a revocation must survive a crash, so every
+// write is fsynced.
+type keyLog struct {
+	// mu guards the fields below. It is independent of the storage write lock.
+	mu sync.Mutex
+
+	// name is the path of the log. The file is created on the first key.
+	name string
+
+	file *os.File
+
+	// byID holds the keys, revoked ones included, keyed by ID.
+	byID map[string]models.APIKey
+
+	// byHash holds the IDs of the active keys keyed by hash.
+	byHash map[string]string
+}
+
+// keysName returns the path of the API key log.
+func (s *Storage) keysName() string {
+	return s.fileName + ".keys"
+}
+
+// loadKeys reads the API key log. A record torn by a crash at the end of the log is cut off.
+func (s *Storage) loadKeys() error {
+	s.keys = &keyLog{name: s.keysName(), byID: make(map[string]models.APIKey), byHash: make(map[string]string)}
+
+	if err := replayEntries(s.keys.name, s.keys.put); err != nil {
+		return fmt.Errorf("storage.memory.loadKeys: %w", err)
+	}
+
+	return nil
+}
+
+// SaveAPIKey stores a new API key.
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	const op = "storage.memory.SaveAPIKey"
+
+	k := s.keys
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.byID[key.ID]; ok {
+		return fmt.Errorf("%s: duplicate api key id %q", op, key.ID)
+	}
+
+	if err := k.write(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the active API key with the given hash.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	k := s.keys
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id, ok := k.byHash[hash]
+	if !ok {
+		return models.APIKey{}, storage.ErrAPIKeyNotFound
+	}
+
+	return k.byID[id], nil
+}
+
+// GetUserAPIKeys retrieves the active API keys of a user, oldest first.
+func (s *Storage) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	k := s.keys
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var keys []models.APIKey
+	for _, key := range k.byID {
+		if key.UserID == userID && !key.IsRevoked() {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.SortFunc(keys, func(a, b models.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the active API key with the given ID owned by the user.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id string, userID string, now time.Time) error {
+	const op = "storage.memory.RevokeAPIKey"
+
+	k := s.keys
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, ok := k.byID[id]
+	if !ok || key.UserID != userID || key.IsRevoked() {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	key.RevokedAt = now
+	if err := k.write(key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// write appends the key to the log and applies it to the maps. It must be called with mu held.
+func (k *keyLog) write(key models.APIKey) error {
+	if err := appendEntry(&k.file, k.name, key); err != nil {
+		return err
+	}
+
+	k.put(key)
+
+	return nil
+}
+
+// put applies the latest state of a key to the maps.
+func (k *keyLog) put(key models.APIKey) {
+	if previous, ok := k.byID[key.ID]; ok {
+		delete(k.byHash, previous.Hash)
+	}
+
+	k.byID[key.ID] = key
+	if !key.IsRevoked() {
+		k.byHash[key.Hash] = key.ID
+	}
+}
+
+// close closes the API key log.
+func (k *keyLog) close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return closeEntries(&k.file)
+}
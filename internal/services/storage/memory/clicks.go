@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// clickLog holds the click events. They are appended to a log of their own next to
+// the record log, so the high volume of clicks never slows down or bloats the URL records.
+type clickLog struct {
+	// mu guards the fields below. It is independent of the storage write lock.
+	mu sync.Mutex
+
+	// name is the path of the log. The file is created on the first click.
+	name string
+
+	file   *os.File
+	writer *bufio.Writer
+
+	// byURL holds the clicks keyed by URL ID.
+	byURL map[int64][]models.Click
+}
+
+// clicksName returns the path of the click log.
+func (s *Storage) clicksName() string {
+	return s.fileName + ".clicks"
+}
+
+// loadClicks reads the click log, keeping the clicks of the loaded URLs only.
+// A click is matched on both the URL ID and the code, as in SaveClicks.
+// A record torn by a crash at the end of the log is cut off.
+func (s *Storage) loadClicks() error {
+	s.clicks = &clickLog{name: s.clicksName(), byURL: make(map[int64][]models.Click)}
+
+	codes := make(map[int64]string)
+	for i := range s.shards {
+		for code, url := range s.shards[i].urls {
+			codes[url.ID] = code
+		}
+	}
+
+	err := replayEntries(s.clicks.name, func(click models.Click) {
+		if code, ok := codes[click.URLID]; ok && code == click.Code {
+			s.clicks.byURL[click.URLID] = append(s.clicks.byURL[click.URLID], click)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("storage.memory.loadClicks: %w", err)
+	}
+
+	return nil
+}
+
+// SaveClicks appends the clicks to the click log. Clicks of URLs that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	const op = "storage.memory.SaveClicks"
+
+	valid := make([]models.Click, 0, len(clicks))
+	for _, click := range clicks {
+		if url, ok := s.get(click.Code); ok && url.ID == click.URLID {
+			valid = append(valid, click)
+		}
+	}
+
+	c := s.clicks
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		file, err := os.OpenFile(c.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permission)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		c.file = file
+		c.writer = bufio.NewWriter(file)
+	}
+
+	encoder := json.NewEncoder(c.writer)
+	for _, click := range valid {
+		if err := encoder.Encode(click); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		c.byURL[click.URLID] = append(c.byURL[click.URLID], click)
+	}
+
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetClickStats aggregates the clicks of the URL with the given ID by interval.
+func (s *Storage) GetClickStats(
+	ctx context.Context,
+	urlID int64,
+	interval models.StatsInterval,
+) (models.ClickStats, error) {
+	c := s.clicks
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stats models.ClickStats
+
+	visitors := make(map[string]struct{})
+
+	for _, click := range c.byURL[urlID] {
+		stats.Total++
+
+		if click.IPHash != "" {
+			visitors[click.IPHash] = struct{}{}
+		}
+
+		start := interval.Truncate(click.Time)
+
+		// Clicks are appended in the order they are flushed, which may be slightly out of
+		// time order across batches, so the bucket is looked up from the end.
+		i := len(stats.Buckets) - 1
+		for i >= 0 && stats.Buckets[i].Start.After(start) {
+			i--
+		}
+
+		if i >= 0 && stats.Buckets[i].Start.Equal(start) {
+			stats.Buckets[i].Clicks++
+			continue
+		}
+
+		stats.Buckets = append(stats.Buckets, models.ClickBucket{})
+		copy(stats.Buckets[i+2:], stats.Buckets[i+1:])
+		stats.Buckets[i+1] = models.ClickBucket{Start: start, Clicks: 1}
+	}
+
+	stats.UniqueVisitors = int64(len(visitors))
+
+	return stats, nil
+}
+
+// close flushes and closes the click log.
+func (c *clickLog) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+
+	err := c.file.Close()
+	c.file = nil
+
+	return err
+}
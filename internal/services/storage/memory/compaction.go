@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// logWriter is the append-only record log. It keeps track of the log size
+// so the compaction threshold can be checked without stat calls.
+type logWriter struct {
+	file *os.File
+	size int64
+}
+
+// Write appends p to the log file.
+func (w *logWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// snapshotName returns the path of the snapshot file.
+func (s *Storage) snapshotName() string {
+	return s.fileName + ".snapshot"
+}
+
+// snapshotTempName returns the path the snapshot is written to before it is swapped in.
+func (s *Storage) snapshotTempName() string {
+	return s.snapshotName() + ".tmp"
+}
+
+// needsCompaction reports whether the log has reached one of the compaction thresholds.
+// The caller must hold s.mu.
+func (s *Storage) needsCompaction() bool {
+	return (s.compactRecords > 0 && s.logRecords >= s.compactRecords) ||
+		(s.compactBytes > 0 && s.log.size >= s.compactBytes)
+}
+
+// Compact writes a snapshot of the current state and truncates the record log.
+// It can be called at any time, for example from an admin trigger.
+func (s *Storage) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compact()
+}
+
+// compact writes a snapshot of the current state and truncates the record log.
+//
+// The steps are ordered so that a crash at any point loses nothing:
+//   - the snapshot is written to a temporary file and fsynced; a crash here leaves
+//     a temporary file that is removed on the next start;
+//   - the temporary file is atomically renamed over the previous snapshot; a crash
+//     after the rename leaves the new snapshot together with the full log, and
+//     since every record carries the complete URL state, replaying the log over the
+//     snapshot yields the same state;
+//   - the log is truncated and fsynced.
+//
+// The caller must hold s.mu.
+func (s *Storage) compact() error {
+	if err := s.writeSnapshot(); err != nil {
+		return err
+	}
+
+	return s.truncateLog()
+}
+
+// writeSnapshot atomically replaces the snapshot with the current state.
+// The caller must hold s.mu.
+func (s *Storage) writeSnapshot() error {
+	const op = "storage.memory.writeSnapshot"
+
+	file, err := os.OpenFile(s.snapshotTempName(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, permission)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.encodeSnapshot(file); err != nil {
+		if errClose := file.Close(); errClose != nil {
+			slog.Error("failed to close snapshot", sl.Err(errClose))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = os.Rename(s.snapshotTempName(), s.snapshotName()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = syncDir(filepath.Dir(s.snapshotName())); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// encodeSnapshot writes a create record for every stored URL, ordered by ID, and fsyncs the file.
+// If the URL holding the last allocated ID has been purged, a sequence record keeps that ID.
+func (s *Storage) encodeSnapshot(file *os.File) error {
+	urls := make([]models.URL, 0, s.lastID)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, url := range sh.urls {
+			urls = append(urls, url)
+		}
+		sh.mu.RUnlock()
+	}
+
+	slices.SortFunc(urls, func(a, b models.URL) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	writer := bufio.NewWriter(file)
+
+	producer, err := NewProducer(writer)
+	if err != nil {
+		return err
+	}
+
+	for i := range urls {
+		if err = producer.WriteURL(&urls[i]); err != nil {
+			return err
+		}
+	}
+
+	if (len(urls) == 0 && s.lastID > 0) || (len(urls) > 0 && urls[len(urls)-1].ID < s.lastID) {
+		if err = producer.WriteRecord(&Record{Type: RecordSequence, URL: models.URL{ID: s.lastID}}); err != nil {
+			return err
+		}
+	}
+
+	if err = writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// truncateLog empties the record log once its contents are covered by the snapshot.
+// The caller must hold s.mu.
+func (s *Storage) truncateLog() error {
+	const op = "storage.memory.truncateLog"
+
+	if err := s.log.file.Truncate(0); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.log.file.Sync(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.log.size = 0
+	s.logRecords = 0
+	s.dirty = false
+
+	return nil
+}
+
+// syncDir fsyncs a directory so that a rename inside it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err = d.Sync(); err != nil {
+		if errClose := d.Close(); errClose != nil {
+			slog.Error("failed to close directory", sl.Err(errClose))
+		}
+		return err
+	}
+
+	return d.Close()
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+)
+
+// fillStorage saves count URLs for user1 and deletes every third one.
+func fillStorage(t *testing.T, s *Storage, count int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	for i := range count {
+		_, err := s.SaveURL(ctx, fmt.Sprintf("code%d", i), fmt.Sprintf("http://example.com/%d", i), "user1", repository.URLSettings{})
+		require.NoError(t, err)
+
+		if i%3 == 0 {
+			require.NoError(t, s.DeleteShortURLs(ctx, []string{fmt.Sprintf("code%d", i)}, "user1"))
+		}
+	}
+}
+
+// snapshotState returns all stored URLs keyed by code.
+func snapshotState(t *testing.T, s *Storage) map[string]models.URL {
+	t.Helper()
+
+	urls, err := s.GetUserURLs(context.Background(), "user1")
+	require.NoError(t, err)
+
+	state := make(map[string]models.URL, len(urls))
+	for _, url := range urls {
+		state[url.Code] = url
+	}
+
+	return state
+}
+
+// countLines returns the number of lines in the named file.
+func countLines(t *testing.T, name string) int {
+	t.Helper()
+
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+
+	return lines
+}
+
+func TestStorage_Compact(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName)
+	require.NoError(t, err)
+
+	fillStorage(t, s, 10)
+	before := snapshotState(t, s)
+
+	require.NoError(t, s.Compact(context.Background()))
+
+	assert.Equal(t, 0, countLines(t, fileName))
+	assert.Equal(t, 10, countLines(t, s.snapshotName()))
+
+	// Records written after the compaction go to the tail of the log.
+	_, err = s.SaveURL(context.Background(), "tail", "http://example.com/tail", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, countLines(t, fileName))
+
+	reloaded, err := New(fileName)
+	require.NoError(t, err)
+
+	after := snapshotState(t, reloaded)
+	assert.Len(t, after, len(before)+1)
+	for code, url := range before {
+		assert.Equal(t, url, after[code])
+	}
+
+	id, err := reloaded.SaveURL(context.Background(), "next", "http://example.com/next", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+}
+
+func TestStorage_CompactThreshold(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName, WithCompaction(5, 0))
+	require.NoError(t, err)
+
+	fillStorage(t, s, 4) // 4 creates and 2 deletes
+
+	assert.Equal(t, 1, countLines(t, fileName))
+	assert.Equal(t, 4, countLines(t, s.snapshotName()))
+
+	reloaded, err := New(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, snapshotState(t, s), snapshotState(t, reloaded))
+}
+
+func TestStorage_CompactSizeThreshold(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName, WithCompaction(0, 1))
+	require.NoError(t, err)
+
+	fillStorage(t, s, 3)
+
+	assert.Equal(t, 0, countLines(t, fileName))
+	assert.Equal(t, 3, countLines(t, s.snapshotName()))
+}
+
+// TestStorage_CompactCrashBeforeRename simulates a crash while the snapshot
+// was being written: a torn temporary snapshot is left behind.
+func TestStorage_CompactCrashBeforeRename(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName)
+	require.NoError(t, err)
+
+	fillStorage(t, s, 10)
+	require.NoError(t, s.Compact(context.Background()))
+
+	_, err = s.SaveURL(context.Background(), "tail", "http://example.com/tail", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	before := snapshotState(t, s)
+
+	err = os.WriteFile(s.snapshotTempName(), []byte(`{"type":"create","id":1,"code":"co`), permission)
+	require.NoError(t, err)
+
+	reloaded, err := New(fileName)
+	require.NoError(t, err)
+
+	assert.Equal(t, before, snapshotState(t, reloaded))
+	assert.NoFileExists(t, s.snapshotTempName())
+}
+
+// TestStorage_CompactCrashBeforeTruncate simulates a crash after the new snapshot
+// was swapped in but before the log was truncated: both hold the same records.
+func TestStorage_CompactCrashBeforeTruncate(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName)
+	require.NoError(t, err)
+
+	fillStorage(t, s, 10)
+	before := snapshotState(t, s)
+
+	s.mu.Lock()
+	require.NoError(t, s.writeSnapshot())
+	s.mu.Unlock()
+
+	assert.Equal(t, 14, countLines(t, fileName))
+	assert.Equal(t, 10, countLines(t, s.snapshotName()))
+
+	reloaded, err := New(fileName)
+	require.NoError(t, err)
+
+	assert.Equal(t, before, snapshotState(t, reloaded))
+
+	id, err := reloaded.SaveURL(context.Background(), "next", "http://example.com/next", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), id)
+}
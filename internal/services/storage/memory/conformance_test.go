@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/services/storage/storagetest"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+)
+
+func TestStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) urlservice.URLStorage {
+		s, err := New(filepath.Join(t.TempDir(), "storage.txt"), WithSync(SyncAlways, 0))
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			require.NoError(t, s.Close())
+		})
+
+		return s
+	})
+}
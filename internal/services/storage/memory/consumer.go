@@ -3,7 +3,10 @@
 package memory
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/vadicheck/shorturl/internal/models"
@@ -18,6 +21,36 @@ type Consumer struct {
 
 	// decoder is the JSON decoder used to decode the input data.
 	decoder *json.Decoder
+
+	// offset is the input offset right after the last successfully decoded record.
+	offset int64
+
+	// lastID is the highest URL ID carried by the replayed records, purged URLs included.
+	lastID int64
+}
+
+// TornRecordError is returned by Replay when the input ends with an incomplete record,
+// which is what a crash in the middle of an append leaves behind.
+type TornRecordError struct {
+	// Offset is the length of the valid input, including the line break
+	// that terminates the last complete record.
+	Offset int64
+
+	// Discarded holds the bytes of the incomplete record.
+	Discarded []byte
+
+	// Err is the decoding error.
+	Err error
+}
+
+// Error returns a string representation of the error.
+func (e *TornRecordError) Error() string {
+	return fmt.Sprintf("torn record at offset %d (%d bytes): %v", e.Offset, len(e.Discarded), e.Err)
+}
+
+// Unwrap returns the underlying decoding error.
+func (e *TornRecordError) Unwrap() error {
+	return e.Err
 }
 
 // NewConsumer creates a new Consumer instance using the provided input reader.
@@ -32,30 +65,102 @@ func NewConsumer(reader io.Reader) (*Consumer, error) {
 // ReadURL reads a single URL entry from the input data and decodes it into a models.URL object.
 // It returns a pointer to the URL object and any error encountered during decoding.
 func (c *Consumer) ReadURL() (*models.URL, error) {
-	url := &models.URL{}
-	if err := c.decoder.Decode(&url); err != nil {
+	record, err := c.ReadRecord()
+	if err != nil {
 		return nil, err
 	}
 
-	return url, nil
+	return &record.URL, nil
 }
 
-// Load reads and decodes all URL entries from the input data into a map of URLs.
-// The map uses the URL code as the key and the decoded URL as the value.
+// ReadRecord reads a single record from the input data.
+// It returns a pointer to the record and any error encountered during decoding.
+func (c *Consumer) ReadRecord() (*Record, error) {
+	record := &Record{}
+	if err := c.decoder.Decode(record); err != nil {
+		return nil, err
+	}
+
+	c.offset = c.decoder.InputOffset()
+
+	return record, nil
+}
+
+// Load replays all records from the input data in order into a map of URLs.
+// The map uses the URL code as the key and the latest state of the URL as the value.
 // It returns the map of URLs and any error encountered during decoding.
 func (c *Consumer) Load() (map[string]models.URL, error) {
 	urlMap := make(map[string]models.URL)
 
+	if _, err := c.Replay(urlMap); err != nil {
+		return nil, err
+	}
+
+	return urlMap, nil
+}
+
+// Replay reads all remaining records from the input data and applies them in order onto urls.
+// It returns the number of records replayed and any error encountered during decoding.
+// If the input ends with an incomplete record, the records before it are applied
+// and a *TornRecordError is returned.
+func (c *Consumer) Replay(urls map[string]models.URL) (int, error) {
+	records := 0
+
 	for {
-		url := &models.URL{}
-		if err := c.decoder.Decode(&url); err != nil {
+		record, err := c.ReadRecord()
+		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			return records, c.tornRecord(err)
+		}
+
+		if err = record.apply(urls); err != nil {
+			return records, fmt.Errorf("failed to replay record: %w", err)
 		}
-		urlMap[url.Code] = *url
+
+		c.lastID = max(c.lastID, record.ID)
+		records++
 	}
 
-	return urlMap, nil
+	return records, nil
+}
+
+// LastID returns the highest URL ID carried by the records replayed so far,
+// including the IDs of purged URLs and of sequence records.
+func (c *Consumer) LastID() int64 {
+	return c.lastID
+}
+
+// tornRecord checks whether the decoding error was caused by an incomplete last record,
+// that is, whether nothing but a single unterminated line follows the last decoded record.
+// If so, it wraps err into a *TornRecordError, otherwise it returns err unchanged.
+func (c *Consumer) tornRecord(err error) error {
+	return tornTail(err, c.decoder, *c.reader, c.offset)
+}
+
+// tornTail implements tornRecord for any decoder reading from reader, where offset is
+// the input offset right after the last value it decoded.
+func tornTail(err error, decoder *json.Decoder, reader io.Reader, offset int64) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	rest, errRead := io.ReadAll(io.MultiReader(decoder.Buffered(), reader))
+	if errRead != nil {
+		return err
+	}
+
+	// Keep the whitespace, including the line break, that terminates the last complete record.
+	tail := bytes.TrimLeft(rest, " \t\r\n")
+	if len(tail) == 0 || bytes.IndexByte(bytes.TrimRight(tail, " \t\r\n"), '\n') >= 0 {
+		return err
+	}
+
+	return &TornRecordError{
+		Offset:    offset + int64(len(rest)-len(tail)),
+		Discarded: tail,
+		Err:       err,
+	}
 }
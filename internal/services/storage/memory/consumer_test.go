@@ -2,10 +2,13 @@ package memory
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
 )
 
 func TestNewConsumer(t *testing.T) {
@@ -89,3 +92,85 @@ func TestLoad_EOF(t *testing.T) {
 	assert.Equal(t, "abcd1234", urls["abcd1234"].Code)
 	assert.Equal(t, "https://example.com", urls["abcd1234"].URL)
 }
+
+func TestLoad_ReplaysRecords(t *testing.T) {
+	data := `{"code":"abcd1234","url":"https://example.com","user_id":"user1"}
+{"type":"create","code":"efgh5678","url":"https://example.org","user_id":"user1"}
+{"type":"delete","code":"abcd1234","url":"https://example.com","user_id":"user1","is_deleted":true}
+{"type":"update","code":"efgh5678","url":"https://example.net","user_id":"user1"}`
+	reader := bytes.NewReader([]byte(data))
+	consumer, err := NewConsumer(reader)
+
+	require.NoError(t, err)
+
+	urls, err := consumer.Load()
+	require.NoError(t, err)
+
+	assert.Len(t, urls, 2)
+	assert.True(t, urls["abcd1234"].IsDeleted)
+	assert.False(t, urls["efgh5678"].IsDeleted)
+	assert.Equal(t, "https://example.net", urls["efgh5678"].URL)
+}
+
+func TestLoad_ReplaysPurge(t *testing.T) {
+	data := `{"type":"create","code":"abcd1234","url":"https://example.com","user_id":"user1","expires_at":"2025-01-01T00:00:00Z"}
+{"type":"create","code":"efgh5678","url":"https://example.org","user_id":"user1"}
+{"type":"purge","code":"abcd1234","url":"https://example.com","user_id":"user1","expires_at":"2025-01-01T00:00:00Z"}`
+	reader := bytes.NewReader([]byte(data))
+	consumer, err := NewConsumer(reader)
+
+	require.NoError(t, err)
+
+	urls, err := consumer.Load()
+	require.NoError(t, err)
+
+	assert.Len(t, urls, 1)
+	assert.Contains(t, urls, "efgh5678")
+	assert.True(t, urls["efgh5678"].ExpiresAt.IsZero())
+}
+
+func TestLoad_UnknownRecordType(t *testing.T) {
+	data := `{"type":"unknown","code":"abcd1234","url":"https://example.com"}`
+	reader := bytes.NewReader([]byte(data))
+	consumer, err := NewConsumer(reader)
+
+	require.NoError(t, err)
+
+	urls, err := consumer.Load()
+	assert.Error(t, err)
+	assert.Nil(t, urls)
+}
+
+func TestReplay_TornRecord(t *testing.T) {
+	first := `{"code":"abcd1234","url":"https://example.com"}` + "\n"
+	data := first + `{"code":"efgh5678","url":"https://exa`
+	consumer, err := NewConsumer(bytes.NewReader([]byte(data)))
+
+	require.NoError(t, err)
+
+	urls := make(map[string]models.URL)
+	records, err := consumer.Replay(urls)
+
+	var torn *TornRecordError
+	require.ErrorAs(t, err, &torn)
+	assert.Equal(t, int64(len(first)), torn.Offset)
+	assert.Equal(t, `{"code":"efgh5678","url":"https://exa`, string(torn.Discarded))
+	assert.Equal(t, 1, records)
+	assert.Contains(t, urls, "abcd1234")
+}
+
+func TestReplay_CorruptedMiddle(t *testing.T) {
+	data := `{"code":"abcd1234","url":"https://example.com"}
+{"code":"efgh5678","url":"https://exa
+{"code":"ijkl9012","url":"https://example.net"}
+`
+	consumer, err := NewConsumer(bytes.NewReader([]byte(data)))
+
+	require.NoError(t, err)
+
+	_, err = consumer.Replay(make(map[string]models.URL))
+
+	var torn *TornRecordError
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &torn))
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// replayEntries decodes the JSON entries of the named log and passes them to apply in order.
+// A missing log is treated as empty. An entry torn by a crash at the end of the log is cut off;
+// any other entry that fails to decode is an error, as the entries after it cannot be skipped.
+func replayEntries[T any](name string, apply func(T)) error {
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			slog.Error("failed to close log", slog.String("file", name), sl.Err(errClose))
+		}
+	}()
+
+	decoder := json.NewDecoder(file)
+	for {
+		offset := decoder.InputOffset()
+
+		var entry T
+		if err = decoder.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			var torn *TornRecordError
+			if err = tornTail(err, decoder, file, offset); !errors.As(err, &torn) {
+				return fmt.Errorf("corrupt entry after offset %d of %s: %w", offset, name, err)
+			}
+
+			return repairLog(name, torn)
+		}
+
+		apply(entry)
+	}
+}
+
+// appendEntry appends entry as a JSON line to the log in *file and fsyncs it.
+// The log is opened, and created if needed, on the first entry.
+func appendEntry(file **os.File, name string, entry any) error {
+	if *file == nil {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permission)
+		if err != nil {
+			return err
+		}
+		*file = f
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = (*file).Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return (*file).Sync()
+}
+
+// closeEntries closes the log in *file if it was opened.
+func closeEntries(file **os.File) error {
+	if *file == nil {
+		return nil
+	}
+
+	err := (*file).Close()
+	*file = nil
+
+	return err
+}
@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// index maintains secondary lookups over the stored URLs so that searches by
+// original URL and by user do not have to scan every shard.
+//
+// Soft-deleted URLs stay indexed: they still own their original URL, exactly as
+// a row with is_deleted = true still holds the unique url in PostgreSQL.
+type index struct {
+	mu sync.RWMutex
+
+	// byURL maps an original URL to its short code.
+	byURL map[string]string
+
+	// byUser maps a user ID to the set of short codes owned by the user.
+	byUser map[string]map[string]struct{}
+}
+
+// newIndex creates an empty index.
+func newIndex() *index {
+	return &index{
+		byURL:  make(map[string]string),
+		byUser: make(map[string]map[string]struct{}),
+	}
+}
+
+// add registers the URL in the index.
+func (i *index) add(url models.URL) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.byURL[url.URL] = url.Code
+
+	codes, ok := i.byUser[url.UserID]
+	if !ok {
+		codes = make(map[string]struct{})
+		i.byUser[url.UserID] = codes
+	}
+	codes[url.Code] = struct{}{}
+}
+
+// remove unregisters the URL from the index.
+func (i *index) remove(url models.URL) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.byURL[url.URL] == url.Code {
+		delete(i.byURL, url.URL)
+	}
+
+	codes := i.byUser[url.UserID]
+	delete(codes, url.Code)
+	if len(codes) == 0 {
+		delete(i.byUser, url.UserID)
+	}
+}
+
+// codeByURL returns the short code registered for the original URL.
+func (i *index) codeByURL(url string) (string, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	code, ok := i.byURL[url]
+
+	return code, ok
+}
+
+// codesByUser returns a copy of the short codes owned by the user.
+func (i *index) codesByUser(userID string) []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	codes := make([]string, 0, len(i.byUser[userID]))
+	for code := range i.byUser[userID] {
+		codes = append(codes, code)
+	}
+
+	return codes
+}
+
+// counts returns the number of indexed URLs and users.
+func (i *index) counts() (urls, users int) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.byURL), len(i.byUser)
+}
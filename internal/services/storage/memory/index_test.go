@@ -0,0 +1,28 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+func TestIndex(t *testing.T) {
+	i := newIndex()
+
+	i.add(models.URL{Code: "abc123", URL: "http://example1.com", UserID: "user1"})
+	i.add(models.URL{Code: "abc124", URL: "http://example2.com", UserID: "user1"})
+	i.add(models.URL{Code: "abc125", URL: "http://example3.com", UserID: "user2"})
+
+	code, ok := i.codeByURL("http://example2.com")
+	assert.True(t, ok)
+	assert.Equal(t, "abc124", code)
+
+	_, ok = i.codeByURL("http://nonexistent.com")
+	assert.False(t, ok)
+
+	assert.ElementsMatch(t, []string{"abc123", "abc124"}, i.codesByUser("user1"))
+	assert.ElementsMatch(t, []string{"abc125"}, i.codesByUser("user2"))
+	assert.Empty(t, i.codesByUser("nonexistentuser"))
+}
@@ -5,62 +5,263 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
 	"os"
-	"slices"
+	"sync"
+	"time"
 
 	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
 )
 
+// shardCount is the number of shards the URL map is split into.
+const shardCount = 32
+
+// shard is a part of the URL map guarded by its own lock.
+type shard struct {
+	mu sync.RWMutex
+
+	// urls is a map of stored URLs, keyed by their unique code.
+	urls map[string]models.URL
+}
+
 // Storage is an in-memory implementation of a URL storage system.
 // It supports saving, retrieving, and deleting individual and batch URLs.
 // The storage is backed by file-based producers and consumers.
+//
+// Storage is safe for concurrent use. URLs are spread over shards keyed by code,
+// each guarded by a RW lock, so lookups by code only contend within one shard.
+// All writes are additionally serialized by a single mutex, which keeps the
+// uniqueness checks, ID allocation and producer appends consistent.
 type Storage struct {
+	// fileName is the path of the record log. The snapshot is kept next to it.
+	fileName string
+
+	// log is the file the producer appends records to.
+	log *logWriter
+
 	// producer is responsible for writing URL data to the storage.
 	producer *Producer
 
-	// consumer is responsible for reading and loading URL data from storage.
-	consumer *Consumer
+	// mu serializes all writes to the shards and to the producer.
+	mu sync.Mutex
 
-	// urls is a map of stored URLs, keyed by their unique code.
-	urls map[string]models.URL
+	// shards holds the stored URLs split by code.
+	shards [shardCount]*shard
+
+	// index holds the lookups by original URL and by user.
+	index *index
+
+	// lastID is the last allocated URL ID. It is guarded by mu.
+	lastID int64
+
+	// logRecords is the number of records in the log since the last compaction. It is guarded by mu.
+	logRecords int
+
+	// compactRecords and compactBytes are the log thresholds that trigger a compaction.
+	// A zero value disables the corresponding threshold.
+	compactRecords int
+	compactBytes   int64
+
+	// syncMode and syncInterval define when the log is fsynced. The zero mode never syncs.
+	syncMode     SyncMode
+	syncInterval time.Duration
+
+	// dirty reports whether records were appended since the last fsync. It is guarded by mu.
+	dirty bool
+
+	// stopSync and syncDone stop and await the background group commit.
+	stopSync chan struct{}
+	syncDone chan struct{}
+
+	// clicks holds the click events, which are logged to a separate file.
+	clicks *clickLog
+
+	// keys holds the API keys, which are logged to a separate file.
+	keys *keyLog
+
+	// users holds the accounts, which are logged to a separate file.
+	users *userLog
+
+	// audit holds the audit trail of the admin actions, which is logged to a separate file.
+	audit *auditLog
+
+	// webhooks holds the webhooks and their dead letters, which are logged to separate files.
+	webhooks *webhookLog
+}
+
+// Option configures optional behaviour of a Storage.
+type Option func(*Storage)
+
+// WithCompaction makes the storage compact its log once it holds at least
+// maxRecords records or maxBytes bytes. A zero value disables the corresponding threshold.
+func WithCompaction(maxRecords int, maxBytes int64) Option {
+	return func(s *Storage) {
+		s.compactRecords = maxRecords
+		s.compactBytes = maxBytes
+	}
 }
 
 // New creates and initializes a new in-memory URL storage instance.
-// It opens files for both reading and writing URL data and creates the producer and consumer.
+// It loads the snapshot and then replays the record log on top of it,
+// and opens the log for appending new records. The click events are loaded from a
+// log of their own, named after the record log with a ".clicks" suffix, the API keys
+// from one with a ".keys" suffix, the accounts from one with a ".users" suffix, the
+// audit trail from one with an ".audit" suffix, the webhooks from one with a ".webhooks"
+// suffix and their dead letters from one with a ".deadletters" suffix.
+// A record torn by a crash at the end of the log is cut off and logged.
+// The storage must be closed with Close to flush and release the log.
 // It returns a pointer to the Storage instance and any error encountered during initialization.
-func New(fileName string) (*Storage, error) {
+func New(fileName string, opts ...Option) (*Storage, error) {
+	s := &Storage{fileName: fileName}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.syncMode == SyncInterval && s.syncInterval <= 0 {
+		return nil, errors.New("storage.memory.New: sync interval must be positive")
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadClicks(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadKeys(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadUsers(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadAudit(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadWebhooks(); err != nil {
+		return nil, err
+	}
+
 	pFile, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, permission)
 	if err != nil {
 		return nil, err
 	}
-	producer, err := NewProducer(pFile)
+
+	info, err := pFile.Stat()
 	if err != nil {
 		return nil, err
 	}
 
-	cFile, err := os.OpenFile(fileName, os.O_RDONLY|os.O_CREATE, permission)
+	s.log = &logWriter{file: pFile, size: info.Size()}
+
+	producer, err := NewProducer(s.log)
 	if err != nil {
 		return nil, err
 	}
 
-	consumer, err := NewConsumer(cFile)
+	s.producer = producer
+	s.startSyncer()
+
+	return s, nil
+}
+
+// load reads the snapshot and the record log and rebuilds the shards and the index from them.
+func (s *Storage) load() error {
+	// A leftover temporary snapshot means a compaction died before swapping it in.
+	// The previous snapshot and the log are still complete, so it is simply dropped.
+	if err := os.Remove(s.snapshotTempName()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	urls := make(map[string]models.URL)
+
+	_, snapshotLastID, err := replayFile(s.snapshotName(), urls)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to load snapshot: %w", err)
 	}
 
-	urls, err := consumer.Load()
+	records, logLastID, err := replayFile(s.fileName, urls)
 	if err != nil {
-		return nil, err
+		var torn *TornRecordError
+		if !errors.As(err, &torn) {
+			return err
+		}
+
+		if err = repairLog(s.fileName, torn); err != nil {
+			return err
+		}
+	}
+
+	for i := range s.shards {
+		s.shards[i] = &shard{urls: make(map[string]models.URL)}
 	}
+	s.index = newIndex()
+	// The IDs of purged URLs are counted too, so they are never handed out again.
+	s.lastID = max(snapshotLastID, logLastID)
+	s.logRecords = records
 
-	return &Storage{
-		producer: producer,
-		consumer: consumer,
-		urls:     urls,
-	}, nil
+	for code, url := range urls {
+		s.shard(code).urls[code] = url
+		s.index.add(url)
+		s.lastID = max(s.lastID, url.ID)
+	}
+
+	return nil
+}
+
+// replayFile applies all records of the named file onto urls.
+// A missing file is treated as empty. It returns the number of records replayed
+// and the highest URL ID they carry.
+func replayFile(name string, urls map[string]models.URL) (int, int64, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			slog.Error("failed to close storage file", sl.Err(errClose))
+		}
+	}()
+
+	consumer, err := NewConsumer(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	records, err := consumer.Replay(urls)
+
+	return records, consumer.LastID(), err
+}
+
+// repairLog cuts the torn record off the end of the log, so new records
+// are appended right after the last complete one.
+func repairLog(name string, torn *TornRecordError) error {
+	slog.Warn("discarding torn record at the end of file storage",
+		slog.String("file", name),
+		slog.Int64("offset", torn.Offset),
+		slog.String("discarded", string(torn.Discarded)),
+		sl.Err(torn.Err),
+	)
+
+	if err := os.Truncate(name, torn.Offset); err != nil {
+		return fmt.Errorf("failed to repair file storage: %w", err)
+	}
+
+	return nil
 }
 
 // PingContext is a no-op method for compatibility with the storage interface.
@@ -72,69 +273,121 @@ func (s *Storage) PingContext(ctx context.Context) error {
 // SaveURL saves a new URL to the storage system.
 // It checks for duplicates and returns an error if the URL already exists.
 // It returns the ID of the newly saved URL and any error encountered during the process.
-func (s *Storage) SaveURL(ctx context.Context, code, url, userID string) (int64, error) {
-	id := int64(len(s.urls) + 1)
-
-	// Check if the URL already exists
-	for _, u := range s.urls {
-		if u.URL == url {
-			return 0, &storage.ExistsURLError{
-				OriginalURL: url,
-				ShortCode:   code,
-				Err:         nil,
-			}
+func (s *Storage) SaveURL(
+	ctx context.Context,
+	code, url, userID string,
+	settings repository.URLSettings,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check if the URL already exists. It is checked before the code, as in the
+	// PostgreSQL storage, so an existing URL is reported even if the code is taken too.
+	if existing, ok := s.findByURL(url); ok {
+		return 0, &storage.ExistsURLError{
+			OriginalURL: url,
+			ShortCode:   existing.Code,
+			Err:         nil,
 		}
 	}
 
-	mURL := models.URL{
-		ID:     id,
-		Code:   code,
-		URL:    url,
-		UserID: userID,
+	// Check if the code is already taken
+	if _, ok := s.get(code); ok {
+		return 0, fmt.Errorf("storage.memory.SaveURL: %w", storage.ErrURLOrCodeExists)
 	}
 
-	// Add the new URL to the map
-	s.urls[code] = mURL
+	mURL := models.URL{
+		ID:           s.lastID + 1,
+		Code:         code,
+		URL:          url,
+		UserID:       userID,
+		ExpiresAt:    settings.ExpiresAt,
+		MaxClicks:    settings.MaxClicks,
+		PasswordHash: settings.PasswordHash,
+	}
 
-	// Write the URL data using the producer
-	err := s.producer.WriteURL(&mURL)
-	if err != nil {
+	// Write the URL data using the producer and add it to the map
+	if err := s.write(RecordCreate, mURL); err != nil {
 		return 0, err
 	}
 
-	return id, nil
+	s.index.add(mURL)
+	s.lastID = mURL.ID
+
+	return mURL.ID, nil
 }
 
 // SaveBatchURL saves a batch of URLs to the storage system.
-// It accepts a slice of BatchURLDto objects and saves each URL individually.
-// It returns a slice of BatchURL objects containing the correlation ID and short code of each URL.
+// It follows the PostgreSQL storage: URLs that already exist are resolved to their
+// existing short codes, while a short code that is already taken fails the whole
+// batch before anything is written.
+// It returns a slice of BatchURL objects containing the correlation ID and short code
+// of each URL, in the order of the input.
 func (s *Storage) SaveBatchURL(
 	ctx context.Context,
 	dto *[]repository.BatchURLDto,
 	userID string,
 ) (*[]repository.BatchURL, error) {
-	entities := make([]repository.BatchURL, 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities := make([]repository.BatchURL, 0, len(*dto))
+	created := make([]models.URL, 0, len(*dto))
+
+	// Codes and URLs created earlier in the same batch.
+	batchCodes := make(map[string]struct{}, len(*dto))
+	batchURLs := make(map[string]string, len(*dto))
 
-	// Save each URL in the batch
 	for _, urlDTO := range *dto {
-		_, err := s.SaveURL(ctx, urlDTO.ShortCode, urlDTO.OriginalURL, userID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to save URL: %w", err)
+		code, ok := batchURLs[urlDTO.OriginalURL]
+		if !ok {
+			if existing, found := s.findByURL(urlDTO.OriginalURL); found {
+				code, ok = existing.Code, true
+			}
+		}
+
+		if !ok {
+			_, taken := batchCodes[urlDTO.ShortCode]
+			if _, exists := s.get(urlDTO.ShortCode); exists || taken {
+				return nil, fmt.Errorf("storage.memory.SaveBatchURL: %w", storage.ErrURLOrCodeExists)
+			}
+
+			code = urlDTO.ShortCode
+			batchCodes[code] = struct{}{}
+			batchURLs[urlDTO.OriginalURL] = code
+			created = append(created, models.URL{
+				ID:           s.lastID + int64(len(created)) + 1,
+				Code:         code,
+				URL:          urlDTO.OriginalURL,
+				UserID:       userID,
+				ExpiresAt:    urlDTO.ExpiresAt,
+				MaxClicks:    urlDTO.MaxClicks,
+				PasswordHash: urlDTO.PasswordHash,
+			})
 		}
 
 		entities = append(entities, repository.BatchURL{
 			CorrelationID: urlDTO.CorrelationID,
-			ShortCode:     urlDTO.ShortCode,
+			ShortCode:     code,
 		})
 	}
 
+	for _, mURL := range created {
+		if err := s.write(RecordCreate, mURL); err != nil {
+			return nil, fmt.Errorf("failed to save URL: %w", err)
+		}
+
+		s.index.add(mURL)
+		s.lastID = mURL.ID
+	}
+
 	return &entities, nil
 }
 
 // GetURLByID retrieves a URL from the storage by its short code.
 // It returns the URL if found, or an empty URL struct if not.
 func (s *Storage) GetURLByID(ctx context.Context, code string) (models.URL, error) {
-	url, ok := s.urls[code]
+	url, ok := s.get(code)
 	if !ok {
 		return models.URL{}, nil
 	}
@@ -145,13 +398,9 @@ func (s *Storage) GetURLByID(ctx context.Context, code string) (models.URL, erro
 // GetURLByURL retrieves a URL from the storage by its original URL.
 // It returns the URL if found, or an empty URL struct if not.
 func (s *Storage) GetURLByURL(ctx context.Context, url string) (models.URL, error) {
-	for _, u := range s.urls {
-		if u.URL == url {
-			return u, nil
-		}
-	}
+	u, _ := s.findByURL(url)
 
-	return models.URL{}, nil
+	return u, nil
 }
 
 // GetUserURLs retrieves all URLs associated with a specific user by their userID.
@@ -160,8 +409,8 @@ func (s *Storage) GetUserURLs(ctx context.Context, userID string) ([]models.URL,
 	var urls []models.URL
 
 	// Collect all URLs associated with the user
-	for _, u := range s.urls {
-		if u.UserID == userID {
+	for _, code := range s.index.codesByUser(userID) {
+		if u, ok := s.get(code); ok {
 			urls = append(urls, u)
 		}
 	}
@@ -169,15 +418,176 @@ func (s *Storage) GetUserURLs(ctx context.Context, userID string) ([]models.URL,
 	return urls, nil
 }
 
+// GetServiceStats counts the stored URLs, including the soft-deleted ones, and the users that own them.
+// Original URLs are unique, so the URLs are counted from the index.
+func (s *Storage) GetServiceStats(ctx context.Context) (models.ServiceStats, error) {
+	urls, users := s.index.counts()
+
+	return models.ServiceStats{URLs: int64(urls), Users: int64(users)}, nil
+}
+
 // DeleteShortURLs deletes a batch of short URLs by their short codes and the userID.
-// It marks the URLs as deleted by setting their IsDeleted flag to true.
+// It marks the URLs as deleted by setting their IsDeleted flag to true
+// and appends a delete record for each of them, so the deletion survives a restart.
 func (s *Storage) DeleteShortURLs(ctx context.Context, urls []string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Mark the specified URLs as deleted
-	for code, url := range s.urls {
-		if url.UserID == userID && slices.Contains(urls, url.Code) {
-			url.IsDeleted = true
-			s.urls[code] = url
+	for _, code := range urls {
+		url, ok := s.get(code)
+		if !ok || url.UserID != userID || url.IsDeleted {
+			continue
+		}
+
+		url.IsDeleted = true
+
+		if err := s.write(RecordDelete, url); err != nil {
+			return err
 		}
 	}
 	return nil
 }
+
+// RestoreShortURLs undoes the deletion of a batch of short URLs by their short codes and the userID.
+// It clears the IsDeleted flag of the URLs and appends a restore record for each of them, so the
+// restore survives a restart. It returns the URLs restored; the codes of URLs that are unknown,
+// owned by another user or not deleted are skipped.
+func (s *Storage) RestoreShortURLs(ctx context.Context, urls []string, userID string) ([]models.URL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var restored []models.URL
+	for _, code := range urls {
+		url, ok := s.get(code)
+		if !ok || url.UserID != userID || !url.IsDeleted {
+			continue
+		}
+
+		url.IsDeleted = false
+
+		if err := s.write(RecordRestore, url); err != nil {
+			return restored, err
+		}
+
+		restored = append(restored, url)
+	}
+
+	return restored, nil
+}
+
+// PurgeExpiredURLs removes the URLs that expired at or before now, including soft-deleted ones,
+// and appends a purge record for each of them. Their codes and original URLs become free again.
+// It returns the number of URLs removed.
+func (s *Storage) PurgeExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []models.URL
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, url := range sh.urls {
+			if url.IsExpired(now) {
+				expired = append(expired, url)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	var purged int64
+	for _, url := range expired {
+		if err := s.write(RecordPurge, url); err != nil {
+			return purged, err
+		}
+
+		s.index.remove(url)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// ConsumeClick uses up one redirect of a click-limited URL and appends an update record
+// with the new click count. It runs under the write lock, so concurrent redirects never
+// exceed the limit. It returns false if the URL is not found, is not click-limited
+// or has no redirects left.
+func (s *Storage) ConsumeClick(ctx context.Context, code string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.get(code)
+	if !ok || !url.IsClickLimited() || url.RemainingClicks() == 0 {
+		return false, nil
+	}
+
+	url.Clicks++
+
+	if err := s.write(RecordUpdate, url); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// write appends a record with the new state of the URL to the file
+// and then stores that state in its shard, or removes the URL from it for a purge record.
+// The caller must hold s.mu.
+func (s *Storage) write(recordType RecordType, url models.URL) error {
+	if err := s.producer.WriteRecord(&Record{Type: recordType, URL: url}); err != nil {
+		return err
+	}
+
+	if err := s.syncWrite(); err != nil {
+		return err
+	}
+
+	sh := s.shard(url.Code)
+
+	sh.mu.Lock()
+	if recordType == RecordPurge {
+		delete(sh.urls, url.Code)
+	} else {
+		sh.urls[url.Code] = url
+	}
+	sh.mu.Unlock()
+
+	s.logRecords++
+
+	if s.needsCompaction() {
+		if err := s.compact(); err != nil {
+			slog.Error("failed to compact file storage", sl.Err(err))
+		}
+	}
+
+	return nil
+}
+
+// shard returns the shard that holds the given code.
+func (s *Storage) shard(code string) *shard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(code))
+
+	return s.shards[h.Sum32()%shardCount]
+}
+
+// get returns the URL stored under the given code.
+func (s *Storage) get(code string) (models.URL, bool) {
+	sh := s.shard(code)
+
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	url, ok := sh.urls[code]
+
+	return url, ok
+}
+
+// findByURL looks up a URL by its original URL using the index.
+func (s *Storage) findByURL(url string) (models.URL, bool) {
+	code, ok := s.index.codeByURL(url)
+	if !ok {
+		return models.URL{}, false
+	}
+
+	return s.get(code)
+}
@@ -2,13 +2,21 @@ package memory
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/storage"
 )
 
 // TestStorage_SaveURL tests the SaveURL method of the Storage.
@@ -21,7 +29,7 @@ func TestStorage_SaveURL(t *testing.T) {
 	url := "http://example.com"
 	userID := "user1"
 
-	id, err := storage.SaveURL(ctx, code, url, userID)
+	id, err := storage.SaveURL(ctx, code, url, userID, repository.URLSettings{})
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), id)
 
@@ -30,6 +38,460 @@ func TestStorage_SaveURL(t *testing.T) {
 	assert.Equal(t, url, storedURL.URL)
 }
 
+// TestStorage_SaveURL_Exists tests that saving a known URL or code is rejected.
+func TestStorage_SaveURL_Exists(t *testing.T) {
+	s, err := getStorage(t)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = s.SaveURL(ctx, "abc123", "http://example.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, "abc124", "http://example.com", "user2", repository.URLSettings{})
+
+	var existsErr *storage.ExistsURLError
+	require.True(t, errors.As(err, &existsErr))
+	assert.Equal(t, "abc123", existsErr.ShortCode)
+	assert.Equal(t, "http://example.com", existsErr.OriginalURL)
+
+	_, err = s.SaveURL(ctx, "abc123", "http://example2.com", "user2", repository.URLSettings{})
+	assert.ErrorIs(t, err, storage.ErrURLOrCodeExists)
+}
+
+// TestStorage_Reload tests that the state, including deletions, and the indexes
+// are restored when the file is loaded again.
+func TestStorage_Reload(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "test_s*.txt")
+	require.NoError(t, err)
+	defer func() {
+		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
+			log.Printf("failed to remove file: %v", errRemove)
+		}
+	}()
+
+	ctx := context.Background()
+
+	first, err := New(tempFile.Name())
+	require.NoError(t, err)
+
+	_, err = first.SaveURL(ctx, "abc123", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = first.SaveURL(ctx, "abc124", "http://example2.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	second, err := New(tempFile.Name())
+	require.NoError(t, err)
+
+	storedURL, err := second.GetURLByURL(ctx, "http://example2.com")
+	require.NoError(t, err)
+	assert.Equal(t, "abc124", storedURL.Code)
+
+	userURLs, err := second.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Len(t, userURLs, 2)
+
+	err = second.DeleteShortURLs(ctx, []string{"abc123"}, "user1")
+	require.NoError(t, err)
+
+	third, err := New(tempFile.Name())
+	require.NoError(t, err)
+
+	storedURL, err = third.GetURLByID(ctx, "abc123")
+	require.NoError(t, err)
+	assert.True(t, storedURL.IsDeleted)
+
+	storedURL, err = third.GetURLByID(ctx, "abc124")
+	require.NoError(t, err)
+	assert.False(t, storedURL.IsDeleted)
+
+	id, err := third.SaveURL(ctx, "abc125", "http://example3.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+func TestStorage_PurgeExpiredURLs_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	now := time.Now()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	_, err = first.SaveURL(ctx, "expired", "http://example1.com", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = first.SaveURL(ctx, "expiring", "http://example2.com", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	purged, err := first.PurgeExpiredURLs(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	storedURL, err := second.GetURLByID(ctx, "expired")
+	require.NoError(t, err)
+	assert.Empty(t, storedURL.Code)
+
+	storedURL, err = second.GetURLByURL(ctx, "http://example2.com")
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Hour).Equal(storedURL.ExpiresAt))
+
+	// The purged URL does not come back with a snapshot either.
+	require.NoError(t, second.Compact(ctx))
+
+	third, err := New(fileName)
+	require.NoError(t, err)
+	defer third.Close()
+
+	userURLs, err := third.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, userURLs, 1)
+	assert.Equal(t, "expiring", userURLs[0].Code)
+}
+
+func TestStorage_RestoreShortURLs_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	_, err = first.SaveURL(ctx, "code1", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, first.DeleteShortURLs(ctx, []string{"code1"}, "user1"))
+
+	restored, err := first.RestoreShortURLs(ctx, []string{"code1"}, "user1")
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	storedURL, err := second.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.False(t, storedURL.IsDeleted, "the restore survives a restart")
+}
+
+func TestStorage_ConsumeClick_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	_, err = first.SaveURL(ctx, "limited", "http://example1.com", "user1", repository.URLSettings{MaxClicks: 2})
+	require.NoError(t, err)
+
+	granted, err := first.ConsumeClick(ctx, "limited")
+	require.NoError(t, err)
+	require.True(t, granted)
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	storedURL, err := second.GetURLByID(ctx, "limited")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), storedURL.MaxClicks)
+	assert.Equal(t, int64(1), storedURL.Clicks)
+
+	granted, err = second.ConsumeClick(ctx, "limited")
+	require.NoError(t, err)
+	assert.True(t, granted)
+
+	granted, err = second.ConsumeClick(ctx, "limited")
+	require.NoError(t, err)
+	assert.False(t, granted)
+}
+
+func TestStorage_Clicks_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	id, err := first.SaveURL(ctx, "code", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	clickTime := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, first.SaveClicks(ctx, []models.Click{
+		{URLID: id, Code: "code", Time: clickTime, IPHash: "a"},
+		{URLID: id, Code: "code", Time: clickTime, IPHash: "b"},
+	}))
+	require.NoError(t, first.Close())
+
+	// A record torn by a crash is cut off on load.
+	clicks, err := os.OpenFile(fileName+".clicks", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = clicks.WriteString(`{"url_id":`)
+	require.NoError(t, err)
+	require.NoError(t, clicks.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	stats, err := second.GetClickStats(ctx, id, models.StatsDay)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Total)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+
+	require.NoError(t, second.SaveClicks(ctx, []models.Click{{URLID: id, Code: "code", Time: clickTime}}))
+
+	stats, err = second.GetClickStats(ctx, id, models.StatsHour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickBucket{{Start: clickTime, Clicks: 3}}, stats.Buckets)
+}
+
+func TestStorage_PurgeReload_DoesNotReuseIDs(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	now := time.Now()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	_, err = first.SaveURL(ctx, "kept", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	purgedID, err := first.SaveURL(ctx, "expired", "http://example2.com", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, first.SaveClicks(ctx, []models.Click{{URLID: purgedID, Code: "expired", Time: now}}))
+
+	purged, err := first.PurgeExpiredURLs(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+	require.NoError(t, first.Close())
+
+	// The ID of the purged URL is kept by the log and, after a compaction, by the snapshot.
+	for _, compact := range []bool{false, true} {
+		storage, errNew := New(fileName)
+		require.NoError(t, errNew)
+
+		if compact {
+			require.NoError(t, storage.Compact(ctx))
+			require.NoError(t, storage.Close())
+
+			storage, errNew = New(fileName)
+			require.NoError(t, errNew)
+		}
+
+		code := fmt.Sprintf("new%t", compact)
+		id, errSave := storage.SaveURL(ctx, code, "http://"+code+".com", "user1", repository.URLSettings{})
+		require.NoError(t, errSave)
+		assert.Greater(t, id, purgedID)
+
+		stats, errStats := storage.GetClickStats(ctx, id, models.StatsDay)
+		require.NoError(t, errStats)
+		assert.Zero(t, stats.Total, "a new URL does not inherit the clicks of a purged one")
+
+		require.NoError(t, storage.Close())
+	}
+}
+
+func TestStorage_APIKeys_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	for _, key := range []models.APIKey{
+		{ID: "key1", UserID: "user1", Hash: "hash1", Scopes: []models.APIKeyScope{models.ScopeRead}, CreatedAt: now},
+		{ID: "key2", UserID: "user1", Hash: "hash2", Scopes: []models.APIKeyScope{models.ScopeWrite}, CreatedAt: now},
+	} {
+		require.NoError(t, first.SaveAPIKey(ctx, key))
+	}
+	require.NoError(t, first.RevokeAPIKey(ctx, "key1", "user1", now))
+	require.NoError(t, first.Close())
+
+	// A record torn by a crash is cut off on load.
+	keys, err := os.OpenFile(fileName+".keys", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = keys.WriteString(`{"id":`)
+	require.NoError(t, err)
+	require.NoError(t, keys.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	_, err = second.GetAPIKeyByHash(ctx, "hash1")
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound, "a revocation survives a restart")
+
+	key, err := second.GetAPIKeyByHash(ctx, "hash2")
+	require.NoError(t, err)
+	assert.Equal(t, "key2", key.ID)
+
+	assert.Error(t, second.SaveAPIKey(ctx, models.APIKey{ID: "key1", UserID: "user1", Hash: "hash3"}),
+		"the ID of a revoked key is not reused")
+}
+
+func TestStorage_CorruptEntry(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+	require.NoError(t, first.SaveAPIKey(ctx, models.APIKey{ID: "key1", UserID: "user1", Hash: "hash1", CreatedAt: now}))
+	require.NoError(t, first.Close())
+
+	// A corrupt entry followed by a revocation: skipping the rest of the log would revive the key.
+	keys, err := os.OpenFile(fileName+".keys", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = keys.WriteString(`{"id":` + "\n" +
+		`{"id":"key1","user_id":"user1","hash":"hash1","revoked_at":"2025-03-01T12:00:00Z"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, keys.Close())
+
+	info, err := os.Stat(fileName + ".keys")
+	require.NoError(t, err)
+
+	_, err = New(fileName)
+	assert.ErrorContains(t, err, "corrupt entry")
+
+	after, err := os.Stat(fileName + ".keys")
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size(), "the log is left as is")
+}
+
+func TestStorage_Users_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	require.NoError(t, first.CreateUser(ctx, models.User{ID: "account", Login: "alice", PasswordHash: "hash"}))
+	_, err = first.SaveURL(ctx, "code", "http://example1.com", "anonymous", repository.URLSettings{})
+	require.NoError(t, err)
+
+	moved, err := first.ReassignUser(ctx, "anonymous", "account")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), moved)
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	user, err := second.GetUserByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "account", user.ID)
+
+	urls, err := second.GetUserURLs(ctx, "account")
+	require.NoError(t, err)
+	require.Len(t, urls, 1, "a reassignment survives a restart")
+	assert.Equal(t, "account", urls[0].UserID)
+
+	urls, err = second.GetUserURLs(ctx, "anonymous")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func TestStorage_Admin_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	require.NoError(t, first.CreateUser(ctx, models.User{ID: "account", Login: "alice", PasswordHash: "hash"}))
+	require.NoError(t, first.SetUserRole(ctx, "account", models.RoleAdmin))
+	_, err = first.SaveURL(ctx, "code1", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = first.SaveURL(ctx, "code2", "http://example2.com", "user2", repository.URLSettings{})
+	require.NoError(t, err)
+
+	found, err := first.SetURLDisabled(ctx, "code1", true)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	purged, err := first.PurgeUserURLs(ctx, "user2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	require.NoError(t, first.SaveAuditEntry(ctx, models.AuditEntry{ActorID: "account", Action: models.AuditDisableURL, Target: "code1"}))
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	user, err := second.GetUserByID(ctx, "account")
+	require.NoError(t, err)
+	assert.True(t, user.IsAdmin(), "a role change survives a restart")
+
+	url, err := second.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.True(t, url.IsDisabled, "a disabled URL stays disabled after a restart")
+
+	url, err = second.GetURLByID(ctx, "code2")
+	require.NoError(t, err)
+	assert.Empty(t, url.Code, "a purged URL stays purged after a restart")
+
+	entries, err := second.GetAuditEntries(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "code1", entries[0].Target)
+}
+
+func TestStorage_Webhooks_Reload(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.json")
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	first, err := New(fileName)
+	require.NoError(t, err)
+
+	for _, id := range []string{"hook1", "hook2"} {
+		require.NoError(t, first.SaveWebhook(ctx, models.Webhook{
+			ID:        id,
+			UserID:    "user1",
+			URL:       "https://example.com/" + id,
+			Secret:    "secret",
+			CreatedAt: now,
+		}))
+	}
+	require.NoError(t, first.DeleteWebhook(ctx, "hook1", "user1", now))
+	require.NoError(t, first.SaveDeadLetter(ctx, models.DeadLetter{
+		WebhookID: "hook2",
+		UserID:    "user1",
+		Event:     models.LinkEvent{Action: models.EventClick, Code: "code1"},
+		Attempts:  3,
+		Error:     "unexpected status 500",
+	}))
+	require.NoError(t, first.Close())
+
+	second, err := New(fileName)
+	require.NoError(t, err)
+	defer second.Close()
+
+	hooks, err := second.GetUserWebhooks(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, hooks, 1, "a deleted webhook stays deleted after a restart")
+	assert.Equal(t, "hook2", hooks[0].ID)
+	assert.Equal(t, "secret", hooks[0].Secret)
+
+	letters, err := second.GetDeadLetters(ctx, "user1", 0)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "code1", letters[0].Event.Code)
+	assert.Equal(t, 3, letters[0].Attempts)
+}
+
 // TestStorage_SaveBatchURL tests the SaveBatchURL method of the Storage.
 func TestStorage_SaveBatchURL(t *testing.T) {
 	storage, err := getStorage(t)
@@ -55,6 +517,59 @@ func TestStorage_SaveBatchURL(t *testing.T) {
 	assert.Equal(t, "http://example2.com", storedURL2.URL)
 }
 
+// TestStorage_SaveBatchURL_Existing checks that existing URLs, including repeats
+// within the batch, resolve to their codes while the results keep the input order.
+func TestStorage_SaveBatchURL_Existing(t *testing.T) {
+	s, err := getStorage(t)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = s.SaveURL(ctx, "old123", "http://example1.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	batch := []repository.BatchURLDto{
+		{CorrelationID: "batch1", ShortCode: "xyz123", OriginalURL: "http://example1.com"},
+		{CorrelationID: "batch2", ShortCode: "xyz124", OriginalURL: "http://example2.com"},
+		{CorrelationID: "batch3", ShortCode: "xyz125", OriginalURL: "http://example2.com"},
+	}
+
+	batchURLs, err := s.SaveBatchURL(ctx, &batch, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []repository.BatchURL{
+		{CorrelationID: "batch1", ShortCode: "old123"},
+		{CorrelationID: "batch2", ShortCode: "xyz124"},
+		{CorrelationID: "batch3", ShortCode: "xyz124"},
+	}, *batchURLs)
+
+	stored, err := s.GetURLByID(ctx, "xyz125")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Code)
+}
+
+// TestStorage_SaveBatchURL_CodeExists checks that a taken short code fails the whole batch.
+func TestStorage_SaveBatchURL_CodeExists(t *testing.T) {
+	s, err := getStorage(t)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = s.SaveURL(ctx, "xyz124", "http://example.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	batch := []repository.BatchURLDto{
+		{CorrelationID: "batch1", ShortCode: "xyz123", OriginalURL: "http://example1.com"},
+		{CorrelationID: "batch2", ShortCode: "xyz124", OriginalURL: "http://example2.com"},
+	}
+
+	_, err = s.SaveBatchURL(ctx, &batch, "user1")
+	require.ErrorIs(t, err, storage.ErrURLOrCodeExists)
+
+	stored, err := s.GetURLByID(ctx, "xyz123")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Code)
+}
+
 // TestStorage_GetURLByID tests the GetURLByID method of the Storage.
 func TestStorage_GetURLByID(t *testing.T) {
 	storage, err := getStorage(t)
@@ -65,7 +580,7 @@ func TestStorage_GetURLByID(t *testing.T) {
 	url := "http://example.com"
 	userID := "user1"
 
-	_, err = storage.SaveURL(ctx, code, url, userID)
+	_, err = storage.SaveURL(ctx, code, url, userID, repository.URLSettings{})
 	assert.NoError(t, err)
 
 	storedURL, err := storage.GetURLByID(ctx, code)
@@ -87,7 +602,7 @@ func TestStorage_GetURLByURL(t *testing.T) {
 	url := "http://example.com"
 	userID := "user1"
 
-	_, err = storage.SaveURL(ctx, code, url, userID)
+	_, err = storage.SaveURL(ctx, code, url, userID, repository.URLSettings{})
 	assert.NoError(t, err)
 
 	storedURL, err := storage.GetURLByURL(ctx, url)
@@ -121,9 +636,9 @@ func TestStorage_GetUserURLs(t *testing.T) {
 	url1 := "http://example1.com"
 	url2 := "http://example2.com"
 
-	_, err = storage.SaveURL(ctx, "abc123", url1, userID)
+	_, err = storage.SaveURL(ctx, "abc123", url1, userID, repository.URLSettings{})
 	assert.NoError(t, err)
-	_, err = storage.SaveURL(ctx, "abc124", url2, userID)
+	_, err = storage.SaveURL(ctx, "abc124", url2, userID, repository.URLSettings{})
 	assert.NoError(t, err)
 
 	userURLs, err := storage.GetUserURLs(ctx, userID)
@@ -145,9 +660,9 @@ func TestStorage_DeleteShortURLs(t *testing.T) {
 	url1 := "http://example1.com"
 	url2 := "http://example2.com"
 
-	_, err = storage.SaveURL(ctx, "abc123", url1, userID)
+	_, err = storage.SaveURL(ctx, "abc123", url1, userID, repository.URLSettings{})
 	assert.NoError(t, err)
-	_, err = storage.SaveURL(ctx, "abc124", url2, userID)
+	_, err = storage.SaveURL(ctx, "abc124", url2, userID, repository.URLSettings{})
 	assert.NoError(t, err)
 
 	err = storage.DeleteShortURLs(ctx, []string{"abc123"}, userID)
@@ -162,8 +677,69 @@ func TestStorage_DeleteShortURLs(t *testing.T) {
 	assert.False(t, storedURL.IsDeleted)
 }
 
+// TestStorage_Concurrent exercises the Storage from many goroutines at once.
+// It is meant to be run with the race detector enabled.
+func TestStorage_Concurrent(t *testing.T) {
+	storage, err := getStorage(t)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+
+	const workers = 16
+	const perWorker = 50
+
+	var wg sync.WaitGroup
+
+	for w := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			userID := fmt.Sprintf("user%d", w)
+
+			for i := range perWorker {
+				code := fmt.Sprintf("c%d-%d", w, i)
+				url := fmt.Sprintf("http://example.com/%d/%d", w, i)
+
+				_, errSave := storage.SaveURL(ctx, code, url, userID, repository.URLSettings{})
+				assert.NoError(t, errSave)
+
+				_, errGet := storage.GetURLByID(ctx, code)
+				assert.NoError(t, errGet)
+
+				_, errGet = storage.GetURLByURL(ctx, url)
+				assert.NoError(t, errGet)
+
+				_, errGet = storage.GetUserURLs(ctx, userID)
+				assert.NoError(t, errGet)
+
+				if i%2 == 0 {
+					assert.NoError(t, storage.DeleteShortURLs(ctx, []string{code}, userID))
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	ids := make(map[int64]struct{})
+
+	for w := range workers {
+		userURLs, errGet := storage.GetUserURLs(ctx, fmt.Sprintf("user%d", w))
+		assert.NoError(t, errGet)
+		assert.Len(t, userURLs, perWorker)
+
+		for _, u := range userURLs {
+			ids[u.ID] = struct{}{}
+		}
+	}
+
+	assert.Len(t, ids, workers*perWorker)
+}
+
 func getStorage(t *testing.T) (*Storage, error) {
-	tempFile, err := os.CreateTemp("", "test_storage_*.txt")
+	tempFile, err := os.CreateTemp("", "test_s*.txt")
 	assert.NoError(t, err)
 	defer func() {
 		if errRemove := os.Remove(tempFile.Name()); errRemove != nil {
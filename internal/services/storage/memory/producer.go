@@ -12,6 +12,8 @@ import (
 
 // Producer is responsible for encoding and writing URL data to a destination writer.
 // It uses a JSON encoder to serialize URLs before writing them.
+//
+// Producer is not safe for concurrent use; Storage serializes all writes to it.
 type Producer struct {
 	// writer is the destination where the encoded URL data is written.
 	writer *io.Writer
@@ -30,8 +32,14 @@ func NewProducer(writer io.Writer) (*Producer, error) {
 	}, nil
 }
 
-// WriteURL serializes the given URL and writes it to the destination writer.
+// WriteURL serializes the given URL as a create record and writes it to the destination writer.
 // It returns an error if encoding or writing the URL fails.
 func (p *Producer) WriteURL(url *models.URL) error {
-	return p.encoder.Encode(&url)
+	return p.WriteRecord(&Record{Type: RecordCreate, URL: *url})
+}
+
+// WriteRecord serializes the given record and appends it to the destination writer.
+// It returns an error if encoding or writing the record fails.
+func (p *Producer) WriteRecord(record *Record) error {
+	return p.encoder.Encode(record)
 }
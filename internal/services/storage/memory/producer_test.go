@@ -33,7 +33,32 @@ func TestWriteURL_Success(t *testing.T) {
 	err = producer.WriteURL(url)
 	require.NoError(t, err)
 
-	expectedJSON := `{"id":0,"code":"abcd1234","url":"https://example.com","user_id":"","is_deleted":false}` + "\n"
+	expectedJSON := `{"type":"create","id":0,"code":"abcd1234","url":"https://example.com","user_id":"","is_deleted":false}` + "\n"
+
+	assert.Equal(t, expectedJSON, buf.String())
+}
+
+func TestWriteRecord_Success(t *testing.T) {
+	var buf bytes.Buffer
+	producer, err := NewProducer(&buf)
+
+	require.NoError(t, err)
+
+	record := &Record{
+		Type: RecordDelete,
+		URL: models.URL{
+			ID:        1,
+			Code:      "abcd1234",
+			URL:       "https://example.com",
+			UserID:    "user1",
+			IsDeleted: true,
+		},
+	}
+
+	err = producer.WriteRecord(record)
+	require.NoError(t, err)
+
+	expectedJSON := `{"type":"delete","id":1,"code":"abcd1234","url":"https://example.com","user_id":"user1","is_deleted":true}` + "\n"
 
 	assert.Equal(t, expectedJSON, buf.String())
 }
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// RecordType identifies the kind of change stored in a file storage record.
+type RecordType string
+
+const (
+	// RecordCreate is written when a new URL is saved.
+	// Records without a type, written before the log was typed, are treated as creates.
+	RecordCreate RecordType = "create"
+
+	// RecordDelete is written when a URL is soft deleted.
+	RecordDelete RecordType = "delete"
+
+	// RecordRestore is written when a soft-deleted URL is restored.
+	RecordRestore RecordType = "restore"
+
+	// RecordUpdate is written when any other attribute of a URL changes.
+	RecordUpdate RecordType = "update"
+
+	// RecordPurge is written when an expired URL is removed for good.
+	RecordPurge RecordType = "purge"
+
+	// RecordSequence is written to the snapshot when the URL holding the last allocated ID
+	// has been purged. It carries that ID alone, so IDs are never reused after a restart.
+	RecordSequence RecordType = "sequence"
+)
+
+// Record is a single entry of the file storage event log.
+//
+// Every record carries the complete state of the URL after the change, so replaying
+// the log in order always yields the latest state, and replaying a record twice is harmless.
+// A purge record removes the URL instead.
+type Record struct {
+	// Type is the kind of change the record describes.
+	Type RecordType `json:"type"`
+
+	models.URL
+}
+
+// apply replays the record onto the URL map.
+// It returns an error if the record type is unknown.
+func (r *Record) apply(urls map[string]models.URL) error {
+	switch r.Type {
+	case "", RecordCreate, RecordUpdate, RecordDelete, RecordRestore:
+		urls[r.Code] = r.URL
+	case RecordPurge:
+		delete(urls, r.Code)
+	case RecordSequence:
+	default:
+		return fmt.Errorf("unknown record type %q for code %q", r.Type, r.Code)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// SyncMode defines when appended records are flushed to stable storage.
+type SyncMode string
+
+const (
+	// SyncAlways fsyncs the log after every write, before the write is acknowledged.
+	SyncAlways SyncMode = "always"
+	// SyncInterval fsyncs the log in the background at a fixed interval, so all writes
+	// made within one interval share a single fsync. Writes acknowledged during the
+	// last interval may be lost on a crash.
+	SyncInterval SyncMode = "interval"
+	// SyncNone never fsyncs the log and leaves flushing to the operating system.
+	SyncNone SyncMode = "none"
+)
+
+// ParseSyncMode converts a string into a SyncMode.
+// It returns an error if the string is not a known mode.
+func ParseSyncMode(mode string) (SyncMode, error) {
+	switch m := SyncMode(mode); m {
+	case SyncAlways, SyncInterval, SyncNone:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown file storage sync mode %q", mode)
+	}
+}
+
+// WithSync sets the durability mode of the record log.
+// The interval is only used by SyncInterval and must be positive for it.
+func WithSync(mode SyncMode, interval time.Duration) Option {
+	return func(s *Storage) {
+		s.syncMode = mode
+		s.syncInterval = interval
+	}
+}
+
+// syncWrite makes a freshly appended record durable according to the sync mode.
+// The caller must hold s.mu.
+func (s *Storage) syncWrite() error {
+	switch s.syncMode {
+	case SyncAlways:
+		return s.log.file.Sync()
+	case SyncInterval:
+		s.dirty = true
+	}
+
+	return nil
+}
+
+// startSyncer starts the background group commit when the storage uses SyncInterval.
+func (s *Storage) startSyncer() {
+	if s.syncMode != SyncInterval {
+		return
+	}
+
+	s.stopSync = make(chan struct{})
+	s.syncDone = make(chan struct{})
+
+	go func() {
+		defer close(s.syncDone)
+
+		ticker := time.NewTicker(s.syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				if err := s.flush(); err != nil {
+					slog.Error("failed to sync file storage", sl.Err(err))
+				}
+				s.mu.Unlock()
+			case <-s.stopSync:
+				return
+			}
+		}
+	}()
+}
+
+// flush fsyncs the log if records were appended since the last fsync.
+// The caller must hold s.mu.
+func (s *Storage) flush() error {
+	if !s.dirty {
+		return nil
+	}
+
+	if err := s.log.file.Sync(); err != nil {
+		return err
+	}
+
+	s.dirty = false
+
+	return nil
+}
+
+// Close stops the background group commit, flushes pending records and closes the log.
+func (s *Storage) Close() error {
+	const op = "storage.memory.Close"
+
+	if s.stopSync != nil {
+		close(s.stopSync)
+		<-s.syncDone
+	}
+
+	if err := s.clicks.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.keys.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.users.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.audit.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.webhooks.close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.flush(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.log.file.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
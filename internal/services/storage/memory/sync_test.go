@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/repository"
+)
+
+func TestParseSyncMode(t *testing.T) {
+	for _, mode := range []SyncMode{SyncAlways, SyncInterval, SyncNone} {
+		parsed, err := ParseSyncMode(string(mode))
+		require.NoError(t, err)
+		assert.Equal(t, mode, parsed)
+	}
+
+	_, err := ParseSyncMode("sometimes")
+	assert.Error(t, err)
+}
+
+func TestStorage_SyncModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     SyncMode
+		interval time.Duration
+	}{
+		{name: "always", mode: SyncAlways},
+		{name: "interval", mode: SyncInterval, interval: time.Millisecond},
+		{name: "none", mode: SyncNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+			s, err := New(fileName, WithSync(tt.mode, tt.interval))
+			require.NoError(t, err)
+
+			fillStorage(t, s, 5)
+			before := snapshotState(t, s)
+
+			require.NoError(t, s.Close())
+
+			reloaded, err := New(fileName)
+			require.NoError(t, err)
+			assert.Equal(t, before, snapshotState(t, reloaded))
+		})
+	}
+}
+
+func TestStorage_SyncIntervalFlushes(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName, WithSync(SyncInterval, time.Millisecond))
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.SaveURL(context.Background(), "code", "http://example.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return !s.dirty
+	}, time.Second, time.Millisecond)
+}
+
+func TestStorage_SyncIntervalInvalid(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "storage.txt"), WithSync(SyncInterval, 0))
+	assert.Error(t, err)
+}
+
+func TestStorage_RepairTornRecord(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "storage.txt")
+
+	s, err := New(fileName)
+	require.NoError(t, err)
+
+	fillStorage(t, s, 4)
+	before := snapshotState(t, s)
+	require.NoError(t, s.Close())
+
+	info, err := os.Stat(fileName)
+	require.NoError(t, err)
+
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, permission)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"type":"create","id":5,"code":"torn","url":"http://exa`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reloaded, err := New(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, before, snapshotState(t, reloaded))
+
+	repaired, err := os.Stat(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), repaired.Size())
+
+	// New records are appended right after the last complete one.
+	_, err = reloaded.SaveURL(context.Background(), "next", "http://example.com/next", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, reloaded.Close())
+
+	again, err := New(fileName)
+	require.NoError(t, err)
+	assert.Len(t, snapshotState(t, again), len(before)+1)
+}
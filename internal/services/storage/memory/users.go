@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+// userLog holds the accounts. They are appended to a log of their own next to the record log,
+// one entry with the complete state per change, and every write is fsynced.
+type userLog struct {
+	// mu guards the fields below. It is independent of the storage write lock.
+	mu sync.Mutex
+
+	// name is the path of the log. The file is created on the first account.
+	name string
+
+	file *os.File
+
+	// byID holds the accounts keyed by ID.
+	byID map[string]models.User
+
+	// byLogin holds the account IDs keyed by login.
+	byLogin map[string]string
+}
+
+// usersName returns the path of the account log.
+func (s *Storage) usersName() string {
+	return s.fileName + ".users"
+}
+
+// loadUsers reads the account log. A record torn by a crash at the end of the log is cut off.
+func (s *Storage) loadUsers() error {
+	s.users = &userLog{name: s.usersName(), byID: make(map[string]models.User), byLogin: make(map[string]string)}
+
+	if err := replayEntries(s.users.name, s.users.put); err != nil {
+		return fmt.Errorf("storage.memory.loadUsers: %w", err)
+	}
+
+	return nil
+}
+
+// CreateUser stores a new account. It returns storage.ErrUserExists if the login or the ID is taken.
+func (s *Storage) CreateUser(ctx context.Context, user models.User) error {
+	const op = "storage.memory.CreateUser"
+
+	u := s.users
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.byLogin[user.Login]; ok {
+		return storage.ErrUserExists
+	}
+	if _, ok := u.byID[user.ID]; ok {
+		return storage.ErrUserExists
+	}
+
+	if err := appendEntry(&u.file, u.name, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.put(user)
+
+	return nil
+}
+
+// GetUserByLogin retrieves the account with the given login.
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	u := s.users
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	id, ok := u.byLogin[login]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return u.byID[id], nil
+}
+
+// GetUserByID retrieves the account with the given ID.
+func (s *Storage) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	u := s.users
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.byID[id]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// SetUserRole changes the role of the account with the given ID.
+func (s *Storage) SetUserRole(ctx context.Context, id string, role models.Role) error {
+	const op = "storage.memory.SetUserRole"
+
+	u := s.users
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.byID[id]
+	if !ok {
+		return storage.ErrUserNotFound
+	}
+
+	user.Role = role
+	if err := appendEntry(&u.file, u.name, user); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.put(user)
+
+	return nil
+}
+
+// ReassignUser moves the URLs and API keys of fromUserID over to toUserID. Every moved URL
+// gets an update record, so the move survives a restart. It returns the number of URLs moved.
+func (s *Storage) ReassignUser(ctx context.Context, fromUserID string, toUserID string) (int64, error) {
+	const op = "storage.memory.ReassignUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var moved int64
+	for _, code := range s.index.codesByUser(fromUserID) {
+		url, ok := s.get(code)
+		if !ok {
+			continue
+		}
+
+		owned := url
+		owned.UserID = toUserID
+
+		if err := s.write(RecordUpdate, owned); err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+
+		s.index.remove(url)
+		s.index.add(owned)
+		moved++
+	}
+
+	k := s.keys
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, key := range k.byID {
+		if key.UserID != fromUserID || key.IsRevoked() {
+			continue
+		}
+
+		key.UserID = toUserID
+		if err := k.write(key); err != nil {
+			return moved, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return moved, nil
+}
+
+// put applies the latest state of an account to the maps.
+func (u *userLog) put(user models.User) {
+	if previous, ok := u.byID[user.ID]; ok {
+		delete(u.byLogin, previous.Login)
+	}
+
+	u.byID[user.ID] = user
+	u.byLogin[user.Login] = user.ID
+}
+
+// close closes the account log.
+func (u *userLog) close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return closeEntries(&u.file)
+}
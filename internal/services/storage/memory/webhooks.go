@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+// webhookLog holds the webhooks and the events that could not be delivered to them. Each is
+// appended to a log of its own next to the record log and fsynced. Like a URL record, every
+// webhook entry carries the complete state of a webhook, so the last entry of a webhook wins
+// on replay; dead letters are never rewritten.
+type webhookLog struct {
+	// mu guards the fields below. It is independent of the storage write lock.
+	mu sync.Mutex
+
+	// name and deadName are the paths of the logs. The files are created on the first entry.
+	name     string
+	deadName string
+
+	file     *os.File
+	deadFile *os.File
+
+	// byID holds the active webhooks keyed by ID.
+	byID map[string]models.Webhook
+
+	// dead holds the dead letters, oldest first.
+	dead []models.DeadLetter
+}
+
+// webhooksName returns the path of the webhook log.
+func (s *Storage) webhooksName() string {
+	return s.fileName + ".webhooks"
+}
+
+// deadLettersName returns the path of the dead letter log.
+func (s *Storage) deadLettersName() string {
+	return s.fileName + ".deadletters"
+}
+
+// loadWebhooks reads the webhook and dead letter logs. A record torn by a crash at the end of
+// a log is cut off.
+func (s *Storage) loadWebhooks() error {
+	const op = "storage.memory.loadWebhooks"
+
+	w := &webhookLog{name: s.webhooksName(), deadName: s.deadLettersName(), byID: make(map[string]models.Webhook)}
+	s.webhooks = w
+
+	if err := replayEntries(w.name, w.put); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err := replayEntries(w.deadName, func(letter models.DeadLetter) {
+		w.dead = append(w.dead, letter)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveWebhook stores a new webhook.
+func (s *Storage) SaveWebhook(ctx context.Context, hook models.Webhook) error {
+	const op = "storage.memory.SaveWebhook"
+
+	w := s.webhooks
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.byID[hook.ID]; ok {
+		return fmt.Errorf("%s: duplicate webhook id %q", op, hook.ID)
+	}
+
+	if err := appendEntry(&w.file, w.name, hook); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.put(hook)
+
+	return nil
+}
+
+// GetUserWebhooks retrieves the active webhooks of a user, oldest first.
+func (s *Storage) GetUserWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	w := s.webhooks
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var hooks []models.Webhook
+	for _, hook := range w.byID {
+		if hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	slices.SortFunc(hooks, func(a, b models.Webhook) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return hooks, nil
+}
+
+// DeleteWebhook deletes the active webhook with the given ID owned by the user.
+func (s *Storage) DeleteWebhook(ctx context.Context, id string, userID string, now time.Time) error {
+	const op = "storage.memory.DeleteWebhook"
+
+	w := s.webhooks
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	hook, ok := w.byID[id]
+	if !ok || hook.UserID != userID {
+		return storage.ErrWebhookNotFound
+	}
+
+	hook.DeletedAt = now
+	if err := appendEntry(&w.file, w.name, hook); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.put(hook)
+
+	return nil
+}
+
+// SaveDeadLetter records an event that could not be delivered.
+func (s *Storage) SaveDeadLetter(ctx context.Context, letter models.DeadLetter) error {
+	w := s.webhooks
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := appendEntry(&w.deadFile, w.deadName, letter); err != nil {
+		return fmt.Errorf("storage.memory.SaveDeadLetter: %w", err)
+	}
+
+	w.dead = append(w.dead, letter)
+
+	return nil
+}
+
+// GetDeadLetters returns the latest dead letters of the webhooks of a user, newest first.
+// A limit of zero returns all of them.
+func (s *Storage) GetDeadLetters(ctx context.Context, userID string, limit int) ([]models.DeadLetter, error) {
+	w := s.webhooks
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var letters []models.DeadLetter
+	for _, letter := range slices.Backward(w.dead) {
+		if letter.UserID == userID {
+			letters = append(letters, letter)
+		}
+	}
+
+	return page(letters, 0, limit), nil
+}
+
+// put applies the latest state of a webhook to the map.
+func (w *webhookLog) put(hook models.Webhook) {
+	if hook.IsDeleted() {
+		delete(w.byID, hook.ID)
+		return
+	}
+
+	w.byID[hook.ID] = hook
+}
+
+// close closes the webhook and dead letter logs.
+func (w *webhookLog) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := closeEntries(&w.file); err != nil {
+		return err
+	}
+
+	return closeEntries(&w.deadFile)
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/services/storage/storagetest"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+)
+
+// TestStorage_Conformance runs the storage contract against both backends.
+// It needs a disposable database in TEST_DATABASE_DSN, since every test truncates its tables.
+func TestStorage_Conformance(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	// Migrations are resolved relative to the repository root.
+	t.Chdir("../../../..")
+
+	open := map[string]func() (*Storage, error){
+		"database/sql": func() (*Storage, error) {
+			return New(dsn)
+		},
+		"pgxpool": func() (*Storage, error) {
+			return NewPool(context.Background(), dsn, PoolConfig{})
+		},
+	}
+
+	for name, newStorage := range open {
+		t.Run(name, func(t *testing.T) {
+			storagetest.Run(t, func(t *testing.T) urlservice.URLStorage {
+				s, err := newStorage()
+				require.NoError(t, err)
+
+				err = s.db.Exec(context.Background(), "TRUNCATE public.urls, public.clicks, public.api_keys, public.users, public.admin_audit, public.webhooks, public.webhook_dead_letters RESTART IDENTITY")
+				require.NoError(t, err)
+
+				t.Cleanup(func() {
+					require.NoError(t, s.Close())
+				})
+
+				return s
+			})
+		})
+	}
+}
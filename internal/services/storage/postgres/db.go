@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// querier runs queries on a connection pool or inside a transaction.
+// It lets the storage queries be written once for both database/sql and pgxpool.
+type querier interface {
+	// Exec executes a query that returns no rows.
+	Exec(ctx context.Context, query string, args ...any) error
+
+	// Query executes a query that returns rows.
+	Query(ctx context.Context, query string, args ...any) (rows, error)
+
+	// QueryRow executes a query that returns at most one row.
+	// A missing row is reported by Scan as an error matching sql.ErrNoRows.
+	QueryRow(ctx context.Context, query string, args ...any) row
+}
+
+// db is a connection pool the storage runs on.
+type db interface {
+	querier
+
+	// Begin starts a transaction.
+	Begin(ctx context.Context) (tx, error)
+
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+
+	// Stats returns the connection pool statistics.
+	Stats() models.PoolStats
+
+	// Close closes the pool.
+	Close() error
+}
+
+// tx is a database transaction.
+type tx interface {
+	querier
+
+	// Commit commits the transaction.
+	Commit(ctx context.Context) error
+
+	// Rollback rolls the transaction back. It is a no-op after Commit.
+	Rollback(ctx context.Context) error
+}
+
+// rows is the result of a query.
+type rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// row is the result of a single row query.
+type row interface {
+	Scan(dest ...any) error
+}
+
+// sqlDB adapts *sql.DB to the db interface.
+type sqlDB struct {
+	db *sql.DB
+}
+
+func (d *sqlDB) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := d.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (d *sqlDB) Query(ctx context.Context, query string, args ...any) (rows, error) {
+	return d.db.QueryContext(ctx, query, args...)
+}
+
+func (d *sqlDB) QueryRow(ctx context.Context, query string, args ...any) row {
+	return d.db.QueryRowContext(ctx, query, args...)
+}
+
+func (d *sqlDB) Begin(ctx context.Context) (tx, error) {
+	t, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlTx{tx: t}, nil
+}
+
+func (d *sqlDB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+func (d *sqlDB) Stats() models.PoolStats {
+	s := d.db.Stats()
+
+	return models.PoolStats{
+		MaxConns:      int32(s.MaxOpenConnections),
+		TotalConns:    int32(s.OpenConnections),
+		AcquiredConns: int32(s.InUse),
+		IdleConns:     int32(s.Idle),
+		WaitCount:     s.WaitCount,
+		WaitDuration:  s.WaitDuration,
+	}
+}
+
+func (d *sqlDB) Close() error {
+	return d.db.Close()
+}
+
+// sqlTx adapts *sql.Tx to the tx interface.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t *sqlTx) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (t *sqlTx) Query(ctx context.Context, query string, args ...any) (rows, error) {
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *sqlTx) QueryRow(ctx context.Context, query string, args ...any) row {
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
+
+func (t *sqlTx) Commit(_ context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback(_ context.Context) error {
+	if err := t.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+
+	return nil
+}
+
+// pgxDB adapts *pgxpool.Pool to the db interface.
+type pgxDB struct {
+	pool *pgxpool.Pool
+}
+
+func (d *pgxDB) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := d.pool.Exec(ctx, query, args...)
+	return err
+}
+
+func (d *pgxDB) Query(ctx context.Context, query string, args ...any) (rows, error) {
+	r, err := d.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgxRows{Rows: r}, nil
+}
+
+func (d *pgxDB) QueryRow(ctx context.Context, query string, args ...any) row {
+	return d.pool.QueryRow(ctx, query, args...)
+}
+
+func (d *pgxDB) Begin(ctx context.Context) (tx, error) {
+	t, err := d.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgxTx{tx: t}, nil
+}
+
+func (d *pgxDB) Ping(ctx context.Context) error {
+	return d.pool.Ping(ctx)
+}
+
+func (d *pgxDB) Stats() models.PoolStats {
+	s := d.pool.Stat()
+
+	return models.PoolStats{
+		MaxConns:      s.MaxConns(),
+		TotalConns:    s.TotalConns(),
+		AcquiredConns: s.AcquiredConns(),
+		IdleConns:     s.IdleConns(),
+		WaitCount:     s.EmptyAcquireCount(),
+		WaitDuration:  s.AcquireDuration(),
+	}
+}
+
+func (d *pgxDB) Close() error {
+	d.pool.Close()
+	return nil
+}
+
+// pgxTx adapts pgx.Tx to the tx interface.
+type pgxTx struct {
+	tx pgx.Tx
+}
+
+func (t *pgxTx) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := t.tx.Exec(ctx, query, args...)
+	return err
+}
+
+func (t *pgxTx) Query(ctx context.Context, query string, args ...any) (rows, error) {
+	r, err := t.tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgxRows{Rows: r}, nil
+}
+
+func (t *pgxTx) QueryRow(ctx context.Context, query string, args ...any) row {
+	return t.tx.QueryRow(ctx, query, args...)
+}
+
+func (t *pgxTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *pgxTx) Rollback(ctx context.Context) error {
+	if err := t.tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// pgxRows adapts pgx.Rows to the rows interface.
+type pgxRows struct {
+	pgx.Rows
+}
+
+// Close closes the rows. pgx reports errors through Err, so it always returns nil.
+func (r *pgxRows) Close() error {
+	r.Rows.Close()
+	return nil
+}
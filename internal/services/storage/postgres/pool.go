@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig holds the tunable settings of the native pgxpool backend.
+// A zero value keeps the pgxpool default for the corresponding setting.
+type PoolConfig struct {
+	// MaxConns is the maximum number of connections in the pool.
+	MaxConns int32
+
+	// MinConns is the number of connections the pool keeps open even when idle.
+	MinConns int32
+
+	// MaxConnLifetime is the duration after which a connection is closed and replaced.
+	MaxConnLifetime time.Duration
+
+	// StatementCacheCapacity is the number of prepared statements cached per connection.
+	// A negative value disables the cache, so every query is described on each execution.
+	StatementCacheCapacity int
+}
+
+// NewPool initializes and returns a new Storage instance backed by a native pgxpool.
+// It applies any necessary migrations, opens the pool with the given settings and checks
+// that the database is reachable.
+// It returns the Storage instance or an error if the connection or migration fails.
+func NewPool(ctx context.Context, databaseDsn string, cfg PoolConfig) (*Storage, error) {
+	poolConfig, err := cfg.parse(databaseDsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = migrateUp(databaseDsn); err != nil {
+		return nil, err
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	return &Storage{db: &pgxDB{pool: pool}}, nil
+}
+
+// parse builds the pgxpool configuration from the DSN and applies the settings on top of it.
+func (cfg PoolConfig) parse(databaseDsn string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseDsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse database config: %w", err)
+	}
+
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.StatementCacheCapacity > 0 {
+		poolConfig.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+	} else if cfg.StatementCacheCapacity < 0 {
+		poolConfig.ConnConfig.StatementCacheCapacity = 0
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+
+	return poolConfig, nil
+}
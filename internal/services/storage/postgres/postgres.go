@@ -6,17 +6,19 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"log/slog"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/lib/pq"
 
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
@@ -28,61 +30,95 @@ import (
 
 // Storage is the PostgreSQL implementation of the Storage interface for managing URL data.
 // It provides methods for saving, retrieving, and deleting URLs, as well as handling batch operations.
+// The same queries run either on a database/sql pool (see New) or on a native pgxpool (see NewPool).
 type Storage struct {
-	// db is the underlying PostgreSQL database connection.
-	db *sql.DB
+	// db is the underlying PostgreSQL connection pool.
+	db db
 }
 
-// New initializes and returns a new Storage instance.
+// New initializes and returns a new Storage instance backed by database/sql.
 // It connects to the PostgreSQL database using the provided database DSN (Data Source Name),
 // and applies any necessary migrations to ensure the database schema is up-to-date.
 // It returns the Storage instance or an error if the connection or migration fails.
 func New(databaseDsn string) (*Storage, error) {
-	db, err := sql.Open("pgx", databaseDsn)
+	conn, err := sql.Open("pgx", databaseDsn)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to database: %v", err)
 	}
 
-	m, err := migrate.New("file://migrations", databaseDsn)
+	if err = migrateUp(databaseDsn); err != nil {
+		if errClose := conn.Close(); errClose != nil {
+			slog.Error("failed to close database", sl.Err(errClose))
+		}
+		return nil, err
+	}
+
+	return &Storage{db: &sqlDB{db: conn}}, nil
+}
+
+// migrationsScheme is the URL scheme of the pgx migration driver.
+const migrationsScheme = "pgx5"
+
+// migrateUp applies the pending migrations. They run on pgx, like the storage itself.
+func migrateUp(databaseDsn string) error {
+	dsn, err := url.Parse(databaseDsn)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("unable to init migrations: %w", err)
+	}
+	if dsn.Scheme != "postgres" && dsn.Scheme != "postgresql" {
+		return fmt.Errorf("unable to init migrations: the DSN must be a postgres:// URL")
 	}
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			slog.Info("No migrations needed")
-		} else {
-			log.Panic(err)
+	dsn.Scheme = migrationsScheme
+
+	m, err := migrate.New("file://migrations", dsn.String())
+	if err != nil {
+		return fmt.Errorf("unable to init migrations: %w", err)
+	}
+
+	if err = m.Up(); err != nil {
+		if !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("unable to apply migrations: %w", err)
 		}
+		slog.Info("No migrations needed")
 	}
 
-	return &Storage{db: db}, nil
+	return nil
 }
 
 // PingContext checks the database connection by executing a ping operation.
 // It returns an error if the connection cannot be established.
 func (s *Storage) PingContext(ctx context.Context) error {
-	return s.db.PingContext(ctx)
+	return s.db.Ping(ctx)
+}
+
+// Stats returns the connection pool statistics.
+func (s *Storage) Stats() models.PoolStats {
+	return s.db.Stats()
+}
+
+// Close closes the database connection pool.
+func (s *Storage) Close() error {
+	return s.db.Close()
 }
 
 // SaveURL saves a URL with a unique code to the database.
 // If the URL already exists in the database, an error is returned.
 // It returns the ID of the newly saved URL or an error if the save operation fails.
-func (s *Storage) SaveURL(ctx context.Context, code, url, userID string) (int64, error) {
+func (s *Storage) SaveURL(
+	ctx context.Context,
+	code, url, userID string,
+	settings repository.URLSettings,
+) (int64, error) {
 	const op = "storage.postgres.SaveURL"
-	const insertURL = "INSERT INTO public.urls (code, url, user_id) VALUES ($1,$2, $3) RETURNING id"
-
-	stmt, err := s.db.Prepare(insertURL)
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
-	}
-	defer func() {
-		if errStmtClose := stmt.Close(); errStmtClose != nil {
-			slog.Error("prepare sql error", sl.Err(errStmtClose))
-		}
-	}()
+	const insertURL = `
+		INSERT INTO public.urls (code, url, user_id, expires_at, max_clicks, password_hash)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 
 	var id int64
-	err = stmt.QueryRowContext(ctx, code, url, userID).Scan(&id)
+	err := s.db.QueryRow(
+		ctx, insertURL, code, url, userID,
+		nullTime(settings.ExpiresAt), nullInt(settings.MaxClicks), nullString(settings.PasswordHash),
+	).Scan(&id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 
@@ -111,66 +147,119 @@ func (s *Storage) SaveURL(ctx context.Context, code, url, userID string) (int64,
 }
 
 // SaveBatchURL saves multiple URLs in a batch operation to the database.
-// It processes a list of BatchURLDto, saving each URL individually. If a URL already exists, it is skipped,
-// and the batch continues with the next URL.
-// It returns a list of BatchURL instances with the correlation ID and short code for each URL.
+// All URLs are inserted by a single multi-row statement inside a transaction, so either
+// the whole batch is stored or nothing is. URLs that already exist are skipped and resolved
+// to their existing short codes within the same round trip; a duplicate short code fails the batch.
+// It returns a list of BatchURL instances with the correlation ID and short code for each URL,
+// in the order of the input.
 func (s *Storage) SaveBatchURL(
 	ctx context.Context,
 	dto *[]repository.BatchURLDto,
 	userID string,
 ) (*[]repository.BatchURL, error) {
-	entities := make([]repository.BatchURL, 0)
+	const op = "storage.postgres.SaveBatchURL"
+
+	// The data-modifying CTE is not visible to the outer query, so public.urls there
+	// only holds the rows that existed before the statement, while inserted holds the new ones.
+	const insertBatch = `
+		WITH input AS (
+			SELECT code, url, expires_at, max_clicks, password_hash, ord
+			FROM unnest($1::text[], $2::text[], $4::timestamptz[], $5::bigint[], $6::text[])
+				WITH ORDINALITY AS t(code, url, expires_at, max_clicks, password_hash, ord)
+		), inserted AS (
+			INSERT INTO public.urls (code, url, user_id, expires_at, max_clicks, password_hash)
+			SELECT code, url, $3, expires_at, max_clicks, password_hash FROM input ORDER BY ord
+			ON CONFLICT (url) DO NOTHING
+			RETURNING code, url
+		)
+		SELECT input.ord, COALESCE(inserted.code, urls.code)
+		FROM input
+		LEFT JOIN inserted ON inserted.url = input.url
+		LEFT JOIN public.urls ON urls.url = input.url
+		ORDER BY input.ord`
 
-	tx, err := s.db.Begin()
+	codes := make([]string, 0, len(*dto))
+	urls := make([]string, 0, len(*dto))
+	expiresAt := make([]*time.Time, 0, len(*dto))
+	maxClicks := make([]*int64, 0, len(*dto))
+	passwordHashes := make([]*string, 0, len(*dto))
+	for _, urlDTO := range *dto {
+		codes = append(codes, urlDTO.ShortCode)
+		urls = append(urls, urlDTO.OriginalURL)
+		expiresAt = append(expiresAt, nullTime(urlDTO.ExpiresAt))
+		maxClicks = append(maxClicks, nullInt(urlDTO.MaxClicks))
+		passwordHashes = append(passwordHashes, nullString(urlDTO.PasswordHash))
+	}
+
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("can't begin transaction: %w", err)
 	}
 
 	defer func() {
-		if errRollback := tx.Rollback(); errRollback != nil {
+		if errRollback := tx.Rollback(ctx); errRollback != nil {
 			slog.Error("transaction rollback error", sl.Err(errRollback))
 		}
 	}()
 
-	for _, urlDTO := range *dto {
-		_, errSaveURL := s.SaveURL(ctx, urlDTO.ShortCode, urlDTO.OriginalURL, userID)
-		if errSaveURL != nil {
-			if errors.Is(errSaveURL, storage.ErrURLOrCodeExists) {
-				mURL, errGetURL := s.GetURLByURL(ctx, urlDTO.OriginalURL)
-				if errGetURL != nil {
-					return nil, fmt.Errorf("failed to retrieve URL: %w", errGetURL)
-				}
+	rows, err := tx.Query(ctx, insertBatch, codes, urls, userID, expiresAt, maxClicks, passwordHashes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, batchError(err))
+	}
 
-				entities = append(entities, repository.BatchURL{
-					CorrelationID: urlDTO.CorrelationID,
-					ShortCode:     mURL.Code,
-				})
-				continue
-			} else {
-				return nil, fmt.Errorf("failed to save URL: %w", errSaveURL)
-			}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	entities := make([]repository.BatchURL, 0, len(*dto))
+
+	for rows.Next() {
+		var (
+			ord  int
+			code string
+		)
+		if err = rows.Scan(&ord, &code); err != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
 		}
 
 		entities = append(entities, repository.BatchURL{
-			CorrelationID: urlDTO.CorrelationID,
-			ShortCode:     urlDTO.ShortCode,
+			CorrelationID: (*dto)[ord-1].CorrelationID,
+			ShortCode:     code,
 		})
 	}
 
-	err = tx.Commit()
-	if err != nil {
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, batchError(err))
+	}
+
+	if err = tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("can't commit transaction: %w", err)
 	}
 
 	return &entities, nil
 }
 
+// batchError maps a unique violation, which can only be caused by a duplicate short code
+// since URL conflicts are skipped, to storage.ErrURLOrCodeExists.
+func batchError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		return storage.ErrURLOrCodeExists
+	}
+
+	return err
+}
+
 // GetURLByID retrieves a URL from the database using its code.
 // It returns the URL corresponding to the provided code or an error if no matching URL is found.
 func (s *Storage) GetURLByID(ctx context.Context, code string) (models.URL, error) {
-	const selectByCode = "SELECT id, code, url, user_id, is_deleted FROM urls WHERE code=$1"
+	const selectByCode = `
+		SELECT id, code, url, user_id, is_deleted, is_disabled, expires_at, max_clicks, clicks, password_hash
+		FROM urls WHERE code=$1`
 
-	row := s.db.QueryRowContext(ctx, selectByCode, code)
+	row := s.db.QueryRow(ctx, selectByCode, code)
 
 	return s.scan(row, "storage.postgres.GetURLByID")
 }
@@ -178,9 +267,11 @@ func (s *Storage) GetURLByID(ctx context.Context, code string) (models.URL, erro
 // GetURLByURL retrieves a URL from the database using the full URL.
 // It returns the URL corresponding to the provided URL or an error if no matching URL is found.
 func (s *Storage) GetURLByURL(ctx context.Context, url string) (models.URL, error) {
-	const selectByURL = "SELECT id, code, url, user_id, is_deleted FROM urls WHERE url=$1"
+	const selectByURL = `
+		SELECT id, code, url, user_id, is_deleted, is_disabled, expires_at, max_clicks, clicks, password_hash
+		FROM urls WHERE url=$1`
 
-	row := s.db.QueryRowContext(ctx, selectByURL, url)
+	row := s.db.QueryRow(ctx, selectByURL, url)
 
 	return s.scan(row, "storage.postgres.GetURLByURL")
 }
@@ -189,9 +280,11 @@ func (s *Storage) GetURLByURL(ctx context.Context, url string) (models.URL, erro
 // It returns a list of URLs for the specified user or an error if no URLs are found.
 func (s *Storage) GetUserURLs(ctx context.Context, userID string) ([]models.URL, error) {
 	const op = "storage.postgres.GetUserURLs"
-	const selectByUserID = "SELECT id, code, url, user_id, is_deleted FROM urls WHERE user_id=$1"
+	const selectByUserID = `
+		SELECT id, code, url, user_id, is_deleted, is_disabled, expires_at, max_clicks, clicks, password_hash
+		FROM urls WHERE user_id=$1`
 
-	rows, err := s.db.QueryContext(ctx, selectByUserID, userID)
+	rows, err := s.db.Query(ctx, selectByUserID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user URLs [%s]: %w", op, err)
 	}
@@ -205,8 +298,8 @@ func (s *Storage) GetUserURLs(ctx context.Context, userID string) ([]models.URL,
 	var urls []models.URL
 
 	for rows.Next() {
-		var url models.URL
-		if err := rows.Scan(&url.ID, &url.Code, &url.URL, &url.UserID, &url.IsDeleted); err != nil {
+		url, err := scanURL(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
 		}
 		urls = append(urls, url)
@@ -219,35 +312,203 @@ func (s *Storage) GetUserURLs(ctx context.Context, userID string) ([]models.URL,
 	return urls, nil
 }
 
+// GetServiceStats counts the stored URLs, including the soft-deleted ones, and the users that own them.
+func (s *Storage) GetServiceStats(ctx context.Context) (models.ServiceStats, error) {
+	const op = "storage.postgres.GetServiceStats"
+	const selectStats = "SELECT count(*), count(DISTINCT user_id) FROM public.urls"
+
+	var stats models.ServiceStats
+
+	if err := s.db.QueryRow(ctx, selectStats).Scan(&stats.URLs, &stats.Users); err != nil {
+		return models.ServiceStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
 // DeleteShortURLs marks URLs as deleted for a given user in the database.
 // It updates the `is_deleted` field to true for each of the provided short URLs.
 func (s *Storage) DeleteShortURLs(ctx context.Context, urls []string, userID string) error {
 	const op = "storage.postgres.DeleteShortURLs"
 	const deleteURLs = "UPDATE public.urls SET is_deleted = true WHERE user_id = $1 AND code = ANY($2)"
 
-	stmt, err := s.db.Prepare(deleteURLs)
+	err := s.db.Exec(ctx, deleteURLs, userID, urls)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("can't execute DeleteShortURLs %s: %w", op, err)
 	}
+
+	return nil
+}
+
+// RestoreShortURLs undoes the deletion of the URLs of a given user in the database.
+// It updates the `is_deleted` field to false for each of the provided short URLs that is deleted
+// and returns the URLs restored.
+func (s *Storage) RestoreShortURLs(ctx context.Context, urls []string, userID string) ([]models.URL, error) {
+	const op = "storage.postgres.RestoreShortURLs"
+	const restoreURLs = `
+		UPDATE public.urls SET is_deleted = false
+		WHERE user_id = $1 AND code = ANY($2) AND is_deleted
+		RETURNING id, code, url, user_id, is_deleted, is_disabled, expires_at, max_clicks, clicks, password_hash`
+
+	rows, err := s.db.Query(ctx, restoreURLs, userID, urls)
+	if err != nil {
+		return nil, fmt.Errorf("can't execute RestoreShortURLs %s: %w", op, err)
+	}
+
 	defer func() {
-		if errStmtClose := stmt.Close(); errStmtClose != nil {
-			slog.Error("prepare sql error", sl.Err(errStmtClose))
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
 		}
 	}()
 
-	_, err = stmt.ExecContext(ctx, userID, pq.Array(urls))
+	var restored []models.URL
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
+		}
+		restored = append(restored, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return restored, nil
+}
+
+// PurgeExpiredURLs deletes the URLs that expired at or before now, including soft-deleted ones,
+// and returns the number of deleted rows. Their codes and original URLs become free again.
+func (s *Storage) PurgeExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.postgres.PurgeExpiredURLs"
+	const purgeURLs = `
+		WITH purged AS (
+			DELETE FROM public.urls WHERE expires_at <= $1 RETURNING 1
+		)
+		SELECT count(*) FROM purged`
+
+	var purged int64
+	if err := s.db.QueryRow(ctx, purgeURLs, now).Scan(&purged); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// ConsumeClick uses up one redirect of a click-limited URL. The check and the increment
+// run as a single conditional update, so concurrent redirects never exceed the limit.
+// It returns false if the URL does not exist, is not click-limited or has no redirects left.
+func (s *Storage) ConsumeClick(ctx context.Context, code string) (bool, error) {
+	const op = "storage.postgres.ConsumeClick"
+	const consumeClick = `
+		UPDATE public.urls SET clicks = clicks + 1
+		WHERE code = $1 AND max_clicks IS NOT NULL AND clicks < max_clicks
+		RETURNING clicks`
+
+	var clicks int64
+	if err := s.db.QueryRow(ctx, consumeClick, code).Scan(&clicks); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// SaveClicks inserts the clicks with a single multi-row statement. Clicks of URLs
+// that were purged in the meantime are skipped by the join.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []models.Click) error {
+	const op = "storage.postgres.SaveClicks"
+	const insertClicks = `
+		INSERT INTO public.clicks (url_id, code, clicked_at, referrer, user_agent, ip_hash)
+		SELECT t.url_id, t.code, t.clicked_at, t.referrer, t.user_agent, t.ip_hash
+		FROM unnest($1::integer[], $2::text[], $3::timestamptz[], $4::text[], $5::text[], $6::text[])
+			AS t(url_id, code, clicked_at, referrer, user_agent, ip_hash)
+		JOIN public.urls ON urls.id = t.url_id`
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	urlIDs := make([]int64, 0, len(clicks))
+	codes := make([]string, 0, len(clicks))
+	times := make([]time.Time, 0, len(clicks))
+	referrers := make([]*string, 0, len(clicks))
+	userAgents := make([]*string, 0, len(clicks))
+	ipHashes := make([]*string, 0, len(clicks))
+	for _, click := range clicks {
+		urlIDs = append(urlIDs, click.URLID)
+		codes = append(codes, click.Code)
+		times = append(times, click.Time)
+		referrers = append(referrers, nullString(click.Referrer))
+		userAgents = append(userAgents, nullString(click.UserAgent))
+		ipHashes = append(ipHashes, nullString(click.IPHash))
+	}
+
+	err := s.db.Exec(ctx, insertClicks, urlIDs, codes, times, referrers, userAgents, ipHashes)
 	if err != nil {
-		return fmt.Errorf("can't execute DeleteShortURLs %s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// GetClickStats aggregates the clicks of the URL with the given ID by interval.
+// The buckets are computed in UTC.
+func (s *Storage) GetClickStats(
+	ctx context.Context,
+	urlID int64,
+	interval models.StatsInterval,
+) (models.ClickStats, error) {
+	const op = "storage.postgres.GetClickStats"
+	const selectTotals = "SELECT count(*), count(DISTINCT ip_hash) FROM public.clicks WHERE url_id = $1"
+	const selectBuckets = `
+		SELECT date_trunc($2, clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS bucket, count(*)
+		FROM public.clicks
+		WHERE url_id = $1
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	var stats models.ClickStats
+
+	if err := s.db.QueryRow(ctx, selectTotals, urlID).Scan(&stats.Total, &stats.UniqueVisitors); err != nil {
+		return models.ClickStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, selectBuckets, urlID, string(interval))
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err = rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			return models.ClickStats{}, fmt.Errorf("failed to scan row[%s]: %w", op, err)
+		}
+		bucket.Start = bucket.Start.UTC()
+		stats.Buckets = append(stats.Buckets, bucket)
+	}
+
+	if err = rows.Err(); err != nil {
+		return models.ClickStats{}, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return stats, nil
+}
+
 // scan is a helper function that scans a row from the database and maps it to a models.URL.
 // It returns the scanned URL or an error if the scan operation fails.
-func (s *Storage) scan(row *sql.Row, op string) (models.URL, error) {
-	var modelURL models.URL
-	err := row.Scan(&modelURL.ID, &modelURL.Code, &modelURL.URL, &modelURL.UserID, &modelURL.IsDeleted)
+func (s *Storage) scan(row row, op string) (models.URL, error) {
+	modelURL, err := scanURL(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.URL{}, nil
@@ -258,3 +519,562 @@ func (s *Storage) scan(row *sql.Row, op string) (models.URL, error) {
 
 	return modelURL, nil
 }
+
+// scanURL maps the columns selected by the URL queries to a models.URL.
+func scanURL(row row) (models.URL, error) {
+	var (
+		modelURL     models.URL
+		expiresAt    sql.NullTime
+		maxClicks    sql.NullInt64
+		passwordHash sql.NullString
+	)
+	err := row.Scan(
+		&modelURL.ID, &modelURL.Code, &modelURL.URL, &modelURL.UserID, &modelURL.IsDeleted, &modelURL.IsDisabled,
+		&expiresAt, &maxClicks, &modelURL.Clicks, &passwordHash,
+	)
+	if err != nil {
+		return models.URL{}, err
+	}
+
+	modelURL.ExpiresAt = expiresAt.Time
+	modelURL.MaxClicks = maxClicks.Int64
+	modelURL.PasswordHash = passwordHash.String
+
+	return modelURL, nil
+}
+
+// nullTime maps the zero time, which means "never", to a NULL timestamp.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// nullInt maps zero, which means "no limit", to a NULL integer.
+func nullInt(n int64) *int64 {
+	if n == 0 {
+		return nil
+	}
+
+	return &n
+}
+
+// nullString maps the empty string, which means "not set", to a NULL text.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// SaveAPIKey inserts a new API key. The scopes are stored as a comma-separated list.
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	const op = "storage.postgres.SaveAPIKey"
+	const insertKey = `
+		INSERT INTO public.api_keys (id, user_id, name, prefix, hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	err := s.db.Exec(ctx, insertKey,
+		key.ID, key.UserID, nullString(key.Name), key.Prefix, key.Hash, joinScopes(key.Scopes), key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the active API key with the given hash.
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	const op = "storage.postgres.GetAPIKeyByHash"
+	const selectKey = `
+		SELECT id, user_id, name, prefix, hash, scopes, created_at
+		FROM public.api_keys
+		WHERE hash = $1 AND revoked_at IS NULL`
+
+	key, err := scanAPIKey(s.db.QueryRow(ctx, selectKey, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, storage.ErrAPIKeyNotFound
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// GetUserAPIKeys retrieves the active API keys of a user, oldest first.
+func (s *Storage) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	const op = "storage.postgres.GetUserAPIKeys"
+	const selectKeys = `
+		SELECT id, user_id, name, prefix, hash, scopes, created_at
+		FROM public.api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at, id`
+
+	rows, err := s.db.Query(ctx, selectKeys, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, errScan := scanAPIKey(rows)
+		if errScan != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, errScan)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the active API key with the given ID owned by the user.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id string, userID string, now time.Time) error {
+	const op = "storage.postgres.RevokeAPIKey"
+	const revokeKey = `
+		UPDATE public.api_keys SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING id`
+
+	var revoked string
+	if err := s.db.QueryRow(ctx, revokeKey, id, userID, now).Scan(&revoked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrAPIKeyNotFound
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// scanAPIKey maps the columns selected by the API key queries to a models.APIKey.
+func scanAPIKey(row row) (models.APIKey, error) {
+	var (
+		key    models.APIKey
+		name   sql.NullString
+		scopes string
+	)
+	if err := row.Scan(&key.ID, &key.UserID, &name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt); err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.Name = name.String
+	for _, scope := range strings.Split(scopes, ",") {
+		key.Scopes = append(key.Scopes, models.APIKeyScope(scope))
+	}
+
+	return key, nil
+}
+
+// joinScopes joins the scopes into the comma-separated list stored in the scopes column.
+func joinScopes(scopes []models.APIKeyScope) string {
+	parts := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		parts = append(parts, string(scope))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// CreateUser inserts a new account. A taken login or ID is reported as storage.ErrUserExists.
+func (s *Storage) CreateUser(ctx context.Context, user models.User) error {
+	const op = "storage.postgres.CreateUser"
+	const insertUser = `
+		INSERT INTO public.users (id, login, password_hash, role, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	err := s.db.Exec(ctx, insertUser, user.ID, user.Login, user.PasswordHash, userRole(user.Role), user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return storage.ErrUserExists
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetUserByLogin retrieves the account with the given login.
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (models.User, error) {
+	const selectUser = "SELECT id, login, password_hash, role, created_at FROM public.users WHERE login = $1"
+
+	return s.scanUser(s.db.QueryRow(ctx, selectUser, login), "storage.postgres.GetUserByLogin")
+}
+
+// GetUserByID retrieves the account with the given ID.
+func (s *Storage) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	const selectUser = "SELECT id, login, password_hash, role, created_at FROM public.users WHERE id = $1"
+
+	return s.scanUser(s.db.QueryRow(ctx, selectUser, id), "storage.postgres.GetUserByID")
+}
+
+// SetUserRole changes the role of the account with the given ID.
+func (s *Storage) SetUserRole(ctx context.Context, id string, role models.Role) error {
+	const op = "storage.postgres.SetUserRole"
+	const updateRole = "UPDATE public.users SET role = $2 WHERE id = $1 RETURNING id"
+
+	var updated string
+	if err := s.db.QueryRow(ctx, updateRole, id, userRole(role)).Scan(&updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrUserNotFound
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReassignUser moves the URLs and the active API keys of fromUserID over to toUserID in one
+// transaction and returns the number of URLs moved.
+func (s *Storage) ReassignUser(ctx context.Context, fromUserID string, toUserID string) (int64, error) {
+	const op = "storage.postgres.ReassignUser"
+	const reassignURLs = `
+		WITH moved AS (
+			UPDATE public.urls SET user_id = $2 WHERE user_id = $1 RETURNING 1
+		)
+		SELECT count(*) FROM moved`
+	const reassignKeys = "UPDATE public.api_keys SET user_id = $2 WHERE user_id = $1 AND revoked_at IS NULL"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("can't begin transaction: %w", err)
+	}
+
+	defer func() {
+		if errRollback := tx.Rollback(ctx); errRollback != nil {
+			slog.Error("transaction rollback error", sl.Err(errRollback))
+		}
+	}()
+
+	var moved int64
+	if err = tx.QueryRow(ctx, reassignURLs, fromUserID, toUserID).Scan(&moved); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Exec(ctx, reassignKeys, fromUserID, toUserID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("can't commit transaction: %w", err)
+	}
+
+	return moved, nil
+}
+
+// scanUser maps the columns selected by the account queries to a models.User.
+// A missing row is reported as storage.ErrUserNotFound.
+func (s *Storage) scanUser(row row, op string) (models.User, error) {
+	var user models.User
+	if err := row.Scan(&user.ID, &user.Login, &user.PasswordHash, &user.Role, &user.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, storage.ErrUserNotFound
+		}
+
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// userRole maps the empty role to models.RoleUser, the default of the role column.
+func userRole(role models.Role) models.Role {
+	if role == "" {
+		return models.RoleUser
+	}
+
+	return role
+}
+
+// FindURLs retrieves the URLs of all users matching the filter, in the order of their IDs.
+func (s *Storage) FindURLs(ctx context.Context, filter models.URLFilter) ([]models.URL, error) {
+	const op = "storage.postgres.FindURLs"
+	const selectURLs = `
+		SELECT id, code, url, user_id, is_deleted, is_disabled, expires_at, max_clicks, clicks, password_hash
+		FROM public.urls
+		WHERE ($1::text = '' OR code = $1::text)
+		  AND ($2::text = '' OR url = $2::text)
+		  AND ($3::text = '' OR user_id::text = $3::text)
+		  AND ($4::boolean IS NULL OR is_deleted = $4)
+		  AND ($5::boolean IS NULL OR is_disabled = $5)
+		ORDER BY id
+		LIMIT $6 OFFSET $7`
+
+	rows, err := s.db.Query(ctx, selectURLs,
+		filter.Code, filter.URL, filter.UserID, filter.Deleted, filter.Disabled,
+		nullInt(int64(filter.Limit)), filter.Offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	var urls []models.URL
+	for rows.Next() {
+		url, errScan := scanURL(rows)
+		if errScan != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, errScan)
+		}
+		urls = append(urls, url)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return urls, nil
+}
+
+// SetURLDisabled disables or re-enables the URL with the given code.
+// It returns false if there is no such URL.
+func (s *Storage) SetURLDisabled(ctx context.Context, code string, disabled bool) (bool, error) {
+	const op = "storage.postgres.SetURLDisabled"
+	const updateURL = "UPDATE public.urls SET is_disabled = $2 WHERE code = $1 RETURNING id"
+
+	var id int64
+	if err := s.db.QueryRow(ctx, updateURL, code, disabled).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// PurgeUserURLs deletes all URLs of the user, including soft-deleted ones, and returns the number
+// of deleted rows.
+func (s *Storage) PurgeUserURLs(ctx context.Context, userID string) (int64, error) {
+	const op = "storage.postgres.PurgeUserURLs"
+	const purgeURLs = `
+		WITH purged AS (
+			DELETE FROM public.urls WHERE user_id = $1 RETURNING 1
+		)
+		SELECT count(*) FROM purged`
+
+	var purged int64
+	if err := s.db.QueryRow(ctx, purgeURLs, userID).Scan(&purged); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purged, nil
+}
+
+// SaveAuditEntry inserts an entry into the audit trail.
+func (s *Storage) SaveAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	const op = "storage.postgres.SaveAuditEntry"
+	const insertEntry = `
+		INSERT INTO public.admin_audit (created_at, actor_id, action, target, details)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	err := s.db.Exec(ctx, insertEntry, entry.Time, entry.ActorID, entry.Action, entry.Target, nullString(entry.Details))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAuditEntries retrieves the latest entries of the audit trail, newest first.
+// A limit of zero returns all entries.
+func (s *Storage) GetAuditEntries(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	const op = "storage.postgres.GetAuditEntries"
+	const selectEntries = `
+		SELECT created_at, actor_id, action, target, details
+		FROM public.admin_audit
+		ORDER BY id DESC
+		LIMIT $1`
+
+	rows, err := s.db.Query(ctx, selectEntries, nullInt(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var (
+			entry   models.AuditEntry
+			details sql.NullString
+		)
+		if err = rows.Scan(&entry.Time, &entry.ActorID, &entry.Action, &entry.Target, &details); err != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
+		}
+		entry.Details = details.String
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// SaveWebhook inserts a new webhook.
+func (s *Storage) SaveWebhook(ctx context.Context, hook models.Webhook) error {
+	const op = "storage.postgres.SaveWebhook"
+	const insertWebhook = `
+		INSERT INTO public.webhooks (id, user_id, url, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	err := s.db.Exec(ctx, insertWebhook, hook.ID, hook.UserID, hook.URL, hook.Secret, hook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetUserWebhooks retrieves the active webhooks of a user, oldest first.
+func (s *Storage) GetUserWebhooks(ctx context.Context, userID string) ([]models.Webhook, error) {
+	const op = "storage.postgres.GetUserWebhooks"
+	const selectWebhooks = `
+		SELECT id, user_id, url, secret, created_at
+		FROM public.webhooks
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at, id`
+
+	rows, err := s.db.Query(ctx, selectWebhooks, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	var hooks []models.Webhook
+	for rows.Next() {
+		var hook models.Webhook
+		if err = rows.Scan(&hook.ID, &hook.UserID, &hook.URL, &hook.Secret, &hook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook deletes the active webhook with the given ID owned by the user.
+func (s *Storage) DeleteWebhook(ctx context.Context, id string, userID string, now time.Time) error {
+	const op = "storage.postgres.DeleteWebhook"
+	const deleteWebhook = `
+		UPDATE public.webhooks SET deleted_at = $3
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		RETURNING id`
+
+	var deleted string
+	if err := s.db.QueryRow(ctx, deleteWebhook, id, userID, now).Scan(&deleted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrWebhookNotFound
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveDeadLetter records an event that could not be delivered. The event is stored as JSON.
+func (s *Storage) SaveDeadLetter(ctx context.Context, letter models.DeadLetter) error {
+	const op = "storage.postgres.SaveDeadLetter"
+	const insertLetter = `
+		INSERT INTO public.webhook_dead_letters (created_at, webhook_id, user_id, url, event, attempts, error)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7)`
+
+	event, err := json.Marshal(letter.Event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.Exec(ctx, insertLetter,
+		letter.Time, letter.WebhookID, letter.UserID, letter.URL, string(event), letter.Attempts, letter.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetDeadLetters retrieves the latest dead letters of the webhooks of a user, newest first.
+// A limit of zero returns all of them.
+func (s *Storage) GetDeadLetters(ctx context.Context, userID string, limit int) ([]models.DeadLetter, error) {
+	const op = "storage.postgres.GetDeadLetters"
+	const selectLetters = `
+		SELECT created_at, webhook_id, user_id, url, event::text, attempts, error
+		FROM public.webhook_dead_letters
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2`
+
+	rows, err := s.db.Query(ctx, selectLetters, userID, nullInt(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("rows close error", sl.Err(err))
+		}
+	}()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		var (
+			letter models.DeadLetter
+			event  string
+		)
+		err = rows.Scan(&letter.Time, &letter.WebhookID, &letter.UserID, &letter.URL, &event, &letter.Attempts, &letter.Error)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row[%s]: %w", op, err)
+		}
+		if err = json.Unmarshal([]byte(event), &letter.Event); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		letters = append(letters, letter)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered during rows iteration [%s]: %w", op, err)
+	}
+
+	return letters, nil
+}
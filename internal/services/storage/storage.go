@@ -11,6 +11,18 @@ import (
 // ErrURLOrCodeExists is an error that is returned when a URL or a short code already exists in the storage.
 var ErrURLOrCodeExists = errors.New("url or code exists")
 
+// ErrAPIKeyNotFound is returned when there is no active API key matching the lookup.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// ErrUserExists is returned when registering a login that is already taken.
+var ErrUserExists = errors.New("user exists")
+
+// ErrUserNotFound is returned when there is no account matching the lookup.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrWebhookNotFound is returned when there is no webhook matching the lookup.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
 // ExistsURLError is an error type that provides details about an existing URL or short code conflict.
 // It includes the original URL, the conflicting short code, and the underlying error that caused the conflict.
 type ExistsURLError struct {
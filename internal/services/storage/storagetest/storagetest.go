@@ -0,0 +1,1191 @@
+// Package storagetest provides a conformance suite for urlservice.URLStorage implementations.
+// Every backend runs the same contract tests by calling Run from its own tests, so the
+// semantics the handlers rely on are kept in line across storages.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/handlers/url/servicestats"
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/account"
+	"github.com/vadicheck/shorturl/internal/services/admin"
+	"github.com/vadicheck/shorturl/internal/services/analytics"
+	"github.com/vadicheck/shorturl/internal/services/apikey"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+	"github.com/vadicheck/shorturl/internal/services/urlservice"
+	"github.com/vadicheck/shorturl/internal/services/webhook"
+)
+
+// Factory returns an empty storage for a single test.
+// It should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) urlservice.URLStorage
+
+// Run runs the conformance suite against the storages created by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s urlservice.URLStorage)
+	}{
+		{name: "Ping", test: testPing},
+		{name: "SaveAndGet", test: testSaveAndGet},
+		{name: "NotFound", test: testNotFound},
+		{name: "DuplicateURL", test: testDuplicateURL},
+		{name: "DuplicateCode", test: testDuplicateCode},
+		{name: "DuplicateURLAndCode", test: testDuplicateURLAndCode},
+		{name: "UserIsolation", test: testUserIsolation},
+		{name: "SoftDelete", test: testSoftDelete},
+		{name: "Restore", test: testRestore},
+		{name: "BatchOrder", test: testBatchOrder},
+		{name: "BatchExisting", test: testBatchExisting},
+		{name: "BatchCodeConflict", test: testBatchCodeConflict},
+		{name: "ConcurrentSave", test: testConcurrentSave},
+		{name: "Expiration", test: testExpiration},
+		{name: "PurgeExpired", test: testPurgeExpired},
+		{name: "ClickLimit", test: testClickLimit},
+		{name: "ConcurrentClicks", test: testConcurrentClicks},
+		{name: "PasswordHash", test: testPasswordHash},
+		{name: "ClickStats", test: analyticsTest(testClickStats)},
+		{name: "ServiceStats", test: serviceStatsTest(testServiceStats)},
+		{name: "APIKeys", test: apiKeyTest(testAPIKeys)},
+		{name: "Users", test: accountTest(testUsers)},
+		{name: "ReassignUser", test: accountTest(testReassignUser)},
+		{name: "FindURLs", test: adminTest(testFindURLs)},
+		{name: "DisableURL", test: adminTest(testDisableURL)},
+		{name: "PurgeUserURLs", test: adminTest(testPurgeUserURLs)},
+		{name: "UserRole", test: adminTest(testUserRole)},
+		{name: "AuditTrail", test: adminTest(testAuditTrail)},
+		{name: "Webhooks", test: webhookTest(testWebhooks)},
+		{name: "DeadLetters", test: webhookTest(testDeadLetters)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+func testPing(t *testing.T, s urlservice.URLStorage) {
+	assert.NoError(t, s.PingContext(context.Background()))
+}
+
+func testSaveAndGet(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	id, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Positive(t, id)
+
+	byCode, err := s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.Equal(t, id, byCode.ID)
+	assert.Equal(t, "code1", byCode.Code)
+	assert.Equal(t, "http://example.com/1", byCode.URL)
+	assert.Equal(t, "user1", byCode.UserID)
+	assert.False(t, byCode.IsDeleted)
+
+	byURL, err := s.GetURLByURL(ctx, "http://example.com/1")
+	require.NoError(t, err)
+	assert.Equal(t, byCode, byURL)
+
+	next, err := s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	assert.Greater(t, next, id)
+}
+
+func testNotFound(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	byCode, err := s.GetURLByID(ctx, "missing")
+	require.NoError(t, err)
+	assert.Zero(t, byCode.ID)
+	assert.Empty(t, byCode.Code)
+
+	byURL, err := s.GetURLByURL(ctx, "http://example.com/missing")
+	require.NoError(t, err)
+	assert.Zero(t, byURL.ID)
+	assert.Empty(t, byURL.Code)
+
+	urls, err := s.GetUserURLs(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	assert.NoError(t, s.DeleteShortURLs(ctx, []string{"missing"}, "nobody"))
+}
+
+func testDuplicateURL(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	// The URL is unique across users.
+	_, err = s.SaveURL(ctx, "code2", "http://example.com", "user2", repository.URLSettings{})
+
+	var existsErr *storage.ExistsURLError
+	require.ErrorAs(t, err, &existsErr)
+	assert.Equal(t, "http://example.com", existsErr.OriginalURL)
+	assert.Equal(t, "code1", existsErr.ShortCode)
+
+	stored, err := s.GetURLByID(ctx, "code2")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Code)
+}
+
+func testDuplicateCode(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(ctx, "code1", "http://example.com/2", "user1", repository.URLSettings{})
+	require.ErrorIs(t, err, storage.ErrURLOrCodeExists)
+
+	var existsErr *storage.ExistsURLError
+	assert.False(t, errors.As(err, &existsErr))
+
+	stored, err := s.GetURLByURL(ctx, "http://example.com/2")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Code)
+}
+
+func testDuplicateURLAndCode(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	// The existing URL is reported even though the code is taken as well.
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/1", "user1", repository.URLSettings{})
+
+	var existsErr *storage.ExistsURLError
+	require.ErrorAs(t, err, &existsErr)
+	assert.Equal(t, "code1", existsErr.ShortCode)
+}
+
+func testUserIsolation(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code3", "http://example.com/3", "user2", repository.URLSettings{})
+	require.NoError(t, err)
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+
+	codes := make([]string, 0, len(urls))
+	for _, url := range urls {
+		assert.Equal(t, "user1", url.UserID)
+		codes = append(codes, url.Code)
+	}
+	assert.ElementsMatch(t, []string{"code1", "code2"}, codes)
+
+	// Another user cannot delete the links.
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code1", "code3"}, "user2"))
+
+	stored, err := s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.False(t, stored.IsDeleted)
+}
+
+func testSoftDelete(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code1", "missing"}, "user1"))
+	// Deleting twice is a no-op.
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code1"}, "user1"))
+
+	deleted, err := s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/1", deleted.URL)
+	assert.True(t, deleted.IsDeleted)
+
+	kept, err := s.GetURLByID(ctx, "code2")
+	require.NoError(t, err)
+	assert.False(t, kept.IsDeleted)
+
+	// A soft-deleted link still owns its URL.
+	_, err = s.SaveURL(ctx, "code3", "http://example.com/1", "user1", repository.URLSettings{})
+
+	var existsErr *storage.ExistsURLError
+	require.ErrorAs(t, err, &existsErr)
+	assert.Equal(t, "code1", existsErr.ShortCode)
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+}
+
+func testRestore(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	for i, userID := range []string{"user1", "user1", "user2"} {
+		code := fmt.Sprintf("code%d", i+1)
+		_, err := s.SaveURL(ctx, code, "http://example.com/"+code, userID, repository.URLSettings{})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code1"}, "user1"))
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code3"}, "user2"))
+
+	// Only the deleted links of the user are restored.
+	restored, err := s.RestoreShortURLs(ctx, []string{"code1", "code2", "code3", "missing"}, "user1")
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, "code1", restored[0].Code)
+	assert.Equal(t, "http://example.com/code1", restored[0].URL)
+	assert.Equal(t, "user1", restored[0].UserID)
+	assert.False(t, restored[0].IsDeleted)
+
+	stored, err := s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.False(t, stored.IsDeleted)
+
+	foreign, err := s.GetURLByID(ctx, "code3")
+	require.NoError(t, err)
+	assert.True(t, foreign.IsDeleted, "another user's link stays deleted")
+
+	// Restoring twice is a no-op.
+	restored, err = s.RestoreShortURLs(ctx, []string{"code1"}, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	// A restored link can be deleted again.
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"code1"}, "user1"))
+
+	stored, err = s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.True(t, stored.IsDeleted)
+}
+
+func testBatchOrder(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	dto := make([]repository.BatchURLDto, 0, 20)
+	want := make([]repository.BatchURL, 0, 20)
+	for i := 20; i > 0; i-- {
+		code := fmt.Sprintf("code%d", i)
+		dto = append(dto, repository.BatchURLDto{
+			CorrelationID: fmt.Sprintf("corr%d", i),
+			OriginalURL:   fmt.Sprintf("http://example.com/%d", i),
+			ShortCode:     code,
+		})
+		want = append(want, repository.BatchURL{CorrelationID: fmt.Sprintf("corr%d", i), ShortCode: code})
+	}
+
+	batch, err := s.SaveBatchURL(ctx, &dto, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, want, *batch)
+
+	for _, d := range dto {
+		stored, err := s.GetURLByID(ctx, d.ShortCode)
+		require.NoError(t, err)
+		assert.Equal(t, d.OriginalURL, stored.URL)
+		assert.Equal(t, "user1", stored.UserID)
+	}
+}
+
+func testBatchExisting(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "old", "http://example.com/old", "user2", repository.URLSettings{})
+	require.NoError(t, err)
+
+	dto := []repository.BatchURLDto{
+		{CorrelationID: "1", OriginalURL: "http://example.com/new", ShortCode: "new1"},
+		{CorrelationID: "2", OriginalURL: "http://example.com/old", ShortCode: "new2"},
+		{CorrelationID: "3", OriginalURL: "http://example.com/new", ShortCode: "new3"},
+	}
+
+	batch, err := s.SaveBatchURL(ctx, &dto, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []repository.BatchURL{
+		{CorrelationID: "1", ShortCode: "new1"},
+		{CorrelationID: "2", ShortCode: "old"},
+		{CorrelationID: "3", ShortCode: "new1"},
+	}, *batch)
+
+	for _, code := range []string{"new2", "new3"} {
+		stored, err := s.GetURLByID(ctx, code)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Code, code)
+	}
+
+	old, err := s.GetURLByID(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, "user2", old.UserID)
+}
+
+func testBatchCodeConflict(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "taken", "http://example.com/taken", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	dto := []repository.BatchURLDto{
+		{CorrelationID: "1", OriginalURL: "http://example.com/1", ShortCode: "code1"},
+		{CorrelationID: "2", OriginalURL: "http://example.com/2", ShortCode: "taken"},
+	}
+
+	_, err = s.SaveBatchURL(ctx, &dto, "user1")
+	require.ErrorIs(t, err, storage.ErrURLOrCodeExists)
+
+	// Nothing from the failed batch is stored.
+	stored, err := s.GetURLByID(ctx, "code1")
+	require.NoError(t, err)
+	assert.Empty(t, stored.Code)
+}
+
+func testConcurrentSave(t *testing.T, s urlservice.URLStorage) {
+	const workers = 16
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	ids := make([]int64, workers)
+	errs := make([]error, workers)
+
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i], errs[i] = s.SaveURL(ctx, fmt.Sprintf("code%d", i), fmt.Sprintf("http://example.com/%d", i), "user1", repository.URLSettings{})
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int64]struct{}, workers)
+	for i := range workers {
+		require.NoError(t, errs[i])
+		seen[ids[i]] = struct{}{}
+	}
+	assert.Len(t, seen, workers)
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Len(t, urls, workers)
+}
+
+func testExpiration(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	// Whole seconds survive the microsecond precision of PostgreSQL timestamps.
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	dto := []repository.BatchURLDto{
+		{
+			CorrelationID: "1",
+			OriginalURL:   "http://example.com/3",
+			ShortCode:     "code3",
+			URLSettings:   repository.URLSettings{ExpiresAt: expiresAt},
+		},
+		{CorrelationID: "2", OriginalURL: "http://example.com/4", ShortCode: "code4"},
+	}
+	_, err = s.SaveBatchURL(ctx, &dto, "user1")
+	require.NoError(t, err)
+
+	for code, want := range map[string]time.Time{"code1": expiresAt, "code3": expiresAt} {
+		stored, err := s.GetURLByID(ctx, code)
+		require.NoError(t, err)
+		assert.True(t, want.Equal(stored.ExpiresAt), "%s expires at %s", code, stored.ExpiresAt)
+	}
+
+	for _, code := range []string{"code2", "code4"} {
+		stored, err := s.GetURLByID(ctx, code)
+		require.NoError(t, err)
+		assert.True(t, stored.ExpiresAt.IsZero(), code)
+	}
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	for _, url := range urls {
+		assert.Equal(t, url.Code == "code1" || url.Code == "code3", !url.ExpiresAt.IsZero(), url.Code)
+	}
+}
+
+func testPurgeExpired(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := s.SaveURL(ctx, "expired", "http://example.com/expired", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "deleted", "http://example.com/deleted", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteShortURLs(ctx, []string{"deleted"}, "user1"))
+	_, err = s.SaveURL(ctx, "future", "http://example.com/future", "user1",
+		repository.URLSettings{ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "forever", "http://example.com/forever", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	purged, err := s.PurgeExpiredURLs(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	for _, code := range []string{"expired", "deleted"} {
+		stored, err := s.GetURLByID(ctx, code)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Code, code)
+	}
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	// The code and the URL of a purged link are free again.
+	_, err = s.SaveURL(ctx, "expired", "http://example.com/expired", "user2", repository.URLSettings{})
+	require.NoError(t, err)
+
+	purged, err = s.PurgeExpiredURLs(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func testClickLimit(t *testing.T, s urlservice.URLStorage) {
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "limited", "http://example.com/1", "user1", repository.URLSettings{MaxClicks: 2})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "unlimited", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	dto := []repository.BatchURLDto{
+		{
+			CorrelationID: "1",
+			OriginalURL:   "http://example.com/3",
+			ShortCode:     "once",
+			URLSettings:   repository.URLSettings{MaxClicks: 1},
+		},
+	}
+	_, err = s.SaveBatchURL(ctx, &dto, "user1")
+	require.NoError(t, err)
+
+	for _, want := range []bool{true, true, false} {
+		granted, err := s.ConsumeClick(ctx, "limited")
+		require.NoError(t, err)
+		assert.Equal(t, want, granted)
+	}
+
+	stored, err := s.GetURLByID(ctx, "limited")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.MaxClicks)
+	assert.Equal(t, int64(2), stored.Clicks)
+	assert.Zero(t, stored.RemainingClicks())
+
+	stored, err = s.GetURLByID(ctx, "once")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stored.MaxClicks)
+
+	// Unlimited and unknown URLs have no clicks to consume.
+	for _, code := range []string{"unlimited", "missing"} {
+		granted, err := s.ConsumeClick(ctx, code)
+		require.NoError(t, err)
+		assert.False(t, granted, code)
+	}
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	for _, url := range urls {
+		assert.Equal(t, url.Code != "unlimited", url.IsClickLimited(), url.Code)
+	}
+}
+
+func testConcurrentClicks(t *testing.T, s urlservice.URLStorage) {
+	const (
+		workers   = 16
+		maxClicks = 5
+	)
+
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "limited", "http://example.com/1", "user1", repository.URLSettings{MaxClicks: maxClicks})
+	require.NoError(t, err)
+
+	var (
+		wg      sync.WaitGroup
+		granted = make([]bool, workers)
+		errs    = make([]error, workers)
+	)
+
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			granted[i], errs[i] = s.ConsumeClick(ctx, "limited")
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range workers {
+		require.NoError(t, errs[i])
+		if granted[i] {
+			total++
+		}
+	}
+	assert.Equal(t, maxClicks, total)
+
+	stored, err := s.GetURLByID(ctx, "limited")
+	require.NoError(t, err)
+	assert.Equal(t, int64(maxClicks), stored.Clicks)
+}
+
+func testPasswordHash(t *testing.T, s urlservice.URLStorage) {
+	const hash = "$2a$10$abcdefghijklmnopqrstuuJ1Mqn0YH7JqZ2Fq1bcFq0x4Q0s0y1qW"
+
+	ctx := context.Background()
+
+	_, err := s.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{PasswordHash: hash})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	dto := []repository.BatchURLDto{
+		{
+			CorrelationID: "1",
+			OriginalURL:   "http://example.com/3",
+			ShortCode:     "code3",
+			URLSettings:   repository.URLSettings{PasswordHash: hash},
+		},
+		{CorrelationID: "2", OriginalURL: "http://example.com/4", ShortCode: "code4"},
+	}
+	_, err = s.SaveBatchURL(ctx, &dto, "user1")
+	require.NoError(t, err)
+
+	for code, want := range map[string]string{"code1": hash, "code2": "", "code3": hash, "code4": ""} {
+		stored, err := s.GetURLByID(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, want, stored.PasswordHash, code)
+	}
+
+	urls, err := s.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	for _, url := range urls {
+		assert.Equal(t, url.Code == "code1" || url.Code == "code3", url.IsProtected(), url.Code)
+	}
+}
+
+// analyticsTest adapts a test of the click storage methods, skipping it for the storages
+// that do not implement analytics.Storage.
+func analyticsTest(test func(t *testing.T, s analytics.Storage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		clickStorage, ok := s.(analytics.Storage)
+		if !ok {
+			t.Skip("the storage does not implement analytics.Storage")
+		}
+
+		test(t, clickStorage)
+	}
+}
+
+func testClickStats(t *testing.T, s analytics.Storage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+
+	id1, err := store.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	id2, err := store.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	day := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.SaveClicks(ctx, nil))
+	require.NoError(t, s.SaveClicks(ctx, []models.Click{
+		{URLID: id1, Code: "code1", Time: day.Add(10 * time.Minute), Referrer: "https://a.example", IPHash: "a"},
+		{URLID: id1, Code: "code1", Time: day.Add(26 * time.Hour), UserAgent: "curl/8.0", IPHash: "a"},
+		{URLID: id2, Code: "code2", Time: day, IPHash: "c"},
+		{URLID: id1 + id2, Code: "unknown", Time: day, IPHash: "d"},
+	}))
+	// The second batch is older than the first one, as batches of different servers may be.
+	require.NoError(t, s.SaveClicks(ctx, []models.Click{
+		{URLID: id1, Code: "code1", Time: day.Add(50 * time.Minute), IPHash: "b"},
+		{URLID: id1, Code: "code1", Time: day.Add(2 * time.Hour)},
+	}))
+
+	stats, err := s.GetClickStats(ctx, id1, models.StatsDay)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Total)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []models.ClickBucket{
+		{Start: day, Clicks: 3},
+		{Start: day.Add(24 * time.Hour), Clicks: 1},
+	}, stats.Buckets)
+
+	stats, err = s.GetClickStats(ctx, id1, models.StatsHour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickBucket{
+		{Start: day, Clicks: 2},
+		{Start: day.Add(2 * time.Hour), Clicks: 1},
+		{Start: day.Add(26 * time.Hour), Clicks: 1},
+	}, stats.Buckets)
+
+	stats, err = s.GetClickStats(ctx, id2, models.StatsDay)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Total)
+
+	stats, err = s.GetClickStats(ctx, id1+id2, models.StatsDay)
+	require.NoError(t, err)
+	assert.Equal(t, models.ClickStats{}, stats, "clicks of unknown URLs are not stored")
+}
+
+// serviceStatsTest adapts a test of the service statistics, skipping it for the storages
+// that do not implement servicestats.URLStorage.
+func serviceStatsTest(test func(t *testing.T, s servicestats.URLStorage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		serviceStatsStorage, ok := s.(servicestats.URLStorage)
+		if !ok {
+			t.Skip("the storage does not implement servicestats.URLStorage")
+		}
+
+		test(t, serviceStatsStorage)
+	}
+}
+
+func testServiceStats(t *testing.T, s servicestats.URLStorage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+
+	stats, err := s.GetServiceStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.ServiceStats{}, stats)
+
+	_, err = store.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code2", "http://example.com/2", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code3", "http://example.com/3", "user2", repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteShortURLs(ctx, []string{"code3"}, "user2"))
+
+	stats, err = s.GetServiceStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.ServiceStats{URLs: 3, Users: 2}, stats)
+}
+
+// apiKeyTest adapts a test of the API key storage methods, skipping it for the storages
+// that do not implement apikey.Storage.
+func apiKeyTest(test func(t *testing.T, s apikey.Storage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		apiKeyStorage, ok := s.(apikey.Storage)
+		if !ok {
+			t.Skip("the storage does not implement apikey.Storage")
+		}
+
+		test(t, apiKeyStorage)
+	}
+}
+
+func testAPIKeys(t *testing.T, s apikey.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err := s.GetAPIKeyByHash(ctx, "missing")
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	keys := []models.APIKey{
+		{
+			ID:        "a9b0c3de-0000-4000-8000-000000000001",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000001",
+			Name:      "backend",
+			Prefix:    "sk_first",
+			Hash:      "hash1",
+			Scopes:    []models.APIKeyScope{models.ScopeRead, models.ScopeWrite},
+			CreatedAt: now,
+		},
+		{
+			ID:        "a9b0c3de-0000-4000-8000-000000000002",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000001",
+			Prefix:    "sk_secnd",
+			Hash:      "hash2",
+			Scopes:    []models.APIKeyScope{models.ScopeDelete},
+			CreatedAt: now.Add(time.Second),
+		},
+		{
+			ID:        "a9b0c3de-0000-4000-8000-000000000003",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000002",
+			Prefix:    "sk_third",
+			Hash:      "hash3",
+			Scopes:    []models.APIKeyScope{models.ScopeRead},
+			CreatedAt: now,
+		},
+	}
+	for _, key := range keys {
+		require.NoError(t, s.SaveAPIKey(ctx, key))
+	}
+
+	key, err := s.GetAPIKeyByHash(ctx, "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, keys[0].ID, key.ID)
+	assert.Equal(t, keys[0].UserID, key.UserID)
+	assert.Equal(t, keys[0].Name, key.Name)
+	assert.Equal(t, keys[0].Prefix, key.Prefix)
+	assert.Equal(t, keys[0].Scopes, key.Scopes)
+	assert.True(t, keys[0].CreatedAt.Equal(key.CreatedAt))
+
+	userKeys, err := s.GetUserAPIKeys(ctx, keys[0].UserID)
+	require.NoError(t, err)
+	require.Len(t, userKeys, 2)
+	assert.Equal(t, keys[0].ID, userKeys[0].ID, "keys are listed oldest first")
+	assert.Equal(t, keys[1].ID, userKeys[1].ID)
+
+	err = s.RevokeAPIKey(ctx, keys[0].ID, keys[2].UserID, now)
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound, "a key of another user cannot be revoked")
+
+	require.NoError(t, s.RevokeAPIKey(ctx, keys[0].ID, keys[0].UserID, now))
+
+	err = s.RevokeAPIKey(ctx, keys[0].ID, keys[0].UserID, now)
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound, "a key is revoked only once")
+
+	_, err = s.GetAPIKeyByHash(ctx, "hash1")
+	assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound, "a revoked key no longer authenticates")
+
+	userKeys, err = s.GetUserAPIKeys(ctx, keys[0].UserID)
+	require.NoError(t, err)
+	require.Len(t, userKeys, 1)
+	assert.Equal(t, keys[1].ID, userKeys[0].ID)
+
+	userKeys, err = s.GetUserAPIKeys(ctx, "0b9f6c4e-0000-4000-8000-000000000003")
+	require.NoError(t, err)
+	assert.Empty(t, userKeys)
+}
+
+// accountTest adapts a test of the account storage methods, skipping it for the storages
+// that do not implement account.Storage.
+func accountTest(test func(t *testing.T, s account.Storage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		accountStorage, ok := s.(account.Storage)
+		if !ok {
+			t.Skip("the storage does not implement account.Storage")
+		}
+
+		test(t, accountStorage)
+	}
+}
+
+func testUsers(t *testing.T, s account.Storage) {
+	ctx := context.Background()
+
+	user := models.User{
+		ID:           "5f0c7a52-0000-4000-8000-000000000001",
+		Login:        "alice",
+		PasswordHash: "hash",
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+
+	_, err := s.GetUserByLogin(ctx, user.Login)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	_, err = s.GetUserByID(ctx, user.ID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	require.NoError(t, s.CreateUser(ctx, user))
+
+	taken := user
+	taken.ID = "5f0c7a52-0000-4000-8000-000000000002"
+	assert.ErrorIs(t, s.CreateUser(ctx, taken), storage.ErrUserExists, "a login is unique")
+
+	for _, get := range []func() (models.User, error){
+		func() (models.User, error) { return s.GetUserByLogin(ctx, user.Login) },
+		func() (models.User, error) { return s.GetUserByID(ctx, user.ID) },
+	} {
+		got, errGet := get()
+		require.NoError(t, errGet)
+		assert.Equal(t, user.ID, got.ID)
+		assert.Equal(t, user.Login, got.Login)
+		assert.Equal(t, user.PasswordHash, got.PasswordHash)
+		assert.True(t, user.CreatedAt.Equal(got.CreatedAt))
+	}
+}
+
+func testReassignUser(t *testing.T, s account.Storage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+	keys := s.(apikey.Storage)
+
+	const (
+		anonymous = "5f0c7a52-0000-4000-8000-000000000001"
+		account   = "5f0c7a52-0000-4000-8000-000000000002"
+		other     = "5f0c7a52-0000-4000-8000-000000000003"
+	)
+
+	_, err := store.SaveURL(ctx, "code1", "http://example.com/1", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code2", "http://example.com/2", anonymous, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code3", "http://example.com/3", account, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code4", "http://example.com/4", other, repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteShortURLs(ctx, []string{"code2"}, anonymous))
+
+	require.NoError(t, keys.SaveAPIKey(ctx, models.APIKey{
+		ID:        "a9b0c3de-0000-4000-8000-000000000001",
+		UserID:    anonymous,
+		Prefix:    "sk_first",
+		Hash:      "hash1",
+		Scopes:    []models.APIKeyScope{models.ScopeRead},
+		CreatedAt: time.Now(),
+	}))
+
+	moved, err := s.ReassignUser(ctx, anonymous, account)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), moved, "deleted URLs are moved too")
+
+	urls, err := store.GetUserURLs(ctx, anonymous)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	urls, err = store.GetUserURLs(ctx, account)
+	require.NoError(t, err)
+	codes := make([]string, 0, len(urls))
+	for _, url := range urls {
+		codes = append(codes, url.Code)
+	}
+	assert.ElementsMatch(t, []string{"code1", "code2", "code3"}, codes)
+
+	url, err := store.GetURLByID(ctx, "code2")
+	require.NoError(t, err)
+	assert.Equal(t, account, url.UserID)
+	assert.True(t, url.IsDeleted)
+
+	key, err := keys.GetAPIKeyByHash(ctx, "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, account, key.UserID, "API keys are moved too")
+
+	urls, err = store.GetUserURLs(ctx, other)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	moved, err = s.ReassignUser(ctx, anonymous, account)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
+
+// adminTest adapts a test of the admin storage methods, skipping it for the storages
+// that do not implement admin.Storage.
+func adminTest(test func(t *testing.T, s admin.Storage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		adminStorage, ok := s.(admin.Storage)
+		if !ok {
+			t.Skip("the storage does not implement admin.Storage")
+		}
+
+		test(t, adminStorage)
+	}
+}
+
+func testFindURLs(t *testing.T, s admin.Storage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+
+	const (
+		user1 = "5f0c7a52-0000-4000-8000-000000000001"
+		user2 = "5f0c7a52-0000-4000-8000-000000000002"
+	)
+
+	for i, userID := range []string{user1, user2, user1, user2} {
+		_, err := store.SaveURL(ctx, fmt.Sprintf("code%d", i+1), fmt.Sprintf("http://example.com/%d", i+1),
+			userID, repository.URLSettings{})
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.DeleteShortURLs(ctx, []string{"code3"}, user1))
+
+	found, err := s.SetURLDisabled(ctx, "code4", true)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		filter models.URLFilter
+		want   []string
+	}{
+		{name: "all", filter: models.URLFilter{}, want: []string{"code1", "code2", "code3", "code4"}},
+		{name: "code", filter: models.URLFilter{Code: "code2"}, want: []string{"code2"}},
+		{name: "url", filter: models.URLFilter{URL: "http://example.com/3"}, want: []string{"code3"}},
+		{name: "user", filter: models.URLFilter{UserID: user1}, want: []string{"code1", "code3"}},
+		{name: "unknown user", filter: models.URLFilter{UserID: "unknown"}, want: nil},
+		{name: "deleted", filter: models.URLFilter{Deleted: &yes}, want: []string{"code3"}},
+		{name: "not deleted", filter: models.URLFilter{Deleted: &no}, want: []string{"code1", "code2", "code4"}},
+		{name: "disabled", filter: models.URLFilter{Disabled: &yes}, want: []string{"code4"}},
+		{name: "combined", filter: models.URLFilter{UserID: user2, Disabled: &no}, want: []string{"code2"}},
+		{name: "page", filter: models.URLFilter{Limit: 2, Offset: 1}, want: []string{"code2", "code3"}},
+		{name: "past the end", filter: models.URLFilter{Offset: 10}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, errFind := s.FindURLs(ctx, tt.filter)
+			require.NoError(t, errFind)
+
+			var codes []string
+			for _, url := range urls {
+				codes = append(codes, url.Code)
+			}
+			assert.Equal(t, tt.want, codes, "URLs are returned in the order of their IDs")
+		})
+	}
+}
+
+func testDisableURL(t *testing.T, s admin.Storage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+
+	_, err := store.SaveURL(ctx, "code1", "http://example.com/1", "user1", repository.URLSettings{})
+	require.NoError(t, err)
+
+	found, err := s.SetURLDisabled(ctx, "missing", true)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	for _, disabled := range []bool{true, true, false} {
+		found, err = s.SetURLDisabled(ctx, "code1", disabled)
+		require.NoError(t, err)
+		assert.True(t, found)
+
+		url, errGet := store.GetURLByID(ctx, "code1")
+		require.NoError(t, errGet)
+		assert.Equal(t, disabled, url.IsDisabled)
+		assert.Equal(t, "user1", url.UserID, "a disabled URL stays owned by its user")
+	}
+}
+
+func testPurgeUserURLs(t *testing.T, s admin.Storage) {
+	ctx := context.Background()
+	store := s.(urlservice.URLStorage)
+
+	const (
+		user1 = "5f0c7a52-0000-4000-8000-000000000001"
+		user2 = "5f0c7a52-0000-4000-8000-000000000002"
+	)
+
+	_, err := store.SaveURL(ctx, "code1", "http://example.com/1", user1, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code2", "http://example.com/2", user1, repository.URLSettings{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, "code3", "http://example.com/3", user2, repository.URLSettings{})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteShortURLs(ctx, []string{"code2"}, user1))
+
+	purged, err := s.PurgeUserURLs(ctx, user1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged, "deleted URLs are purged too")
+
+	urls, err := store.GetUserURLs(ctx, user1)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	urls, err = store.GetUserURLs(ctx, user2)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	_, err = store.SaveURL(ctx, "code1", "http://example.com/1", user2, repository.URLSettings{})
+	assert.NoError(t, err, "the code and URL of a purged URL are free again")
+
+	purged, err = s.PurgeUserURLs(ctx, user1)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func testUserRole(t *testing.T, s admin.Storage) {
+	ctx := context.Background()
+	store := s.(account.Storage)
+
+	user := models.User{
+		ID:           "5f0c7a52-0000-4000-8000-000000000001",
+		Login:        "alice",
+		PasswordHash: "hash",
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+	require.NoError(t, store.CreateUser(ctx, user))
+
+	got, err := s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, got.IsAdmin(), "an account is not an admin by default")
+
+	require.NoError(t, s.SetUserRole(ctx, user.ID, models.RoleAdmin))
+
+	got, err = store.GetUserByLogin(ctx, user.Login)
+	require.NoError(t, err)
+	assert.True(t, got.IsAdmin())
+	assert.Equal(t, user.PasswordHash, got.PasswordHash)
+
+	err = s.SetUserRole(ctx, "5f0c7a52-0000-4000-8000-000000000002", models.RoleAdmin)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testAuditTrail(t *testing.T, s admin.Storage) {
+	ctx := context.Background()
+
+	entries, err := s.GetAuditEntries(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, action := range []string{models.AuditDisableURL, models.AuditEnableURL, models.AuditPurgeUser} {
+		require.NoError(t, s.SaveAuditEntry(ctx, models.AuditEntry{
+			Time:    now.Add(time.Duration(i) * time.Second),
+			ActorID: "5f0c7a52-0000-4000-8000-000000000001",
+			Action:  action,
+			Target:  fmt.Sprintf("target%d", i+1),
+			Details: "details",
+		}))
+	}
+
+	entries, err = s.GetAuditEntries(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, models.AuditPurgeUser, entries[0].Action, "the newest entry comes first")
+	assert.Equal(t, "target3", entries[0].Target)
+	assert.Equal(t, "details", entries[0].Details)
+	assert.True(t, now.Add(2*time.Second).Equal(entries[0].Time))
+	assert.Equal(t, models.AuditEnableURL, entries[1].Action)
+
+	entries, err = s.GetAuditEntries(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+// webhookTest adapts a test of the webhook storage methods, skipping it for the storages
+// that do not implement webhook.Storage.
+func webhookTest(test func(t *testing.T, s webhook.Storage)) func(t *testing.T, s urlservice.URLStorage) {
+	return func(t *testing.T, s urlservice.URLStorage) {
+		webhookStorage, ok := s.(webhook.Storage)
+		if !ok {
+			t.Skip("the storage does not implement webhook.Storage")
+		}
+
+		test(t, webhookStorage)
+	}
+}
+
+func testWebhooks(t *testing.T, s webhook.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	hooks := []models.Webhook{
+		{
+			ID:        "7c1e5b2a-0000-4000-8000-000000000001",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000001",
+			URL:       "https://example.com/first",
+			Secret:    "secret1",
+			CreatedAt: now,
+		},
+		{
+			ID:        "7c1e5b2a-0000-4000-8000-000000000002",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000001",
+			URL:       "https://example.com/second",
+			Secret:    "secret2",
+			CreatedAt: now.Add(time.Second),
+		},
+		{
+			ID:        "7c1e5b2a-0000-4000-8000-000000000003",
+			UserID:    "0b9f6c4e-0000-4000-8000-000000000002",
+			URL:       "https://example.com/third",
+			Secret:    "secret3",
+			CreatedAt: now,
+		},
+	}
+	for _, hook := range hooks {
+		require.NoError(t, s.SaveWebhook(ctx, hook))
+	}
+
+	userHooks, err := s.GetUserWebhooks(ctx, hooks[0].UserID)
+	require.NoError(t, err)
+	require.Len(t, userHooks, 2)
+	assert.Equal(t, hooks[0].ID, userHooks[0].ID, "webhooks are listed oldest first")
+	assert.Equal(t, hooks[0].URL, userHooks[0].URL)
+	assert.Equal(t, hooks[0].Secret, userHooks[0].Secret)
+	assert.True(t, hooks[0].CreatedAt.Equal(userHooks[0].CreatedAt))
+	assert.Equal(t, hooks[1].ID, userHooks[1].ID)
+
+	err = s.DeleteWebhook(ctx, hooks[0].ID, hooks[2].UserID, now)
+	assert.ErrorIs(t, err, storage.ErrWebhookNotFound, "a webhook of another user cannot be deleted")
+
+	require.NoError(t, s.DeleteWebhook(ctx, hooks[0].ID, hooks[0].UserID, now))
+
+	err = s.DeleteWebhook(ctx, hooks[0].ID, hooks[0].UserID, now)
+	assert.ErrorIs(t, err, storage.ErrWebhookNotFound, "a webhook is deleted only once")
+
+	userHooks, err = s.GetUserWebhooks(ctx, hooks[0].UserID)
+	require.NoError(t, err)
+	require.Len(t, userHooks, 1)
+	assert.Equal(t, hooks[1].ID, userHooks[0].ID)
+
+	userHooks, err = s.GetUserWebhooks(ctx, "0b9f6c4e-0000-4000-8000-000000000003")
+	require.NoError(t, err)
+	assert.Empty(t, userHooks)
+}
+
+func testDeadLetters(t *testing.T, s webhook.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	userID := "0b9f6c4e-0000-4000-8000-000000000001"
+
+	letters, err := s.GetDeadLetters(ctx, userID, 0)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	for i, owner := range []string{userID, "0b9f6c4e-0000-4000-8000-000000000002", userID, userID} {
+		require.NoError(t, s.SaveDeadLetter(ctx, models.DeadLetter{
+			Time:      now.Add(time.Duration(i) * time.Second),
+			WebhookID: "7c1e5b2a-0000-4000-8000-000000000001",
+			UserID:    owner,
+			URL:       "https://example.com/hook",
+			Event: models.LinkEvent{
+				Time:    now,
+				Action:  models.EventClick,
+				OwnerID: owner,
+				Code:    fmt.Sprintf("code%d", i+1),
+				URL:     "https://example.com",
+			},
+			Attempts: i + 1,
+			Error:    "unexpected status 503 Service Unavailable",
+		}))
+	}
+
+	letters, err = s.GetDeadLetters(ctx, userID, 2)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "code4", letters[0].Event.Code, "the newest dead letter comes first")
+	assert.Equal(t, "code3", letters[1].Event.Code)
+	assert.True(t, now.Add(3*time.Second).Equal(letters[0].Time))
+	assert.True(t, now.Equal(letters[0].Event.Time))
+	assert.Equal(t, models.EventClick, letters[0].Event.Action)
+	assert.Equal(t, "https://example.com/hook", letters[0].URL)
+	assert.Equal(t, 4, letters[0].Attempts)
+	assert.Equal(t, "unexpected status 503 Service Unavailable", letters[0].Error)
+
+	letters, err = s.GetDeadLetters(ctx, userID, 0)
+	require.NoError(t, err)
+	assert.Len(t, letters, 3, "only the dead letters of the user are listed")
+}
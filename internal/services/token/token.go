@@ -0,0 +1,183 @@
+// Package token issues and verifies the JWTs that identify users.
+//
+// A token carries the UserID in its subject. It is signed either with a shared secret
+// (HS256) or with a private key (RS256 or EdDSA), in which case only the public half
+// is needed to verify it. Tokens signed with any other algorithm are rejected, so a
+// token cannot downgrade the configured algorithm.
+//
+// The signing keys come from a keyring. A token names its key in the "kid" header;
+// a token without one predates the keyring and is verified with the current key.
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+)
+
+// The supported signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// ErrInvalidToken is returned for a token that is malformed, expired, badly signed or carries no UserID.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config holds the token settings.
+type Config struct {
+	// Algorithm is the signing algorithm: HS256 (the default), RS256 or EdDSA.
+	Algorithm string
+
+	// TTL is the lifetime of an issued token.
+	TTL time.Duration
+}
+
+// signingKey is the key material of one keyring key.
+type signingKey struct {
+	sign   any
+	verify any
+}
+
+// Manager issues and verifies tokens.
+type Manager struct {
+	method jwt.SigningMethod
+	ring   *keyring.Keyring
+	keys   map[string]signingKey
+	ttl    time.Duration
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// New creates a token manager signing with the keys of ring. For HS256 every key needs
+// a JWT secret, for RS256 and EdDSA the path to a private key, which is loaded here.
+func New(cfg Config, ring *keyring.Keyring) (*Manager, error) {
+	const op = "token.New"
+
+	m := &Manager{ring: ring, keys: make(map[string]signingKey), ttl: cfg.TTL, now: time.Now}
+
+	switch cfg.Algorithm {
+	case "", HS256:
+		m.method = jwt.SigningMethodHS256
+	case RS256:
+		m.method = jwt.SigningMethodRS256
+	case EdDSA:
+		m.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%s: unsupported algorithm %q", op, cfg.Algorithm)
+	}
+
+	for _, key := range ring.Keys() {
+		material, err := loadKey(m.method, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, key.ID, err)
+		}
+		m.keys[key.ID] = material
+	}
+
+	return m, nil
+}
+
+// loadKey returns the key material of key for method.
+func loadKey(method jwt.SigningMethod, key keyring.Key) (signingKey, error) {
+	if method == jwt.SigningMethodHS256 {
+		if key.JwtSecret == "" {
+			return signingKey{}, errors.New("HS256 requires a secret")
+		}
+
+		return signingKey{sign: []byte(key.JwtSecret), verify: []byte(key.JwtSecret)}, nil
+	}
+
+	pemKey, err := os.ReadFile(key.JwtKeyPath)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	if method == jwt.SigningMethodRS256 {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pemKey)
+		if err != nil {
+			return signingKey{}, err
+		}
+
+		return signingKey{sign: private, verify: &private.PublicKey}, nil
+	}
+
+	private, err := jwt.ParseEdPrivateKeyFromPEM(pemKey)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	return signingKey{sign: private, verify: private.(crypto.Signer).Public().(ed25519.PublicKey)}, nil
+}
+
+// Issue returns a token identifying userID, signed with the current key.
+func (m *Manager) Issue(userID string) (string, error) {
+	key, err := m.ring.Current()
+	if err != nil {
+		return "", fmt.Errorf("token.Issue: %w", err)
+	}
+
+	now := m.now()
+
+	claims := jwt.RegisteredClaims{
+		Subject:  userID,
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+	if m.ttl > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.ttl))
+	}
+
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(m.keys[key.ID].sign)
+	if err != nil {
+		return "", fmt.Errorf("token.Issue: %w", err)
+	}
+
+	return signed, nil
+}
+
+// Parse verifies the token with the key it names and returns the UserID it identifies.
+// stale reports whether the token was signed with another key than the current one,
+// in which case it should be issued again.
+func (m *Manager) Parse(token string) (userID string, stale bool, err error) {
+	current, err := m.ring.Current()
+	if err != nil {
+		return "", false, fmt.Errorf("token.Parse: %w", err)
+	}
+
+	claims := &jwt.RegisteredClaims{}
+
+	_, err = jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = current.ID
+		}
+
+		if _, ok := m.ring.Get(kid); !ok {
+			return nil, fmt.Errorf("unknown or expired key %q", kid)
+		}
+		stale = kid != current.ID
+
+		return m.keys[kid].verify, nil
+	}, jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithTimeFunc(m.now))
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return "", false, fmt.Errorf("%w: subject is empty", ErrInvalidToken)
+	}
+
+	return claims.Subject, stale, nil
+}
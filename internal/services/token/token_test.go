@@ -0,0 +1,209 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/services/keyring"
+)
+
+// newRing returns a keyring of a single key with the given JWT secret and key path.
+func newRing(t *testing.T, secret, keyPath string) *keyring.Keyring {
+	t.Helper()
+
+	ring, err := keyring.New([]keyring.Key{{ID: "k1", HashKey: "hash", JwtSecret: secret, JwtKeyPath: keyPath}})
+	require.NoError(t, err)
+
+	return ring
+}
+
+// writeKey writes key as a PKCS #8 PEM file and returns its path.
+func writeKey(t *testing.T, key any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestManager_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		secret  string
+		keyPath string
+	}{
+		{name: "HS256", cfg: Config{Algorithm: HS256}, secret: "secret"},
+		{name: "default", secret: "secret"},
+		{name: "RS256", cfg: Config{Algorithm: RS256}, keyPath: writeKey(t, rsaKey)},
+		{name: "EdDSA", cfg: Config{Algorithm: EdDSA}, keyPath: writeKey(t, edKey)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.TTL = time.Hour
+
+			m, err := New(tt.cfg, newRing(t, tt.secret, tt.keyPath))
+			require.NoError(t, err)
+
+			signed, err := m.Issue("user1")
+			require.NoError(t, err)
+
+			userID, stale, err := m.Parse(signed)
+			require.NoError(t, err)
+			assert.Equal(t, "user1", userID)
+			assert.False(t, stale)
+
+			_, _, err = m.Parse(signed[:len(signed)-2])
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestManager_Expired(t *testing.T) {
+	m, err := New(Config{TTL: time.Minute}, newRing(t, "secret", ""))
+	require.NoError(t, err)
+
+	signed, err := m.Issue("user1")
+	require.NoError(t, err)
+
+	m.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	_, _, err = m.Parse(signed)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestManager_RejectsOtherTokens(t *testing.T) {
+	m, err := New(Config{}, newRing(t, "secret", ""))
+	require.NoError(t, err)
+
+	other, err := New(Config{}, newRing(t, "another secret", ""))
+	require.NoError(t, err)
+
+	signed, err := other.Issue("user1")
+	require.NoError(t, err)
+
+	_, _, err = m.Parse(signed)
+	assert.ErrorIs(t, err, ErrInvalidToken, "a token signed with another secret is rejected")
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{Subject: "user1"}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	_, _, err = m.Parse(unsigned)
+	assert.ErrorIs(t, err, ErrInvalidToken, "an unsigned token is rejected")
+
+	anonymous, err := m.Issue("")
+	require.NoError(t, err)
+
+	_, _, err = m.Parse(anonymous)
+	assert.ErrorIs(t, err, ErrInvalidToken, "a token without a subject is rejected")
+}
+
+func TestManager_RejectsAlgorithmSwitch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m, err := New(Config{Algorithm: RS256}, newRing(t, "", writeKey(t, rsaKey)))
+	require.NoError(t, err)
+
+	// An HS256 token keyed with the public key must not pass as RS256.
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user1"}).
+		SignedString(publicDER)
+	require.NoError(t, err)
+
+	_, _, err = m.Parse(forged)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	_, err := New(Config{Algorithm: HS256}, newRing(t, "", ""))
+	assert.Error(t, err)
+
+	_, err = New(Config{Algorithm: "HS512"}, newRing(t, "secret", ""))
+	assert.Error(t, err)
+
+	_, err = New(Config{Algorithm: RS256}, newRing(t, "", filepath.Join(t.TempDir(), "missing.pem")))
+	assert.Error(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	_, err = New(Config{Algorithm: RS256}, newRing(t, "", writeKey(t, edKey)))
+	assert.Error(t, err, "an EdDSA key cannot sign RS256")
+}
+
+func TestManager_Rotation(t *testing.T) {
+	now := time.Now()
+
+	oldKey := keyring.Key{ID: "old", HashKey: "hash", JwtSecret: "old secret", NotAfter: now.Add(time.Hour)}
+	newKey := keyring.Key{ID: "new", HashKey: "hash", JwtSecret: "new secret", NotBefore: now.Add(-time.Minute)}
+
+	before, err := keyring.New([]keyring.Key{oldKey})
+	require.NoError(t, err)
+	oldManager, err := New(Config{}, before)
+	require.NoError(t, err)
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "user0"}).
+		SignedString([]byte("old secret"))
+	require.NoError(t, err)
+
+	_, stale, err := oldManager.Parse(legacy)
+	require.NoError(t, err, "a token without a key ID is verified with the current key")
+	assert.False(t, stale)
+
+	oldToken, err := oldManager.Issue("user1")
+	require.NoError(t, err)
+
+	after, err := keyring.New([]keyring.Key{oldKey, newKey})
+	require.NoError(t, err)
+	m, err := New(Config{}, after)
+	require.NoError(t, err)
+
+	userID, stale, err := m.Parse(oldToken)
+	require.NoError(t, err, "a token of a previous key is still accepted")
+	assert.Equal(t, "user1", userID)
+	assert.True(t, stale, "a token of a previous key is to be re-issued")
+
+	newToken, err := m.Issue(userID)
+	require.NoError(t, err)
+
+	_, stale, err = m.Parse(newToken)
+	require.NoError(t, err)
+	assert.False(t, stale)
+
+	_, _, err = oldManager.Parse(newToken)
+	assert.ErrorIs(t, err, ErrInvalidToken, "a token of an unknown key is rejected")
+
+	expired, err := keyring.New([]keyring.Key{{ID: "old", HashKey: "hash", JwtSecret: "old secret", NotAfter: now}, newKey})
+	require.NoError(t, err)
+	m, err = New(Config{}, expired)
+	require.NoError(t, err)
+
+	_, _, err = m.Parse(oldToken)
+	assert.ErrorIs(t, err, ErrInvalidToken, "a token of an expired key is rejected")
+}
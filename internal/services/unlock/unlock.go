@@ -0,0 +1,177 @@
+// Package unlock checks the passwords of protected short URLs.
+//
+// Failed attempts are counted per short code, and a code that fails too often is locked
+// for a while, so a password cannot be guessed by brute force over either transport.
+package unlock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// pruneThreshold is the number of tracked codes above which the stale entries are dropped.
+const pruneThreshold = 1024
+
+var (
+	// ErrPasswordRequired is returned when a protected URL is resolved without a password.
+	ErrPasswordRequired = errors.New("password is required")
+
+	// ErrWrongPassword is returned when the password does not match.
+	ErrWrongPassword = errors.New("password is incorrect")
+
+	// ErrTooManyAttempts is matched by a LockedError.
+	ErrTooManyAttempts = errors.New("too many failed attempts")
+)
+
+// LockedError is returned while a short code is locked after too many failed attempts.
+type LockedError struct {
+	// RetryAfter is the time left until the lock is lifted.
+	RetryAfter time.Duration
+}
+
+// Error returns the error message with the time left until the lock is lifted.
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrTooManyAttempts.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+// failures tracks the failed attempts of a single short code.
+type failures struct {
+	count int
+	since time.Time
+
+	// pending is the number of attempts whose password is being compared. They count
+	// against the limit until they are settled, so parallel guesses cannot exceed it.
+	pending int
+}
+
+// Unlocker verifies passwords and limits the failed attempts per short code.
+// It is safe for concurrent use.
+type Unlocker struct {
+	maxFailures int
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*failures
+}
+
+// New creates an Unlocker that locks a short code for lockout once maxFailures
+// attempts have failed within lockout of the first one.
+func New(maxFailures int, lockout time.Duration) *Unlocker {
+	return &Unlocker{
+		maxFailures: maxFailures,
+		lockout:     lockout,
+		now:         time.Now,
+		failures:    make(map[string]*failures),
+	}
+}
+
+// Check verifies password against the protected URL.
+// It returns nil for an unprotected URL or the matching password, ErrPasswordRequired
+// for an empty password, ErrWrongPassword for a mismatch and a LockedError while the
+// code is locked. Only mismatches count as failed attempts; a correct password clears them.
+func (u *Unlocker) Check(url models.URL, password string) error {
+	if !url.IsProtected() {
+		return nil
+	}
+
+	if password == "" {
+		return ErrPasswordRequired
+	}
+
+	if err := u.reserve(url.Code); err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)) != nil {
+		u.settle(url.Code, false)
+		return ErrWrongPassword
+	}
+
+	u.settle(url.Code, true)
+
+	return nil
+}
+
+// reserve counts an attempt for the code as pending, or returns a LockedError if the failed
+// and pending attempts have used up the limit.
+func (u *Unlocker) reserve(code string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.now()
+
+	if len(u.failures) >= pruneThreshold {
+		u.prune(now)
+	}
+
+	f, ok := u.failures[code]
+	if !ok {
+		f = &failures{}
+		u.failures[code] = f
+	}
+
+	if f.count > 0 && !now.Before(f.since.Add(u.lockout)) {
+		f.count = 0
+	}
+
+	if f.count+f.pending >= u.maxFailures {
+		left := u.lockout
+		if f.count > 0 {
+			left = f.since.Add(u.lockout).Sub(now)
+		}
+		if f.count == 0 && f.pending == 0 {
+			delete(u.failures, code)
+		}
+
+		return &LockedError{RetryAfter: left}
+	}
+
+	f.pending++
+
+	return nil
+}
+
+// settle ends a pending attempt for the code: a match clears the failed attempts,
+// a mismatch records one.
+func (u *Unlocker) settle(code string, matched bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	f := u.failures[code]
+	f.pending--
+
+	if matched {
+		f.count = 0
+	} else {
+		if f.count == 0 {
+			f.since = u.now()
+		}
+		f.count++
+	}
+
+	if f.count == 0 && f.pending == 0 {
+		delete(u.failures, code)
+	}
+}
+
+// prune drops the codes whose failures are older than the lockout and that have no pending
+// attempts. The caller must hold u.mu.
+func (u *Unlocker) prune(now time.Time) {
+	for code, f := range u.failures {
+		if f.pending == 0 && !now.Before(f.since.Add(u.lockout)) {
+			delete(u.failures, code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package unlock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+func protectedURL(t *testing.T, code, password string) models.URL {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return models.URL{ID: 1, Code: code, URL: "https://example.com/", PasswordHash: string(hash)}
+}
+
+func TestUnlocker_Check(t *testing.T) {
+	u := New(3, time.Minute)
+	url := protectedURL(t, "secret", "hunter2")
+
+	assert.NoError(t, u.Check(models.URL{Code: "open"}, ""))
+	assert.ErrorIs(t, u.Check(url, ""), ErrPasswordRequired)
+	assert.ErrorIs(t, u.Check(url, "wrong"), ErrWrongPassword)
+	assert.NoError(t, u.Check(url, "hunter2"))
+}
+
+func TestUnlocker_Lockout(t *testing.T) {
+	now := time.Now()
+
+	u := New(2, time.Minute)
+	u.now = func() time.Time { return now }
+
+	url := protectedURL(t, "secret", "hunter2")
+	other := protectedURL(t, "other", "hunter2")
+
+	assert.ErrorIs(t, u.Check(url, "wrong"), ErrWrongPassword)
+	assert.ErrorIs(t, u.Check(url, "wrong"), ErrWrongPassword)
+
+	// Even the correct password is rejected while the code is locked.
+	err := u.Check(url, "hunter2")
+	require.ErrorIs(t, err, ErrTooManyAttempts)
+
+	var lockedErr *LockedError
+	require.ErrorAs(t, err, &lockedErr)
+	assert.Equal(t, time.Minute, lockedErr.RetryAfter)
+
+	// The lock is per code.
+	assert.NoError(t, u.Check(other, "hunter2"))
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, u.Check(url, "hunter2"))
+}
+
+func TestUnlocker_SuccessClearsFailures(t *testing.T) {
+	u := New(2, time.Minute)
+	url := protectedURL(t, "secret", "hunter2")
+
+	assert.ErrorIs(t, u.Check(url, "wrong"), ErrWrongPassword)
+	assert.NoError(t, u.Check(url, "hunter2"))
+	assert.ErrorIs(t, u.Check(url, "wrong"), ErrWrongPassword)
+	assert.NoError(t, u.Check(url, "hunter2"))
+}
+
+func TestUnlocker_Prune(t *testing.T) {
+	now := time.Now()
+
+	u := New(5, time.Minute)
+	u.now = func() time.Time { return now }
+
+	for i := range pruneThreshold {
+		u.failures[fmt.Sprintf("code%d", i)] = &failures{count: 1, since: now}
+	}
+
+	now = now.Add(time.Minute)
+	require.NoError(t, u.reserve("fresh"))
+	u.settle("fresh", false)
+
+	assert.Len(t, u.failures, 1)
+}
+
+func TestUnlocker_ConcurrentGuesses(t *testing.T) {
+	const maxFailures = 3
+
+	u := New(maxFailures, time.Minute)
+	url := protectedURL(t, "secret", "hunter2")
+
+	// The guesses race past any check made before the slow comparison; only
+	// maxFailures of them may reach it.
+	const guesses = 50
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		wrong  int
+		locked int
+	)
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := u.Check(url, "wrong")
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrWrongPassword):
+				wrong++
+			case errors.Is(err, ErrTooManyAttempts):
+				locked++
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, maxFailures, wrong, "only the allowed guesses are compared")
+	assert.Equal(t, guesses-maxFailures, locked)
+	assert.ErrorIs(t, u.Check(url, "hunter2"), ErrTooManyAttempts)
+}
@@ -1,25 +1,100 @@
 // Package urlservice provides services for managing URL shortening operations,
 // including the creation of short URLs, batch creation, deletion, and URL generation.
+//
+// The service is the one place the lifecycle events of short URLs are emitted from:
+// every change it makes, and every admin action reported to it, is passed to the
+// audit sink it is configured with.
 package urlservice
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/vadicheck/shorturl/internal/models"
 	"github.com/vadicheck/shorturl/internal/models/shorten"
 	"github.com/vadicheck/shorturl/internal/repository"
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
 	"github.com/vadicheck/shorturl/pkg/random"
+	"github.com/vadicheck/shorturl/pkg/validators/alias"
+)
+
+var (
+	// ErrInvalidAlias is returned when a custom alias fails validation.
+	// It wraps the alias validation error describing the problem.
+	ErrInvalidAlias = errors.New("invalid alias")
+
+	// ErrAliasTaken is returned when a custom alias is already used as a short code.
+	ErrAliasTaken = errors.New("alias is already taken")
+
+	// ErrInvalidExpiration is returned when the expiration time or TTL of a new URL is invalid.
+	ErrInvalidExpiration = errors.New("invalid expiration")
+
+	// ErrInvalidMaxClicks is returned when the click limit of a new URL is negative.
+	ErrInvalidMaxClicks = errors.New("invalid max_clicks: must not be negative")
+
+	// ErrInvalidPassword is returned when the password of a new URL cannot be hashed.
+	ErrInvalidPassword = errors.New("invalid password")
+
+	// ErrInvalidLimit is returned when the history is requested with a negative or too large limit.
+	ErrInvalidLimit = errors.New("invalid limit")
+
+	// ErrHistoryUnavailable is returned when the history is requested but no audit sink keeps one.
+	ErrHistoryUnavailable = errors.New("history is unavailable")
+)
+
+const (
+	// DefaultHistoryLimit is the number of events returned when no limit is given.
+	DefaultHistoryLimit = 100
+
+	// MaxHistoryLimit is the largest number of events returned at once.
+	MaxHistoryLimit = 1000
 )
 
 // Service provides the main URL shortening services, including creating short URLs,
 // batch processing of short URLs, and deleting URLs.
 type Service struct {
 	storage URLStorage
+
+	// sink receives the lifecycle events. Nil disables them.
+	sink audit.Sink
+
+	// now returns the current time.
+	now func() time.Time
+}
+
+// Option configures the Service.
+type Option func(*Service)
+
+// WithAudit makes the Service emit the lifecycle events of the URLs to sink.
+func WithAudit(sink audit.Sink) Option {
+	return func(s *Service) {
+		s.sink = sink
+	}
+}
+
+// WithClock makes the Service read the current time from now instead of time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
 }
 
 // New creates a new instance of the Service with the provided URLStorage implementation.
-func New(storage URLStorage) *Service {
-	return &Service{storage}
+func New(storage URLStorage, opts ...Option) *Service {
+	s := &Service{storage: storage, now: time.Now}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // URLStorage is an interface that defines the storage operations needed by the URL service.
@@ -28,8 +103,8 @@ type URLStorage interface {
 	// PingContext checks the health of the storage.
 	PingContext(ctx context.Context) error
 
-	// SaveURL stores a new URL with the provided short code and user ID.
-	SaveURL(ctx context.Context, code string, url string, userID string) (int64, error)
+	// SaveURL stores a new URL with the provided short code, user ID and settings.
+	SaveURL(ctx context.Context, code string, url string, userID string, settings repository.URLSettings) (int64, error)
 
 	// SaveBatchURL stores multiple URLs in a batch, associating them with the user ID.
 	SaveBatchURL(ctx context.Context, dto *[]repository.BatchURLDto, userID string) (*[]repository.BatchURL, error)
@@ -45,23 +120,120 @@ type URLStorage interface {
 
 	// DeleteShortURLs deletes multiple short URLs associated with the given user ID.
 	DeleteShortURLs(ctx context.Context, urls []string, userID string) error
+
+	// RestoreShortURLs undoes the deletion of the short URLs the given user owns and returns them.
+	RestoreShortURLs(ctx context.Context, urls []string, userID string) ([]models.URL, error)
+
+	// PurgeExpiredURLs removes the URLs that expired at or before now and returns their number.
+	PurgeExpiredURLs(ctx context.Context, now time.Time) (int64, error)
+
+	// ConsumeClick atomically uses up one redirect of a click-limited URL.
+	// It returns false if the URL is not click-limited or has no redirects left.
+	ConsumeClick(ctx context.Context, code string) (bool, error)
 }
 
 const defaultCodeLength = 10
 
+// MaxPasswordLength is the longest password in bytes that bcrypt can hash.
+const MaxPasswordLength = 72
+
+// CreateOption configures the short URL made by Create.
+type CreateOption func(*createOptions)
+
+type createOptions struct {
+	alias     string
+	expiresAt time.Time
+	ttl       time.Duration
+	maxClicks int64
+	password  string
+}
+
+// WithAlias makes Create use alias as the short code instead of a generated one.
+// An empty alias keeps the generated code.
+func WithAlias(alias string) CreateOption {
+	return func(o *createOptions) {
+		o.alias = alias
+	}
+}
+
+// WithExpiresAt makes the short URL expire at the given time, which must be in the future.
+// The zero time keeps the URL from expiring.
+func WithExpiresAt(expiresAt time.Time) CreateOption {
+	return func(o *createOptions) {
+		o.expiresAt = expiresAt
+	}
+}
+
+// WithTTL makes the short URL expire once ttl has passed. It cannot be combined with WithExpiresAt.
+// A zero ttl keeps the URL from expiring.
+func WithTTL(ttl time.Duration) CreateOption {
+	return func(o *createOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithMaxClicks limits the number of redirects the short URL allows; 1 makes a one-time link.
+// Zero keeps the URL unlimited.
+func WithMaxClicks(maxClicks int64) CreateOption {
+	return func(o *createOptions) {
+		o.maxClicks = maxClicks
+	}
+}
+
+// WithPassword protects the short URL with password, which is stored as a bcrypt hash.
+// An empty password leaves the URL unprotected.
+func WithPassword(password string) CreateOption {
+	return func(o *createOptions) {
+		o.password = password
+	}
+}
+
 // Create generates a new short code for the given source URL and saves it in the storage.
+// With WithAlias the alias is validated and used as the short code; ErrInvalidAlias is
+// returned for an invalid alias and ErrAliasTaken if the alias is already in use.
+// With WithExpiresAt or WithTTL the URL expires; ErrInvalidExpiration is returned if
+// the expiration is in the past or both options are given.
+// With WithMaxClicks the URL stops resolving after that many redirects; ErrInvalidMaxClicks
+// is returned for a negative limit.
+// With WithPassword the URL requires the password to resolve; ErrInvalidPassword is returned
+// for a password longer than MaxPasswordLength bytes.
 // Returns the short code or an error if the operation fails.
-func (s *Service) Create(ctx context.Context, sourceURL, userID string) (string, error) {
-	code, err := s.generateCode(ctx)
+func (s *Service) Create(ctx context.Context, sourceURL, userID string, opts ...CreateOption) (string, error) {
+	var o createOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	expiresAt, err := expiration(o.expiresAt, o.ttl, s.now())
 	if err != nil {
 		return "", err
 	}
 
-	_, err = s.storage.SaveURL(ctx, code, sourceURL, userID)
+	if o.maxClicks < 0 {
+		return "", ErrInvalidMaxClicks
+	}
+
+	passwordHash, err := hashPassword(o.password)
 	if err != nil {
 		return "", err
 	}
 
+	code, err := s.shortCode(ctx, o.alias)
+	if err != nil {
+		return "", err
+	}
+
+	settings := repository.URLSettings{ExpiresAt: expiresAt, MaxClicks: o.maxClicks, PasswordHash: passwordHash}
+
+	_, err = s.storage.SaveURL(ctx, code, sourceURL, userID, settings)
+	if err != nil {
+		return "", aliasError(err, o.alias != "")
+	}
+
+	s.emit(ctx, []models.LinkEvent{
+		{Action: models.EventCreate, ActorID: userID, OwnerID: userID, Code: code, URL: sourceURL},
+	})
+
 	return code, nil
 }
 
@@ -73,32 +245,274 @@ func (s *Service) CreateBatch(
 	userID string,
 ) (*[]repository.BatchURL, error) {
 	dto := make([]repository.BatchURLDto, 0)
+	withAlias := false
+	now := s.now()
 
 	for _, r := range request {
-		code, err := s.generateCode(ctx)
+		expiresAt, err := expiration(r.ExpiresAt, time.Duration(r.TTL)*time.Second, now)
+		if err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", r.CorrelationID, err)
+		}
+
+		if r.MaxClicks < 0 {
+			return nil, fmt.Errorf("correlation_id %q: %w", r.CorrelationID, ErrInvalidMaxClicks)
+		}
+
+		passwordHash, err := hashPassword(r.Password)
+		if err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", r.CorrelationID, err)
+		}
+
+		code, err := s.shortCode(ctx, r.Alias)
 		if err != nil {
 			return nil, err
 		}
 
+		withAlias = withAlias || r.Alias != ""
+
 		dto = append(dto, repository.BatchURLDto{
 			CorrelationID: r.CorrelationID,
 			OriginalURL:   r.OriginalURL,
 			ShortCode:     code,
+			URLSettings: repository.URLSettings{
+				ExpiresAt:    expiresAt,
+				MaxClicks:    r.MaxClicks,
+				PasswordHash: passwordHash,
+			},
 		})
 	}
 
 	batch, err := s.storage.SaveBatchURL(ctx, &dto, userID)
 	if err != nil {
-		return nil, err
+		return nil, aliasError(err, withAlias)
+	}
+
+	originalURLs := make(map[string]string, len(dto))
+	for _, d := range dto {
+		originalURLs[d.CorrelationID] = d.OriginalURL
+	}
+
+	events := make([]models.LinkEvent, 0, len(*batch))
+	for _, b := range *batch {
+		events = append(events, models.LinkEvent{
+			Action:  models.EventBatchCreate,
+			ActorID: userID,
+			OwnerID: userID,
+			Code:    b.ShortCode,
+			URL:     originalURLs[b.CorrelationID],
+		})
 	}
+	s.emit(ctx, events)
 
 	return batch, nil
 }
 
 // Delete deletes multiple short URLs associated with the provided user ID.
+// Only the URLs the user owns and has not deleted yet are reported as deleted.
 // Returns an error if the operation fails.
 func (s *Service) Delete(ctx context.Context, urls []string, userID string) error {
-	return s.storage.DeleteShortURLs(ctx, urls, userID)
+	var events []models.LinkEvent
+
+	if s.sink != nil {
+		for _, code := range urls {
+			url, err := s.storage.GetURLByID(ctx, code)
+			if err != nil {
+				return err
+			}
+
+			if url.ID != 0 && url.UserID == userID && !url.IsDeleted {
+				events = append(events, models.LinkEvent{
+					Action:  models.EventDelete,
+					ActorID: userID,
+					OwnerID: userID,
+					Code:    code,
+					URL:     url.URL,
+				})
+			}
+		}
+	}
+
+	if err := s.storage.DeleteShortURLs(ctx, urls, userID); err != nil {
+		return err
+	}
+
+	s.emit(ctx, events)
+
+	return nil
+}
+
+// Restore undoes the deletion of multiple short URLs associated with the provided user ID.
+// The URLs the user does not own or has not deleted are skipped. It returns the codes of the
+// URLs restored, in the order they were given.
+func (s *Service) Restore(ctx context.Context, urls []string, userID string) ([]string, error) {
+	restored, err := s.storage.RestoreShortURLs(ctx, urls, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.URL, len(restored))
+	for _, url := range restored {
+		byCode[url.Code] = url
+	}
+
+	codes := make([]string, 0, len(restored))
+	events := make([]models.LinkEvent, 0, len(restored))
+	for _, code := range urls {
+		url, ok := byCode[code]
+		if !ok {
+			continue
+		}
+		delete(byCode, code)
+
+		codes = append(codes, code)
+		events = append(events, models.LinkEvent{
+			Action:  models.EventRestore,
+			ActorID: userID,
+			OwnerID: userID,
+			Code:    code,
+			URL:     url.URL,
+		})
+	}
+	s.emit(ctx, events)
+
+	return codes, nil
+}
+
+// RecordAdminAction reports an action of the admin actorID on the URLs, as they are after
+// the action, so it is emitted along with the other lifecycle events.
+func (s *Service) RecordAdminAction(ctx context.Context, actorID, action string, urls []models.URL) {
+	events := make([]models.LinkEvent, 0, len(urls))
+	for _, url := range urls {
+		events = append(events, models.LinkEvent{
+			Action:  action,
+			ActorID: actorID,
+			OwnerID: url.UserID,
+			Code:    url.Code,
+			URL:     url.URL,
+		})
+	}
+
+	s.emit(ctx, events)
+}
+
+// History returns the latest lifecycle events of the URLs owned by userID, newest first,
+// limited to the URL with the given code unless it is empty. A zero limit returns
+// DefaultHistoryLimit events; a negative or larger limit than MaxHistoryLimit is rejected
+// with ErrInvalidLimit. ErrHistoryUnavailable is returned if the audit sink keeps no history.
+func (s *Service) History(ctx context.Context, userID, code string, limit int) ([]models.LinkEvent, error) {
+	if limit < 0 || limit > MaxHistoryLimit {
+		return nil, fmt.Errorf("%w: must be 0 to %d", ErrInvalidLimit, MaxHistoryLimit)
+	}
+	if limit == 0 {
+		limit = DefaultHistoryLimit
+	}
+
+	reader, ok := s.sink.(audit.Reader)
+	if !ok {
+		return nil, ErrHistoryUnavailable
+	}
+
+	events, err := reader.History(ctx, userID, code, limit)
+	if errors.Is(err, audit.ErrNoHistory) {
+		return nil, ErrHistoryUnavailable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("urlservice.History: %w", err)
+	}
+
+	return events, nil
+}
+
+// emit stamps the events with the current time and the request ID carried by ctx for their
+// code and passes them to the audit sink. A failing sink is logged but does not fail the change.
+func (s *Service) emit(ctx context.Context, events []models.LinkEvent) {
+	if s.sink == nil || len(events) == 0 {
+		return
+	}
+
+	now := s.now().UTC()
+	for i := range events {
+		events[i].Time = now
+		events[i].RequestID = audit.CodeRequestID(ctx, events[i].Code)
+	}
+
+	if err := s.sink.Emit(ctx, events); err != nil {
+		slog.Error("failed to emit link events", slog.Int("events", len(events)), sl.Err(err))
+	}
+}
+
+// Now returns the current time of the service clock, which the expiration of the URLs
+// is set and checked against.
+func (s *Service) Now() time.Time {
+	return s.now()
+}
+
+// PurgeExpired removes the URLs that have expired by now and returns their number.
+func (s *Service) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.storage.PurgeExpiredURLs(ctx, s.now())
+}
+
+// expiration resolves the expiration time of a new URL from an absolute time or a TTL,
+// at most one of which may be set. The zero time means the URL never expires.
+func expiration(expiresAt time.Time, ttl time.Duration, now time.Time) (time.Time, error) {
+	switch {
+	case !expiresAt.IsZero() && ttl != 0:
+		return time.Time{}, fmt.Errorf("%w: expires_at and ttl cannot be combined", ErrInvalidExpiration)
+	case ttl < 0:
+		return time.Time{}, fmt.Errorf("%w: ttl must be positive", ErrInvalidExpiration)
+	case ttl > 0:
+		return now.Add(ttl).UTC(), nil
+	case expiresAt.IsZero():
+		return time.Time{}, nil
+	case !expiresAt.After(now):
+		return time.Time{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiration)
+	default:
+		return expiresAt.UTC(), nil
+	}
+}
+
+// hashPassword returns the bcrypt hash of a new URL's password, or an empty string without one.
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("%w: password must be at most %d bytes long", ErrInvalidPassword, MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// shortCode returns the validated alias if one is given, or a newly generated code otherwise.
+func (s *Service) shortCode(ctx context.Context, code string) (string, error) {
+	if code == "" {
+		return s.generateCode(ctx)
+	}
+
+	if _, err := alias.IsValid(code); err != nil {
+		return "", fmt.Errorf("%w %q: %w", ErrInvalidAlias, code, err)
+	}
+
+	return code, nil
+}
+
+// aliasError reports a taken short code as ErrAliasTaken when custom aliases were requested.
+// Generated codes are checked before saving, so a conflict on them is left as is.
+// An existing URL is reported by the storage with storage.ExistsURLError and is left as is too.
+func aliasError(err error, withAlias bool) error {
+	var existsErr *storage.ExistsURLError
+
+	if withAlias && errors.Is(err, storage.ErrURLOrCodeExists) && !errors.As(err, &existsErr) {
+		return fmt.Errorf("%w: %w", ErrAliasTaken, err)
+	}
+
+	return err
 }
 
 // generateCode generates a unique random code for a new short URL.
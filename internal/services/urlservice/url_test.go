@@ -3,14 +3,24 @@ package urlservice
 import (
 	"context"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vadicheck/shorturl/internal/models/shorten"
 	"github.com/vadicheck/shorturl/internal/repository"
 	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+	"golang.org/x/crypto/bcrypt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/audit"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+	"github.com/vadicheck/shorturl/pkg/validators/alias"
 )
 
 const userID = "4fddc63f-b1c7-48cd-b004-ff979346ea65"
@@ -122,6 +132,208 @@ func TestService_CreateBatch(t *testing.T) {
 	}
 }
 
+func TestService_CreateAlias(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	urlService := New(storageService)
+
+	code, err := urlService.Create(ctx, "http://example.com/1", userID, WithAlias("q3-report"))
+	require.NoError(t, err)
+	assert.Equal(t, "q3-report", code)
+
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithAlias("q3-report"))
+	require.ErrorIs(t, err, ErrAliasTaken)
+	require.ErrorIs(t, err, storage.ErrURLOrCodeExists)
+
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithAlias("ping"))
+	require.ErrorIs(t, err, ErrInvalidAlias)
+	require.ErrorIs(t, err, alias.ErrReserved)
+
+	// An already shortened URL is reported as such, not as a taken alias.
+	_, err = urlService.Create(ctx, "http://example.com/1", userID, WithAlias("q3-report"))
+	var existsErr *storage.ExistsURLError
+	require.ErrorAs(t, err, &existsErr)
+	assert.NotErrorIs(t, err, ErrAliasTaken)
+
+	code, err = urlService.Create(ctx, "http://example.com/3", userID, WithAlias(""))
+	require.NoError(t, err)
+	assert.Len(t, code, defaultCodeLength)
+}
+
+func TestService_CreateBatchAlias(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	urlService := New(storageService)
+
+	entities, err := urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1", Alias: "first"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2"},
+	}, userID)
+	require.NoError(t, err)
+	require.Len(t, *entities, 2)
+	assert.Equal(t, "first", (*entities)[0].ShortCode)
+	assert.Len(t, (*entities)[1].ShortCode, defaultCodeLength)
+
+	_, err = urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/3", Alias: "first"},
+	}, userID)
+	require.ErrorIs(t, err, ErrAliasTaken)
+
+	_, err = urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/3", Alias: "a/b"},
+	}, userID)
+	require.ErrorIs(t, err, ErrInvalidAlias)
+}
+
+func TestService_CreateExpiration(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	urlService := New(storageService)
+	urlService.now = func() time.Time { return now }
+
+	code, err := urlService.Create(ctx, "http://example.com/1", userID, WithTTL(time.Hour))
+	require.NoError(t, err)
+
+	mURL, err := storageService.GetURLByID(ctx, code)
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Hour).Equal(mURL.ExpiresAt), "the TTL runs from the service clock")
+
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithExpiresAt(now.Add(-time.Minute)))
+	require.ErrorIs(t, err, ErrInvalidExpiration, "the service clock decides what is in the past")
+
+	_, err = urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/3", TTL: -1},
+	}, userID)
+	require.ErrorIs(t, err, ErrInvalidExpiration)
+}
+
+func TestService_CreateMaxClicks(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	urlService := New(storageService)
+
+	code, err := urlService.Create(ctx, "http://example.com/1", userID, WithMaxClicks(1))
+	require.NoError(t, err)
+
+	mURL, err := storageService.GetURLByID(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), mURL.MaxClicks)
+
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithMaxClicks(-1))
+	require.ErrorIs(t, err, ErrInvalidMaxClicks)
+
+	_, err = urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/3", MaxClicks: -1},
+	}, userID)
+	require.ErrorIs(t, err, ErrInvalidMaxClicks)
+}
+
+func TestService_CreatePassword(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	urlService := New(storageService)
+
+	code, err := urlService.Create(ctx, "http://example.com/1", userID, WithPassword("hunter2"))
+	require.NoError(t, err)
+
+	mURL, err := storageService.GetURLByID(ctx, code)
+	require.NoError(t, err)
+	require.True(t, mURL.IsProtected())
+	assert.NotContains(t, mURL.PasswordHash, "hunter2")
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(mURL.PasswordHash), []byte("hunter2")))
+
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithPassword(strings.Repeat("a", MaxPasswordLength+1)))
+	require.ErrorIs(t, err, ErrInvalidPassword)
+
+	batch, err := urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/3", Password: "hunter2"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/4"},
+	}, userID)
+	require.NoError(t, err)
+
+	for i, protected := range []bool{true, false} {
+		mURL, err = storageService.GetURLByID(ctx, (*batch)[i].ShortCode)
+		require.NoError(t, err)
+		assert.Equal(t, protected, mURL.IsProtected())
+	}
+}
+
+func TestService_PurgeExpired(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	urlService := New(storageService)
+
+	_, err = storageService.SaveURL(ctx, "expired", "http://example.com/1", userID,
+		repository.URLSettings{ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = urlService.Create(ctx, "http://example.com/2", userID, WithTTL(time.Hour))
+	require.NoError(t, err)
+	_, err = urlService.Create(ctx, "http://example.com/3", userID, WithTTL(3*time.Hour))
+	require.NoError(t, err)
+
+	purged, err := urlService.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	now := time.Now()
+	urlService.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	purged, err = urlService.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged, "the URLs expired by the service clock are purged")
+}
+
+func Test_expiration(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		ttl       time.Duration
+		want      time.Time
+		err       bool
+	}{
+		{name: "never"},
+		{name: "ttl", ttl: time.Hour, want: now.Add(time.Hour)},
+		{name: "expires_at", expiresAt: now.Add(time.Minute), want: now.Add(time.Minute)},
+		{
+			name:      "expires_at in another zone",
+			expiresAt: now.Add(time.Minute).In(time.FixedZone("UTC+3", 3*60*60)),
+			want:      now.Add(time.Minute),
+		},
+		{name: "negative ttl", ttl: -time.Second, err: true},
+		{name: "expires_at now", expiresAt: now, err: true},
+		{name: "expires_at in the past", expiresAt: now.Add(-time.Minute), err: true},
+		{name: "both", expiresAt: now.Add(time.Minute), ttl: time.Minute, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expiration(tt.expiresAt, tt.ttl, now)
+			if tt.err {
+				require.ErrorIs(t, err, ErrInvalidExpiration)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestService_Delete(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -195,3 +407,150 @@ func TestService_generateCode(t *testing.T) {
 		})
 	}
 }
+
+// recordingSink records the emitted events.
+type recordingSink struct {
+	events []models.LinkEvent
+}
+
+func (r *recordingSink) Emit(_ context.Context, events []models.LinkEvent) error {
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func TestService_Events(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storageService.Close()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	sink := &recordingSink{}
+	urlService := New(storageService, WithAudit(sink))
+	urlService.now = func() time.Time { return now }
+
+	ctx := audit.WithRequestID(context.Background(), "req-1")
+	otherUserID := "0b8e1c4a-3f9d-4e1b-9c3e-7a2d5f6b8c90"
+
+	code, err := urlService.Create(ctx, "https://example.com/1", userID)
+	require.NoError(t, err)
+
+	batch, err := urlService.CreateBatch(ctx, []shorten.CreateBatchURLRequest{
+		{CorrelationID: "a", OriginalURL: "https://example.com/a"},
+		{CorrelationID: "b", OriginalURL: "https://example.com/b"},
+	}, userID)
+	require.NoError(t, err)
+
+	foreign, err := urlService.Create(context.Background(), "https://example.com/2", otherUserID)
+	require.NoError(t, err)
+
+	require.NoError(t, urlService.Delete(ctx, []string{code, foreign, "missing"}, userID))
+	require.NoError(t, urlService.Delete(ctx, []string{code}, userID))
+
+	urlService.RecordAdminAction(ctx, otherUserID, models.AuditDisableURL, []models.URL{
+		{Code: foreign, URL: "https://example.com/2", UserID: otherUserID},
+	})
+
+	restored, err := urlService.Restore(ctx, []string{code, code, foreign, (*batch)[0].ShortCode}, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{code}, restored, "only the user's own deleted URL is restored, once")
+
+	require.Len(t, sink.events, 7)
+
+	assert.Equal(t, models.LinkEvent{
+		Time:      now,
+		Action:    models.EventCreate,
+		ActorID:   userID,
+		OwnerID:   userID,
+		Code:      code,
+		URL:       "https://example.com/1",
+		RequestID: "req-1",
+	}, sink.events[0])
+
+	for i, b := range *batch {
+		event := sink.events[1+i]
+		assert.Equal(t, models.EventBatchCreate, event.Action)
+		assert.Equal(t, b.ShortCode, event.Code)
+		assert.Equal(t, "https://example.com/"+b.CorrelationID, event.URL)
+	}
+
+	assert.Equal(t, models.EventCreate, sink.events[3].Action)
+	assert.Empty(t, sink.events[3].RequestID, "a context without a request ID leaves it empty")
+
+	assert.Equal(t, models.EventDelete, sink.events[4].Action, "only the user's own URL is reported deleted, once")
+	assert.Equal(t, code, sink.events[4].Code)
+	assert.Equal(t, "https://example.com/1", sink.events[4].URL)
+
+	assert.Equal(t, models.AuditDisableURL, sink.events[5].Action)
+	assert.Equal(t, foreign, sink.events[5].Code)
+	assert.Equal(t, otherUserID, sink.events[5].OwnerID)
+
+	assert.Equal(t, models.LinkEvent{
+		Time:      now,
+		Action:    models.EventRestore,
+		ActorID:   userID,
+		OwnerID:   userID,
+		Code:      code,
+		URL:       "https://example.com/1",
+		RequestID: "req-1",
+	}, sink.events[6])
+}
+
+func TestService_EventsCodeRequestIDs(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storageService.Close()
+
+	sink := &recordingSink{}
+	urlService := New(storageService, WithAudit(sink))
+
+	first, err := urlService.Create(context.Background(), "https://example.com/1", userID)
+	require.NoError(t, err)
+	second, err := urlService.Create(context.Background(), "https://example.com/2", userID)
+	require.NoError(t, err)
+
+	ctx := audit.WithCodeRequestIDs(audit.WithRequestID(context.Background(), "req-0"),
+		map[string]string{first: "req-1"})
+	require.NoError(t, urlService.Delete(ctx, []string{first, second}, userID))
+
+	require.Len(t, sink.events, 4)
+	assert.Equal(t, "req-1", sink.events[2].RequestID)
+	assert.Equal(t, "req-0", sink.events[3].RequestID, "a code without its own request ID falls back to the context's")
+}
+
+func TestService_History(t *testing.T) {
+	storageService, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	defer storageService.Close()
+
+	ctx := context.Background()
+
+	_, err = New(storageService).History(ctx, userID, "", 0)
+	assert.ErrorIs(t, err, ErrHistoryUnavailable)
+
+	_, err = New(storageService, WithAudit(&recordingSink{})).History(ctx, userID, "", 0)
+	assert.ErrorIs(t, err, ErrHistoryUnavailable, "a sink that is not a reader keeps no history")
+
+	sink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	urlService := New(storageService, WithAudit(audit.Multi{&recordingSink{}, sink}))
+
+	_, err = urlService.History(ctx, userID, "", -1)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	_, err = urlService.History(ctx, userID, "", MaxHistoryLimit+1)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+
+	for range 3 {
+		_, err = urlService.Create(ctx, "https://example.com/"+uuid.NewString(), userID)
+		require.NoError(t, err)
+	}
+
+	events, err := urlService.History(ctx, userID, "", 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	events, err = urlService.History(ctx, userID, "", 2)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned when a webhook points, or resolves, to an address of the
+// service's own network: loopback, private, link-local or unspecified.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// reservedPrefixes are the ranges that are neither public nor caught by the netip predicates.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// publicAddr reports whether webhooks may be delivered to addr.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkHost rejects a webhook host that is a non-public IP address or a name of the local host.
+// Other names are checked once resolved, when the delivery is dialed.
+func checkHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// dialControl is a net.Dialer Control hook refusing connections to non-public addresses.
+// It runs on the resolved address, so a name that resolves to an internal address, or
+// rebinds to one after the webhook was registered, is refused too.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// newDialer returns the dialer of the delivery client, guarded by dialControl unless
+// private addresses are allowed.
+func newDialer(allowPrivate bool) *net.Dialer {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: dialTimeout}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+
+	return dialer
+}
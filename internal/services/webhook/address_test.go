@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{address: "93.184.216.34:443", allowed: true},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443", allowed: true},
+		{address: "127.0.0.1:80"},
+		{address: "10.1.2.3:80"},
+		{address: "172.16.0.1:80"},
+		{address: "192.168.0.1:80"},
+		{address: "169.254.169.254:80"},
+		{address: "100.64.0.1:80"},
+		{address: "0.0.0.0:80"},
+		{address: "[::]:80"},
+		{address: "[::1]:80"},
+		{address: "[fe80::1]:80"},
+		{address: "[fd12::1]:80"},
+		{address: "[::ffff:10.0.0.1]:80"},
+		{address: "224.0.0.1:80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialControl("tcp", tt.address, nil)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrForbiddenAddress)
+			}
+
+			assert.Equal(t, tt.allowed, publicAddr(netip.MustParseAddrPort(tt.address).Addr()))
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/pkg/logger/sl"
+)
+
+// The headers sent with every delivery.
+const (
+	// SignatureHeader carries the signature of the delivery, see Sign.
+	SignatureHeader = "X-Shorturl-Signature"
+
+	// TimestampHeader carries the Unix time the delivery was signed at.
+	TimestampHeader = "X-Shorturl-Timestamp"
+
+	// EventHeader carries the action of the event.
+	EventHeader = "X-Shorturl-Event"
+
+	// DeliveryHeader carries an ID that stays the same across the retries of a delivery,
+	// so the receiver can drop duplicates.
+	DeliveryHeader = "X-Shorturl-Delivery"
+)
+
+// signaturePrefix names the algorithm of a signature.
+const signaturePrefix = "sha256="
+
+// maxResponseBody is the number of response bytes read to let the connection be reused.
+const maxResponseBody = 64 << 10
+
+// dialTimeout bounds the connection of a delivery attempt, below the attempt Timeout.
+const dialTimeout = 30 * time.Second
+
+// deliveredActions are the actions of the events delivered to the webhooks. The admin actions
+// on a URL are not delivered to its owner.
+var deliveredActions = map[string]bool{
+	models.EventCreate:      true,
+	models.EventBatchCreate: true,
+	models.EventDelete:      true,
+	models.EventRestore:     true,
+	models.EventClick:       true,
+}
+
+// Payload is the body of a delivery: an event of a URL, without who made it and in which request.
+type Payload struct {
+	// Time is the time of the event in UTC.
+	Time time.Time `json:"time"`
+
+	// Action is the kind of event: "create", "batch_create", "delete", "restore" or "click".
+	Action string `json:"action"`
+
+	// OwnerID is the ID of the user owning the URL.
+	OwnerID string `json:"owner_id"`
+
+	// Code is the short code of the URL.
+	Code string `json:"code"`
+
+	// URL is the original URL.
+	URL string `json:"url"`
+}
+
+// ErrQueueFull is returned by Emit when the queue has no room left; the events that did not fit are dropped.
+var ErrQueueFull = errors.New("webhook queue is full")
+
+// ErrClosed is returned by Emit once the dispatcher is shutting down.
+var ErrClosed = errors.New("webhook dispatcher is closed")
+
+// Config holds the dispatcher settings.
+type Config struct {
+	// Size is the number of events the queue holds before new ones are dropped.
+	Size int
+
+	// Workers is the number of deliveries made concurrently.
+	Workers int
+
+	// MaxAttempts is the number of times a delivery is attempted before it is dead-lettered.
+	MaxAttempts int
+
+	// RetryBackoff is the wait before the first retry. It doubles with every retry.
+	RetryBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff time.Duration
+
+	// Timeout is the longest time a delivery attempt may take.
+	Timeout time.Duration
+
+	// AllowPrivateAddresses lets the deliveries reach loopback, private and link-local
+	// addresses, which are refused otherwise. It is meant for tests only.
+	AllowPrivateAddresses bool
+}
+
+// Dispatcher delivers the events to the webhooks of the owners of the URLs.
+//
+// It is an audit.Sink: Emit queues the lifecycle events and the clicks without blocking, and
+// a pool of workers posts each of them as a Payload to every webhook of the owner of its URL.
+// The other events, the admin actions, are dropped. An attempt succeeds with a 2xx response.
+// A network error, a timeout, a 408, a 429 or a 5xx response is retried after a backoff that
+// starts at RetryBackoff and doubles up to MaxBackoff; any other response is not. A delivery
+// that does not succeed within MaxAttempts attempts is saved as a dead letter.
+//
+// The deliveries are only made to public addresses; see ErrForbiddenAddress. The check is
+// made on the address dialed, after the name of the webhook is resolved.
+//
+// Every delivery is signed: the SignatureHeader carries Sign of the secret of the webhook,
+// the TimestampHeader and the body, which the receiver recomputes with Verify.
+type Dispatcher struct {
+	storage Storage
+	cfg     Config
+	client  *http.Client
+
+	// mu guards closed and the sends to events against the close of the channel.
+	mu     sync.RWMutex
+	closed bool
+	events chan models.LinkEvent
+
+	// ctx is canceled when the shutdown deadline passes, which fails the pending deliveries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// done is closed once the workers have stopped. It is nil until Start is called.
+	done chan struct{}
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// NewDispatcher creates a dispatcher delivering to the webhooks kept in storage.
+// Start must be called to run it.
+func NewDispatcher(storage Storage, cfg Config) *Dispatcher {
+	cfg.Size = max(cfg.Size, 1)
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.MaxAttempts = max(cfg.MaxAttempts, 1)
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.RetryBackoff)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connections on behalf of the dispatcher, past the address check.
+	transport.Proxy = nil
+	transport.DialContext = newDialer(cfg.AllowPrivateAddresses).DialContext
+
+	return &Dispatcher{
+		storage: storage,
+		cfg:     cfg,
+		client: &http.Client{
+			Transport: transport,
+			// A redirect is a failed delivery: following it would turn the POST into a GET.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		events: make(chan models.LinkEvent, cfg.Size),
+		ctx:    ctx,
+		cancel: cancel,
+		now:    time.Now,
+	}
+}
+
+// Start runs the workers in the background.
+func (d *Dispatcher) Start() {
+	d.done = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range d.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.worker()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(d.done)
+	}()
+}
+
+// Emit queues the lifecycle events and the clicks for delivery without blocking, leaving out
+// the actor and the request ID. The events that do not fit in the queue are dropped and
+// reported with ErrQueueFull.
+func (d *Dispatcher) Emit(_ context.Context, events []models.LinkEvent) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+
+	dropped := 0
+	for _, event := range events {
+		if !deliveredActions[event.Action] {
+			continue
+		}
+
+		// Neither is part of the payload, nor of the dead letters shown to the owner.
+		event.ActorID, event.RequestID = "", ""
+
+		select {
+		case d.events <- event:
+		default:
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		return fmt.Errorf("%w: %d events dropped", ErrQueueFull, dropped)
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting events and waits for the workers to deliver the queued ones.
+// If ctx is done first, the pending deliveries fail at once, are saved as dead letters,
+// and ctx.Err() is returned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+	d.mu.Unlock()
+
+	if d.done == nil {
+		d.cancel()
+		return nil
+	}
+
+	select {
+	case <-d.done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return ctx.Err()
+	}
+}
+
+// worker delivers the queued events to the webhooks of their owners.
+func (d *Dispatcher) worker() {
+	// The storage is still used once the deliveries are canceled, to save the dead letters.
+	ctx := context.WithoutCancel(d.ctx)
+
+	for event := range d.events {
+		hooks, err := d.storage.GetUserWebhooks(ctx, event.OwnerID)
+		if err != nil {
+			slog.Error("failed to get webhooks", slog.String("user_id", event.OwnerID), sl.Err(err))
+			continue
+		}
+
+		for _, hook := range hooks {
+			d.deliver(ctx, hook, event)
+		}
+	}
+}
+
+// deliver posts the event to the webhook, retrying with backoff, and saves a dead letter if it fails.
+func (d *Dispatcher) deliver(ctx context.Context, hook models.Webhook, event models.LinkEvent) {
+	body, err := json.Marshal(Payload{
+		Time:    event.Time,
+		Action:  event.Action,
+		OwnerID: event.OwnerID,
+		Code:    event.Code,
+		URL:     event.URL,
+	})
+	if err != nil {
+		slog.Error("failed to encode webhook event", sl.Err(err))
+		return
+	}
+
+	delivery := uuid.New().String()
+
+	attempts := 0
+	for {
+		attempts++
+
+		var retry bool
+		if retry, err = d.post(hook, event.Action, delivery, body); err == nil {
+			return
+		}
+
+		if !retry || attempts >= d.cfg.MaxAttempts || !d.wait(attempts) {
+			break
+		}
+	}
+
+	slog.Warn("webhook delivery failed",
+		slog.String("webhook_id", hook.ID), slog.Int("attempts", attempts), sl.Err(err))
+
+	letter := models.DeadLetter{
+		Time:      d.now().UTC(),
+		WebhookID: hook.ID,
+		UserID:    hook.UserID,
+		URL:       hook.URL,
+		Event:     event,
+		Attempts:  attempts,
+		Error:     err.Error(),
+	}
+	if err = d.storage.SaveDeadLetter(ctx, letter); err != nil {
+		slog.Error("failed to save dead letter", slog.String("webhook_id", hook.ID), sl.Err(err))
+	}
+}
+
+// post makes one delivery attempt. retry reports whether a failed attempt is worth repeating.
+func (d *Dispatcher) post(hook models.Webhook, action, delivery string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := d.now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, action)
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		// An attempt canceled by the shutdown or refused by the address check is not retried.
+		return d.ctx.Err() == nil && !errors.Is(err, ErrForbiddenAddress), err
+	}
+	defer res.Body.Close()
+
+	if _, err = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody)); err != nil {
+		slog.Debug("failed to read webhook response", sl.Err(err))
+	}
+
+	switch code := res.StatusCode; {
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		return false, nil
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+		return true, fmt.Errorf("unexpected status %s", res.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", res.Status)
+	}
+}
+
+// wait sleeps for the backoff after the given number of attempts.
+// It returns false if the dispatcher is canceled meanwhile.
+func (d *Dispatcher) wait(attempts int) bool {
+	timer := time.NewTimer(d.backoff(attempts))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+// backoff returns the wait after the given number of attempts: RetryBackoff doubled
+// for every attempt but the first, capped at MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBackoff
+	for range attempts - 1 {
+		if delay >= d.cfg.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// Sign returns the signature of a payload sent at the Unix time timestamp: "sha256=" followed
+// by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret.
+// Signing the timestamp lets the receiver reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of the payload, comparing in constant time.
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
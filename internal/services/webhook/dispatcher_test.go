@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+)
+
+// delivery is a request received by a receiver.
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is an endpoint answering the deliveries with the given statuses in turn,
+// then with 204 No Content.
+type receiver struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	statuses   []int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	rc.deliveries = append(rc.deliveries, delivery{header: r.Header.Clone(), body: body})
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	rc.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) Deliveries() []delivery {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return append([]delivery(nil), rc.deliveries...)
+}
+
+// newReceiver starts an endpoint answering with the given statuses in turn.
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	rc := &receiver{statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	return rc, server
+}
+
+var testConfig = Config{
+	Size:                  10,
+	Workers:               2,
+	MaxAttempts:           3,
+	RetryBackoff:          time.Millisecond,
+	MaxBackoff:            5 * time.Millisecond,
+	AllowPrivateAddresses: true,
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	storage := newStorage(t)
+	ctx := context.Background()
+
+	owner := uuid.New().String()
+	rc, server := newReceiver(t)
+	other, otherServer := newReceiver(t)
+
+	hook, err := New(storage, WithPrivateAddresses()).Create(ctx, owner, server.URL+"/hook", "a shared secret of mine")
+	require.NoError(t, err)
+	_, err = New(storage, WithPrivateAddresses()).Create(ctx, uuid.New().String(), otherServer.URL, "")
+	require.NoError(t, err)
+
+	d := NewDispatcher(storage, testConfig)
+	d.Start()
+
+	event := models.LinkEvent{
+		Time:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Action:    models.EventCreate,
+		ActorID:   owner,
+		OwnerID:   owner,
+		Code:      "code1",
+		URL:       "https://example.com",
+		RequestID: "req-1",
+	}
+	moderation := models.LinkEvent{
+		Action:  models.AuditDisableURL,
+		ActorID: uuid.New().String(),
+		OwnerID: owner,
+		Code:    "code1",
+	}
+	require.NoError(t, d.Emit(ctx, []models.LinkEvent{moderation, event}))
+	require.NoError(t, d.Shutdown(ctx))
+
+	deliveries := rc.Deliveries()
+	require.Len(t, deliveries, 1, "the admin actions are not delivered")
+	assert.Empty(t, other.Deliveries(), "only the webhooks of the owner are notified")
+
+	got := deliveries[0]
+	assert.Equal(t, "application/json", got.header.Get("Content-Type"))
+	assert.Equal(t, models.EventCreate, got.header.Get(EventHeader))
+	assert.NoError(t, uuid.Validate(got.header.Get(DeliveryHeader)))
+
+	timestamp, err := strconv.ParseInt(got.header.Get(TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify(hook.Secret, got.header.Get(SignatureHeader), timestamp, got.body), "the signature verifies")
+	assert.False(t, Verify("another secret", got.header.Get(SignatureHeader), timestamp, got.body))
+	assert.False(t, Verify(hook.Secret, got.header.Get(SignatureHeader), timestamp+1, got.body))
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal(got.body, &payload))
+	assert.Equal(t, Payload{
+		Time:    event.Time,
+		Action:  models.EventCreate,
+		OwnerID: owner,
+		Code:    "code1",
+		URL:     "https://example.com",
+	}, payload)
+	assert.NotContains(t, string(got.body), "actor_id")
+	assert.NotContains(t, string(got.body), "req-1")
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	storage := newStorage(t)
+	ctx := context.Background()
+
+	owner := uuid.New().String()
+	rc, server := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	_, err := New(storage, WithPrivateAddresses()).Create(ctx, owner, server.URL, "")
+	require.NoError(t, err)
+
+	d := NewDispatcher(storage, testConfig)
+	d.Start()
+
+	require.NoError(t, d.Emit(ctx, []models.LinkEvent{{Action: models.EventDelete, OwnerID: owner, Code: "code1"}}))
+	require.NoError(t, d.Shutdown(ctx))
+
+	deliveries := rc.Deliveries()
+	require.Len(t, deliveries, 3, "the delivery succeeds on the third attempt")
+	assert.Equal(t, deliveries[0].header.Get(DeliveryHeader), deliveries[2].header.Get(DeliveryHeader),
+		"the retries keep the delivery ID")
+
+	letters, err := storage.GetDeadLetters(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{
+			name:     "retries exhausted",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable},
+			attempts: 3,
+		},
+		{
+			name:     "rejected",
+			statuses: []int{http.StatusBadRequest},
+			attempts: 1,
+		},
+		{
+			name:     "redirected",
+			statuses: []int{http.StatusFound},
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newStorage(t)
+			ctx := context.Background()
+
+			owner := uuid.New().String()
+			rc, server := newReceiver(t, tt.statuses...)
+
+			hook, err := New(storage, WithPrivateAddresses()).Create(ctx, owner, server.URL, "")
+			require.NoError(t, err)
+
+			d := NewDispatcher(storage, testConfig)
+			d.Start()
+
+			event := models.LinkEvent{Action: models.EventClick, ActorID: owner, OwnerID: owner, Code: "code1", RequestID: "req-1"}
+			require.NoError(t, d.Emit(ctx, []models.LinkEvent{event}))
+			require.NoError(t, d.Shutdown(ctx))
+
+			assert.Len(t, rc.Deliveries(), tt.attempts)
+
+			letters, err := storage.GetDeadLetters(ctx, owner, 0)
+			require.NoError(t, err)
+			require.Len(t, letters, 1)
+			assert.Equal(t, hook.ID, letters[0].WebhookID)
+			assert.Equal(t, hook.URL, letters[0].URL)
+			assert.Equal(t, models.LinkEvent{Action: models.EventClick, OwnerID: owner, Code: "code1"}, letters[0].Event,
+				"the dead letter keeps neither the actor nor the request ID")
+			assert.Equal(t, tt.attempts, letters[0].Attempts)
+			assert.Contains(t, letters[0].Error, strconv.Itoa(tt.statuses[tt.attempts-1]))
+		})
+	}
+}
+
+func TestDispatcher_ForbiddenAddress(t *testing.T) {
+	storage := newStorage(t)
+	ctx := context.Background()
+
+	owner := uuid.New().String()
+	rc, server := newReceiver(t)
+
+	// The webhook was let in, but the dispatcher dials the loopback address the name resolves to.
+	hook, err := New(storage, WithPrivateAddresses()).Create(ctx, owner, server.URL, "")
+	require.NoError(t, err)
+
+	cfg := testConfig
+	cfg.AllowPrivateAddresses = false
+
+	d := NewDispatcher(storage, cfg)
+	d.Start()
+
+	require.NoError(t, d.Emit(ctx, []models.LinkEvent{{Action: models.EventCreate, OwnerID: owner, Code: "code1"}}))
+	require.NoError(t, d.Shutdown(ctx))
+
+	assert.Empty(t, rc.Deliveries(), "no connection is made")
+
+	letters, err := storage.GetDeadLetters(ctx, owner, 0)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, hook.ID, letters[0].WebhookID)
+	assert.Equal(t, 1, letters[0].Attempts, "a refused address is not retried")
+	assert.Contains(t, letters[0].Error, ErrForbiddenAddress.Error())
+}
+
+func TestDispatcher_ShutdownDeadline(t *testing.T) {
+	storage := newStorage(t)
+	ctx := context.Background()
+
+	owner := uuid.New().String()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := New(storage, WithPrivateAddresses()).Create(ctx, owner, server.URL, "")
+	require.NoError(t, err)
+
+	d := NewDispatcher(storage, Config{Size: 10, Workers: 1, MaxAttempts: 5, Timeout: time.Minute, AllowPrivateAddresses: true})
+	d.Start()
+
+	require.NoError(t, d.Emit(ctx, []models.LinkEvent{
+		{Action: models.EventCreate, OwnerID: owner, Code: "code1"},
+		{Action: models.EventCreate, OwnerID: owner, Code: "code2"},
+	}))
+
+	deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, d.Shutdown(deadline), context.DeadlineExceeded)
+
+	letters, err := storage.GetDeadLetters(ctx, owner, 0)
+	require.NoError(t, err)
+	assert.Len(t, letters, 2, "the pending deliveries are dead-lettered, not lost")
+	for _, letter := range letters {
+		assert.Equal(t, 1, letter.Attempts, "a canceled delivery is not retried")
+	}
+
+	assert.ErrorIs(t, d.Emit(ctx, []models.LinkEvent{{OwnerID: owner}}), ErrClosed)
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	d := NewDispatcher(newStorage(t), Config{Size: 2})
+
+	// The workers are not running, so nothing drains the queue.
+	event := models.LinkEvent{Action: models.EventClick}
+	err := d.Emit(context.Background(), []models.LinkEvent{event, event, event})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Len(t, d.events, 2)
+
+	require.NoError(t, d.Shutdown(context.Background()))
+}
+
+func TestDispatcher_backoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{RetryBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  5 * time.Second,
+		60: 5 * time.Second,
+	} {
+		assert.Equal(t, want, d.backoff(attempts), attempts)
+	}
+}
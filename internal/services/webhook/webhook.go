@@ -0,0 +1,189 @@
+// Package webhook notifies the endpoints registered by users of the events of their URLs.
+//
+// A user registers a webhook with the URL of an endpoint and a secret shared with it. Every
+// event of the user's URLs, including clicks, is then posted to the endpoint as a JSON object,
+// signed with the secret so the receiver can tell it came from the service. The deliveries
+// are made in the background by a Dispatcher; see its documentation for the retries and the
+// signature scheme.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage"
+)
+
+const (
+	// MaxWebhooks is the number of webhooks a user may register.
+	MaxWebhooks = 10
+
+	// DefaultLimit is the number of dead letters listed when no limit is given.
+	DefaultLimit = 100
+
+	// MaxLimit is the largest number of dead letters listed at once.
+	MaxLimit = 1000
+
+	// secretPrefix starts every generated secret.
+	secretPrefix = "whsec_"
+
+	// secretSize is the number of random bytes of a generated secret.
+	secretSize = 32
+
+	// minSecretLength and maxSecretLength bound the length of a secret chosen by the user.
+	minSecretLength = 16
+	maxSecretLength = 256
+)
+
+// ErrInvalidParams is returned when registering a webhook with an invalid URL or secret,
+// or more than MaxWebhooks of them, and when listing dead letters with an invalid limit.
+var ErrInvalidParams = errors.New("invalid webhook parameters")
+
+// ErrNotFound is returned when deleting a webhook that is not an active webhook of the user.
+var ErrNotFound = errors.New("webhook not found")
+
+// Storage defines the methods for storing the webhooks and their dead letters.
+type Storage interface {
+	SaveWebhook(ctx context.Context, hook models.Webhook) error
+	GetUserWebhooks(ctx context.Context, userID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string, userID string, now time.Time) error
+	SaveDeadLetter(ctx context.Context, letter models.DeadLetter) error
+	GetDeadLetters(ctx context.Context, userID string, limit int) ([]models.DeadLetter, error)
+}
+
+// Service manages the webhooks of the users.
+type Service struct {
+	storage Storage
+
+	// allowPrivate lets webhooks point to non-public addresses.
+	allowPrivate bool
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Option configures the Service.
+type Option func(*Service)
+
+// WithPrivateAddresses lets webhooks point to loopback, private and link-local addresses,
+// which are rejected otherwise. It is meant for tests only.
+func WithPrivateAddresses() Option {
+	return func(s *Service) {
+		s.allowPrivate = true
+	}
+}
+
+// New creates a webhook service backed by storage.
+func New(storage Storage, opts ...Option) *Service {
+	s := &Service{storage: storage, now: time.Now}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Create registers a webhook of userID posting to endpoint. The endpoint must be an absolute
+// http or https URL, and not the local host or a non-public IP address. Without a secret,
+// a random one is generated; it is returned along with the webhook either way.
+func (s *Service) Create(ctx context.Context, userID, endpoint, secret string) (models.Webhook, error) {
+	const op = "webhook.Create"
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.Webhook{}, fmt.Errorf("%s: %w: url must be an absolute http or https URL", op, ErrInvalidParams)
+	}
+	if !s.allowPrivate {
+		if err = checkHost(parsed.Hostname()); err != nil {
+			return models.Webhook{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidParams, err)
+		}
+	}
+
+	if secret == "" {
+		random := make([]byte, secretSize)
+		if _, err = rand.Read(random); err != nil {
+			return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+		}
+		secret = secretPrefix + base64.RawURLEncoding.EncodeToString(random)
+	} else if len(secret) < minSecretLength || len(secret) > maxSecretLength {
+		return models.Webhook{}, fmt.Errorf(
+			"%s: %w: secret must be %d to %d characters long", op, ErrInvalidParams, minSecretLength, maxSecretLength,
+		)
+	}
+
+	hooks, err := s.storage.GetUserWebhooks(ctx, userID)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(hooks) >= MaxWebhooks {
+		return models.Webhook{}, fmt.Errorf("%s: %w: at most %d webhooks are allowed", op, ErrInvalidParams, MaxWebhooks)
+	}
+
+	hook := models.Webhook{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		URL:       endpoint,
+		Secret:    secret,
+		CreatedAt: s.now().UTC(),
+	}
+
+	if err = s.storage.SaveWebhook(ctx, hook); err != nil {
+		return models.Webhook{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return hook, nil
+}
+
+// List returns the webhooks of userID, oldest first.
+func (s *Service) List(ctx context.Context, userID string) ([]models.Webhook, error) {
+	hooks, err := s.storage.GetUserWebhooks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("webhook.List: %w", err)
+	}
+
+	return hooks, nil
+}
+
+// Delete deletes the webhook with the given ID. It returns ErrNotFound unless it is a webhook of userID.
+func (s *Service) Delete(ctx context.Context, userID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	err := s.storage.DeleteWebhook(ctx, id, userID, s.now().UTC())
+	if errors.Is(err, storage.ErrWebhookNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("webhook.Delete: %w", err)
+	}
+
+	return nil
+}
+
+// DeadLetters returns the latest events that could not be delivered to the webhooks of userID,
+// newest first. A zero limit returns DefaultLimit of them; a larger limit than MaxLimit is rejected.
+func (s *Service) DeadLetters(ctx context.Context, userID string, limit int) ([]models.DeadLetter, error) {
+	if limit < 0 || limit > MaxLimit {
+		return nil, fmt.Errorf("%w: limit must be 0 to %d", ErrInvalidParams, MaxLimit)
+	}
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	letters, err := s.storage.GetDeadLetters(ctx, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("webhook.DeadLetters: %w", err)
+	}
+
+	return letters, nil
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vadicheck/shorturl/internal/models"
+	"github.com/vadicheck/shorturl/internal/services/storage/memory"
+)
+
+func newStorage(t *testing.T) *memory.Storage {
+	t.Helper()
+
+	storage, err := memory.New(filepath.Join(t.TempDir(), "storage.json"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, storage.Close())
+	})
+
+	return storage
+}
+
+func TestService_Create(t *testing.T) {
+	s := New(newStorage(t))
+	ctx := context.Background()
+
+	userID := uuid.New().String()
+
+	hook, err := s.Create(ctx, userID, "https://example.com/hook", "")
+	require.NoError(t, err)
+	assert.NoError(t, uuid.Validate(hook.ID))
+	assert.Equal(t, userID, hook.UserID)
+	assert.Equal(t, "https://example.com/hook", hook.URL)
+	assert.True(t, strings.HasPrefix(hook.Secret, secretPrefix), "a secret is generated")
+
+	chosen, err := s.Create(ctx, userID, "http://hooks.example.org:9000/hook", "a shared secret of mine")
+	require.NoError(t, err)
+	assert.Equal(t, "a shared secret of mine", chosen.Secret)
+
+	hooks, err := s.List(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, hooks, 2)
+	assert.Equal(t, hook.ID, hooks[0].ID)
+	assert.Equal(t, chosen.ID, hooks[1].ID)
+
+	for _, endpoint := range []string{"", "example.com/hook", "ftp://example.com/hook", "https://"} {
+		_, err = s.Create(ctx, userID, endpoint, "")
+		assert.ErrorIs(t, err, ErrInvalidParams, endpoint)
+	}
+
+	_, err = s.Create(ctx, userID, "https://example.com/hook", "short")
+	assert.ErrorIs(t, err, ErrInvalidParams)
+}
+
+func TestService_CreatePrivate(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New().String()
+
+	for _, endpoint := range []string{
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://[fd00::1]/hook",
+	} {
+		_, err := New(newStorage(t)).Create(ctx, userID, endpoint, "")
+		assert.ErrorIs(t, err, ErrInvalidParams, endpoint)
+		assert.ErrorIs(t, err, ErrForbiddenAddress, endpoint)
+	}
+
+	_, err := New(newStorage(t)).Create(ctx, userID, "https://93.184.216.34/hook", "")
+	assert.NoError(t, err, "a public address is allowed")
+
+	_, err = New(newStorage(t), WithPrivateAddresses()).Create(ctx, userID, "http://127.0.0.1:8080/hook", "")
+	assert.NoError(t, err)
+}
+
+func TestService_CreateLimit(t *testing.T) {
+	s := New(newStorage(t))
+	ctx := context.Background()
+
+	userID := uuid.New().String()
+
+	for i := range MaxWebhooks {
+		_, err := s.Create(ctx, userID, fmt.Sprintf("https://example.com/%d", i), "")
+		require.NoError(t, err)
+	}
+
+	_, err := s.Create(ctx, userID, "https://example.com/one-too-many", "")
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	_, err = s.Create(ctx, uuid.New().String(), "https://example.com/other", "")
+	assert.NoError(t, err, "the limit is per user")
+}
+
+func TestService_Delete(t *testing.T) {
+	s := New(newStorage(t))
+	ctx := context.Background()
+
+	userID := uuid.New().String()
+
+	hook, err := s.Create(ctx, userID, "https://example.com/hook", "")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.Delete(ctx, uuid.New().String(), hook.ID), ErrNotFound, "a webhook of another user")
+	assert.ErrorIs(t, s.Delete(ctx, userID, "not-an-id"), ErrNotFound)
+
+	require.NoError(t, s.Delete(ctx, userID, hook.ID))
+	assert.ErrorIs(t, s.Delete(ctx, userID, hook.ID), ErrNotFound, "a webhook is deleted only once")
+
+	hooks, err := s.List(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+}
+
+func TestService_DeadLetters(t *testing.T) {
+	storage := newStorage(t)
+	s := New(storage)
+	ctx := context.Background()
+
+	userID := uuid.New().String()
+
+	for i := range 3 {
+		require.NoError(t, storage.SaveDeadLetter(ctx, models.DeadLetter{
+			Time:      time.Now().UTC(),
+			WebhookID: uuid.New().String(),
+			UserID:    userID,
+			Event:     models.LinkEvent{Code: fmt.Sprintf("code%d", i)},
+		}))
+	}
+
+	letters, err := s.DeadLetters(ctx, userID, 2)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "code2", letters[0].Event.Code, "the newest dead letter comes first")
+
+	letters, err = s.DeadLetters(ctx, uuid.New().String(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+
+	_, err = s.DeadLetters(ctx, userID, -1)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	_, err = s.DeadLetters(ctx, userID, MaxLimit+1)
+	assert.ErrorIs(t, err, ErrInvalidParams)
+}
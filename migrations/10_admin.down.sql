@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS admin_audit;
+ALTER TABLE users
+    DROP COLUMN role;
+ALTER TABLE urls
+    DROP COLUMN is_disabled;
\ No newline at end of file
@@ -0,0 +1,13 @@
+ALTER TABLE urls
+    ADD is_disabled boolean NOT NULL DEFAULT false;
+ALTER TABLE users
+    ADD role VARCHAR(16) NOT NULL DEFAULT 'user';
+CREATE TABLE IF NOT EXISTS admin_audit
+(
+    id         BIGSERIAL PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    actor_id   text        NOT NULL,
+    action     VARCHAR(32) NOT NULL,
+    target     text        NOT NULL,
+    details    text
+    );
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid        NOT NULL,
+    url        text        NOT NULL,
+    secret     text        NOT NULL,
+    created_at timestamptz NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks (user_id);
+CREATE TABLE IF NOT EXISTS webhook_dead_letters
+(
+    id         BIGSERIAL PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    webhook_id uuid        NOT NULL,
+    user_id    uuid        NOT NULL,
+    url        text        NOT NULL,
+    event      jsonb       NOT NULL,
+    attempts   integer     NOT NULL,
+    error      text        NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_user_id ON webhook_dead_letters (user_id);
\ No newline at end of file
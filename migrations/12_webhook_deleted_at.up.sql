@@ -0,0 +1,2 @@
+ALTER TABLE webhooks
+    ADD deleted_at timestamptz;
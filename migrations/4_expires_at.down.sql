@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_expires_at;
+ALTER TABLE urls
+    DROP COLUMN expires_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+    ADD expires_at timestamptz;
+CREATE INDEX IF NOT EXISTS idx_expires_at ON urls (expires_at) WHERE expires_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+    DROP COLUMN max_clicks,
+    DROP COLUMN clicks;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+    ADD max_clicks bigint,
+    ADD clicks bigint NOT NULL DEFAULT 0;
\ No newline at end of file
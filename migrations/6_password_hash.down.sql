@@ -0,0 +1,2 @@
+ALTER TABLE urls
+    DROP COLUMN password_hash;
\ No newline at end of file
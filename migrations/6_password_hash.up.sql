@@ -0,0 +1,2 @@
+ALTER TABLE urls
+    ADD password_hash text;
\ No newline at end of file
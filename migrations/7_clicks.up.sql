@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS clicks
+(
+    id         BIGSERIAL PRIMARY KEY,
+    url_id     INTEGER     NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+    code       VARCHAR(255) NOT NULL,
+    clicked_at timestamptz NOT NULL,
+    referrer   text,
+    user_agent text,
+    ip_hash    text
+    );
+CREATE INDEX IF NOT EXISTS idx_clicks_url_id_clicked_at ON clicks (url_id, clicked_at);
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         uuid PRIMARY KEY,
+    user_id    uuid        NOT NULL,
+    name       text,
+    prefix     VARCHAR(16) NOT NULL,
+    hash       VARCHAR(64) NOT NULL UNIQUE,
+    scopes     text        NOT NULL,
+    created_at timestamptz NOT NULL,
+    revoked_at timestamptz
+    );
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
\ No newline at end of file
@@ -0,0 +1,85 @@
+// Package alias provides a function for validating custom short URL aliases.
+package alias
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// MinLength is the shortest allowed alias.
+	MinLength = 3
+
+	// MaxLength is the longest allowed alias.
+	MaxLength = 64
+)
+
+var (
+	// ErrLength is returned for an alias shorter than MinLength or longer than MaxLength.
+	ErrLength = errors.New("alias must be between 3 and 64 characters long")
+
+	// ErrCharacters is returned for an alias with characters other than
+	// latin letters, digits, '-' and '_'.
+	ErrCharacters = errors.New("alias may only contain letters, digits, '-' and '_'")
+
+	// ErrReserved is returned for an alias that clashes with a route or another reserved word.
+	ErrReserved = errors.New("alias is reserved")
+)
+
+// reserved holds the words that cannot be used as aliases, in lower case.
+// It includes the top-level routes of the service, so an alias never shadows them.
+var reserved = map[string]struct{}{
+	"admin":   {},
+	"api":     {},
+	"debug":   {},
+	"health":  {},
+	"login":   {},
+	"logout":  {},
+	"metrics": {},
+	"ping":    {},
+	"static":  {},
+	"user":    {},
+}
+
+// IsValid checks whether a given string can be used as a short URL alias.
+//
+// An alias must be between MinLength and MaxLength characters long, consist of
+// latin letters, digits, '-' and '_', and must not be a reserved word. Reserved
+// words are matched case-insensitively.
+//
+// Parameters:
+//   - alias: the alias to be checked.
+//
+// Returns:
+//   - `true` if the alias is valid;
+//   - `false` and one of ErrLength, ErrCharacters or ErrReserved otherwise.
+//
+// Example usage:
+//
+//	if _, err := IsValid("q3-report"); err != nil {
+//	    fmt.Println("Invalid alias:", err)
+//	}
+func IsValid(alias string) (bool, error) {
+	if len(alias) < MinLength || len(alias) > MaxLength {
+		return false, ErrLength
+	}
+
+	for _, c := range alias {
+		if !isAllowed(c) {
+			return false, ErrCharacters
+		}
+	}
+
+	if _, ok := reserved[strings.ToLower(alias)]; ok {
+		return false, ErrReserved
+	}
+
+	return true, nil
+}
+
+func isAllowed(c rune) bool {
+	return c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' ||
+		c == '-' || c == '_'
+}
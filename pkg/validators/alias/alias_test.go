@@ -0,0 +1,37 @@
+package alias
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		name  string
+		alias string
+		err   error
+	}{
+		{name: "simple", alias: "q3-report"},
+		{name: "mixed case", alias: "My_Link2"},
+		{name: "min length", alias: "abc"},
+		{name: "max length", alias: strings.Repeat("a", MaxLength)},
+		{name: "empty", alias: "", err: ErrLength},
+		{name: "too short", alias: "ab", err: ErrLength},
+		{name: "too long", alias: strings.Repeat("a", MaxLength+1), err: ErrLength},
+		{name: "slash", alias: "a/b/c", err: ErrCharacters},
+		{name: "space", alias: "my link", err: ErrCharacters},
+		{name: "non latin", alias: "ссылка", err: ErrCharacters},
+		{name: "reserved", alias: "ping", err: ErrReserved},
+		{name: "reserved upper case", alias: "API", err: ErrReserved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isValid, err := IsValid(tt.alias)
+			assert.Equal(t, tt.err == nil, isValid)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}